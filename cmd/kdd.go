@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"os"
@@ -8,13 +9,23 @@ import (
 	"path/filepath"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the time zones of the cronjob schedules, the alpine image does not contain them
 
 	"github.com/gin-gonic/gin"
 	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/alerting"
+	"gitlab.com/patrick.erber/kdd/internal/audit"
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/authz"
 	"gitlab.com/patrick.erber/kdd/internal/collector"
+	"gitlab.com/patrick.erber/kdd/internal/config"
 	"gitlab.com/patrick.erber/kdd/internal/controller"
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
 	"gitlab.com/patrick.erber/kdd/internal/persistence"
 	"gitlab.com/patrick.erber/kdd/internal/router"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
 	"go.uber.org/zap"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -73,6 +84,158 @@ func buildMetricsClientSet() *metrics.Clientset {
 	return clientSet
 }
 
+// buildAuthConfig returns the configuration of the authenticators, the OIDC login is disabled without issuer
+func buildAuthConfig(cfg config.AuthConfig) *auth.AuthConfig {
+	authConfig := &auth.AuthConfig{Anonymous: cfg.Anonymous, Tokens: cfg.Tokens}
+	if cfg.OIDC.Issuer != "" {
+		authConfig.OIDC = &auth.OIDCConfig{
+			Issuer:        cfg.OIDC.Issuer,
+			ClientID:      cfg.OIDC.ClientID,
+			ClientSecret:  cfg.OIDC.ClientSecret,
+			RedirectURL:   cfg.OIDC.RedirectURL,
+			Scopes:        cfg.OIDC.Scopes,
+			SessionSecret: cfg.OIDC.SessionSecret,
+			SessionTTL:    cfg.OIDC.SessionTTL,
+		}
+	}
+
+	return authConfig
+}
+
+// buildAuthorizer returns the authorizer of the configured mode, the rbac mode checks the stored namespaces
+func buildAuthorizer(cfg config.AuthorizationConfig, clientSet kubernetes.Interface, ds *persistence.DataStore) authz.Authorizer {
+	switch cfg.Mode {
+	case authz.MODE_NONE, "":
+		return authz.Unrestricted{}
+	case authz.MODE_GROUPS:
+		mappings := make([]authz.Mapping, len(cfg.Mappings))
+		for i, mapping := range cfg.Mappings {
+			mappings[i] = authz.Mapping{Group: mapping.Group, User: mapping.User, Namespaces: mapping.Namespaces}
+		}
+		return authz.NewGroupMappings(&authz.GroupMappingsConfig{Mappings: mappings})
+	case authz.MODE_RBAC:
+		reviews, err := authz.NewAccessReviews(&authz.AccessReviewsConfig{
+			ClientSet: clientSet,
+			Namespaces: func() ([]string, error) {
+				collection, err := ds.GetAllNamespaces()
+				if err != nil {
+					return nil, err
+				}
+				names := make([]string, 0, collection.Len())
+				for _, namespace := range collection.ToList() {
+					names = append(names, namespace.Name)
+				}
+				return names, nil
+			},
+			CacheTTL: cfg.CacheTTL,
+		})
+		if err != nil {
+			zap.L().Fatal("could not set up authorization", zap.Error(err))
+		}
+		return reviews
+	}
+
+	zap.L().Fatal("unknown authorization mode", zap.String("mode", cfg.Mode))
+	return nil
+}
+
+// buildAlerting returns the alerting engine of the configured rules, alerting is disabled without rules
+func buildAlerting(cfg config.AlertingConfig, ka *adapters.KubeAPIAdapter) *alerting.Engine {
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+
+	receivers := make([]alerting.Receiver, len(cfg.Receivers))
+	for i, receiver := range cfg.Receivers {
+		receivers[i] = alerting.Receiver{Name: receiver.Name, SendResolved: receiver.SendResolved == nil || *receiver.SendResolved}
+		switch receiver.Type {
+		case alerting.RECEIVER_WEBHOOK:
+			receivers[i].Notifier = alerting.NewWebhook(&alerting.WebhookConfig{URL: receiver.URL, Headers: receiver.Headers})
+		case alerting.RECEIVER_SLACK:
+			receivers[i].Notifier = alerting.NewSlack(&alerting.SlackConfig{URL: receiver.URL, Channel: receiver.Channel, Username: receiver.Username})
+		case alerting.RECEIVER_SMTP:
+			receivers[i].Notifier = alerting.NewSMTP(&alerting.SMTPConfig{
+				Host:     receiver.SMTP.Host,
+				Port:     receiver.SMTP.Port,
+				Username: receiver.SMTP.Username,
+				Password: receiver.SMTP.Password,
+				From:     receiver.SMTP.From,
+				To:       receiver.SMTP.To,
+			})
+		default:
+			zap.L().Fatal("unknown alert receiver type", zap.String("receiver", receiver.Name), zap.String("type", receiver.Type))
+		}
+	}
+
+	rules := make([]alerting.Rule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		selector, err := models.ParseLabelSelector(rule.Selector)
+		if err != nil {
+			zap.L().Fatal("invalid selector of alerting rule", zap.String("rule", rule.Name), zap.Error(err))
+		}
+		rules[i] = alerting.Rule{
+			Name:       rule.Name,
+			Kind:       rule.Kind,
+			For:        rule.For,
+			Threshold:  rule.Threshold,
+			Window:     rule.Window,
+			MaxAge:     rule.MaxAge,
+			Resource:   rule.Resource,
+			Namespaces: rule.Namespaces,
+			Selector:   selector,
+			Severity:   rule.Severity,
+			GroupBy:    rule.GroupBy,
+			Receivers:  rule.Receivers,
+		}
+	}
+
+	silences := make([]alerting.Silence, len(cfg.Silences))
+	for i, silence := range cfg.Silences {
+		selector, err := models.ParseLabelSelector(silence.Selector)
+		if err != nil {
+			zap.L().Fatal("invalid selector of silence", zap.String("selector", silence.Selector), zap.Error(err))
+		}
+		silences[i] = alerting.Silence{Selector: selector, Comment: silence.Comment}
+		if silence.Until != "" {
+			if silences[i].Until, err = time.Parse(time.RFC3339, silence.Until); err != nil {
+				zap.L().Fatal("invalid end of silence", zap.String("until", silence.Until), zap.Error(err))
+			}
+		}
+	}
+
+	engine, err := alerting.NewEngine(&alerting.EngineConfig{
+		Rules:     rules,
+		Silences:  silences,
+		Receivers: receivers,
+		Cronjobs:  func() (*models.WorkloadCollection, error) { return ka.GetCronjobs("") },
+		Timeout:   cfg.Timeout,
+	})
+	if err != nil {
+		zap.L().Fatal("could not set up alerting", zap.Error(err))
+	}
+
+	return engine
+}
+
+// buildCostModel returns the prices of the cost allocation
+func buildCostModel(cfg config.CostsConfig) models.CostModel {
+	model := models.CostModel{
+		Currency: cfg.Currency,
+		Default:  models.CostPrice{CPUHour: cfg.CPUHour, MemoryGBHour: cfg.MemoryGBHour},
+		Nodes:    make([]models.NodePrice, len(cfg.NodePrices)),
+	}
+
+	for i, price := range cfg.NodePrices {
+		selector, err := models.ParseLabelSelector(price.Selector)
+		if err != nil {
+			zap.L().Fatal("invalid selector of node price", zap.String("selector", price.Selector), zap.Error(err))
+		}
+		model.Nodes[i] = models.NodePrice{Selector: selector, Price: models.CostPrice{CPUHour: price.CPUHour, MemoryGBHour: price.MemoryGBHour}}
+	}
+
+	return model
+}
+
 func main() {
 	logger, _ := zap.NewDevelopment()
 	defer logger.Sync()
@@ -80,23 +243,62 @@ func main() {
 	undo := zap.ReplaceGlobals(logger)
 	defer undo()
 
+	appConfig := config.GetConfig("..", "kdd")
+	if appConfig == nil {
+		zap.L().Fatal("could not load configuration")
+	}
+
+	authn, err := auth.NewAuth(context.Background(), buildAuthConfig(appConfig.Auth))
+	if err != nil {
+		zap.L().Fatal("could not set up authentication", zap.Error(err))
+	}
+
 	// Initialize Database
-	ds, err := persistence.NewSQLiteDataStore("data.sqlite")
+	ds, err := persistence.NewSQLiteDataStore("data.sqlite", appConfig.Metrics.Retention)
 	if err != nil {
 		zap.L().Error("failed to initialize data store", zap.Error(err))
 	}
 	defer ds.CloseConnections()
+	exporter.RegisterDataStore(exporter.DefaultRegistry, ds)
+
+	authorizer := buildAuthorizer(appConfig.Authorization, buildClientSet(), ds)
+
+	var auditLog *audit.Logger
+	if appConfig.Audit.Enabled {
+		auditLog, err = audit.NewLogger(&audit.LoggerConfig{Store: ds, File: appConfig.Audit.File, Retention: appConfig.Audit.Retention})
+		if err != nil {
+			zap.L().Fatal("could not set up the audit log", zap.Error(err))
+		}
+	} else {
+		zap.L().Warn("the audit log is disabled")
+	}
 
 	// Configure Collector & pass it to controller for handling the updates
+	checker := health.NewChecker(&health.CheckerConfig{
+		Kinds:           collector.COLLECTION_KINDS,
+		Staleness:       appConfig.Health.Staleness,
+		LivenessTimeout: appConfig.Health.LivenessTimeout,
+		Ping:            ds.Ping,
+	})
 	cfg := collector.WorkloadCollectorConfig{
 		ClientSet:         buildClientSet(),
 		MertricsClientSet: buildMetricsClientSet(),
+		Checker:           checker,
 	}
 	collector := collector.NewWorkloadCollector(&cfg)
-	ctrl := controller.NewController(collector, ds, time.Second*10)
+	broker := watch.NewBroker(&watch.BrokerConfig{HistorySize: appConfig.Watch.HistorySize, Heartbeat: appConfig.Watch.Heartbeat})
+	ka := adapters.NewKubeAPIAdapter(&adapters.KubeAPIAdapterConfig{ClientSet: buildClientSet()})
+	alerts := buildAlerting(appConfig.Alerting, ka)
+	ctrl := controller.NewController(collector, ds, broker, checker, alerts, func() (*models.WorkloadCollection, error) { return ka.GetCronjobs("") }, time.Second*10, appConfig.History, appConfig.Metrics)
 
 	sigReceiver := sigHandler()
 	go ctrl.Run(sigReceiver)
+	if auditLog != nil {
+		go auditLog.Run(sigReceiver)
+	}
+	if alerts != nil {
+		go alerts.Run(sigReceiver)
+	}
 
 	// Configure HTTP Server
 	gin.SetMode(gin.DebugMode)
@@ -105,7 +307,8 @@ func main() {
 		ReadTimeout:    30 * time.Second,
 		WriteTimeout:   30 * time.Second,
 		MaxHeaderBytes: 1 << 20,
-		Handler:        router.InitRouter(ds, adapters.NewKubeAPIAdapter(&adapters.KubeAPIAdapterConfig{ClientSet: buildClientSet()})),
+		Handler:        router.InitRouter(ds, ka, broker, checker, buildCostModel(appConfig.Costs), authn, authorizer, auditLog),
+		ConnContext:    router.ConnContext,
 	}
 
 	go func() {
@@ -118,5 +321,11 @@ func main() {
 	}()
 
 	<-sigReceiver // wait for the termination signal
+	if auditLog != nil {
+		auditLog.Wait() // store the entries of the last requests
+	}
+	if alerts != nil {
+		alerts.Wait() // deliver the queued notifications
+	}
 
 }
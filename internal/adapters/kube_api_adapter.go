@@ -2,13 +2,16 @@ package adapters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
 	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -18,19 +21,30 @@ type KubeAPIAdapterConfig struct {
 }
 
 type KubeAPIAdapter struct {
-	cfg *KubeAPIAdapterConfig
+	cfg   *KubeAPIAdapterConfig
+	scope *models.NamespaceScope // restricts the results of a view created by WithScope, nil for all namespaces
 }
 
 func NewKubeAPIAdapter(cfg *KubeAPIAdapterConfig) *KubeAPIAdapter {
 	return &KubeAPIAdapter{cfg: cfg}
 }
 
-func (a *KubeAPIAdapter) GetEventsForNamespace(namespace string) (*models.Collection, error) {
-	collection := models.NewCollection()
+// WithScope returns a view of the adapter which only returns the objects of the namespaces in the scope.
+// The requests are still sent with the service account of kdd, the results are filtered afterwards.
+func (a *KubeAPIAdapter) WithScope(scope *models.NamespaceScope) *KubeAPIAdapter {
+	return &KubeAPIAdapter{cfg: a.cfg, scope: scope}
+}
+
+func (a *KubeAPIAdapter) GetEventsForNamespace(namespace string) (*models.EventCollection, error) {
+	if err := a.scope.Check(namespace); err != nil {
+		return nil, err
+	}
+
+	collection := models.NewCollection[string, models.Event]()
 	eventsClient := a.cfg.ClientSet.CoreV1().Events(namespace)
 	result, err := eventsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
-		return nil, err
+		return nil, kubeError(err)
 	}
 	for _, event := range result.Items {
 		e := models.Event{
@@ -51,19 +65,24 @@ func (a *KubeAPIAdapter) GetEventsForNamespace(namespace string) (*models.Collec
 	return collection, nil
 }
 
-func (a *KubeAPIAdapter) GetCronjobs(namespace string) (*models.Collection, error) {
-	collection := models.NewCollection()
+func (a *KubeAPIAdapter) GetCronjobs(namespace string) (*models.WorkloadCollection, error) {
+	collection := models.NewCollection[string, models.Workload]()
 
 	if namespace == "" {
 		namespace = v1.NamespaceAll
+	} else if err := a.scope.Check(namespace); err != nil {
+		return nil, err
 	}
 
 	jobsClient := a.cfg.ClientSet.BatchV1().CronJobs(namespace)
 	result, err := jobsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
-		return nil, err
+		return nil, kubeError(err)
 	}
 	for _, job := range result.Items {
+		if !a.scope.Allows(job.GetNamespace()) {
+			continue
+		}
 		w := a.createWorkloadObjectFromCronjob(&job)
 		collection.Set(fmt.Sprintf("cronjob_%s_%s", job.GetName(), job.GetNamespace()), w, true)
 	}
@@ -103,13 +122,14 @@ func (a *KubeAPIAdapter) createWorkloadObjectFromCronjob(job *batch_v1.CronJob)
 
 	w := models.CronjobWorkload{
 		GeneralWorkloadInfo: models.GeneralWorkloadInfo{
-			WorkloadName:      job.GetObjectMeta().GetName(),
-			Namespace:         job.GetNamespace(),
-			Labels:            job.Labels,
-			Annotations:       job.Annotations,
-			Selector:          selector,
-			Containers:        containers,
-			CreationTimestamp: job.CreationTimestamp.Time,
+			WorkloadName:        job.GetObjectMeta().GetName(),
+			Namespace:           job.GetNamespace(),
+			Labels:              job.Labels,
+			Annotations:         job.Annotations,
+			Selector:            selector,
+			SelectorExpressions: models.NewLabelSelector(job.Spec.JobTemplate.Spec.Selector).MatchExpressions,
+			Containers:          containers,
+			CreationTimestamp:   job.CreationTimestamp.Time,
 		},
 		ConcurrencyPolicy:     string(job.Spec.ConcurrencyPolicy),
 		BackoffLimit:          job.Spec.JobTemplate.Spec.BackoffLimit,
@@ -117,6 +137,7 @@ func (a *KubeAPIAdapter) createWorkloadObjectFromCronjob(job *batch_v1.CronJob)
 		SuccessfulJobsHistory: job.Spec.SuccessfulJobsHistoryLimit,
 		Suspend:               job.Spec.Suspend,
 		Schedule:              job.Spec.Schedule,
+		TimeZone:              job.Spec.TimeZone,
 		Status: models.CronjobStatus{
 			Active:             activeJobs,
 			LastScheduledTime:  lastScheduledTime,
@@ -127,19 +148,24 @@ func (a *KubeAPIAdapter) createWorkloadObjectFromCronjob(job *batch_v1.CronJob)
 	return w
 }
 
-func (a *KubeAPIAdapter) GetJobs(namespace string) (*models.Collection, error) {
-	collection := models.NewCollection()
+func (a *KubeAPIAdapter) GetJobs(namespace string) (*models.WorkloadCollection, error) {
+	collection := models.NewCollection[string, models.Workload]()
 
 	if namespace == "" {
 		namespace = v1.NamespaceAll
+	} else if err := a.scope.Check(namespace); err != nil {
+		return nil, err
 	}
 
 	jobsClient := a.cfg.ClientSet.BatchV1().Jobs(namespace)
 	result, err := jobsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
-		return nil, err
+		return nil, kubeError(err)
 	}
 	for _, job := range result.Items {
+		if !a.scope.Allows(job.GetNamespace()) {
+			continue
+		}
 		w := a.createWorkloadObjectFromJob(&job)
 		collection.Set(fmt.Sprintf("job_%s_%s", job.GetName(), job.GetNamespace()), w, true)
 	}
@@ -163,15 +189,23 @@ func (a *KubeAPIAdapter) createWorkloadObjectFromJob(job *batch_v1.Job) models.W
 		completionTime = &job.Status.CompletionTime.Time
 	}
 
+	cronjobName := ""
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind == "CronJob" {
+			cronjobName = owner.Name
+		}
+	}
+
 	w := models.JobWorkload{
 		GeneralWorkloadInfo: models.GeneralWorkloadInfo{
-			WorkloadName:      job.GetObjectMeta().GetName(),
-			Namespace:         job.GetNamespace(),
-			Labels:            job.Labels,
-			Annotations:       job.Annotations,
-			Selector:          job.Spec.Selector.MatchLabels,
-			Containers:        containers,
-			CreationTimestamp: job.CreationTimestamp.Time,
+			WorkloadName:        job.GetObjectMeta().GetName(),
+			Namespace:           job.GetNamespace(),
+			Labels:              job.Labels,
+			Annotations:         job.Annotations,
+			Selector:            job.Spec.Selector.MatchLabels,
+			SelectorExpressions: models.NewLabelSelector(job.Spec.Selector).MatchExpressions,
+			Containers:          containers,
+			CreationTimestamp:   job.CreationTimestamp.Time,
 		},
 		Status: models.JobStatus{
 			Active:         job.Status.Active,
@@ -181,11 +215,58 @@ func (a *KubeAPIAdapter) createWorkloadObjectFromJob(job *batch_v1.Job) models.W
 			StartTime:      startTime,
 			CompletionTime: completionTime,
 		},
+		CronjobName: cronjobName,
 	}
 
 	return w
 }
 
+// AnalyzeCronjobs adds the analysis of their schedule to the cronjobs, the durations of their jobs are loaded from the namespace
+func (a *KubeAPIAdapter) AnalyzeCronjobs(cronjobs *models.WorkloadCollection, namespace string, now time.Time) *models.WorkloadCollection {
+	durations := a.jobDurations(namespace)
+
+	return models.MapCollection(cronjobs, func(w models.Workload) models.Workload {
+		cronjob, ok := w.(models.CronjobWorkload)
+		if !ok {
+			return w
+		}
+
+		cronjob.Analysis = models.AnalyzeCronjob(cronjob, durations[cronjob.Namespace+"/"+cronjob.WorkloadName], now)
+		return cronjob
+	})
+}
+
+// AnalyzeCronjob adds the analysis of its schedule to a single cronjob
+func (a *KubeAPIAdapter) AnalyzeCronjob(cronjob models.CronjobWorkload, now time.Time) models.CronjobWorkload {
+	cronjob.Analysis = models.AnalyzeCronjob(cronjob, a.jobDurations(cronjob.Namespace)[cronjob.Namespace+"/"+cronjob.WorkloadName], now)
+
+	return cronjob
+}
+
+// jobDurations returns the durations of the completed jobs by the namespace and name of the cronjob which created them.
+// The analysis does not depend on the durations, so an error is only logged.
+func (a *KubeAPIAdapter) jobDurations(namespace string) map[string][]time.Duration {
+	durations := make(map[string][]time.Duration)
+
+	jobs, err := a.GetJobs(namespace)
+	if err != nil {
+		zap.L().Warn("could not load the jobs of the cronjobs", zap.String("namespace", namespace), zap.Error(err))
+		return durations
+	}
+
+	for _, w := range jobs.ToList() {
+		job, ok := w.(models.JobWorkload)
+		if !ok || job.CronjobName == "" || job.Status.StartTime == nil || job.Status.CompletionTime == nil {
+			continue
+		}
+
+		key := job.Namespace + "/" + job.CronjobName
+		durations[key] = append(durations[key], job.Status.CompletionTime.Sub(*job.Status.StartTime))
+	}
+
+	return durations
+}
+
 func (a *KubeAPIAdapter) GetWorkloadBy(filters map[string]string) (models.Workload, error) {
 	namespace := ""
 	workloadType := ""
@@ -194,19 +275,23 @@ func (a *KubeAPIAdapter) GetWorkloadBy(filters map[string]string) (models.Worklo
 	if v, ok := filters["namespace"]; ok {
 		namespace = v
 	} else {
-		return nil, fmt.Errorf("namespace parameter is required")
+		return nil, fmt.Errorf("%w: namespace parameter is required", models.ErrInvalidFilter)
 	}
 
 	if v, ok := filters["workload_name"]; ok {
 		workloadName = v
 	} else {
-		return nil, fmt.Errorf("workload_name parameter is required")
+		return nil, fmt.Errorf("%w: workload_name parameter is required", models.ErrInvalidFilter)
 	}
 
 	if v, ok := filters["workload_type"]; ok {
 		workloadType = v
 	} else {
-		return nil, fmt.Errorf("workload_type parameter is required")
+		return nil, fmt.Errorf("%w: workload_type parameter is required", models.ErrInvalidFilter)
+	}
+
+	if err := a.scope.Check(namespace); err != nil {
+		return nil, err
 	}
 
 	switch workloadType {
@@ -214,7 +299,7 @@ func (a *KubeAPIAdapter) GetWorkloadBy(filters map[string]string) (models.Worklo
 		jobsClient := a.cfg.ClientSet.BatchV1().Jobs(namespace)
 		result, err := jobsClient.Get(context.TODO(), workloadName, v1.GetOptions{})
 		if err != nil {
-			return nil, err
+			return nil, kubeError(err)
 		}
 
 		return a.createWorkloadObjectFromJob(result), nil
@@ -222,13 +307,13 @@ func (a *KubeAPIAdapter) GetWorkloadBy(filters map[string]string) (models.Worklo
 		jobsClient := a.cfg.ClientSet.BatchV1().CronJobs(namespace)
 		result, err := jobsClient.Get(context.TODO(), workloadName, v1.GetOptions{})
 		if err != nil {
-			return nil, err
+			return nil, kubeError(err)
 		}
 
 		return a.createWorkloadObjectFromCronjob(result), nil
 	}
 
-	return nil, fmt.Errorf("unsupported type found")
+	return nil, fmt.Errorf("%w: unsupported type %s", models.ErrInvalidFilter, workloadType)
 }
 
 func (a *KubeAPIAdapter) buildContainerList(listOfContainers []core_v1.Container, listOfInitContainers []core_v1.Container, containerStatuses []core_v1.ContainerStatus) []models.Container {
@@ -240,22 +325,30 @@ func (a *KubeAPIAdapter) buildContainerList(listOfContainers []core_v1.Container
 		}
 
 		restarts := 0
+		waitingReason := ""
+		var lastTermination *models.ContainerTermination
 		for _, containerStatus := range containerStatuses {
 			if container.Name == containerStatus.Name {
 				restarts = int(containerStatus.RestartCount)
+				lastTermination = models.NewContainerTermination(containerStatus.LastTerminationState.Terminated)
+				if containerStatus.State.Waiting != nil {
+					waitingReason = containerStatus.State.Waiting.Reason
+				}
 			}
 		}
 
 		containers[i] = models.Container{
-			Image:         imageParts[0],
-			ImageVersion:  imageParts[1],
-			ContainerName: container.Name,
-			LimitCPU:      container.Resources.Limits.Cpu().MilliValue(),
-			LimitMemory:   container.Resources.Limits.Memory().Value(),
-			RequestCPU:    container.Resources.Requests.Cpu().MilliValue(),
-			RequestMemory: container.Resources.Requests.Memory().Value(),
-			Restarts:      restarts,
-			InitContainer: false,
+			Image:           imageParts[0],
+			ImageVersion:    imageParts[1],
+			ContainerName:   container.Name,
+			LimitCPU:        container.Resources.Limits.Cpu().MilliValue(),
+			LimitMemory:     container.Resources.Limits.Memory().Value(),
+			RequestCPU:      container.Resources.Requests.Cpu().MilliValue(),
+			RequestMemory:   container.Resources.Requests.Memory().Value(),
+			Restarts:        restarts,
+			InitContainer:   false,
+			WaitingReason:   waitingReason,
+			LastTermination: lastTermination,
 		}
 	}
 
@@ -277,3 +370,26 @@ func (a *KubeAPIAdapter) buildContainerList(listOfContainers []core_v1.Container
 	}
 	return containers
 }
+
+// kubeError wraps the errors of the kubernetes api with the matching model error
+func kubeError(err error) error {
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("%w: %s", models.ErrNotFound, err.Error())
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return fmt.Errorf("%w: %s", models.ErrForbidden, err.Error())
+	case apierrors.IsBadRequest(err), apierrors.IsInvalid(err):
+		return fmt.Errorf("%w: %s", models.ErrInvalidFilter, err.Error())
+	case apierrors.IsServiceUnavailable(err), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err),
+		apierrors.IsTooManyRequests(err), apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		return fmt.Errorf("%w: %s", models.ErrUnavailable, err.Error())
+	}
+
+	// errors without api status are raised before a response was received, e.g. connection refused
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return fmt.Errorf("%w: %s", models.ErrUnavailable, err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,302 @@
+package alerting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+var alerting_start = time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+
+// recorder - a notifier keeping the notifications
+type recorder struct {
+	notifications []models.AlertNotification
+}
+
+func (r *recorder) Notify(ctx context.Context, notification models.AlertNotification) error {
+	r.notifications = append(r.notifications, notification)
+	return nil
+}
+
+func (r *recorder) take() []models.AlertNotification {
+	result := r.notifications
+	r.notifications = nil
+	return result
+}
+
+func pod(name string, restarts int, waiting string, phase string) models.PodWorkload {
+	return models.PodWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{
+			WorkloadName: name,
+			Namespace:    "default",
+			Labels:       map[string]string{"app": "web"},
+			Containers:   []models.Container{{ContainerName: "web", Restarts: restarts, WaitingReason: waiting, LimitMemory: 1000}},
+		},
+		Status:   phase,
+		Restarts: restarts,
+	}
+}
+
+func deployment(name string, ready int) models.DeploymentWorkload {
+	return models.DeploymentWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: name, Namespace: "default"},
+		Status:              models.DeploymentStatus{Desired: 2, Ready: ready},
+	}
+}
+
+func collections(workloads ...models.Workload) *models.WorkloadCollection {
+	collection := models.NewCollection[string, models.Workload]()
+	for _, w := range workloads {
+		collection.Set(w.GetType()+"_"+w.GetWorkloadName(), w, true)
+	}
+
+	return collection
+}
+
+func memory(podName string, usage int64) *models.MetricCollection {
+	collection := models.NewCollection[string, models.PodContainerMetric]()
+	collection.Set(podName, models.PodContainerMetric{PodName: podName, Namespace: "default", ContainerName: "web", MemoryUsage: usage}, true)
+
+	return collection
+}
+
+// deliver evaluates and delivers the queued notifications
+func deliver(e *Engine, workloads *models.WorkloadCollection, metrics *models.MetricCollection, at time.Time) {
+	e.Evaluate(workloads, metrics, at)
+	for len(e.queue) > 0 {
+		e.deliver(<-e.queue)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	all, critical := &recorder{}, &recorder{}
+	engine, err := NewEngine(&EngineConfig{
+		Rules: []Rule{
+			{Name: "unavailable", Kind: KIND_UNAVAILABLE, For: time.Minute * 5, GroupBy: []string{"namespace"}},
+			{Name: "crashloop", Kind: KIND_CRASHLOOP, Severity: "critical", Receivers: []string{"critical"}},
+			{Name: "restarts", Kind: KIND_RESTARTS, Threshold: 2, Window: time.Minute * 10},
+			{Name: "pending", Kind: KIND_PENDING},
+			{Name: "memory", Kind: KIND_USAGE, Resource: RESOURCE_MEMORY},
+		},
+		Silences: []Silence{{Selector: mustSelector(t, "rule=pending"), Until: alerting_start.Add(time.Minute * 3)}},
+		Receivers: []Receiver{
+			{Name: "all", SendResolved: true, Notifier: all},
+			{Name: "critical", Notifier: critical},
+		},
+	})
+	require.NoError(t, err)
+
+	at := func(minutes int) time.Time { return alerting_start.Add(time.Minute * time.Duration(minutes)) }
+
+	// the unavailable deployments are pending, the silenced pod is not notified
+	deliver(engine, collections(deployment("a", 1), deployment("b", 0), pod("web-1", 0, "", "Pending")), memory("web-1", 500), at(0))
+	assert.Empty(t, all.take())
+
+	// the silence expired, the still pending pod is notified now
+	deliver(engine, collections(deployment("a", 1), deployment("b", 0), pod("web-1", 0, "", "Pending")), memory("web-1", 500), at(4))
+	notifications := all.take()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "pending", notifications[0].Rule)
+	assert.Equal(t, "pod default/web-1 is pending", notifications[0].Alerts[0].Summary)
+
+	// both deployments fire after the for-duration in a single group, the pod is running with high memory usage
+	deliver(engine, collections(deployment("a", 1), deployment("b", 0), pod("web-1", 1, "", "Running")), memory("web-1", 950), at(5))
+	notifications = all.take()
+	require.Len(t, notifications, 3)
+	byRule := make(map[string]models.AlertNotification)
+	for _, n := range notifications {
+		byRule[n.Rule] = n
+	}
+	assert.Equal(t, models.ALERT_STATUS_RESOLVED, byRule["pending"].Status)
+	assert.Equal(t, map[string]string{"namespace": "default"}, byRule["unavailable"].GroupLabels)
+	require.Len(t, byRule["unavailable"].Alerts, 2)
+	assert.Equal(t, at(0), byRule["unavailable"].Alerts[0].ActiveAt)
+	assert.Equal(t, at(5), byRule["unavailable"].Alerts[0].StartsAt)
+	assert.Equal(t, 95.0, byRule["memory"].Alerts[0].Value)
+
+	// a firing group is notified again when one of its alerts is resolved, restarts and crashloops fire at once
+	deliver(engine, collections(deployment("a", 2), deployment("b", 0), pod("web-1", 3, waiting_reason_crashloop, "Running")), memory("web-1", 950), at(6))
+	notifications = all.take()
+	require.Len(t, notifications, 2)
+	byRule = make(map[string]models.AlertNotification)
+	for _, n := range notifications {
+		byRule[n.Rule] = n
+	}
+	assert.Equal(t, models.ALERT_STATUS_FIRING, byRule["unavailable"].Status)
+	assert.Equal(t, 1, byRule["unavailable"].Firing())
+	assert.Len(t, byRule["unavailable"].Alerts, 2)
+	assert.Equal(t, 3.0, byRule["restarts"].Alerts[0].Value)
+	assert.NotContains(t, byRule, "crashloop", "the rule only notifies its receivers")
+
+	// the rules without receivers notify all receivers
+	crashloops := make([]models.AlertNotification, 0)
+	for _, n := range critical.take() {
+		if n.Rule == "crashloop" {
+			crashloops = append(crashloops, n)
+		}
+	}
+	require.Len(t, crashloops, 1)
+	assert.Equal(t, map[string]string{"namespace": "default", "pod": "web-1", "container": "web"}, crashloops[0].GroupLabels)
+	assert.Equal(t, "critical", crashloops[0].Alerts[0].Severity)
+
+	// unchanged alerts are not notified again
+	deliver(engine, collections(deployment("a", 2), deployment("b", 0), pod("web-1", 3, waiting_reason_crashloop, "Running")), memory("web-1", 950), at(7))
+	assert.Empty(t, all.take())
+
+	// the receiver without resolved notifications does not get the recovery of the crashloop
+	deliver(engine, collections(deployment("a", 2), deployment("b", 0), pod("web-1", 3, "", "Running")), memory("web-1", 950), at(8))
+	assert.Empty(t, critical.take())
+
+	// the restarts leave the window
+	deliver(engine, collections(deployment("a", 2), deployment("b", 0), pod("web-1", 3, "", "Running")), memory("web-1", 950), at(17))
+	notifications = all.take()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "restarts", notifications[0].Rule)
+	assert.Equal(t, models.ALERT_STATUS_RESOLVED, notifications[0].Status)
+	require.NotNil(t, notifications[0].Alerts[0].EndsAt)
+	assert.Equal(t, at(17), *notifications[0].Alerts[0].EndsAt)
+}
+
+func TestCronjobRule(t *testing.T) {
+	recorded := &recorder{}
+	lastSuccess := alerting_start.Add(-time.Hour * 30)
+	suspended := true
+	cronjobs := collections(
+		models.CronjobWorkload{GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "backup", Namespace: "default"}, Status: models.CronjobStatus{LastSuccessfulTime: &lastSuccess}},
+		models.CronjobWorkload{GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "report", Namespace: "default"}, Suspend: &suspended},
+	)
+
+	engine, err := NewEngine(&EngineConfig{
+		Rules:     []Rule{{Name: "backup", Kind: KIND_CRONJOB, MaxAge: time.Hour * 25}},
+		Receivers: []Receiver{{Name: "all", Notifier: recorded}},
+		Cronjobs:  func() (*models.WorkloadCollection, error) { return cronjobs, nil },
+	})
+	require.NoError(t, err)
+
+	deliver(engine, collections(), models.NewCollection[string, models.PodContainerMetric](), alerting_start)
+	require.Len(t, recorded.notifications, 1)
+	assert.Equal(t, map[string]string{"namespace": "default", "workload": "backup", "type": models.WORKLOAD_TYPE_CRONJOB}, recorded.notifications[0].Alerts[0].Labels)
+
+	_, err = NewEngine(&EngineConfig{Rules: []Rule{{Name: "backup", Kind: KIND_CRONJOB}}})
+	assert.Error(t, err, "the max age is required")
+	_, err = NewEngine(&EngineConfig{Rules: []Rule{{Name: "backup", Kind: KIND_PENDING, Receivers: []string{"missing"}}}})
+	assert.Error(t, err)
+}
+
+func TestReceivers(t *testing.T) {
+	notification := models.AlertNotification{
+		Status:      models.ALERT_STATUS_FIRING,
+		Rule:        "crashloop",
+		GroupKey:    "crashloop,namespace=default",
+		GroupLabels: map[string]string{"namespace": "default"},
+		Alerts: []models.Alert{{
+			Rule:     "crashloop",
+			Status:   models.ALERT_STATUS_FIRING,
+			Labels:   map[string]string{"namespace": "default", "pod": "web-1"},
+			Summary:  "container web of pod default/web-1 is in CrashLoopBackOff after 3 restarts",
+			ActiveAt: alerting_start,
+			StartsAt: alerting_start,
+		}},
+	}
+
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies <- body
+		if r.Header.Get("Authorization") == "" && r.URL.Path == "/webhook" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(&WebhookConfig{URL: server.URL + "/webhook", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	require.NoError(t, webhook.Notify(context.Background(), notification))
+	var received models.AlertNotification
+	require.NoError(t, json.Unmarshal(<-bodies, &received))
+	assert.Equal(t, notification.GroupKey, received.GroupKey)
+	assert.Len(t, received.Alerts, 1)
+
+	assert.Error(t, NewWebhook(&WebhookConfig{URL: server.URL + "/webhook"}).Notify(context.Background(), notification), "failed deliveries are reported")
+	<-bodies
+
+	slack := NewSlack(&SlackConfig{URL: server.URL + "/slack", Channel: "#alerts"})
+	require.NoError(t, slack.Notify(context.Background(), notification))
+	var message slackMessage
+	require.NoError(t, json.Unmarshal(<-bodies, &message))
+	assert.Equal(t, "#alerts", message.Channel)
+	assert.Equal(t, "*[FIRING:1] crashloop namespace=default*\n• [firing] container web of pod default/web-1 is in CrashLoopBackOff after 3 restarts", message.Text)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	mails := make(chan string, 1)
+	go serveSMTP(listener, mails)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	mail := NewSMTP(&SMTPConfig{Host: "127.0.0.1", Port: port, From: "kdd@example.com", To: []string{"ops@example.com"}})
+	require.NoError(t, mail.Notify(context.Background(), notification))
+	content := <-mails
+	assert.Contains(t, content, "Subject: [FIRING:1] crashloop namespace=default\r\n")
+	assert.Contains(t, content, "[firing] container web of pod default/web-1 is in CrashLoopBackOff after 3 restarts")
+}
+
+// serveSMTP is a stand-in mail server accepting a single mail
+func serveSMTP(listener net.Listener, mails chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mails <- data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func mustSelector(t *testing.T, selector string) models.LabelSelector {
+	s, err := models.ParseLabelSelector(selector)
+	require.NoError(t, err)
+	return s
+}
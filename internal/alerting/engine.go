@@ -0,0 +1,435 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The alerting package evaluates the alerting rules after each collection of the controller.
+	An alert is pending while its condition is true for less than the for-duration of its rule, afterwards it fires.
+	The alerts of a rule are grouped by labels, a group is notified when one of its alerts fires or is resolved.
+	Silenced alerts are not notified, an alert still firing when its silence expires is notified then.
+	The notifications are queued and delivered by Run, the controller never waits for a receiver.
+**/
+
+const (
+	DEFAULT_QUEUE_SIZE = 256
+	DEFAULT_TIMEOUT    = time.Second * 10
+)
+
+// Silence - suppresses the notifications of the alerts matching the selector until it expires.
+// The selector matches the labels of the alerts and the rule & severity labels, e.g. "rule=crashloop,namespace in (dev)".
+type Silence struct {
+	Selector models.LabelSelector
+	Until    time.Time // the silence never expires when zero
+	Comment  string
+}
+
+// Receiver - delivers the notifications of the rules
+type Receiver struct {
+	Name         string
+	SendResolved bool // resolved alerts are sent as well
+	Notifier     Notifier
+}
+
+// Notifier - sends a notification, e.g. to a webhook or by mail
+type Notifier interface {
+	Notify(ctx context.Context, notification models.AlertNotification) error
+}
+
+type EngineConfig struct {
+	Rules     []Rule
+	Silences  []Silence
+	Receivers []Receiver
+	// loads the cronjobs for the cronjob rules, they are not collected by the controller
+	Cronjobs  func() (*models.WorkloadCollection, error)
+	QueueSize int           // notifications waiting to be delivered, further notifications are dropped, defaults to DEFAULT_QUEUE_SIZE
+	Timeout   time.Duration // of a single delivery, defaults to DEFAULT_TIMEOUT
+}
+
+// Engine - evaluates the rules and notifies the receivers, Run needs to be started to deliver the notifications
+type Engine struct {
+	cfg       *EngineConfig
+	receivers map[string]Receiver
+	alerts    map[string]*alertState // by fingerprint
+	restarts  *restartHistory
+	queue     chan delivery
+	done      chan struct{}
+}
+
+type alertState struct {
+	rule     *Rule
+	alert    models.Alert
+	group    string
+	notified bool // the firing alert was sent, so its resolution is sent as well
+}
+
+type delivery struct {
+	receiver     Receiver
+	notification models.AlertNotification
+}
+
+// input - the state of the cluster an evaluation is based on
+type input struct {
+	time      time.Time
+	workloads []models.Workload
+	metrics   []models.PodContainerMetric
+	cronjobs  []models.Workload
+}
+
+func (in *input) pods() []models.PodWorkload {
+	pods := make([]models.PodWorkload, 0)
+	for _, w := range in.workloads {
+		if pod, ok := w.(models.PodWorkload); ok {
+			pods = append(pods, pod)
+		}
+	}
+
+	return pods
+}
+
+// NewEngine validates the rules and creates the engine
+func NewEngine(cfg *EngineConfig) (*Engine, error) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DEFAULT_TIMEOUT
+	}
+
+	e := &Engine{
+		cfg:       cfg,
+		receivers: make(map[string]Receiver),
+		alerts:    make(map[string]*alertState),
+		restarts:  newRestartHistory(),
+		queue:     make(chan delivery, cfg.QueueSize),
+		done:      make(chan struct{}),
+	}
+
+	for _, receiver := range cfg.Receivers {
+		if receiver.Name == "" || receiver.Notifier == nil {
+			return nil, errors.New("receiver without name or notifier")
+		}
+		if _, ok := e.receivers[receiver.Name]; ok {
+			return nil, fmt.Errorf("duplicate receiver %s", receiver.Name)
+		}
+		e.receivers[receiver.Name] = receiver
+	}
+
+	names := make(map[string]bool)
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule %s", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Kind == KIND_RESTARTS && rule.Window > e.restarts.keep {
+			e.restarts.keep = rule.Window
+		}
+
+		for _, name := range rule.Receivers {
+			if _, ok := e.receivers[name]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown receiver %s", rule.Name, name)
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// Evaluate evaluates the rules against the collected workloads and metrics and queues the notifications of the changed groups
+func (e *Engine) Evaluate(workloads *models.WorkloadCollection, metrics *models.MetricCollection, now time.Time) {
+	in := &input{time: now, workloads: workloads.ToList(), metrics: metrics.ToList()}
+	e.restarts.update(in)
+
+	cronjobs := true
+	if e.hasKind(KIND_CRONJOB) && e.cfg.Cronjobs != nil {
+		collection, err := e.cfg.Cronjobs()
+		if err != nil {
+			// the cronjob alerts are kept as they are until the cronjobs can be loaded again
+			zap.L().Error("could not load cronjobs for alerting", zap.Error(err))
+			cronjobs = false
+		} else {
+			in.cronjobs = collection.ToList()
+		}
+	}
+
+	changed := make(map[string]bool) // groups with a firing or resolved alert
+	for i := range e.cfg.Rules {
+		rule := &e.cfg.Rules[i]
+		if rule.Kind == KIND_CRONJOB && !cronjobs {
+			continue
+		}
+
+		current := make(map[string]bool)
+		for _, c := range rule.evaluate(in, e.restarts) {
+			id := fingerprint(rule.Name, c.labels)
+			current[id] = true
+
+			state, ok := e.alerts[id]
+			if !ok {
+				state = &alertState{
+					rule:  rule,
+					group: fingerprint(rule.Name, rule.groupLabels(c.labels)),
+					alert: models.Alert{Rule: rule.Name, Severity: rule.Severity, Labels: c.labels, ActiveAt: now},
+				}
+				e.alerts[id] = state
+			}
+			state.alert.Value = c.value
+			state.alert.Summary = c.summary
+
+			if state.alert.Status == "" && now.Sub(state.alert.ActiveAt) >= rule.For {
+				state.alert.Status = models.ALERT_STATUS_FIRING
+				state.alert.StartsAt = now
+			}
+			if state.alert.Status == models.ALERT_STATUS_FIRING && !state.notified && !e.silenced(state.alert, now) {
+				changed[state.group] = true
+			}
+		}
+
+		firing := 0
+		for id, state := range e.alerts {
+			if state.rule != rule {
+				continue
+			}
+			if current[id] {
+				if state.alert.Status == models.ALERT_STATUS_FIRING {
+					firing++
+				}
+				continue
+			}
+
+			// a pending alert is dropped silently, a firing alert is resolved
+			if state.alert.Status != models.ALERT_STATUS_FIRING || !state.notified {
+				delete(e.alerts, id)
+				continue
+			}
+			ended := now
+			state.alert.Status = models.ALERT_STATUS_RESOLVED
+			state.alert.EndsAt = &ended
+			changed[state.group] = true
+		}
+		exporter.AlertsFiring.Set(float64(firing), rule.Name)
+	}
+
+	for group := range changed {
+		e.notify(group, now)
+	}
+}
+
+// notify queues the notification of the group and removes its resolved alerts
+func (e *Engine) notify(group string, now time.Time) {
+	var rule *Rule
+	alerts := make([]models.Alert, 0)
+	for id, state := range e.alerts {
+		if state.group != group {
+			continue
+		}
+		rule = state.rule
+
+		switch {
+		case state.alert.Status == models.ALERT_STATUS_RESOLVED:
+			alerts = append(alerts, state.alert)
+			delete(e.alerts, id)
+		case state.alert.Status == models.ALERT_STATUS_FIRING && !e.silenced(state.alert, now):
+			alerts = append(alerts, state.alert)
+			state.notified = true
+		}
+	}
+	if rule == nil || len(alerts) == 0 {
+		return
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return fingerprint(alerts[i].Rule, alerts[i].Labels) < fingerprint(alerts[j].Rule, alerts[j].Labels)
+	})
+	notification := models.AlertNotification{
+		Status:      models.ALERT_STATUS_RESOLVED,
+		Rule:        rule.Name,
+		GroupKey:    group,
+		GroupLabels: rule.groupLabels(alerts[0].Labels),
+		Alerts:      alerts,
+	}
+	if notification.Firing() > 0 {
+		notification.Status = models.ALERT_STATUS_FIRING
+	}
+
+	for _, receiver := range e.receiversOf(rule) {
+		n := notification
+		if !receiver.SendResolved {
+			n.Alerts = firingAlerts(n.Alerts)
+			if len(n.Alerts) == 0 {
+				continue
+			}
+		}
+
+		select {
+		case e.queue <- delivery{receiver: receiver, notification: n}:
+		default:
+			exporter.AlertNotifications.Inc(receiver.Name, "dropped")
+			zap.L().Error("alert queue is full, dropping notification", zap.String("receiver", receiver.Name), zap.String("group", group))
+		}
+	}
+}
+
+// Run delivers the queued notifications until stop is closed, the queued notifications are delivered before it returns
+func (e *Engine) Run(stop <-chan struct{}) {
+	defer close(e.done)
+
+	for {
+		select {
+		case <-stop:
+			for len(e.queue) > 0 {
+				e.deliver(<-e.queue)
+			}
+			return
+		case d := <-e.queue:
+			e.deliver(d)
+		}
+	}
+}
+
+// Wait blocks until Run delivered the remaining notifications after the stop
+func (e *Engine) Wait() {
+	<-e.done
+}
+
+func (e *Engine) deliver(d delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	defer cancel()
+
+	if err := d.receiver.Notifier.Notify(ctx, d.notification); err != nil {
+		exporter.AlertNotifications.Inc(d.receiver.Name, "error")
+		zap.L().Error("could not send alert notification", zap.String("receiver", d.receiver.Name), zap.String("group", d.notification.GroupKey), zap.Error(err))
+		return
+	}
+
+	exporter.AlertNotifications.Inc(d.receiver.Name, "success")
+}
+
+func (e *Engine) silenced(alert models.Alert, now time.Time) bool {
+	labels := make(map[string]string, len(alert.Labels)+2)
+	for name, value := range alert.Labels {
+		labels[name] = value
+	}
+	labels["rule"] = alert.Rule
+	labels["severity"] = alert.Severity
+
+	for _, silence := range e.cfg.Silences {
+		if (silence.Until.IsZero() || now.Before(silence.Until)) && silence.Selector.Matches(labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Engine) receiversOf(rule *Rule) []Receiver {
+	if len(rule.Receivers) == 0 {
+		return e.cfg.Receivers
+	}
+
+	receivers := make([]Receiver, len(rule.Receivers))
+	for i, name := range rule.Receivers {
+		receivers[i] = e.receivers[name]
+	}
+
+	return receivers
+}
+
+func (e *Engine) hasKind(kind string) bool {
+	for _, rule := range e.cfg.Rules {
+		if rule.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+func firingAlerts(alerts []models.Alert) []models.Alert {
+	result := make([]models.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if alert.Status == models.ALERT_STATUS_FIRING {
+			result = append(result, alert)
+		}
+	}
+
+	return result
+}
+
+// restartHistory - the restarts of the containers in the previous evaluations
+type restartHistory struct {
+	samples map[string][]restartSample // by container key
+	keep    time.Duration              // the largest window of the restart rules
+}
+
+type restartSample struct {
+	time     time.Time
+	restarts int
+}
+
+func newRestartHistory() *restartHistory {
+	return &restartHistory{samples: make(map[string][]restartSample)}
+}
+
+// update adds the restarts of the collected containers, the containers which no longer exist are removed
+func (h *restartHistory) update(in *input) {
+	seen := make(map[string]bool)
+	for _, pod := range in.pods() {
+		for _, container := range pod.Containers {
+			key := containerKey(pod, container)
+			seen[key] = true
+
+			samples := append(h.samples[key], restartSample{time: in.time, restarts: container.Restarts})
+			// the oldest sample before the kept duration is the baseline of the largest window
+			for len(samples) > 1 && !samples[1].time.After(in.time.Add(-h.keep)) {
+				samples = samples[1:]
+			}
+			h.samples[key] = samples
+		}
+	}
+
+	for key := range h.samples {
+		if !seen[key] {
+			delete(h.samples, key)
+		}
+	}
+}
+
+// increase returns the restarts of the container since the start of the window.
+// The baseline is the last sample at or before the start, or the first sample when the container is younger.
+func (h *restartHistory) increase(key string, start time.Time) int {
+	samples := h.samples[key]
+	if len(samples) == 0 {
+		return 0
+	}
+
+	baseline := samples[0]
+	for _, sample := range samples[1:] {
+		if sample.time.After(start) {
+			break
+		}
+		baseline = sample
+	}
+
+	// a recreated container starts counting again
+	increase := samples[len(samples)-1].restarts - baseline.restarts
+	if increase < 0 {
+		return 0
+	}
+
+	return increase
+}
@@ -0,0 +1,201 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// types of the receivers
+const (
+	RECEIVER_WEBHOOK = "webhook" // the notification is posted as json
+	RECEIVER_SLACK   = "slack"   // a message is posted to a slack compatible incoming webhook
+	RECEIVER_SMTP    = "smtp"    // a mail is sent
+)
+
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string // e.g. an authorization header
+	Client  *http.Client      // defaults to http.DefaultClient
+}
+
+// Webhook - posts the notifications as json
+type Webhook struct {
+	cfg *WebhookConfig
+}
+
+func NewWebhook(cfg *WebhookConfig) *Webhook {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &Webhook{cfg: cfg}
+}
+
+func (w *Webhook) Notify(ctx context.Context, notification models.AlertNotification) error {
+	return postJSON(ctx, w.cfg.Client, w.cfg.URL, w.cfg.Headers, notification)
+}
+
+type SlackConfig struct {
+	URL      string // incoming webhook
+	Channel  string // overrides the channel of the webhook when set
+	Username string
+	Client   *http.Client // defaults to http.DefaultClient
+}
+
+// Slack - posts the notifications as messages to slack compatible incoming webhooks, e.g. slack or mattermost
+type Slack struct {
+	cfg *SlackConfig
+}
+
+func NewSlack(cfg *SlackConfig) *Slack {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &Slack{cfg: cfg}
+}
+
+// slackMessage - the payload of an incoming webhook
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (s *Slack) Notify(ctx context.Context, notification models.AlertNotification) error {
+	lines := []string{"*" + subject(notification) + "*"}
+	for _, alert := range notification.Alerts {
+		lines = append(lines, fmt.Sprintf("• [%s] %s", alert.Status, alert.Summary))
+	}
+
+	return postJSON(ctx, s.cfg.Client, s.cfg.URL, nil, slackMessage{Text: strings.Join(lines, "\n"), Channel: s.cfg.Channel, Username: s.cfg.Username})
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int // defaults to 25
+	Username string
+	Password string // the credentials are only sent when the server supports STARTTLS or runs on localhost
+	From     string
+	To       []string
+}
+
+// SMTP - sends the notifications as plain text mails
+type SMTP struct {
+	cfg *SMTPConfig
+}
+
+func NewSMTP(cfg *SMTPConfig) *SMTP {
+	if cfg.Port == 0 {
+		cfg.Port = 25
+	}
+
+	return &SMTP{cfg: cfg}
+}
+
+func (s *SMTP) Notify(ctx context.Context, notification models.AlertNotification) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	var body strings.Builder
+	for _, alert := range notification.Alerts {
+		fmt.Fprintf(&body, "[%s] %s\r\n", alert.Status, alert.Summary)
+		fmt.Fprintf(&body, "  labels: %s\r\n", formatLabels(alert.Labels))
+		fmt.Fprintf(&body, "  active since: %s\r\n", alert.ActiveAt.Format(time.RFC3339))
+		if alert.EndsAt != nil {
+			fmt.Fprintf(&body, "  resolved at: %s\r\n", alert.EndsAt.Format(time.RFC3339))
+		}
+		body.WriteString("\r\n")
+	}
+
+	headers := []string{
+		"From: " + s.cfg.From,
+		"To: " + strings.Join(s.cfg.To, ", "),
+		"Subject: " + headerValue(subject(notification)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + body.String()
+
+	// smtp.SendMail does not support contexts, the delivery runs until the deadline and its result is dropped afterwards
+	result := make(chan error, 1)
+	go func() {
+		result <- smtp.SendMail(net.JoinHostPort(s.cfg.Host, fmt.Sprint(s.cfg.Port)), auth, s.cfg.From, s.cfg.To, []byte(message))
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// subject summarizes the notification, e.g. "[FIRING:2] crashloop namespace=default"
+func subject(notification models.AlertNotification) string {
+	status := strings.ToUpper(notification.Status)
+	if notification.Status == models.ALERT_STATUS_FIRING {
+		status = fmt.Sprintf("%s:%d", status, notification.Firing())
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("[%s] %s %s", status, notification.Rule, formatLabels(notification.GroupLabels)))
+}
+
+// formatLabels joins the labels sorted by name, e.g. "container=web namespace=default"
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+// headerValue removes line breaks, so values can not add headers to a mail
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
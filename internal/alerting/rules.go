@@ -0,0 +1,346 @@
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// kinds of the alerting rules
+const (
+	KIND_RESTARTS    = "restarts"    // the restarts of a container increased by at least the threshold within the window
+	KIND_CRASHLOOP   = "crashloop"   // a container of a pod is waiting in CrashLoopBackOff
+	KIND_UNAVAILABLE = "unavailable" // a deployment, statefulset or daemonset has less ready replicas than desired
+	KIND_CRONJOB     = "cronjob"     // the last successful run of a cronjob is older than the max age
+	KIND_PENDING     = "pending"     // a pod is in the phase Pending
+	KIND_USAGE       = "usage"       // the cpu or memory usage of a container is above the threshold in percent of its limit
+)
+
+const (
+	RESOURCE_CPU    = "cpu"
+	RESOURCE_MEMORY = "memory"
+)
+
+const (
+	DEFAULT_RESTARTS_WINDOW = time.Minute * 10
+	DEFAULT_USAGE_THRESHOLD = 90
+)
+
+const (
+	waiting_reason_crashloop = "CrashLoopBackOff"
+	pod_phase_pending        = "Pending"
+)
+
+// Rule - a condition evaluated after each collection, the alerts fire when it is true for the for-duration
+type Rule struct {
+	Name       string
+	Kind       string
+	For        time.Duration        // the condition needs to be true this long before the alert fires
+	Threshold  float64              // restarts within the window or usage in percent of the limit
+	Window     time.Duration        // window of the restarts, defaults to DEFAULT_RESTARTS_WINDOW
+	MaxAge     time.Duration        // maximum age of the last successful run of a cronjob
+	Resource   string               // cpu or memory for the usage rules
+	Namespaces []string             // namespaces the rule applies to, all namespaces when empty
+	Selector   models.LabelSelector // labels of the workloads the rule applies to
+	Severity   string
+	GroupBy    []string // labels the alerts are grouped by in notifications, every alert is a group of its own when empty
+	Receivers  []string // names of the receivers, all receivers when empty
+}
+
+// candidate - an alert whose condition is true in the current evaluation
+type candidate struct {
+	labels  map[string]string
+	value   float64
+	summary string
+}
+
+// validate checks the rule and sets the defaults
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("rule without name")
+	}
+
+	switch r.Kind {
+	case KIND_RESTARTS:
+		if r.Window <= 0 {
+			r.Window = DEFAULT_RESTARTS_WINDOW
+		}
+		if r.Threshold <= 0 {
+			r.Threshold = 1
+		}
+	case KIND_USAGE:
+		if r.Resource != RESOURCE_CPU && r.Resource != RESOURCE_MEMORY {
+			return fmt.Errorf("rule %s: resource needs to be %s or %s", r.Name, RESOURCE_CPU, RESOURCE_MEMORY)
+		}
+		if r.Threshold <= 0 {
+			r.Threshold = DEFAULT_USAGE_THRESHOLD
+		}
+	case KIND_CRONJOB:
+		if r.MaxAge <= 0 {
+			return fmt.Errorf("rule %s: max_age is required", r.Name)
+		}
+	case KIND_CRASHLOOP, KIND_UNAVAILABLE, KIND_PENDING:
+	default:
+		return fmt.Errorf("rule %s: unknown kind %q", r.Name, r.Kind)
+	}
+
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: for needs to be positive", r.Name)
+	}
+
+	return nil
+}
+
+// applies checks the namespace and the labels of a workload
+func (r *Rule) applies(w models.Workload) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, w.GetNamespace()) {
+		return false
+	}
+
+	return r.Selector.Matches(w.GetLabels())
+}
+
+// evaluate returns the candidates of the rule, the restart history needs to be updated with the input before
+func (r *Rule) evaluate(in *input, restarts *restartHistory) []candidate {
+	switch r.Kind {
+	case KIND_RESTARTS:
+		return r.evaluateRestarts(in, restarts)
+	case KIND_CRASHLOOP:
+		return r.evaluateCrashloop(in)
+	case KIND_UNAVAILABLE:
+		return r.evaluateUnavailable(in)
+	case KIND_CRONJOB:
+		return r.evaluateCronjobs(in)
+	case KIND_PENDING:
+		return r.evaluatePending(in)
+	case KIND_USAGE:
+		return r.evaluateUsage(in)
+	}
+
+	return nil
+}
+
+func (r *Rule) evaluateRestarts(in *input, restarts *restartHistory) []candidate {
+	result := make([]candidate, 0)
+	for _, pod := range in.pods() {
+		if !r.applies(pod) {
+			continue
+		}
+
+		for _, container := range pod.Containers {
+			increase := restarts.increase(containerKey(pod, container), in.time.Add(-r.Window))
+			if float64(increase) < r.Threshold {
+				continue
+			}
+
+			result = append(result, candidate{
+				labels:  containerLabels(pod, container),
+				value:   float64(increase),
+				summary: fmt.Sprintf("container %s of pod %s/%s restarted %d times within %s", container.ContainerName, pod.Namespace, pod.WorkloadName, increase, r.Window),
+			})
+		}
+	}
+
+	return result
+}
+
+func (r *Rule) evaluateCrashloop(in *input) []candidate {
+	result := make([]candidate, 0)
+	for _, pod := range in.pods() {
+		if !r.applies(pod) {
+			continue
+		}
+
+		for _, container := range pod.Containers {
+			if container.WaitingReason != waiting_reason_crashloop {
+				continue
+			}
+
+			result = append(result, candidate{
+				labels:  containerLabels(pod, container),
+				value:   float64(container.Restarts),
+				summary: fmt.Sprintf("container %s of pod %s/%s is in %s after %d restarts", container.ContainerName, pod.Namespace, pod.WorkloadName, waiting_reason_crashloop, container.Restarts),
+			})
+		}
+	}
+
+	return result
+}
+
+func (r *Rule) evaluateUnavailable(in *input) []candidate {
+	result := make([]candidate, 0)
+	for _, w := range in.workloads {
+		if !r.applies(w) {
+			continue
+		}
+
+		var desired, ready int
+		switch workload := w.(type) {
+		case models.DeploymentWorkload:
+			desired, ready = workload.Status.Desired, workload.Status.Ready
+		case models.StatefulSetWorkload:
+			desired, ready = workload.Status.Replicas, workload.Status.Ready
+		case models.DaemonSetWorkload:
+			desired, ready = workload.Status.Desired, workload.Status.Ready
+		default:
+			continue
+		}
+		if ready >= desired {
+			continue
+		}
+
+		result = append(result, candidate{
+			labels:  workloadLabels(w),
+			value:   float64(desired - ready),
+			summary: fmt.Sprintf("%s %s/%s has %d of %d replicas ready", strings.ToLower(w.GetType()), w.GetNamespace(), w.GetWorkloadName(), ready, desired),
+		})
+	}
+
+	return result
+}
+
+func (r *Rule) evaluateCronjobs(in *input) []candidate {
+	result := make([]candidate, 0)
+	for _, w := range in.cronjobs {
+		cronjob, ok := w.(models.CronjobWorkload)
+		if !ok || !r.applies(cronjob) || (cronjob.Suspend != nil && *cronjob.Suspend) {
+			continue
+		}
+
+		// a cronjob which never succeeded is measured from its creation
+		last := cronjob.CreationTimestamp
+		if cronjob.Status.LastSuccessfulTime != nil {
+			last = *cronjob.Status.LastSuccessfulTime
+		}
+		age := in.time.Sub(last)
+		if age <= r.MaxAge {
+			continue
+		}
+
+		summary := fmt.Sprintf("cronjob %s/%s did not succeed within %s, last success %s ago", cronjob.Namespace, cronjob.WorkloadName, r.MaxAge, age.Truncate(time.Second))
+		if cronjob.Status.LastSuccessfulTime == nil {
+			summary = fmt.Sprintf("cronjob %s/%s did not succeed since its creation %s ago", cronjob.Namespace, cronjob.WorkloadName, age.Truncate(time.Second))
+		}
+		result = append(result, candidate{labels: workloadLabels(cronjob), value: age.Seconds(), summary: summary})
+	}
+
+	return result
+}
+
+func (r *Rule) evaluatePending(in *input) []candidate {
+	result := make([]candidate, 0)
+	for _, pod := range in.pods() {
+		if !r.applies(pod) || pod.Status != pod_phase_pending {
+			continue
+		}
+
+		result = append(result, candidate{
+			labels:  map[string]string{"namespace": pod.Namespace, "pod": pod.WorkloadName},
+			value:   in.time.Sub(pod.CreationTimestamp).Seconds(),
+			summary: fmt.Sprintf("pod %s/%s is pending", pod.Namespace, pod.WorkloadName),
+		})
+	}
+
+	return result
+}
+
+func (r *Rule) evaluateUsage(in *input) []candidate {
+	pods := make(map[string]models.PodWorkload)
+	for _, pod := range in.pods() {
+		pods[pod.Namespace+"/"+pod.WorkloadName] = pod
+	}
+
+	result := make([]candidate, 0)
+	for _, metric := range in.metrics {
+		pod, ok := pods[metric.Namespace+"/"+metric.PodName]
+		if !ok || !r.applies(pod) {
+			continue
+		}
+
+		for _, container := range pod.Containers {
+			if container.ContainerName != metric.ContainerName {
+				continue
+			}
+
+			usage, limit, unit := metric.CPUUsage, container.LimitCPU, "m"
+			if r.Resource == RESOURCE_MEMORY {
+				usage, limit, unit = metric.MemoryUsage, container.LimitMemory, " bytes"
+			}
+			if limit <= 0 {
+				continue
+			}
+
+			percent := float64(usage) / float64(limit) * 100
+			if percent <= r.Threshold {
+				continue
+			}
+
+			labels := containerLabels(pod, container)
+			labels["resource"] = r.Resource
+			result = append(result, candidate{
+				labels:  labels,
+				value:   percent,
+				summary: fmt.Sprintf("%s usage of container %s of pod %s/%s is %.0f%% of the limit (%d%s of %d%s)", r.Resource, container.ContainerName, pod.Namespace, pod.WorkloadName, percent, usage, unit, limit, unit),
+			})
+		}
+	}
+
+	return result
+}
+
+// groupLabels returns the labels of the alert the rule groups by
+func (r *Rule) groupLabels(labels map[string]string) map[string]string {
+	if len(r.GroupBy) == 0 {
+		return labels
+	}
+
+	result := make(map[string]string, len(r.GroupBy))
+	for _, name := range r.GroupBy {
+		result[name] = labels[name]
+	}
+
+	return result
+}
+
+func containerKey(pod models.PodWorkload, container models.Container) string {
+	return pod.Namespace + "/" + pod.WorkloadName + "/" + container.ContainerName
+}
+
+func containerLabels(pod models.PodWorkload, container models.Container) map[string]string {
+	return map[string]string{"namespace": pod.Namespace, "pod": pod.WorkloadName, "container": container.ContainerName}
+}
+
+func workloadLabels(w models.Workload) map[string]string {
+	return map[string]string{"namespace": w.GetNamespace(), "workload": w.GetWorkloadName(), "type": w.GetType()}
+}
+
+// fingerprint identifies the alert of a rule by its labels
+func fingerprint(rule string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(rule)
+	for _, name := range names {
+		b.WriteString("," + name + "=" + labels[name])
+	}
+
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ds, err := persistence.NewSQLiteDataStore(filepath.Join(t.TempDir(), "audit.sqlite"), config.MetricsRetentionConfig{})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := NewLogger(&LoggerConfig{Store: ds, File: file, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	authn, err := auth.NewAuth(context.Background(), &auth.AuthConfig{Tokens: map[string]string{"ci": "secret"}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(Middleware(l))
+	r.Use(authn.Middleware(func(c *gin.Context, err error) { c.AbortWithStatus(http.StatusUnauthorized) }))
+	r.GET("/api/v1/namespaces/:name", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, request := range []struct {
+		path  string
+		token string
+	}{
+		{path: "/api/v1/namespaces/default?at=2023-01-22T10:00:00Z&token=leaked", token: "secret"},
+		{path: "/api/v1/namespaces/default"},
+		{path: "/healthz"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, request.path, nil)
+		if request.token != "" {
+			req.Header.Set("Authorization", "Bearer "+request.token)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	stop := make(chan struct{})
+	go l.Run(stop)
+	close(stop)
+	l.Wait()
+
+	page, err := ds.ListAuditEntries(models.AuditFilter{}, models.ListOptions{Sort: []models.SortField{{Field: "id"}}})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2, "only the api requests are recorded")
+
+	allowed := page.Items[0]
+	assert.Equal(t, "ci", allowed.User)
+	assert.Equal(t, auth.METHOD_TOKEN, allowed.AuthMethod)
+	assert.Equal(t, "/api/v1/namespaces/:name", allowed.Route)
+	assert.Equal(t, map[string]string{"name": "default", "at": "2023-01-22T10:00:00Z", "token": redacted}, allowed.Params)
+	assert.Equal(t, http.StatusOK, allowed.Status)
+	assert.False(t, allowed.Denied)
+
+	denied := page.Items[1]
+	assert.Empty(t, denied.User)
+	assert.Equal(t, http.StatusUnauthorized, denied.Status)
+	assert.True(t, denied.Denied)
+
+	yes := true
+	page, err = ds.ListAuditEntries(models.AuditFilter{Denied: &yes, Path: "/api/v1/namespaces/"}, models.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.Metadata.Total)
+
+	// the file contains the same entries as json lines
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		var entry models.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		assert.Equal(t, page.Items[0].Path, entry.Path)
+	}
+	assert.Equal(t, 2, lines)
+
+	// the entries are removed after the retention
+	require.NoError(t, ds.RemoveAuditEntriesBefore(time.Now().Add(time.Minute)))
+	page, err = ds.ListAuditEntries(models.AuditFilter{}, models.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, page.Metadata.Total)
+}
+
+func TestRecordDropsWhenFull(t *testing.T) {
+	ds, err := persistence.NewSQLiteDataStore(filepath.Join(t.TempDir(), "audit.sqlite"), config.MetricsRetentionConfig{})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	l, err := NewLogger(&LoggerConfig{Store: ds, BufferSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		l.Record(models.AuditEntry{User: "ci", Path: "/api/v1/nodes"})
+	}
+	assert.Equal(t, int64(3), l.dropped)
+
+	// the drops are logged once and the counter is reset
+	l.logDropped()
+	assert.Equal(t, int64(0), l.dropped)
+
+	stop := make(chan struct{})
+	go l.Run(stop)
+	close(stop)
+	l.Wait()
+
+	page, err := ds.ListAuditEntries(models.AuditFilter{}, models.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The audit package records every request to the api, including the requests denied by the authentication or the authorization.
+	The entries are buffered and appended to the audit log of the data store in batches, the requests never wait for the database.
+	Optionally every entry is also appended as json line to a file, e.g. to ship it to a log collector.
+**/
+
+const (
+	DEFAULT_RETENTION      = time.Hour * 24 * 30
+	DEFAULT_BUFFER_SIZE    = 1024
+	DEFAULT_FLUSH_INTERVAL = time.Second
+)
+
+// the entries older than the retention are removed in this interval
+const retention_interval = time.Hour
+
+// the entries dropped because of a full buffer are logged at most once in this interval
+const dropped_log_interval = time.Minute
+
+// Store - the append-only audit log
+type Store interface {
+	AppendAuditEntries(entries []models.AuditEntry) error
+	RemoveAuditEntriesBefore(t time.Time) error
+}
+
+type LoggerConfig struct {
+	Store         Store
+	File          string        // the entries are also appended as json lines to the file when set
+	Retention     time.Duration // defaults to DEFAULT_RETENTION
+	BufferSize    int           // entries waiting to be stored, further entries are dropped, defaults to DEFAULT_BUFFER_SIZE
+	FlushInterval time.Duration // defaults to DEFAULT_FLUSH_INTERVAL
+}
+
+// Logger - records the audit entries, Run needs to be started to store them
+type Logger struct {
+	cfg     *LoggerConfig
+	entries chan models.AuditEntry
+	file    *os.File
+	done    chan struct{}
+	dropped int64 // entries dropped since the last log, accessed atomically
+}
+
+// NewLogger creates the logger, the file is opened for appending on creation
+func NewLogger(cfg *LoggerConfig) (*Logger, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("store is required")
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DEFAULT_RETENTION
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DEFAULT_BUFFER_SIZE
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+
+	l := &Logger{cfg: cfg, entries: make(chan models.AuditEntry, cfg.BufferSize), done: make(chan struct{})}
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+
+	return l, nil
+}
+
+// Record queues the entry, it is dropped when the buffer is full.
+// The drops are counted and logged by Run, logging every drop would flood the log while the store is slow.
+func (l *Logger) Record(entry models.AuditEntry) {
+	select {
+	case l.entries <- entry:
+	default:
+		exporter.AuditDropped.Inc()
+		atomic.AddInt64(&l.dropped, 1)
+	}
+}
+
+// Run stores the queued entries and removes the expired ones until stop is closed, the remaining entries are stored before it returns
+func (l *Logger) Run(stop <-chan struct{}) {
+	defer close(l.done)
+
+	flush := time.NewTicker(l.cfg.FlushInterval)
+	defer flush.Stop()
+	retention := time.NewTicker(retention_interval)
+	defer retention.Stop()
+	dropped := time.NewTicker(dropped_log_interval)
+	defer dropped.Stop()
+
+	l.removeExpired()
+	for {
+		select {
+		case <-stop:
+			l.flush()
+			l.logDropped()
+			if l.file != nil {
+				_ = l.file.Close()
+			}
+			return
+		case <-flush.C:
+			l.flush()
+		case <-retention.C:
+			l.removeExpired()
+		case <-dropped.C:
+			l.logDropped()
+		}
+	}
+}
+
+// Wait blocks until Run stored the remaining entries after the stop
+func (l *Logger) Wait() {
+	<-l.done
+}
+
+// flush stores all queued entries
+func (l *Logger) flush() {
+	entries := make([]models.AuditEntry, 0, len(l.entries))
+	for len(entries) < cap(entries) {
+		entries = append(entries, <-l.entries)
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := l.cfg.Store.AppendAuditEntries(entries); err != nil {
+		exporter.AuditDropped.Add(float64(len(entries)))
+		zap.L().Error("could not store audit entries", zap.Int("entries", len(entries)), zap.Error(err))
+	}
+
+	if l.file != nil {
+		l.writeLines(entries)
+	}
+}
+
+// writeLines appends the entries as json lines to the file
+func (l *Logger) writeLines(entries []models.AuditEntry) {
+	encoder := json.NewEncoder(l.file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			zap.L().Error("could not write audit entry to file", zap.String("file", l.cfg.File), zap.Error(err))
+			return
+		}
+	}
+}
+
+// logDropped logs the number of entries dropped because of a full buffer since the last call
+func (l *Logger) logDropped() {
+	if dropped := atomic.SwapInt64(&l.dropped, 0); dropped > 0 {
+		zap.L().Error("audit buffer is full, dropped entries", zap.Int64("entries", dropped), zap.Int("buffer_size", l.cfg.BufferSize))
+	}
+}
+
+func (l *Logger) removeExpired() {
+	if err := l.cfg.Store.RemoveAuditEntriesBefore(time.Now().Add(-l.cfg.Retention)); err != nil {
+		zap.L().Error("could not remove expired audit entries", zap.Error(err))
+	}
+}
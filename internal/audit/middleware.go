@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// audited_paths are the prefixes of the recorded requests, the ui and the static files are not recorded
+var audited_paths = []string{"/api/", "/auth/"}
+
+// redacted_params are secrets sent as query parameters, their values are not recorded
+var redacted_params = map[string]bool{"code": true, "state": true, "token": true, "access_token": true, "id_token": true}
+
+const redacted = "[redacted]"
+
+// Middleware records the requests when they are finished, it needs to run before the authentication to record the denied requests
+func Middleware(l *Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAudited(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := models.AuditEntry{
+			Timestamp: start,
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Params:    params(c),
+			Status:    status,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Denied:    status == http.StatusUnauthorized || status == http.StatusForbidden,
+			ClientIP:  c.ClientIP(),
+		}
+		if principal := auth.GetPrincipal(c); principal != nil {
+			entry.User = principal.Name
+			entry.AuthMethod = principal.Method
+		}
+
+		l.Record(entry)
+	}
+}
+
+func isAudited(path string) bool {
+	for _, prefix := range audited_paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// params returns the path and query parameters of the request, multiple values of a query parameter are joined by commas
+func params(c *gin.Context) map[string]string {
+	result := make(map[string]string)
+	for name, values := range c.Request.URL.Query() {
+		result[name] = strings.Join(values, ",")
+	}
+	for _, param := range c.Params {
+		result[param.Key] = param.Value
+	}
+
+	for name := range result {
+		if redacted_params[name] {
+			result[name] = redacted
+		}
+	}
+
+	return result
+}
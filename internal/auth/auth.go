@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The auth package authenticates the requests to kdd.
+	Every authenticator checks the credentials it understands: static api tokens, OIDC sessions and OIDC id tokens.
+	Requests without credentials are only allowed when anonymous access is enabled explicitly.
+**/
+
+const (
+	METHOD_ANONYMOUS string = "anonymous"
+	METHOD_TOKEN     string = "token"
+	METHOD_OIDC      string = "oidc"
+)
+
+// key of the principal in the gin context
+const principal_key = "kdd.principal"
+
+// public_paths are served without authentication, the ui shell and the static files contain no cluster data
+var public_paths = []string{"/healthz", "/readyz", "/auth/", "/static/", "/favicon.ico", "/manifest.json", "/robots.txt"}
+
+// Principal - the authenticated client of a request
+type Principal struct {
+	Name   string   `json:"name"`
+	Email  string   `json:"email,omitempty"`
+	Groups []string `json:"groups,omitempty"` // groups of the OIDC groups claim
+	Method string   `json:"method"`
+}
+
+// Authenticator - checks the credentials of a request.
+// nil is returned without error when the request carries no credentials of the authenticator.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type AuthConfig struct {
+	Anonymous bool              // allows requests without credentials
+	Tokens    map[string]string // static api tokens by name
+	OIDC      *OIDCConfig       // disabled when nil
+}
+
+// Auth - the authenticators of the server
+type Auth struct {
+	cfg            *AuthConfig
+	authenticators []Authenticator
+	oidc           *OIDC
+}
+
+// NewAuth creates the configured authenticators, the OIDC provider is discovered on creation.
+// At least one way to authenticate or the anonymous access needs to be configured.
+func NewAuth(ctx context.Context, cfg *AuthConfig) (*Auth, error) {
+	a := &Auth{cfg: cfg, authenticators: make([]Authenticator, 0)}
+
+	if len(cfg.Tokens) > 0 {
+		tokens, err := NewStaticTokens(cfg.Tokens)
+		if err != nil {
+			return nil, err
+		}
+		a.authenticators = append(a.authenticators, tokens)
+	}
+
+	if cfg.OIDC != nil {
+		oidc, err := NewOIDC(ctx, cfg.OIDC)
+		if err != nil {
+			return nil, fmt.Errorf("could not set up oidc: %w", err)
+		}
+		a.oidc = oidc
+		a.authenticators = append(a.authenticators, oidc)
+	}
+
+	if len(a.authenticators) == 0 && !cfg.Anonymous {
+		return nil, errors.New("no authentication configured, configure tokens or oidc or enable the anonymous access explicitly")
+	}
+	if cfg.Anonymous {
+		zap.L().Warn("anonymous access is enabled, requests without credentials can read all data")
+	}
+
+	return a, nil
+}
+
+// GetPrincipal returns the principal of the request, nil when the route is public
+func GetPrincipal(c *gin.Context) *Principal {
+	if principal, ok := c.Get(principal_key); ok {
+		return principal.(*Principal)
+	}
+
+	return nil
+}
+
+// RegisterRoutes registers the login routes of the OIDC provider below /auth
+func (a *Auth) RegisterRoutes(r gin.IRouter) {
+	if a.oidc == nil {
+		return
+	}
+
+	group := r.Group("/auth")
+	group.GET("/login", a.oidc.Login)
+	group.GET("/callback", a.oidc.Callback)
+	group.POST("/logout", a.oidc.Logout)
+}
+
+// Middleware authenticates the requests, rejected requests are answered by reject.
+// Browsers are redirected to the OIDC login instead when it is configured.
+func (a *Auth) Middleware(reject func(c *gin.Context, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublic(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		for _, authenticator := range a.authenticators {
+			principal, err := authenticator.Authenticate(c.Request)
+			if err != nil {
+				zap.L().Info("authentication failed", zap.String("path", c.Request.URL.Path), zap.Error(err))
+				reject(c, fmt.Errorf("%w: %s", models.ErrUnauthorized, err))
+				return
+			}
+			if principal != nil {
+				c.Set(principal_key, principal)
+				c.Next()
+				return
+			}
+		}
+
+		// credentials nobody understands are rejected even with anonymous access
+		if c.GetHeader("Authorization") != "" {
+			reject(c, fmt.Errorf("%w: invalid credentials", models.ErrUnauthorized))
+			return
+		}
+
+		if a.cfg.Anonymous {
+			c.Set(principal_key, &Principal{Name: METHOD_ANONYMOUS, Method: METHOD_ANONYMOUS})
+			c.Next()
+			return
+		}
+
+		if a.oidc != nil && isBrowser(c.Request) {
+			c.Redirect(http.StatusFound, "/auth/login?redirect="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+
+		reject(c, fmt.Errorf("%w: credentials required", models.ErrUnauthorized))
+	}
+}
+
+func isPublic(path string) bool {
+	for _, public := range public_paths {
+		if path == public || (strings.HasSuffix(public, "/") && strings.HasPrefix(path, public)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBrowser checks if the request is a page load, api requests and streams are answered with errors
+func isBrowser(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// bearerToken returns the token of the authorization header, empty when the header is missing or another scheme is used
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// provider - a stand-in OIDC provider issuing id tokens for the code "valid-code"
+type provider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	lock  sync.Mutex
+	nonce string // nonce of the id token issued by the token endpoint
+}
+
+func newProvider(t *testing.T) *provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &provider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		p.lock.Lock()
+		nonce := p.nonce
+		p.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.sign(t, "key-1", p.claims(map[string]any{"nonce": nonce})),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+// claims returns valid claims for the client kdd, the overrides replace or remove (nil) claims
+func (p *provider) claims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":   p.URL,
+		"sub":   "user-1",
+		"aud":   "kdd",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "jane@example.com",
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+
+	return claims
+}
+
+func (p *provider) sign(t *testing.T, kid string, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hash[:])
+	require.NoError(t, err)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestRouter(a *Auth) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(a.Middleware(func(c *gin.Context, err error) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	}))
+	a.RegisterRoutes(r)
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/v1/nodes", func(c *gin.Context) { c.JSON(http.StatusOK, GetPrincipal(c)) })
+	r.GET("/ui/*page", func(c *gin.Context) { c.String(http.StatusOK, "ui") })
+
+	return r
+}
+
+func request(r http.Handler, path string, header http.Header, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	return requestMethod(r, http.MethodGet, path, header, cookies...)
+}
+
+func requestMethod(r http.Handler, method string, path string, header http.Header, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func TestNewAuthRequiresExplicitAnonymousAccess(t *testing.T) {
+	_, err := NewAuth(context.Background(), &AuthConfig{})
+	assert.Error(t, err)
+
+	_, err = NewAuth(context.Background(), &AuthConfig{Anonymous: true})
+	assert.NoError(t, err)
+
+	_, err = NewAuth(context.Background(), &AuthConfig{Tokens: map[string]string{"ci": ""}})
+	assert.Error(t, err)
+}
+
+func TestStaticTokens(t *testing.T) {
+	for _, anonymous := range []bool{false, true} {
+		a, err := NewAuth(context.Background(), &AuthConfig{Anonymous: anonymous, Tokens: map[string]string{"ci": "secret"}})
+		require.NoError(t, err)
+		r := newTestRouter(a)
+
+		w := request(r, "/api/v1/nodes", bearer("secret"))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"name":"ci","method":"token"}`, w.Body.String())
+
+		assert.Equal(t, http.StatusUnauthorized, request(r, "/api/v1/nodes", bearer("wrong")).Code)
+		assert.Equal(t, http.StatusOK, request(r, "/healthz", nil).Code)
+
+		w = request(r, "/api/v1/nodes", nil)
+		if !anonymous {
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			continue
+		}
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"name":"anonymous","method":"anonymous"}`, w.Body.String())
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newProvider(t)
+	a, err := NewAuth(context.Background(), &AuthConfig{OIDC: &OIDCConfig{
+		Issuer:        p.URL,
+		ClientID:      "kdd",
+		ClientSecret:  "client-secret",
+		RedirectURL:   "http://kdd.local/auth/callback",
+		SessionSecret: "session-secret",
+	}})
+	require.NoError(t, err)
+	r := newTestRouter(a)
+
+	// browsers are redirected to the login, api clients get an error
+	w := request(r, "/ui/workloads", http.Header{"Accept": {"text/html"}})
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/auth/login?redirect=%2Fui%2Fworkloads", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusUnauthorized, request(r, "/api/v1/nodes", nil).Code)
+
+	w = request(r, "/auth/login?redirect=%2Fui%2Fworkloads", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	authorize, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, p.URL+"/authorize", authorize.Scheme+"://"+authorize.Host+authorize.Path)
+	assert.Equal(t, "kdd", authorize.Query().Get("client_id"))
+	state := cookie(w, state_cookie)
+	require.NotNil(t, state)
+
+	p.lock.Lock()
+	p.nonce = authorize.Query().Get("nonce")
+	p.lock.Unlock()
+
+	// the state needs to match the login
+	w = request(r, "/auth/callback?code=valid-code&state=forged", nil, state)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(r, "/auth/callback?code=valid-code&state="+authorize.Query().Get("state"), nil, state)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/ui/workloads", w.Header().Get("Location"))
+	session := cookie(w, SESSION_COOKIE)
+	require.NotNil(t, session)
+	assert.True(t, session.HttpOnly)
+
+	w = request(r, "/api/v1/nodes", nil, session)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name":"jane@example.com","email":"jane@example.com","method":"oidc"}`, w.Body.String())
+
+	// the logout changes state and is only accepted as a post
+	assert.NotEqual(t, http.StatusOK, request(r, "/auth/logout", nil, session).Code)
+	w = requestMethod(r, http.MethodPost, "/auth/logout", nil, session)
+	require.Equal(t, http.StatusOK, w.Code)
+	cleared := cookie(w, SESSION_COOKIE)
+	require.NotNil(t, cleared)
+	assert.Empty(t, cleared.Value)
+	assert.True(t, cleared.MaxAge < 0)
+
+	// a changed session is ignored
+	session.Value += "x"
+	assert.Equal(t, http.StatusUnauthorized, request(r, "/api/v1/nodes", nil, session).Code)
+
+	// a nonce of another login is rejected
+	p.lock.Lock()
+	p.nonce = "replayed"
+	p.lock.Unlock()
+	w = request(r, "/auth/callback?code=valid-code&state="+authorize.Query().Get("state"), nil, state)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// only local pages are opened after the login
+	w = request(r, "/auth/login?redirect=%2F%2Fevil.example.com", nil)
+	var login loginState
+	require.NoError(t, a.oidc.signer.decode(cookie(w, state_cookie).Value, &login))
+	assert.Equal(t, default_redirect, login.Redirect)
+}
+
+func TestOIDCBearerIDToken(t *testing.T) {
+	p := newProvider(t)
+	a, err := NewAuth(context.Background(), &AuthConfig{OIDC: &OIDCConfig{Issuer: p.URL, ClientID: "kdd", RedirectURL: "http://kdd.local/auth/callback"}})
+	require.NoError(t, err)
+	r := newTestRouter(a)
+
+	w := request(r, "/api/v1/nodes", bearer(p.sign(t, "key-1", p.claims(nil))))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "audience", token: p.sign(t, "key-1", p.claims(map[string]any{"aud": []string{"other"}}))},
+		{name: "issuer", token: p.sign(t, "key-1", p.claims(map[string]any{"iss": "https://evil.example.com"}))},
+		{name: "expired", token: p.sign(t, "key-1", p.claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{name: "unknown key", token: p.sign(t, "key-2", p.claims(nil))},
+		{name: "unsigned", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(p.sign(t, "key-1", p.claims(nil)), ".")[1] + "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, http.StatusUnauthorized, request(r, "/api/v1/nodes", bearer(tt.token)).Code)
+		})
+	}
+}
+
+func TestIDTokenClaimsPrincipal(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims IDTokenClaims
+		want   string
+	}{
+		{name: "email", claims: IDTokenClaims{Subject: "1234", Email: "jane@example.com", Name: "Jane Doe", PreferredUsername: "jane"}, want: "jane@example.com"},
+		{name: "name", claims: IDTokenClaims{Subject: "1234", Name: "Jane Doe", PreferredUsername: "jane"}, want: "Jane Doe"},
+		{name: "preferred username", claims: IDTokenClaims{Subject: "1234", PreferredUsername: "jane"}, want: "jane"},
+		{name: "subject", claims: IDTokenClaims{Subject: "1234"}, want: "1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.claims.principal().Name)
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+	Verification of the id tokens issued by the OIDC provider.
+	Only the RSA signatures (RS256, RS384, RS512) are supported, they are the default of the common providers.
+**/
+
+// clock_skew tolerated between kdd and the provider
+const clock_skew = time.Minute
+
+// minimum time between two requests of the keys, unknown key ids would cause a request otherwise
+const jwks_refresh_interval = time.Minute
+
+var signing_hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// IDTokenClaims - the claims of an id token used by kdd
+type IDTokenClaims struct {
+	Issuer            string     `json:"iss"`
+	Subject           string     `json:"sub"`
+	Audience          stringList `json:"aud"`
+	Expiry            int64      `json:"exp"`
+	NotBefore         int64      `json:"nbf"`
+	Nonce             string     `json:"nonce"`
+	Email             string     `json:"email"`
+	Name              string     `json:"name"`
+	PreferredUsername string     `json:"preferred_username"`
+	Groups            stringList `json:"groups"`
+}
+
+// stringList is a claim sent as single string or as list, e.g. the audience
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = stringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet - the signing keys of the provider, refreshed when a token is signed by an unknown key
+type keySet struct {
+	uri       string
+	client    *http.Client
+	lock      sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	refreshed time.Time
+}
+
+// verifier - checks the signature and the claims of id tokens
+type verifier struct {
+	issuer   string
+	clientID string
+	keys     *keySet
+	now      func() time.Time
+}
+
+// verify checks the token and returns its claims, the nonce is only checked when it is not empty
+func (v *verifier) verify(ctx context.Context, token string, nonce string) (*IDTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed id token header: %w", err)
+	}
+
+	hash, ok := signing_hashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id token signature: %w", err)
+	}
+
+	key, err := v.keys.get(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature); err != nil {
+		return nil, errors.New("invalid id token signature")
+	}
+
+	var claims IDTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed id token claims: %w", err)
+	}
+
+	now := v.now()
+	switch {
+	case claims.Issuer != v.issuer:
+		return nil, fmt.Errorf("id token issued by %s", claims.Issuer)
+	case !contains(claims.Audience, v.clientID):
+		return nil, errors.New("id token issued for another client")
+	case now.After(time.Unix(claims.Expiry, 0).Add(clock_skew)):
+		return nil, errors.New("id token expired")
+	case claims.NotBefore != 0 && now.Add(clock_skew).Before(time.Unix(claims.NotBefore, 0)):
+		return nil, errors.New("id token not valid yet")
+	case nonce != "" && claims.Nonce != nonce:
+		return nil, errors.New("id token nonce does not match")
+	}
+
+	return &claims, nil
+}
+
+// get returns the key of the key id, the keys are requested again when the id is unknown
+func (s *keySet) get(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if key := s.find(kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(s.refreshed) < jwks_refresh_interval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil, fmt.Errorf("could not load signing keys: %w", err)
+	}
+
+	if key := s.find(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// find returns the key of the key id, a token without key id is accepted when the provider has a single key
+func (s *keySet) find(kid string) *rsa.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+
+	return s.keys[kid]
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	s.refreshed = time.Now()
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.uri, &document); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range document.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("invalid modulus of key %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("invalid exponent of key %s: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	s.keys = keys
+
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// getJSON requests the url and decodes the json response
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
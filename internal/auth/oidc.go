@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+/**
+	OIDC login with the authorization code flow.
+	After the login the identity is kept in a signed session cookie, kdd keeps no session state.
+	Clients without a browser can send the id token as bearer token instead.
+**/
+
+const (
+	SESSION_COOKIE      = "kdd_session"
+	DEFAULT_SESSION_TTL = time.Hour * 12
+)
+
+// the state of a login is kept in a cookie until the provider redirects back
+const (
+	state_cookie = "kdd_oidc_state"
+	state_ttl    = time.Minute * 10
+)
+
+// default_redirect is the page opened after the login when no page was requested
+const default_redirect = "/ui/"
+
+type OIDCConfig struct {
+	Issuer        string        // url of the provider, the configuration is discovered below /.well-known/openid-configuration
+	ClientID      string        // client id registered at the provider
+	ClientSecret  string        // client secret registered at the provider
+	RedirectURL   string        // public url of /auth/callback
+	Scopes        []string      // defaults to openid, profile and email
+	SessionSecret string        // signs the cookies, a random secret invalidates the sessions on restart when empty
+	SessionTTL    time.Duration // lifetime of the sessions, defaults to DEFAULT_SESSION_TTL
+	HTTPClient    *http.Client  // used for the requests to the provider, defaults to http.DefaultClient
+}
+
+// OIDC - authenticates sessions created by the login and id tokens of the provider
+type OIDC struct {
+	cfg      *OIDCConfig
+	oauth    *oauth2.Config
+	verifier *verifier
+	signer   signer
+	secure   bool // cookies are only sent over https when kdd is served over https
+}
+
+type session struct {
+	Subject string   `json:"sub"`
+	Name    string   `json:"name"`
+	Email   string   `json:"email,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Expiry  int64    `json:"exp"`
+}
+
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Redirect string `json:"redirect"`
+	Expiry   int64  `json:"exp"`
+}
+
+// NewOIDC discovers the provider and creates the authenticator
+func NewOIDC(ctx context.Context, cfg *OIDCConfig) (*OIDC, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("issuer, client id and redirect url are required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = DEFAULT_SESSION_TTL
+	}
+
+	key := []byte(cfg.SessionSecret)
+	if cfg.SessionSecret == "" {
+		zap.L().Warn("no session secret configured, the sessions are invalidated on restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, cfg.HTTPClient, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("could not discover provider: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("provider reports issuer %s instead of %s", discovery.Issuer, cfg.Issuer)
+	}
+
+	return &OIDC{
+		cfg: cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: discovery.AuthorizationEndpoint, TokenURL: discovery.TokenEndpoint},
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		},
+		verifier: &verifier{
+			issuer:   discovery.Issuer,
+			clientID: cfg.ClientID,
+			keys:     &keySet{uri: discovery.JwksURI, client: cfg.HTTPClient},
+			now:      time.Now,
+		},
+		signer: signer{key: key},
+		secure: strings.HasPrefix(cfg.RedirectURL, "https://"),
+	}, nil
+}
+
+// Authenticate accepts id tokens as bearer token and the session cookie, an invalid or expired session counts as missing
+func (o *OIDC) Authenticate(r *http.Request) (*Principal, error) {
+	// id tokens are jwts, other bearer tokens belong to other authenticators
+	if token := bearerToken(r); strings.Count(token, ".") == 2 {
+		claims, err := o.verifier.verify(r.Context(), token, "")
+		if err != nil {
+			return nil, err
+		}
+		return claims.principal(), nil
+	}
+
+	cookie, err := r.Cookie(SESSION_COOKIE)
+	if err != nil {
+		return nil, nil
+	}
+
+	var s session
+	if err := o.signer.decode(cookie.Value, &s); err != nil {
+		zap.L().Debug("ignoring invalid session", zap.Error(err))
+		return nil, nil
+	}
+	if time.Now().After(time.Unix(s.Expiry, 0)) {
+		return nil, nil
+	}
+
+	return &Principal{Name: s.Name, Email: s.Email, Groups: s.Groups, Method: METHOD_OIDC}, nil
+}
+
+// Login redirects to the provider, the redirect query parameter is the page opened after the login
+func (o *OIDC) Login(c *gin.Context) {
+	state := loginState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Redirect: localRedirect(c.Query("redirect")),
+		Expiry:   time.Now().Add(state_ttl).Unix(),
+	}
+
+	value, err := o.signer.encode(state)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "could not start login")
+		return
+	}
+
+	o.setCookie(c, state_cookie, value, state_ttl, "/auth")
+	c.Redirect(http.StatusFound, o.oauth.AuthCodeURL(state.State, oauth2.SetAuthURLParam("nonce", state.Nonce)))
+}
+
+// Callback exchanges the code of the provider and creates the session
+func (o *OIDC) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		zap.L().Info("login failed at the provider", zap.String("error", reason), zap.String("description", c.Query("error_description")))
+		c.String(http.StatusUnauthorized, "login failed: %s", reason)
+		return
+	}
+
+	var state loginState
+	cookie, err := c.Cookie(state_cookie)
+	if err == nil {
+		err = o.signer.decode(cookie, &state)
+	}
+	if err != nil || time.Now().After(time.Unix(state.Expiry, 0)) || state.State != c.Query("state") {
+		c.String(http.StatusUnauthorized, "login expired, please try again")
+		return
+	}
+	o.setCookie(c, state_cookie, "", -1, "/auth")
+
+	ctx := context.WithValue(c.Request.Context(), oauth2.HTTPClient, o.cfg.HTTPClient)
+	token, err := o.oauth.Exchange(ctx, c.Query("code"))
+	if err != nil {
+		zap.L().Error("could not exchange the code", zap.Error(err))
+		c.String(http.StatusUnauthorized, "login failed")
+		return
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		c.String(http.StatusUnauthorized, "login failed: no id token received")
+		return
+	}
+
+	claims, err := o.verifier.verify(ctx, raw, state.Nonce)
+	if err != nil {
+		zap.L().Error("invalid id token", zap.Error(err))
+		c.String(http.StatusUnauthorized, "login failed")
+		return
+	}
+
+	principal := claims.principal()
+	value, err := o.signer.encode(session{
+		Subject: claims.Subject,
+		Name:    principal.Name,
+		Email:   principal.Email,
+		Groups:  principal.Groups,
+		Expiry:  time.Now().Add(o.cfg.SessionTTL).Unix(),
+	})
+	if err != nil {
+		c.String(http.StatusInternalServerError, "could not create session")
+		return
+	}
+
+	o.setCookie(c, SESSION_COOKIE, value, o.cfg.SessionTTL, "/")
+	zap.L().Info("login", zap.String("name", principal.Name))
+	c.Redirect(http.StatusFound, state.Redirect)
+}
+
+// Logout removes the session cookie, the session at the provider is kept
+func (o *OIDC) Logout(c *gin.Context) {
+	o.setCookie(c, SESSION_COOKIE, "", -1, "/")
+	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
+}
+
+func (o *OIDC) setCookie(c *gin.Context, name string, value string, ttl time.Duration, path string) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", o.secure, true)
+}
+
+// principal returns the principal of the claims, named by the first of email, name and preferred username, the subject otherwise
+func (c *IDTokenClaims) principal() *Principal {
+	name := c.Subject
+	for _, candidate := range []string{c.Email, c.Name, c.PreferredUsername} {
+		if candidate != "" {
+			name = candidate
+			break
+		}
+	}
+
+	return &Principal{Name: name, Email: c.Email, Groups: c.Groups, Method: METHOD_OIDC}
+}
+
+// localRedirect returns the path if it stays on kdd, other urls are replaced to prevent open redirects
+func localRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return default_redirect
+	}
+
+	return path
+}
+
+func randomString() string {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// StaticTokens - authenticates bearer tokens configured in kdd.yaml, e.g. for scripts and prometheus
+type StaticTokens struct {
+	names  []string
+	hashes [][sha256.Size]byte // compared in constant time, the hash hides the length of the tokens
+}
+
+// NewStaticTokens creates the authenticator of the tokens by name
+func NewStaticTokens(tokens map[string]string) (*StaticTokens, error) {
+	s := &StaticTokens{}
+
+	names := make([]string, 0, len(tokens))
+	for name := range tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if tokens[name] == "" {
+			return nil, fmt.Errorf("token %s is empty", name)
+		}
+		s.names = append(s.names, name)
+		s.hashes = append(s.hashes, sha256.Sum256([]byte(tokens[name])))
+	}
+
+	return s, nil
+}
+
+func (s *StaticTokens) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, nil
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	for i, expected := range s.hashes {
+		if subtle.ConstantTimeCompare(hash[:], expected[:]) == 1 {
+			return &Principal{Name: s.names[i], Method: METHOD_TOKEN}, nil
+		}
+	}
+
+	// the token may be an id token of the OIDC provider
+	return nil, nil
+}
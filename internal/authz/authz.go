@@ -0,0 +1,70 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The authz package decides which namespaces the authenticated principal of a request can see.
+	The namespace scope is either resolved by SubjectAccessReviews against the RBAC of kubernetes or by configured mappings of groups and users.
+	The api restricts the data store and the kubernetes api adapter to the scope of the request.
+**/
+
+const (
+	MODE_NONE   string = "none"   // every principal sees all namespaces
+	MODE_GROUPS string = "groups" // the namespaces are mapped to groups and users in kdd.yaml
+	MODE_RBAC   string = "rbac"   // the namespaces are checked with SubjectAccessReviews
+)
+
+// key of the namespace scope in the gin context
+const scope_key = "kdd.scope"
+
+// Authorizer - resolves the namespaces a principal is allowed to see
+type Authorizer interface {
+	Scope(ctx context.Context, principal *auth.Principal) (*models.NamespaceScope, error)
+}
+
+// Unrestricted - allows every principal to see all namespaces
+type Unrestricted struct{}
+
+func (Unrestricted) Scope(ctx context.Context, principal *auth.Principal) (*models.NamespaceScope, error) {
+	return models.AllNamespaces(), nil
+}
+
+// GetScope returns the namespace scope of the request, nil when the request is not restricted
+func GetScope(c *gin.Context) *models.NamespaceScope {
+	if scope, ok := c.Get(scope_key); ok {
+		return scope.(*models.NamespaceScope)
+	}
+
+	return nil
+}
+
+// Middleware resolves the namespace scope of the authenticated principal, failures are answered by reject.
+// Requests without principal are public and not restricted.
+func Middleware(authorizer Authorizer, reject func(c *gin.Context, err error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := auth.GetPrincipal(c)
+		if principal == nil {
+			c.Next()
+			return
+		}
+
+		scope, err := authorizer.Scope(c.Request.Context(), principal)
+		if err != nil {
+			zap.L().Error("could not resolve the namespaces of the principal", zap.String("name", principal.Name), zap.Error(err))
+			reject(c, fmt.Errorf("%w: could not authorize %s: %s", models.ErrUnavailable, principal.Name, err))
+			return
+		}
+
+		c.Set(scope_key, scope)
+		c.Next()
+	}
+}
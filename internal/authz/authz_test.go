@@ -0,0 +1,119 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorization_v1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+)
+
+func TestGroupMappings(t *testing.T) {
+	m := NewGroupMappings(&GroupMappingsConfig{Mappings: []Mapping{
+		{Group: "team-a", Namespaces: []string{"a", "a-staging"}},
+		{Group: "team-b", Namespaces: []string{"b"}},
+		{Group: "admins", Namespaces: []string{ALL_NAMESPACES}},
+		{User: "ci", Namespaces: []string{"ci"}},
+	}})
+
+	tests := []struct {
+		name       string
+		principal  *auth.Principal
+		restricted bool
+		namespaces []string
+	}{
+		{name: "groups", principal: &auth.Principal{Name: "jane", Groups: []string{"team-a", "team-b"}}, restricted: true, namespaces: []string{"a", "a-staging", "b"}},
+		{name: "user", principal: &auth.Principal{Name: "ci", Method: auth.METHOD_TOKEN}, restricted: true, namespaces: []string{"ci"}},
+		{name: "all", principal: &auth.Principal{Name: "joe", Groups: []string{"team-b", "admins"}}, restricted: false},
+		{name: "unmapped", principal: &auth.Principal{Name: "anonymous", Method: auth.METHOD_ANONYMOUS}, restricted: true, namespaces: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := m.Scope(context.Background(), tt.principal)
+			require.NoError(t, err)
+			assert.Equal(t, tt.restricted, scope.Restricted())
+			if tt.restricted {
+				assert.Equal(t, tt.namespaces, scope.Namespaces())
+			}
+		})
+	}
+}
+
+// newReviewServer returns a kubernetes api allowing the users to list pods in the namespaces, "" is the cluster wide access
+func newReviewServer(t *testing.T, allowed map[string][]string, requests *int32) kubernetes.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/apis/authorization.k8s.io/v1/subjectaccessreviews", r.URL.Path)
+		atomic.AddInt32(requests, 1)
+
+		var review authorization_v1.SubjectAccessReview
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+		assert.Equal(t, "list", review.Spec.ResourceAttributes.Verb)
+		assert.Equal(t, "pods", review.Spec.ResourceAttributes.Resource)
+
+		for _, namespace := range allowed[review.Spec.User] {
+			if namespace == review.Spec.ResourceAttributes.Namespace {
+				review.Status.Allowed = true
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(server.Close)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	return clientSet
+}
+
+func TestAccessReviews(t *testing.T) {
+	var requests int32
+	clientSet := newReviewServer(t, map[string][]string{"jane": {"a", "c"}, "admin": {""}}, &requests)
+	reviews, err := NewAccessReviews(&AccessReviewsConfig{
+		ClientSet:  clientSet,
+		Namespaces: func() ([]string, error) { return []string{"a", "b", "c"}, nil },
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	reviews.now = func() time.Time { return now }
+
+	jane := &auth.Principal{Name: "jane", Groups: []string{"team-a"}, Method: auth.METHOD_OIDC}
+	scope, err := reviews.Scope(context.Background(), jane)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, scope.Namespaces())
+	// the cluster wide access and every namespace is reviewed
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	// the scope is cached until the ttl passed
+	_, err = reviews.Scope(context.Background(), jane)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	now = now.Add(DEFAULT_CACHE_TTL)
+	_, err = reviews.Scope(context.Background(), jane)
+	require.NoError(t, err)
+	assert.Equal(t, int32(8), atomic.LoadInt32(&requests))
+
+	// the cluster wide access skips the reviews of the namespaces
+	scope, err = reviews.Scope(context.Background(), &auth.Principal{Name: "admin", Method: auth.METHOD_TOKEN})
+	require.NoError(t, err)
+	assert.False(t, scope.Restricted())
+	assert.Equal(t, int32(9), atomic.LoadInt32(&requests))
+
+	scope, err = reviews.Scope(context.Background(), &auth.Principal{Name: auth.METHOD_ANONYMOUS, Method: auth.METHOD_ANONYMOUS})
+	require.NoError(t, err)
+	assert.Empty(t, scope.Namespaces())
+	assert.True(t, scope.Restricted())
+}
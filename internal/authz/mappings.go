@@ -0,0 +1,57 @@
+package authz
+
+import (
+	"context"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// ALL_NAMESPACES allows a mapping to see every namespace
+const ALL_NAMESPACES = "*"
+
+// Mapping - the namespaces of a group or a user, either group or user is set
+type Mapping struct {
+	Group      string   // group of the OIDC groups claim
+	User       string   // name of the principal, e.g. the name of a static token or "anonymous"
+	Namespaces []string // ALL_NAMESPACES allows every namespace
+}
+
+type GroupMappingsConfig struct {
+	Mappings []Mapping
+}
+
+// GroupMappings - resolves the namespaces of the mappings matching the principal, principals without mapping see no namespace
+type GroupMappings struct {
+	groups map[string][]string
+	users  map[string][]string
+}
+
+func NewGroupMappings(cfg *GroupMappingsConfig) *GroupMappings {
+	m := &GroupMappings{groups: make(map[string][]string), users: make(map[string][]string)}
+	for _, mapping := range cfg.Mappings {
+		if mapping.Group != "" {
+			m.groups[mapping.Group] = append(m.groups[mapping.Group], mapping.Namespaces...)
+		}
+		if mapping.User != "" {
+			m.users[mapping.User] = append(m.users[mapping.User], mapping.Namespaces...)
+		}
+	}
+
+	return m
+}
+
+func (m *GroupMappings) Scope(ctx context.Context, principal *auth.Principal) (*models.NamespaceScope, error) {
+	namespaces := append([]string{}, m.users[principal.Name]...)
+	for _, group := range principal.Groups {
+		namespaces = append(namespaces, m.groups[group]...)
+	}
+
+	for _, namespace := range namespaces {
+		if namespace == ALL_NAMESPACES {
+			return models.AllNamespaces(), nil
+		}
+	}
+
+	return models.NewNamespaceScope(namespaces...), nil
+}
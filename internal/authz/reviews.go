@@ -0,0 +1,185 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authorization_v1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The namespaces of a principal are checked with SubjectAccessReviews, the service account of kdd needs to be allowed to create them.
+	A principal sees a namespace when it may list the pods of the namespace, the namespaces are only checked one by one without cluster wide access.
+	The OIDC principals are reviewed by their name and groups, the static tokens as user with the name of the token.
+**/
+
+const DEFAULT_CACHE_TTL = time.Minute
+
+// the access checked for every namespace
+const (
+	review_verb     = "list"
+	review_resource = "pods"
+)
+
+// review_concurrency limits the reviews sent at the same time for a principal
+const review_concurrency = 8
+
+// the user and group kubernetes assigns to requests without credentials
+const (
+	anonymous_user  = "system:anonymous"
+	anonymous_group = "system:unauthenticated"
+)
+
+// authenticated_group is assigned by kubernetes to every authenticated user
+const authenticated_group = "system:authenticated"
+
+type AccessReviewsConfig struct {
+	ClientSet  kubernetes.Interface
+	Namespaces func() ([]string, error) // the namespaces checked for principals without cluster wide access
+	CacheTTL   time.Duration            // how long the scope of a principal is kept, defaults to DEFAULT_CACHE_TTL
+}
+
+// AccessReviews - resolves the namespaces by the RBAC of kubernetes, the scopes are cached per principal
+type AccessReviews struct {
+	cfg   *AccessReviewsConfig
+	lock  sync.Mutex
+	cache map[string]cachedScope
+	now   func() time.Time
+}
+
+type cachedScope struct {
+	scope  *models.NamespaceScope
+	expiry time.Time
+}
+
+func NewAccessReviews(cfg *AccessReviewsConfig) (*AccessReviews, error) {
+	if cfg.ClientSet == nil || cfg.Namespaces == nil {
+		return nil, errors.New("client set and namespaces are required")
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DEFAULT_CACHE_TTL
+	}
+
+	return &AccessReviews{cfg: cfg, cache: make(map[string]cachedScope), now: time.Now}, nil
+}
+
+func (r *AccessReviews) Scope(ctx context.Context, principal *auth.Principal) (*models.NamespaceScope, error) {
+	user, groups := reviewSubject(principal)
+	key := user + "|" + strings.Join(groups, ",")
+
+	r.lock.Lock()
+	cached, ok := r.cache[key]
+	r.lock.Unlock()
+	if ok && r.now().Before(cached.expiry) {
+		return cached.scope, nil
+	}
+
+	scope, err := r.review(ctx, user, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.now()
+	for k, c := range r.cache {
+		if !now.Before(c.expiry) {
+			delete(r.cache, k)
+		}
+	}
+	r.cache[key] = cachedScope{scope: scope, expiry: now.Add(r.cfg.CacheTTL)}
+
+	return scope, nil
+}
+
+// review checks the cluster wide access first, the namespaces are checked one by one without it
+func (r *AccessReviews) review(ctx context.Context, user string, groups []string) (*models.NamespaceScope, error) {
+	allowed, err := r.allowed(ctx, user, groups, v1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	if allowed {
+		return models.AllNamespaces(), nil
+	}
+
+	namespaces, err := r.cfg.Namespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	visible := make([]string, 0)
+	semaphore := make(chan struct{}, review_concurrency)
+	for _, namespace := range namespaces {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(namespace string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			allowed, err := r.allowed(ctx, user, groups, namespace)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if allowed {
+				visible = append(visible, namespace)
+			}
+		}(namespace)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return models.NewNamespaceScope(visible...), nil
+}
+
+func (r *AccessReviews) allowed(ctx context.Context, user string, groups []string, namespace string) (bool, error) {
+	review := &authorization_v1.SubjectAccessReview{
+		Spec: authorization_v1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: groups,
+			ResourceAttributes: &authorization_v1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      review_verb,
+				Resource:  review_resource,
+			},
+		},
+	}
+
+	result, err := r.cfg.ClientSet.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, v1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Status.Allowed, nil
+}
+
+// reviewSubject returns the user and the sorted groups the principal is reviewed as
+func reviewSubject(principal *auth.Principal) (string, []string) {
+	if principal.Method == auth.METHOD_ANONYMOUS {
+		return anonymous_user, []string{anonymous_group}
+	}
+
+	groups := append([]string{authenticated_group}, principal.Groups...)
+	sort.Strings(groups)
+
+	return principal.Name, groups
+}
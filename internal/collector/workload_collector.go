@@ -3,8 +3,12 @@ package collector
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
 	"gitlab.com/patrick.erber/kdd/internal/models"
 	"go.uber.org/zap"
 	core_v1 "k8s.io/api/core/v1"
@@ -18,9 +22,36 @@ import (
 The collector package is responsible to collect informations from the workloads deployed in Kubernetes.
 */
 
+// kinds collected from kubernetes, a failed kind aborts the collection except for resource quotas and limit ranges
+const (
+	COLLECTION_KIND_NODE             string = "node"
+	COLLECTION_KIND_NAMESPACE        string = "namespace"
+	COLLECTION_KIND_RESOURCE_QUOTA   string = "resource_quota"
+	COLLECTION_KIND_LIMIT_RANGE      string = "limit_range"
+	COLLECTION_KIND_DEPLOYMENT       string = "deployment"
+	COLLECTION_KIND_DAEMONSET        string = "daemonset"
+	COLLECTION_KIND_STATEFULSET      string = "statefulset"
+	COLLECTION_KIND_POD              string = "pod"
+	COLLECTION_KIND_CONTAINER_METRIC string = "container_metric"
+)
+
+// COLLECTION_KINDS - the kinds in the order of collection
+var COLLECTION_KINDS = []string{
+	COLLECTION_KIND_NODE,
+	COLLECTION_KIND_NAMESPACE,
+	COLLECTION_KIND_RESOURCE_QUOTA,
+	COLLECTION_KIND_LIMIT_RANGE,
+	COLLECTION_KIND_DEPLOYMENT,
+	COLLECTION_KIND_DAEMONSET,
+	COLLECTION_KIND_STATEFULSET,
+	COLLECTION_KIND_POD,
+	COLLECTION_KIND_CONTAINER_METRIC,
+}
+
 type WorkloadCollectorConfig struct {
 	ClientSet         *kubernetes.Clientset
 	MertricsClientSet *metrics.Clientset
+	Checker           *health.Checker // records the result of every kind
 }
 
 // WorkloadCollector
@@ -36,34 +67,34 @@ func NewWorkloadCollector(cfg *WorkloadCollectorConfig) *WorkloadCollector {
 }
 
 type CollectorResult struct {
-	containerMetricsCollection *models.Collection
-	nodeCollection             *models.Collection
-	namespaceCollection        *models.Collection
-	workloadCollection         *models.Collection
+	containerMetricsCollection *models.MetricCollection
+	nodeCollection             *models.NodeCollection
+	namespaceCollection        *models.NamespaceCollection
+	workloadCollection         *models.WorkloadCollection
 }
 
 func NewCollectorResult() *CollectorResult {
 	return &CollectorResult{
-		containerMetricsCollection: models.NewCollection(),
-		nodeCollection:             models.NewCollection(),
-		namespaceCollection:        models.NewCollection(),
-		workloadCollection:         models.NewCollection(),
+		containerMetricsCollection: models.NewCollection[string, models.PodContainerMetric](),
+		nodeCollection:             models.NewCollection[string, models.Node](),
+		namespaceCollection:        models.NewCollection[string, models.Namespace](),
+		workloadCollection:         models.NewCollection[string, models.Workload](),
 	}
 }
 
-func (r *CollectorResult) GetNodeCollection() *models.Collection {
+func (r *CollectorResult) GetNodeCollection() *models.NodeCollection {
 	return r.nodeCollection
 }
 
-func (r *CollectorResult) GetContainerMetricsCollection() *models.Collection {
+func (r *CollectorResult) GetContainerMetricsCollection() *models.MetricCollection {
 	return r.containerMetricsCollection
 }
 
-func (r *CollectorResult) GetNamespaceCollection() *models.Collection {
+func (r *CollectorResult) GetNamespaceCollection() *models.NamespaceCollection {
 	return r.namespaceCollection
 }
 
-func (r *CollectorResult) GetWorkloadCollection() *models.Collection {
+func (r *CollectorResult) GetWorkloadCollection() *models.WorkloadCollection {
 	return r.workloadCollection
 }
 
@@ -72,30 +103,34 @@ func (w *WorkloadCollector) Collect() (*CollectorResult, error) {
 	result := NewCollectorResult()
 	zap.L().Debug("start requesting data")
 
-	if err := w.collectNodes(result.nodeCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_NODE, func() error { return w.collectNodes(result.nodeCollection) }); err != nil {
 		return nil, err
 	}
-	if err := w.collectNamspaces(result.namespaceCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_NAMESPACE, func() error { return w.collectNamspaces(result.namespaceCollection) }); err != nil {
 		return nil, err
 	}
 
-	if err := w.collectDeployments(result.workloadCollection); err != nil {
+	// the quotas and limit ranges only add to the namespaces, the namespaces are stored without them when they can't be listed
+	_ = w.observe(COLLECTION_KIND_RESOURCE_QUOTA, func() error { return w.collectResourceQuotas(result.namespaceCollection) })
+	_ = w.observe(COLLECTION_KIND_LIMIT_RANGE, func() error { return w.collectLimitRanges(result.namespaceCollection) })
+
+	if err := w.observe(COLLECTION_KIND_DEPLOYMENT, func() error { return w.collectDeployments(result.workloadCollection) }); err != nil {
 		return nil, err
 	}
 
-	if err := w.collectDaemonSets(result.workloadCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_DAEMONSET, func() error { return w.collectDaemonSets(result.workloadCollection) }); err != nil {
 		return nil, err
 	}
 
-	if err := w.collectStatefulSet(result.workloadCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_STATEFULSET, func() error { return w.collectStatefulSet(result.workloadCollection) }); err != nil {
 		return nil, err
 	}
 
-	if err := w.collectPods(result.workloadCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_POD, func() error { return w.collectPods(result.workloadCollection) }); err != nil {
 		return nil, err
 	}
 
-	if err := w.collectContainerMetrics(result.containerMetricsCollection); err != nil {
+	if err := w.observe(COLLECTION_KIND_CONTAINER_METRIC, func() error { return w.collectContainerMetrics(result.containerMetricsCollection) }); err != nil {
 		return nil, err
 	}
 
@@ -104,7 +139,21 @@ func (w *WorkloadCollector) Collect() (*CollectorResult, error) {
 	return result, nil
 }
 
-func (w *WorkloadCollector) collectNodes(collection *models.Collection) error {
+// observe records the duration and the result of collecting a kind
+func (w *WorkloadCollector) observe(kind string, collect func() error) error {
+	start := time.Now()
+	err := collect()
+	exporter.CollectionDuration.Observe(time.Since(start).Seconds(), kind)
+	if err != nil {
+		zap.L().Error("could not collect from kubernetes", zap.String("kind", kind), zap.Error(err))
+		exporter.CollectionErrors.Inc(kind)
+	}
+	w.cfg.Checker.Collected(kind, err)
+
+	return err
+}
+
+func (w *WorkloadCollector) collectNodes(collection *models.NodeCollection) error {
 	nodesList, err := w.cfg.ClientSet.CoreV1().Nodes().List(context.TODO(), v1.ListOptions{})
 	if err != nil {
 		return err
@@ -154,7 +203,7 @@ func (w *WorkloadCollector) collectNodes(collection *models.Collection) error {
 }
 
 // collectNamespaces this function is responsible to collect namespaces
-func (w *WorkloadCollector) collectNamspaces(collection *models.Collection) error {
+func (w *WorkloadCollector) collectNamspaces(collection *models.NamespaceCollection) error {
 	namespaces := w.cfg.ClientSet.CoreV1().Namespaces()
 	nsList, err := namespaces.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
@@ -168,13 +217,89 @@ func (w *WorkloadCollector) collectNamspaces(collection *models.Collection) erro
 			Name:              item.Name,
 			Labels:            item.Labels,
 			Annotations:       item.Annotations,
+			ResourceQuotas:    make([]models.ResourceQuota, 0),
+			LimitRanges:       make([]models.LimitRange, 0),
 		}, false)
 	}
 
 	return nil
 }
 
-func (w *WorkloadCollector) collectDeployments(collection *models.Collection) error {
+// collectResourceQuotas adds the resource quotas to the collected namespaces
+func (w *WorkloadCollector) collectResourceQuotas(collection *models.NamespaceCollection) error {
+	quotaList, err := w.cfg.ClientSet.CoreV1().ResourceQuotas("").List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range quotaList.Items {
+		namespace, ok := collection.Get(item.Namespace)
+		if !ok {
+			continue
+		}
+
+		quota := models.ResourceQuota{Name: item.Name, Resources: make([]models.QuotaResource, 0, len(item.Status.Hard))}
+		for name, hard := range item.Status.Hard {
+			used := item.Status.Used[name]
+
+			// quantities like cpu are fractional, the milli value keeps their precision
+			var utilization float64
+			if hard.MilliValue() > 0 {
+				utilization = float64(used.MilliValue()) / float64(hard.MilliValue()) * 100
+			}
+
+			quota.Resources = append(quota.Resources, models.QuotaResource{
+				Resource:    string(name),
+				Hard:        hard.String(),
+				Used:        used.String(),
+				Utilization: utilization,
+			})
+		}
+		sort.Slice(quota.Resources, func(i, j int) bool { return quota.Resources[i].Resource < quota.Resources[j].Resource })
+
+		namespace.ResourceQuotas = append(namespace.ResourceQuotas, quota)
+		collection.Set(item.Namespace, namespace, true)
+	}
+
+	return nil
+}
+
+// collectLimitRanges adds the limit ranges to the collected namespaces
+func (w *WorkloadCollector) collectLimitRanges(collection *models.NamespaceCollection) error {
+	limitRangeList, err := w.cfg.ClientSet.CoreV1().LimitRanges("").List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	values := func(list core_v1.ResourceList) models.ResourceValues {
+		return models.ResourceValues{CPU: list.Cpu().MilliValue(), Memory: list.Memory().Value()}
+	}
+
+	for _, item := range limitRangeList.Items {
+		namespace, ok := collection.Get(item.Namespace)
+		if !ok {
+			continue
+		}
+
+		limitRange := models.LimitRange{Name: item.Name, Limits: make([]models.LimitRangeItem, 0, len(item.Spec.Limits))}
+		for _, limit := range item.Spec.Limits {
+			limitRange.Limits = append(limitRange.Limits, models.LimitRangeItem{
+				Type:           string(limit.Type),
+				Min:            values(limit.Min),
+				Max:            values(limit.Max),
+				Default:        values(limit.Default),
+				DefaultRequest: values(limit.DefaultRequest),
+			})
+		}
+
+		namespace.LimitRanges = append(namespace.LimitRanges, limitRange)
+		collection.Set(item.Namespace, namespace, true)
+	}
+
+	return nil
+}
+
+func (w *WorkloadCollector) collectDeployments(collection *models.WorkloadCollection) error {
 	deploymentsClient := w.cfg.ClientSet.AppsV1().Deployments(v1.NamespaceAll)
 	deploymentList, err := deploymentsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
@@ -188,13 +313,14 @@ func (w *WorkloadCollector) collectDeployments(collection *models.Collection) er
 
 		err := collection.Set(fmt.Sprintf("%s_%s", deployment.ObjectMeta.Namespace, deployment.Name), models.DeploymentWorkload{
 			GeneralWorkloadInfo: models.GeneralWorkloadInfo{
-				Namespace:         deployment.ObjectMeta.Namespace,
-				WorkloadName:      deployment.Name,
-				Labels:            deployment.Labels,
-				Annotations:       deployment.Annotations,
-				Selector:          deployment.Spec.Selector.MatchLabels,
-				Containers:        containers,
-				CreationTimestamp: deployment.CreationTimestamp.Time,
+				Namespace:           deployment.ObjectMeta.Namespace,
+				WorkloadName:        deployment.Name,
+				Labels:              deployment.Labels,
+				Annotations:         deployment.Annotations,
+				Selector:            deployment.Spec.Selector.MatchLabels,
+				SelectorExpressions: models.NewLabelSelector(deployment.Spec.Selector).MatchExpressions,
+				Containers:          containers,
+				CreationTimestamp:   deployment.CreationTimestamp.Time,
 			},
 			Status: models.DeploymentStatus{
 				Desired:   int(*deployment.Spec.Replicas),
@@ -212,7 +338,7 @@ func (w *WorkloadCollector) collectDeployments(collection *models.Collection) er
 	return nil
 }
 
-func (w *WorkloadCollector) collectDaemonSets(collection *models.Collection) error {
+func (w *WorkloadCollector) collectDaemonSets(collection *models.WorkloadCollection) error {
 	daemonSetsClient := w.cfg.ClientSet.AppsV1().DaemonSets(v1.NamespaceAll)
 	daemonsetList, err := daemonSetsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
@@ -226,13 +352,14 @@ func (w *WorkloadCollector) collectDaemonSets(collection *models.Collection) err
 
 		err := collection.Set(fmt.Sprintf("%s_%s", daemonset.ObjectMeta.Namespace, daemonset.Name), models.DaemonSetWorkload{
 			GeneralWorkloadInfo: models.GeneralWorkloadInfo{
-				Namespace:         daemonset.ObjectMeta.Namespace,
-				WorkloadName:      daemonset.Name,
-				Labels:            daemonset.Labels,
-				Annotations:       daemonset.Annotations,
-				Selector:          daemonset.Spec.Selector.MatchLabels,
-				Containers:        containers,
-				CreationTimestamp: daemonset.CreationTimestamp.Time,
+				Namespace:           daemonset.ObjectMeta.Namespace,
+				WorkloadName:        daemonset.Name,
+				Labels:              daemonset.Labels,
+				Annotations:         daemonset.Annotations,
+				Selector:            daemonset.Spec.Selector.MatchLabels,
+				SelectorExpressions: models.NewLabelSelector(daemonset.Spec.Selector).MatchExpressions,
+				Containers:          containers,
+				CreationTimestamp:   daemonset.CreationTimestamp.Time,
 			},
 			Status: models.DaemonSetStatus{
 				Desired: int(daemonset.Status.DesiredNumberScheduled),
@@ -250,7 +377,7 @@ func (w *WorkloadCollector) collectDaemonSets(collection *models.Collection) err
 	return nil
 }
 
-func (w *WorkloadCollector) collectStatefulSet(collection *models.Collection) error {
+func (w *WorkloadCollector) collectStatefulSet(collection *models.WorkloadCollection) error {
 	statefulSetClient := w.cfg.ClientSet.AppsV1().StatefulSets(v1.NamespaceAll)
 	statefuleSetList, err := statefulSetClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
@@ -264,13 +391,14 @@ func (w *WorkloadCollector) collectStatefulSet(collection *models.Collection) er
 
 		err := collection.Set(fmt.Sprintf("%s_%s", statefulSet.ObjectMeta.Namespace, statefulSet.Name), models.StatefulSetWorkload{
 			GeneralWorkloadInfo: models.GeneralWorkloadInfo{
-				Namespace:         statefulSet.ObjectMeta.Namespace,
-				WorkloadName:      statefulSet.Name,
-				Labels:            statefulSet.Labels,
-				Annotations:       statefulSet.Annotations,
-				Selector:          statefulSet.Spec.Selector.MatchLabels,
-				Containers:        containers,
-				CreationTimestamp: statefulSet.CreationTimestamp.Time,
+				Namespace:           statefulSet.ObjectMeta.Namespace,
+				WorkloadName:        statefulSet.Name,
+				Labels:              statefulSet.Labels,
+				Annotations:         statefulSet.Annotations,
+				Selector:            statefulSet.Spec.Selector.MatchLabels,
+				SelectorExpressions: models.NewLabelSelector(statefulSet.Spec.Selector).MatchExpressions,
+				Containers:          containers,
+				CreationTimestamp:   statefulSet.CreationTimestamp.Time,
 			},
 			Status: models.StatefulSetStatus{
 				Available: int(statefulSet.Status.AvailableReplicas),
@@ -289,13 +417,15 @@ func (w *WorkloadCollector) collectStatefulSet(collection *models.Collection) er
 	return nil
 }
 
-func (w *WorkloadCollector) collectPods(collection *models.Collection) error {
+func (w *WorkloadCollector) collectPods(collection *models.WorkloadCollection) error {
 	podsClient := w.cfg.ClientSet.CoreV1().Pods(v1.NamespaceAll)
 	podsList, err := podsClient.List(context.TODO(), v1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
+	jobOwners := w.jobOwners()
+
 	for _, pod := range podsList.Items {
 		listOfContainers := pod.Spec.Containers
 		listOfInitContainers := pod.Spec.InitContainers
@@ -316,6 +446,9 @@ func (w *WorkloadCollector) collectPods(collection *models.Collection) error {
 				UID:        string(owner.UID),
 				Name:       owner.Name,
 			}
+			if owner.Kind == "Job" {
+				podOwnerRessources[i].Owner = jobOwners[fmt.Sprintf("%s_%s", pod.ObjectMeta.Namespace, owner.Name)]
+			}
 		}
 
 		err := collection.Set(fmt.Sprintf("%s_%s", pod.ObjectMeta.Namespace, pod.Name), models.PodWorkload{
@@ -330,6 +463,7 @@ func (w *WorkloadCollector) collectPods(collection *models.Collection) error {
 			PodOwnerRessources: podOwnerRessources,
 			Status:             string(pod.Status.Phase),
 			Restarts:           int(restarts),
+			NodeName:           pod.Spec.NodeName,
 		}, false)
 
 		if err != nil {
@@ -340,6 +474,32 @@ func (w *WorkloadCollector) collectPods(collection *models.Collection) error {
 	return nil
 }
 
+// jobOwners returns the cronjobs owning jobs by the namespace and name of the job.
+// Jobs are only listed to account their pods to the cronjobs, the pods are still collected when the jobs can't be listed.
+func (w *WorkloadCollector) jobOwners() map[string]*models.PodOwnerRessource {
+	owners := make(map[string]*models.PodOwnerRessource)
+	jobList, err := w.cfg.ClientSet.BatchV1().Jobs(v1.NamespaceAll).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		zap.L().Error("could not list jobs, the pods of cronjobs are accounted to their jobs", zap.Error(err))
+		return owners
+	}
+
+	for _, job := range jobList.Items {
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == "CronJob" {
+				owners[fmt.Sprintf("%s_%s", job.Namespace, job.Name)] = &models.PodOwnerRessource{
+					APIVersion: owner.APIVersion,
+					Kind:       owner.Kind,
+					UID:        string(owner.UID),
+					Name:       owner.Name,
+				}
+			}
+		}
+	}
+
+	return owners
+}
+
 func (*WorkloadCollector) buildContainerList(listOfContainers []core_v1.Container, listOfInitContainers []core_v1.Container, containerStatuses []core_v1.ContainerStatus) []models.Container {
 	containers := make([]models.Container, len(listOfContainers)+len(listOfInitContainers))
 	for i, container := range listOfContainers {
@@ -349,22 +509,30 @@ func (*WorkloadCollector) buildContainerList(listOfContainers []core_v1.Containe
 		}
 
 		restarts := 0
+		waitingReason := ""
+		var lastTermination *models.ContainerTermination
 		for _, containerStatus := range containerStatuses {
 			if container.Name == containerStatus.Name {
 				restarts = int(containerStatus.RestartCount)
+				lastTermination = models.NewContainerTermination(containerStatus.LastTerminationState.Terminated)
+				if containerStatus.State.Waiting != nil {
+					waitingReason = containerStatus.State.Waiting.Reason
+				}
 			}
 		}
 
 		containers[i] = models.Container{
-			Image:         imageParts[0],
-			ImageVersion:  imageParts[1],
-			ContainerName: container.Name,
-			LimitCPU:      container.Resources.Limits.Cpu().MilliValue(),
-			LimitMemory:   container.Resources.Limits.Memory().Value(),
-			RequestCPU:    container.Resources.Requests.Cpu().MilliValue(),
-			RequestMemory: container.Resources.Requests.Memory().Value(),
-			Restarts:      restarts,
-			InitContainer: false,
+			Image:           imageParts[0],
+			ImageVersion:    imageParts[1],
+			ContainerName:   container.Name,
+			LimitCPU:        container.Resources.Limits.Cpu().MilliValue(),
+			LimitMemory:     container.Resources.Limits.Memory().Value(),
+			RequestCPU:      container.Resources.Requests.Cpu().MilliValue(),
+			RequestMemory:   container.Resources.Requests.Memory().Value(),
+			Restarts:        restarts,
+			InitContainer:   false,
+			WaitingReason:   waitingReason,
+			LastTermination: lastTermination,
 		}
 	}
 
@@ -387,7 +555,7 @@ func (*WorkloadCollector) buildContainerList(listOfContainers []core_v1.Containe
 	return containers
 }
 
-func (w *WorkloadCollector) collectContainerMetrics(collection *models.Collection) error {
+func (w *WorkloadCollector) collectContainerMetrics(collection *models.MetricCollection) error {
 	metrics, err := w.cfg.MertricsClientSet.MetricsV1beta1().PodMetricses(v1.NamespaceAll).List(context.TODO(), v1.ListOptions{})
 	if err != nil {
 		zap.L().Error("could not load metrics data", zap.Error(err))
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
+
+	"gitlab.com/patrick.erber/kdd/internal/health"
+)
+
+// stubKubeAPI lists a single namespace and denies the resource quotas, every other list is empty
+func stubKubeAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var response any
+	switch r.URL.Path {
+	case "/api/v1/namespaces":
+		response = core_v1.NamespaceList{Items: []core_v1.Namespace{{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "default"},
+			Status:     core_v1.NamespaceStatus{Phase: core_v1.NamespaceActive},
+		}}}
+	case "/api/v1/resourcequotas":
+		w.WriteHeader(http.StatusForbidden)
+		response = meta_v1.Status{Status: meta_v1.StatusFailure, Reason: meta_v1.StatusReasonForbidden, Code: http.StatusForbidden}
+	default:
+		response = map[string]any{"items": []any{}}
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func TestCollectWithoutResourceQuotas(t *testing.T) {
+	kube := httptest.NewServer(http.HandlerFunc(stubKubeAPI))
+	t.Cleanup(kube.Close)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+	metricsClientSet, err := metrics.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+
+	checker := health.NewChecker(&health.CheckerConfig{Kinds: COLLECTION_KINDS, Ping: func(ctx context.Context) error { return nil }})
+	collector := NewWorkloadCollector(&WorkloadCollectorConfig{ClientSet: clientSet, MertricsClientSet: metricsClientSet, Checker: checker})
+
+	// the collection continues with the namespaces without quotas
+	result, err := collector.Collect()
+	require.NoError(t, err)
+	namespace, ok := result.GetNamespaceCollection().Get("default")
+	require.True(t, ok)
+	assert.Empty(t, namespace.ResourceQuotas)
+
+	for _, status := range checker.Status(context.Background()).Collections {
+		if status.Kind == COLLECTION_KIND_RESOURCE_QUOTA {
+			assert.NotEmpty(t, status.Error, "the failed kind is reported")
+		} else {
+			assert.Empty(t, status.Error, status.Kind)
+		}
+	}
+}
@@ -1,10 +1,164 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
 
 type AppConfig struct {
-	Namespaces []string
-	Workloads  []string
+	Namespaces    []string
+	Workloads     []string
+	History       HistoryConfig
+	Metrics       MetricsConfig
+	Watch         WatchConfig
+	Health        HealthConfig
+	Auth          AuthConfig
+	Authorization AuthorizationConfig
+	Audit         AuditConfig
+	Alerting      AlertingConfig
+	Costs         CostsConfig
+}
+
+// HistoryConfig configures the stored history which is used for point in time requests
+type HistoryConfig struct {
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"`
+	Retention        time.Duration `mapstructure:"retention"`
+}
+
+// MetricsConfig configures the rollups of the container metrics
+type MetricsConfig struct {
+	RollupInterval time.Duration          `mapstructure:"rollup_interval"`
+	Retention      MetricsRetentionConfig `mapstructure:"retention"`
+}
+
+// MetricsRetentionConfig configures how long the samples of each tier are kept
+type MetricsRetentionConfig struct {
+	Raw         time.Duration `mapstructure:"raw"`
+	OneMinute   time.Duration `mapstructure:"1m"`
+	FiveMinutes time.Duration `mapstructure:"5m"`
+	OneHour     time.Duration `mapstructure:"1h"`
+}
+
+// WatchConfig configures the change stream of the watch api
+type WatchConfig struct {
+	HistorySize int           `mapstructure:"history_size"`
+	Heartbeat   time.Duration `mapstructure:"heartbeat"`
+}
+
+// HealthConfig configures the liveness and readiness checks
+type HealthConfig struct {
+	Staleness       time.Duration `mapstructure:"staleness"`
+	LivenessTimeout time.Duration `mapstructure:"liveness_timeout"`
+}
+
+// AuthConfig configures the authentication of the requests
+type AuthConfig struct {
+	Anonymous bool              `mapstructure:"anonymous"`
+	Tokens    map[string]string `mapstructure:"tokens"` // static api tokens by name
+	OIDC      OIDCConfig        `mapstructure:"oidc"`
+}
+
+// OIDCConfig configures the login with an OIDC provider, the login is disabled without issuer
+type OIDCConfig struct {
+	Issuer        string        `mapstructure:"issuer"`
+	ClientID      string        `mapstructure:"client_id"`
+	ClientSecret  string        `mapstructure:"client_secret"`
+	RedirectURL   string        `mapstructure:"redirect_url"`
+	Scopes        []string      `mapstructure:"scopes"`
+	SessionSecret string        `mapstructure:"session_secret"`
+	SessionTTL    time.Duration `mapstructure:"session_ttl"`
+}
+
+// AuthorizationConfig configures which namespaces the authenticated principals can see
+type AuthorizationConfig struct {
+	Mode     string                   `mapstructure:"mode"`      // none, groups or rbac
+	CacheTTL time.Duration            `mapstructure:"cache_ttl"` // how long the rbac result of a principal is kept
+	Mappings []NamespaceMappingConfig `mapstructure:"mappings"`  // namespaces of the groups and users in the groups mode
+}
+
+// NamespaceMappingConfig maps namespaces to a group or a user, "*" maps all namespaces
+type NamespaceMappingConfig struct {
+	Group      string   `mapstructure:"group"`
+	User       string   `mapstructure:"user"`
+	Namespaces []string `mapstructure:"namespaces"`
+}
+
+// AuditConfig configures the audit log of the api requests
+type AuditConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Retention time.Duration `mapstructure:"retention"`
+	File      string        `mapstructure:"file"` // the entries are also appended as json lines when set
+}
+
+// AlertingConfig configures the alerting rules evaluated after each collection, alerting is disabled without rules
+type AlertingConfig struct {
+	Rules     []AlertRuleConfig     `mapstructure:"rules"`
+	Silences  []AlertSilenceConfig  `mapstructure:"silences"`
+	Receivers []AlertReceiverConfig `mapstructure:"receivers"`
+	Timeout   time.Duration         `mapstructure:"timeout"` // of a single notification
+}
+
+// AlertRuleConfig configures a rule, the options used depend on the kind
+type AlertRuleConfig struct {
+	Name       string        `mapstructure:"name"`
+	Kind       string        `mapstructure:"kind"` // restarts, crashloop, unavailable, cronjob, pending or usage
+	For        time.Duration `mapstructure:"for"`
+	Threshold  float64       `mapstructure:"threshold"` // restarts within the window or usage in percent of the limit
+	Window     time.Duration `mapstructure:"window"`
+	MaxAge     time.Duration `mapstructure:"max_age"`
+	Resource   string        `mapstructure:"resource"` // cpu or memory
+	Namespaces []string      `mapstructure:"namespaces"`
+	Selector   string        `mapstructure:"selector"` // label selector of the workloads
+	Severity   string        `mapstructure:"severity"`
+	GroupBy    []string      `mapstructure:"group_by"`
+	Receivers  []string      `mapstructure:"receivers"`
+}
+
+// AlertSilenceConfig configures a silence of the alerts matching the selector
+type AlertSilenceConfig struct {
+	Selector string `mapstructure:"selector"` // label selector of the alert labels, rule and severity included
+	Until    string `mapstructure:"until"`    // RFC3339, the silence never expires when empty
+	Comment  string `mapstructure:"comment"`
+}
+
+// AlertReceiverConfig configures where notifications are sent
+type AlertReceiverConfig struct {
+	Name         string            `mapstructure:"name"`
+	Type         string            `mapstructure:"type"` // webhook, slack or smtp
+	SendResolved *bool             `mapstructure:"send_resolved"`
+	URL          string            `mapstructure:"url"`
+	Headers      map[string]string `mapstructure:"headers"`
+	Channel      string            `mapstructure:"channel"`
+	Username     string            `mapstructure:"username"`
+	SMTP         SMTPConfig        `mapstructure:"smtp"`
+}
+
+// SMTPConfig configures the mail server of a smtp receiver
+type SMTPConfig struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// CostsConfig configures the prices of the cost allocation, the first matching node price applies and the default prices otherwise
+type CostsConfig struct {
+	Currency     string            `mapstructure:"currency"`
+	CPUHour      float64           `mapstructure:"cpu_hour"`       // price per vCPU hour
+	MemoryGBHour float64           `mapstructure:"memory_gb_hour"` // price per GiB hour
+	NodePrices   []NodePriceConfig `mapstructure:"node_prices"`
+}
+
+// NodePriceConfig configures the prices of the nodes matching the selector, e.g. by instance type or spot nodes
+type NodePriceConfig struct {
+	Selector     string  `mapstructure:"selector"` // label selector of the node labels
+	CPUHour      float64 `mapstructure:"cpu_hour"`
+	MemoryGBHour float64 `mapstructure:"memory_gb_hour"`
 }
 
 func GetConfig(configPath string, configName string) *AppConfig {
@@ -12,11 +166,55 @@ func GetConfig(configPath string, configName string) *AppConfig {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(configPath)
 	viper.SetConfigName(configName)
+
+	viper.SetDefault("history.snapshot_interval", time.Hour)
+	viper.SetDefault("history.retention", time.Hour*24*7)
+	viper.SetDefault("metrics.rollup_interval", time.Minute)
+	viper.SetDefault("metrics.retention.raw", time.Hour*24)
+	viper.SetDefault("metrics.retention.1m", time.Hour*24*7)
+	viper.SetDefault("metrics.retention.5m", time.Hour*24*30)
+	viper.SetDefault("metrics.retention.1h", time.Hour*24*365)
+	viper.SetDefault("watch.history_size", 4096)
+	viper.SetDefault("watch.heartbeat", time.Second*15)
+	viper.SetDefault("health.staleness", time.Minute*2)
+	viper.SetDefault("health.liveness_timeout", time.Minute*10)
+	viper.SetDefault("auth.anonymous", false)
+	viper.SetDefault("auth.oidc.session_ttl", time.Hour*12)
+	viper.SetDefault("authorization.mode", "none")
+	viper.SetDefault("authorization.cache_ttl", time.Minute)
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.retention", time.Hour*24*30)
+	viper.SetDefault("alerting.timeout", time.Second*10)
+	viper.SetDefault("costs.currency", "USD")
+	viper.SetDefault("costs.cpu_hour", 0.031611)
+	viper.SetDefault("costs.memory_gb_hour", 0.004237)
+	// secrets can be passed by the environment instead of the config file
+	_ = viper.BindEnv("auth.oidc.client_secret", "KDD_OIDC_CLIENT_SECRET")
+	_ = viper.BindEnv("auth.oidc.session_secret", "KDD_OIDC_SESSION_SECRET")
+
+	if err := viper.ReadInConfig(); err != nil {
+		zap.L().Warn("could not read config file, using defaults", zap.Error(err))
+	}
+
 	err := viper.Unmarshal(&cfg)
 
 	if err != nil {
 		return nil
 	}
 
+	if err := cfg.validate(); err != nil {
+		zap.L().Error("invalid configuration", zap.Error(err))
+		return nil
+	}
+
 	return &cfg
 }
+
+// validate checks the values which can't be used, e.g. intervals of tickers
+func (c *AppConfig) validate() error {
+	if c.Metrics.RollupInterval <= 0 {
+		return fmt.Errorf("metrics.rollup_interval must be positive: %s", c.Metrics.RollupInterval)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := AppConfig{Metrics: MetricsConfig{RollupInterval: time.Minute}}
+	assert.NoError(t, cfg.validate())
+
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		cfg.Metrics.RollupInterval = interval
+		assert.Error(t, cfg.validate(), "the rollup ticker requires a positive interval")
+	}
+}
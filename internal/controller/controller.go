@@ -1,16 +1,26 @@
 package controller
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
+	"gitlab.com/patrick.erber/kdd/internal/alerting"
 	"gitlab.com/patrick.erber/kdd/internal/collector"
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
 	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
 	"go.uber.org/zap"
 )
 
 /**
 	controller package is responsible to manage the syncing interval & provides informationen for prometheus endpoints.
+	The stored workloads are handed to the exporter, the cluster state served at /metrics follows the data store.
+	Cronjobs are not collected, they are loaded from kubernetes for the cronjob gauges on every sync.
+	After each collection the alerting rules are evaluated and the allocations of the costs are recorded.
 **/
 
 // Controller - Managing the application
@@ -20,14 +30,31 @@ type Controller struct {
 	wlc      *collector.WorkloadCollector
 	ds       *persistence.DataStore
 	interval time.Duration
+	history  config.HistoryConfig
+	metrics  config.MetricsConfig
+	broker   *watch.Broker
+	checker  *health.Checker
+	alerts   *alerting.Engine // nil when alerting is disabled
+	cronjobs func() (*models.WorkloadCollection, error)
+	// last stored collections, the changes to them are published to the broker
+	nodes      *models.NodeCollection
+	namespaces *models.NamespaceCollection
+	workloads  *models.WorkloadCollection
+	samples    *models.MetricCollection
 }
 
 // NewController create a new controller Instance
-func NewController(wlc *collector.WorkloadCollector, ds *persistence.DataStore, interval time.Duration) *Controller {
+func NewController(wlc *collector.WorkloadCollector, ds *persistence.DataStore, broker *watch.Broker, checker *health.Checker, alerts *alerting.Engine, cronjobs func() (*models.WorkloadCollection, error), interval time.Duration, history config.HistoryConfig, metrics config.MetricsConfig) *Controller {
 	return &Controller{
 		wlc:      wlc,
 		interval: interval,
 		ds:       ds,
+		broker:   broker,
+		checker:  checker,
+		alerts:   alerts,
+		cronjobs: cronjobs,
+		history:  history,
+		metrics:  metrics,
 	}
 }
 
@@ -42,37 +69,28 @@ func (c *Controller) Run(done <-chan struct{}) {
 	go func(<-chan struct{}) {
 		defer wg.Done()
 		zap.L().Debug("start collecting initial data")
-		res, err := c.wlc.Collect()
-		c.ds.ReplaceNodes(res.GetNodeCollection())
-		c.ds.ReplaceNamespaces(res.GetNamespaceCollection())
-		c.ds.ReplaceWorkloads(res.GetWorkloadCollection())
-		c.ds.UpdateMetrics(res.GetContainerMetricsCollection())
-		if err != nil {
-			zap.L().Error("could not fetch data from kubernetes", zap.Error(err))
-		} else {
-			zap.L().Debug("finished collecting initial data")
-		}
+		c.sync()
+		zap.L().Debug("finished collecting initial data")
 
 		ticker := time.NewTicker(c.interval)
+		rollupTicker := time.NewTicker(c.metrics.RollupInterval)
 		for {
 			select {
 			case <-done:
 				zap.L().Debug("shutting down collector")
 				ticker.Stop()
+				rollupTicker.Stop()
 				return
 			case <-ticker.C:
 				zap.L().Debug("start collecting data")
-				res, err := c.wlc.Collect()
-				c.ds.ReplaceNodes(res.GetNodeCollection())
-				c.ds.ReplaceNamespaces(res.GetNamespaceCollection())
-				c.ds.ReplaceWorkloads(res.GetWorkloadCollection())
-				c.ds.UpdateMetrics(res.GetContainerMetricsCollection())
-				if err != nil {
-					zap.L().Error("could not fetch data from kubernetes", zap.Error(err))
-				} else {
-					zap.L().Debug("finished collecting initial data")
-				}
+				c.sync()
 				zap.L().Debug("finished collecting data")
+			case <-rollupTicker.C:
+				zap.L().Debug("start rollup of metrics")
+				if err := c.ds.RollupMetrics(time.Now()); err != nil {
+					zap.L().Error("could not rollup metrics", zap.Error(err))
+				}
+				zap.L().Debug("finished rollup of metrics")
 			}
 		}
 	}(done)
@@ -80,6 +98,123 @@ func (c *Controller) Run(done <-chan struct{}) {
 	c.stop()
 }
 
+// sync collects the data from kubernetes and stores it
+func (c *Controller) sync() {
+	c.checker.SyncStarted()
+
+	res, err := c.wlc.Collect()
+	if err != nil {
+		zap.L().Error("could not fetch data from kubernetes", zap.Error(err))
+		c.checker.SyncFinished(fmt.Errorf("could not fetch data from kubernetes: %w", err))
+		return
+	}
+
+	now := time.Now()
+	events := make([]models.WatchEvent, 0)
+	// the first failed write, the sync is only successful when all collections were stored
+	var failed error
+	exporter.LastSync.Set(float64(now.Unix()))
+
+	if err := c.ds.ReplaceNodes(res.GetNodeCollection()); err != nil {
+		zap.L().Error("could not store nodes", zap.Error(err))
+		if failed == nil {
+			failed = fmt.Errorf("could not store nodes: %w", err)
+		}
+		exporter.StoreErrors.Inc(models.WATCH_KIND_NODE)
+	} else {
+		if c.nodes != nil {
+			events = append(events, watch.CollectionEvents(models.WATCH_KIND_NODE, c.nodes, res.GetNodeCollection(), func(models.Node) string { return "" }, now)...)
+		}
+		c.nodes = res.GetNodeCollection()
+	}
+
+	if err := c.ds.ReplaceNamespaces(res.GetNamespaceCollection()); err != nil {
+		zap.L().Error("could not store namespaces", zap.Error(err))
+		if failed == nil {
+			failed = fmt.Errorf("could not store namespaces: %w", err)
+		}
+		exporter.StoreErrors.Inc(models.WATCH_KIND_NAMESPACE)
+	} else {
+		if c.namespaces != nil {
+			events = append(events, watch.CollectionEvents(models.WATCH_KIND_NAMESPACE, c.namespaces, res.GetNamespaceCollection(), func(n models.Namespace) string { return n.Name }, now)...)
+		}
+		c.namespaces = res.GetNamespaceCollection()
+	}
+
+	if err := c.ds.ReplaceWorkloads(res.GetWorkloadCollection()); err != nil {
+		zap.L().Error("could not store workloads", zap.Error(err))
+		if failed == nil {
+			failed = fmt.Errorf("could not store workloads: %w", err)
+		}
+		exporter.StoreErrors.Inc(models.WATCH_KIND_WORKLOAD)
+	} else {
+		if c.workloads != nil {
+			events = append(events, watch.CollectionEvents(models.WATCH_KIND_WORKLOAD, c.workloads, res.GetWorkloadCollection(), models.Workload.GetNamespace, now)...)
+		}
+		c.workloads = res.GetWorkloadCollection()
+		exporter.Cluster.Update(c.workloads)
+	}
+
+	// the gauges keep the last loaded cronjobs until they can be loaded again
+	if cronjobs, err := c.cronjobs(); err != nil {
+		zap.L().Error("could not load cronjobs", zap.Error(err))
+	} else {
+		exporter.Cluster.UpdateCronjobs(cronjobs)
+	}
+
+	if err := c.ds.UpdateMetrics(res.GetContainerMetricsCollection()); err != nil {
+		zap.L().Error("could not store metrics", zap.Error(err))
+		if failed == nil {
+			failed = fmt.Errorf("could not store metrics: %w", err)
+		}
+		exporter.StoreErrors.Inc(models.WATCH_KIND_METRIC)
+	} else {
+		if c.samples != nil {
+			events = append(events, watch.MetricEvents(c.samples, res.GetContainerMetricsCollection(), now)...)
+		}
+		c.samples = res.GetContainerMetricsCollection()
+	}
+
+	// the allocations of the costs are recorded from the collected state, also when it could not be stored
+	if err := c.ds.RecordAllocations(res.GetNodeCollection(), res.GetWorkloadCollection(), now); err != nil {
+		zap.L().Error("could not record allocations", zap.Error(err))
+	}
+
+	// the first sync is the baseline of the changes, clients load the current state from the lists
+	c.broker.Publish(events)
+	c.checker.SyncFinished(failed)
+
+	// the alerts are evaluated on the collected state, also when it could not be stored
+	if c.alerts != nil {
+		c.alerts.Evaluate(res.GetWorkloadCollection(), res.GetContainerMetricsCollection(), now)
+	}
+
+	c.maintainHistory()
+}
+
+// maintainHistory creates a snapshot when the snapshot interval elapsed and removes history older than the retention
+func (c *Controller) maintainHistory() {
+	latest, err := c.ds.GetLatestSnapshotTimestamp()
+	if err != nil {
+		zap.L().Error("could not load latest snapshot", zap.Error(err))
+		return
+	}
+
+	if latest != nil && time.Since(*latest) < c.history.SnapshotInterval {
+		return
+	}
+
+	zap.L().Debug("creating snapshot")
+	if err := c.ds.CreateSnapshot(); err != nil {
+		zap.L().Error("could not create snapshot", zap.Error(err))
+		return
+	}
+
+	if err := c.ds.RemoveHistoryBefore(time.Now().Add(-c.history.Retention)); err != nil {
+		zap.L().Error("could not remove old history", zap.Error(err))
+	}
+}
+
 // hasStarted checks if the controller is already started
 func (c *Controller) hasStarted() bool {
 	c.lock.Lock()
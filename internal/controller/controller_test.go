@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batch_v1 "k8s.io/api/batch/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
+
+	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/collector"
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+// stubKubeAPI lists a single cronjob which succeeded an hour ago, every other list is empty
+func stubKubeAPI(lastSuccess time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var response any = map[string]any{"items": []any{}}
+		if r.URL.Path == "/apis/batch/v1/cronjobs" {
+			response = batch_v1.CronJobList{Items: []batch_v1.CronJob{{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "nightly", Namespace: "batch"},
+				Spec:       batch_v1.CronJobSpec{Schedule: "0 2 * * *"},
+				Status:     batch_v1.CronJobStatus{LastSuccessfulTime: &meta_v1.Time{Time: lastSuccess}},
+			}}}
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+func TestSyncLoadsCronjobs(t *testing.T) {
+	kube := httptest.NewServer(stubKubeAPI(time.Now().Add(-time.Hour)))
+	t.Cleanup(kube.Close)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+	metricsClientSet, err := metrics.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+
+	ds, err := persistence.NewSQLiteDataStore(filepath.Join(t.TempDir(), "controller.sqlite"), config.MetricsRetentionConfig{
+		Raw:         time.Hour * 24,
+		OneMinute:   time.Hour * 24 * 7,
+		FiveMinutes: time.Hour * 24 * 30,
+		OneHour:     time.Hour * 24 * 365,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	checker := health.NewChecker(&health.CheckerConfig{Kinds: collector.COLLECTION_KINDS, Ping: func(ctx context.Context) error { return nil }})
+	wlc := collector.NewWorkloadCollector(&collector.WorkloadCollectorConfig{ClientSet: clientSet, MertricsClientSet: metricsClientSet, Checker: checker})
+	ka := adapters.NewKubeAPIAdapter(&adapters.KubeAPIAdapterConfig{ClientSet: clientSet})
+
+	c := NewController(wlc, ds, watch.NewBroker(&watch.BrokerConfig{}), checker, nil, func() (*models.WorkloadCollection, error) { return ka.GetCronjobs("") },
+		time.Second, config.HistoryConfig{Retention: time.Hour}, config.MetricsConfig{})
+	c.sync()
+
+	r := exporter.NewRegistry()
+	r.Register(exporter.Cluster)
+	var out bytes.Buffer
+	require.NoError(t, r.Write(&out))
+
+	sample := `kdd_cronjob_last_successful_age_seconds{namespace="batch",cronjob="nightly"} `
+	var age float64
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, sample) {
+			age, err = strconv.ParseFloat(strings.TrimPrefix(line, sample), 64)
+			require.NoError(t, err)
+		}
+	}
+	// the age is rendered at the scrape
+	assert.InDelta(t, time.Hour.Seconds(), age, 60, out.String())
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The export package writes lists in the formats used by spreadsheets and log tools instead of the json envelope of the api.
+	csv and xlsx flatten the items into the columns of a Table, ndjson writes every item as json line.
+	The output is streamed, the rows are written and flushed to the client in batches while the list is encoded.
+**/
+
+const (
+	FORMAT_JSON   = "json"
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+	FORMAT_XLSX   = "xlsx"
+)
+
+// media_types of the formats, used to negotiate the format by the Accept header
+var media_types = map[string]string{
+	FORMAT_JSON:   "application/json",
+	FORMAT_CSV:    "text/csv",
+	FORMAT_NDJSON: "application/x-ndjson",
+	FORMAT_XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// the buffered rows are flushed to the client after this number of rows
+const flush_rows = 500
+
+// MediaType returns the media type of the format
+func MediaType(format string) string {
+	return media_types[format]
+}
+
+// Negotiate returns the format requested by the format query parameter or else by the Accept header, json is the default.
+// An unknown format parameter is an error, unknown media types of the Accept header are ignored.
+func Negotiate(format string, accept string) (string, error) {
+	if format != "" {
+		format = strings.ToLower(format)
+		if _, ok := media_types[format]; !ok {
+			return "", fmt.Errorf("%w: unsupported format: %s", models.ErrInvalidFilter, format)
+		}
+		return format, nil
+	}
+
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType = strings.TrimSpace(strings.Split(mediaType, ";")[0])
+		for format, value := range media_types {
+			if strings.EqualFold(mediaType, value) {
+				return format, nil
+			}
+		}
+	}
+
+	return FORMAT_JSON, nil
+}
+
+// encoder - writes the rows of a format
+type encoder interface {
+	header(columns []string) error
+	row(values []any) error
+	flush() error
+	close() error
+}
+
+// Write streams the items in the format to w, the items are reduced to the fields.
+// The name is used as sheet name of xlsx files. When w is a http.Flusher the rows are sent to the client in batches.
+func Write[T any](w io.Writer, format string, name string, items []T, fields []string) error {
+	return WriteEach(w, format, name, func(fn func(T) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, fields)
+}
+
+// WriteEach is Write of the items passed to fn by each, every item is written before the next one is read
+func WriteEach[T any](w io.Writer, format string, name string, each func(fn func(T) error) error, fields []string) error {
+	out := bufio.NewWriter(w)
+	flush := func() error {
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if format == FORMAT_NDJSON || format == FORMAT_JSON {
+		return writeLines(out, flush, each, fields)
+	}
+
+	var enc encoder
+	switch format {
+	case FORMAT_CSV:
+		enc = newCSVEncoder(out)
+	case FORMAT_XLSX:
+		enc = newXLSXEncoder(out, name)
+	default:
+		return fmt.Errorf("%w: unsupported format: %s", models.ErrInvalidFilter, format)
+	}
+
+	table := NewTable(reflect.TypeOf((*T)(nil)).Elem(), fields)
+	if err := enc.header(table.Columns()); err != nil {
+		return err
+	}
+
+	written := 0
+	if err := each(func(item T) error {
+		rows, err := table.Rows(item)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := enc.row(row); err != nil {
+				return err
+			}
+			if written++; written%flush_rows == 0 {
+				if err := enc.flush(); err != nil {
+					return err
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := enc.close(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// writeLines writes every item as json line
+func writeLines[T any](out *bufio.Writer, flush func() error, each func(fn func(T) error) error, fields []string) error {
+	encoder := json.NewEncoder(out)
+	written := 0
+	if err := each(func(item T) error {
+		value, err := models.ProjectItem(item, fields)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+
+		if written++; written%flush_rows == 0 {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) header(columns []string) error {
+	e.record = make([]string, len(columns))
+	return e.w.Write(columns)
+}
+
+// row writes the values, text starting like a formula is prefixed with a quote so spreadsheets do not evaluate it
+func (e *csvEncoder) row(values []any) error {
+	for i, value := range values {
+		text := Format(value)
+		if _, ok := value.(json.Number); !ok && text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+			text = "'" + text
+		}
+		e.record[i] = text
+	}
+
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) close() error {
+	return e.flush()
+}
@@ -0,0 +1,149 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+var export_created = time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+
+func exportWorkloads() []models.Workload {
+	return []models.Workload{
+		models.DeploymentWorkload{
+			GeneralWorkloadInfo: models.GeneralWorkloadInfo{
+				WorkloadName: "web",
+				Namespace:    "default",
+				Labels:       map[string]string{"tier": "frontend", "app": "web"},
+				Containers: []models.Container{
+					{ContainerName: "web", Image: "nginx", RequestCPU: 100, LimitMemory: 8 * 1024 * 1024 * 1024},
+					{ContainerName: "init", Image: "busybox", InitContainer: true},
+				},
+				CreationTimestamp: export_created,
+			},
+			Status: models.DeploymentStatus{Desired: 2, Ready: 1},
+		},
+		models.PodWorkload{
+			GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "=cmd", Namespace: "default", CreationTimestamp: export_created},
+			Status:              "Running",
+			Restarts:            3,
+		},
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	format, err := Negotiate("", "text/html, text/csv;q=0.9")
+	require.NoError(t, err)
+	assert.Equal(t, FORMAT_CSV, format)
+
+	format, err = Negotiate("XLSX", "text/csv")
+	require.NoError(t, err)
+	assert.Equal(t, FORMAT_XLSX, format, "the parameter wins over the header")
+
+	format, err = Negotiate("", "*/*")
+	require.NoError(t, err)
+	assert.Equal(t, FORMAT_JSON, format)
+
+	_, err = Negotiate("pdf", "")
+	assert.ErrorIs(t, err, models.ErrInvalidFilter)
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FORMAT_CSV, "workloads", exportWorkloads(), nil))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4, "header, a row per container of the deployment and a row for the pod without containers")
+
+	value := func(record int, column string) string {
+		for i, name := range records[0] {
+			if name == column {
+				return records[record][i]
+			}
+		}
+		t.Fatalf("missing column %s", column)
+		return ""
+	}
+
+	// the columns of all workload types are exported
+	for _, column := range []string{"status.up2date", "status.current", "restarts", "schedule", "status.last_successful_time"} {
+		value(0, column)
+	}
+
+	assert.Equal(t, "Deployment", value(1, "type"))
+	assert.Equal(t, "app=web,tier=frontend", value(1, "workload_info.labels"))
+	assert.Equal(t, "2", value(1, "status.desired"))
+	assert.Equal(t, "", value(1, "status"))
+	assert.Equal(t, "web", value(1, "workload_info.containers.container_name"))
+	assert.Equal(t, "8589934592", value(1, "workload_info.containers.limit_memory"))
+	assert.Equal(t, "init", value(2, "workload_info.containers.container_name"))
+	assert.Equal(t, "true", value(2, "workload_info.containers.init_container"))
+	assert.Equal(t, "2023-01-22T10:00:00Z", value(2, "workload_info.creation_date"))
+
+	assert.Equal(t, "Running", value(3, "status"))
+	assert.Equal(t, "", value(3, "status.desired"))
+	assert.Equal(t, "'=cmd", value(3, "workload_info.workload_name"), "formulas are not evaluated by spreadsheets")
+	assert.Equal(t, "", value(3, "workload_info.containers.container_name"))
+
+	// the fields select the columns in their order, a single row is exported per item without container columns
+	buf.Reset()
+	require.NoError(t, Write(&buf, FORMAT_CSV, "workloads", exportWorkloads(), []string{"workload_info.workload_name", "workload_info.labels.app", "status.ready"}))
+	records, err = csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"workload_info.workload_name", "workload_info.labels.app", "status.ready"},
+		{"web", "web", "1"},
+		{"'=cmd", "", ""},
+	}, records)
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FORMAT_NDJSON, "workloads", exportWorkloads(), []string{"type", "restarts"}))
+	assert.Equal(t, "{\"type\":\"Deployment\"}\n{\"restarts\":3,\"type\":\"Pod\"}\n", buf.String())
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FORMAT_XLSX, "workloads", exportWorkloads(), []string{"workload_info.workload_name", "workload_info.containers.request_cpu"}))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		parts[file.Name] = string(content)
+
+		// every part is well-formed xml
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				require.ErrorIs(t, err, io.EOF, file.Name)
+				break
+			}
+		}
+	}
+
+	require.Contains(t, parts, "[Content_Types].xml")
+	assert.Contains(t, parts["xl/workbook.xml"], `name="workloads"`)
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Equal(t, 4, strings.Count(sheet, "<row>"))
+	assert.Contains(t, sheet, `<c><v>100</v></c>`, "numbers are numeric cells")
+	assert.Contains(t, sheet, `<t xml:space="preserve">=cmd</t>`, "inline strings are not evaluated")
+}
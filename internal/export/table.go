@@ -0,0 +1,305 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// expanded_lists are the json paths of lists which are exported as one row per element, the other columns are repeated in every row
+var expanded_lists = map[string]bool{
+	"workload_info.containers": true, // the resources of every container, e.g. workload_info.containers.request_cpu
+	"changes":                  true, // the changed fields of a workload revision
+}
+
+// workload_types are the implementations of models.Workload, a list of workloads has the columns of all of them
+var workload_types = []reflect.Type{
+	reflect.TypeOf(models.DeploymentWorkload{}),
+	reflect.TypeOf(models.DaemonSetWorkload{}),
+	reflect.TypeOf(models.StatefulSetWorkload{}),
+	reflect.TypeOf(models.PodWorkload{}),
+	reflect.TypeOf(models.JobWorkload{}),
+	reflect.TypeOf(models.CronjobWorkload{}),
+}
+
+var (
+	workload_type = reflect.TypeOf((*models.Workload)(nil)).Elem()
+	time_type     = reflect.TypeOf(time.Time{})
+)
+
+// column - a flattened attribute, addressed by its dotted json path
+type column struct {
+	path     string
+	element  string // path relative to the element of the expanded list, empty for the attributes of the item
+	scalar   bool   // the attribute is a string, number, bool or timestamp
+	expanded bool
+}
+
+// Table - the columns of a list of items.
+// The columns are derived from the type of the items and not from their values, so every export of a list has the same columns.
+type Table struct {
+	columns []column
+	expand  string // json path of the expanded list, empty when every item is a single row
+}
+
+// NewTable returns the table of items of type t reduced to the fields, all columns are kept when no field is given
+func NewTable(t reflect.Type, fields []string) *Table {
+	table := &Table{}
+	seen := make(map[string]bool)
+
+	if t == workload_type {
+		// the type is added by the json representation of the workloads
+		table.add(column{path: "type", scalar: true}, seen)
+		for _, workload := range workload_types {
+			table.addFields(workload, "", seen)
+		}
+	} else {
+		table.addFields(t, "", seen)
+	}
+
+	if len(fields) > 0 {
+		table.columns = table.project(fields)
+	}
+
+	for _, c := range table.columns {
+		if c.expanded {
+			return table
+		}
+	}
+	table.expand = ""
+
+	return table
+}
+
+// Columns returns the header of the table
+func (t *Table) Columns() []string {
+	columns := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		columns[i] = c.path
+	}
+
+	return columns
+}
+
+// Rows returns the values of the columns of an item, an item with an expanded list returns a row per element.
+// Numbers are returned as json.Number, lists and objects which are not split into columns as they are.
+func (t *Table) Rows(item any) ([][]any, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	value := make(map[string]any)
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if t.expand == "" {
+		return [][]any{t.row(value, nil)}, nil
+	}
+
+	list, _ := models.LookupField(value, t.expand)
+	elements, _ := list.([]any)
+	if len(elements) == 0 {
+		return [][]any{t.row(value, nil)}, nil
+	}
+
+	rows := make([][]any, len(elements))
+	for i, element := range elements {
+		object, _ := element.(map[string]any)
+		rows[i] = t.row(value, object)
+	}
+
+	return rows, nil
+}
+
+func (t *Table) row(item map[string]any, element map[string]any) []any {
+	row := make([]any, len(t.columns))
+	for i, c := range t.columns {
+		var value any
+		if c.expanded {
+			value, _ = models.LookupField(element, c.element)
+		} else {
+			value, _ = models.LookupField(item, c.path)
+		}
+
+		// the column is shared by workload types with a different representation, e.g. the status of pods and deployments
+		switch value.(type) {
+		case map[string]any, []any:
+			if c.scalar {
+				value = nil
+			}
+		}
+		row[i] = value
+	}
+
+	return row
+}
+
+func (t *Table) add(c column, seen map[string]bool) {
+	if seen[c.path] {
+		return
+	}
+
+	seen[c.path] = true
+	t.columns = append(t.columns, c)
+}
+
+// addFields adds the columns of the exported fields of a struct, nested structs are flattened
+func (t *Table) addFields(s reflect.Type, prefix string, seen map[string]bool) {
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		// embedded structs without json name are inlined
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			t.addFields(fieldType, prefix, seen)
+			continue
+		}
+
+		t.addField(fieldType, join(prefix, name), seen)
+	}
+}
+
+func (t *Table) addField(fieldType reflect.Type, path string, seen map[string]bool) {
+	switch {
+	case fieldType == time_type:
+		t.add(column{path: path, scalar: true}, seen)
+	case fieldType.Kind() == reflect.Struct:
+		t.addFields(fieldType, path, seen)
+	case fieldType.Kind() == reflect.Slice && expanded_lists[path] && fieldType.Elem().Kind() == reflect.Struct:
+		t.expand = path
+		element := &Table{}
+		element.addFields(fieldType.Elem(), "", make(map[string]bool))
+		for _, c := range element.columns {
+			t.add(column{path: join(path, c.path), element: c.path, scalar: c.scalar, expanded: true}, seen)
+		}
+	case fieldType.Kind() == reflect.Slice, fieldType.Kind() == reflect.Array, fieldType.Kind() == reflect.Map, fieldType.Kind() == reflect.Interface:
+		t.add(column{path: path}, seen)
+	default:
+		t.add(column{path: path, scalar: true}, seen)
+	}
+}
+
+// project returns the columns of the fields in the order of the fields.
+// A field addresses all columns below it, a field below a list or object column (e.g. labels.app) becomes a column of its own.
+func (t *Table) project(fields []string) []column {
+	columns := make([]column, 0)
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		found := false
+		for _, c := range t.columns {
+			if c.path == field || strings.HasPrefix(c.path, field+".") {
+				found = true
+				if !seen[c.path] {
+					seen[c.path] = true
+					columns = append(columns, c)
+				}
+			}
+		}
+		if found || seen[field] {
+			continue
+		}
+
+		for _, c := range t.columns {
+			if !c.scalar && !c.expanded && strings.HasPrefix(field, c.path+".") {
+				seen[field] = true
+				columns = append(columns, column{path: field})
+				break
+			}
+		}
+	}
+
+	return columns
+}
+
+// Format returns the text of a value returned by Rows.
+// Objects of scalars are joined as key=value pairs sorted by key and lists of scalars by commas, e.g. the labels app=web,tier=db.
+// Other objects and lists are returned as json.
+func Format(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key, item := range v {
+			if !isScalar(item) {
+				return formatJSON(v)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, len(keys))
+		for i, key := range keys {
+			pairs[i] = key + "=" + Format(v[key])
+		}
+		return strings.Join(pairs, ",")
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			if !isScalar(item) {
+				return formatJSON(v)
+			}
+			items[i] = Format(item)
+		}
+		return strings.Join(items, ",")
+	}
+
+	return formatJSON(value)
+}
+
+func isScalar(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return false
+	}
+
+	return true
+}
+
+func formatJSON(value any) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return string(raw)
+}
+
+func join(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+/**
+	A xlsx file is a zip archive of xml parts. The workbook contains a single sheet with the header in the frozen first row.
+	Text is written as inline strings, so the rows can be streamed without collecting a shared string table first.
+**/
+
+// max_cell_length is the maximum number of characters of a cell, longer text (e.g. annotations) is truncated
+const max_cell_length = 32767
+
+const xlsx_content_types = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+const xlsx_rels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsx_workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsx_workbook_rels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+const xlsx_sheet_start = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`
+
+const xlsx_sheet_end = `</sheetData></worksheet>`
+
+type xlsxEncoder struct {
+	zip   *zip.Writer
+	name  string
+	sheet io.Writer
+	err   error
+}
+
+func newXLSXEncoder(w io.Writer, name string) *xlsxEncoder {
+	return &xlsxEncoder{zip: zip.NewWriter(w), name: name}
+}
+
+// header writes the parts of the workbook and starts the sheet, it needs to be the last part of the archive
+func (e *xlsxEncoder) header(columns []string) error {
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{name: "[Content_Types].xml", content: xlsx_content_types},
+		{name: "_rels/.rels", content: xlsx_rels},
+		{name: "xl/workbook.xml", content: fmt.Sprintf(xlsx_workbook, escape(sheetName(e.name)))},
+		{name: "xl/_rels/workbook.xml.rels", content: xlsx_workbook_rels},
+	}
+	for _, part := range parts {
+		w, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	e.write(xlsx_sheet_start)
+
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+
+	return e.row(values)
+}
+
+// row writes the values, numbers are written as numeric cells and everything else as text
+func (e *xlsxEncoder) row(values []any) error {
+	e.write("<row>")
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			e.write("<c/>")
+		case json.Number:
+			e.write(`<c><v>` + v.String() + `</v></c>`)
+		default:
+			e.write(`<c t="inlineStr"><is><t xml:space="preserve">` + escape(truncate(Format(v))) + `</t></is></c>`)
+		}
+	}
+	e.write("</row>")
+
+	return e.err
+}
+
+func (e *xlsxEncoder) flush() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.zip.Flush()
+}
+
+func (e *xlsxEncoder) close() error {
+	e.write(xlsx_sheet_end)
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.zip.Close()
+}
+
+// write keeps the first error, so the cells of a row can be written without checking every write
+func (e *xlsxEncoder) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.sheet, s)
+	}
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= max_cell_length {
+		return s
+	}
+
+	return string([]rune(s)[:max_cell_length])
+}
+
+// sheetName removes the characters not allowed in sheet names and shortens the name to 31 characters
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "export"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+
+	return name
+}
@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The cluster state is exposed in the style of kube-state-metrics.
+	It is rendered from the collection the controller stored last, a scrape never reads the database.
+**/
+
+// ClusterState - renders the gauges of the last stored workloads and the last loaded cronjobs
+type ClusterState struct {
+	lock      sync.RWMutex
+	workloads *models.WorkloadCollection
+	cronjobs  *models.WorkloadCollection
+	now       func() time.Time
+}
+
+// NewClusterState creates an empty cluster state, no samples are written until the first update
+func NewClusterState() *ClusterState {
+	return &ClusterState{now: time.Now}
+}
+
+// Update replaces the workloads the gauges are rendered from
+func (s *ClusterState) Update(workloads *models.WorkloadCollection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.workloads = workloads
+}
+
+// UpdateCronjobs replaces the cronjobs the gauges are rendered from, they are not part of the collected workloads
+func (s *ClusterState) UpdateCronjobs(cronjobs *models.WorkloadCollection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cronjobs = cronjobs
+}
+
+func (s *ClusterState) Collect(w *Writer) {
+	s.lock.RLock()
+	workloads := s.workloads
+	cronjobs := s.cronjobs
+	s.lock.RUnlock()
+
+	list := make([]models.Workload, 0)
+	if workloads != nil {
+		list = workloads.SortedList(models.WorkloadNameLess)
+	}
+	cronjobList := make([]models.Workload, 0)
+	if cronjobs != nil {
+		cronjobList = cronjobs.SortedList(models.WorkloadNameLess)
+	}
+	now := s.now()
+
+	w.Family("kdd_workload_replicas_desired", "Number of desired replicas of a deployment, daemonset or statefulset.", METRIC_TYPE_GAUGE)
+	forEachReplicated(list, func(labels []Label, desired int, _ int) {
+		w.Sample("kdd_workload_replicas_desired", labels, float64(desired))
+	})
+
+	w.Family("kdd_workload_replicas_ready", "Number of ready replicas of a deployment, daemonset or statefulset.", METRIC_TYPE_GAUGE)
+	forEachReplicated(list, func(labels []Label, _ int, ready int) {
+		w.Sample("kdd_workload_replicas_ready", labels, float64(ready))
+	})
+
+	w.Family("kdd_pod_container_restarts_total", "Number of restarts of a container of a pod.", METRIC_TYPE_COUNTER)
+	for _, workload := range list {
+		if workload.GetType() != models.WORKLOAD_TYPE_POD {
+			continue
+		}
+		for _, container := range workload.GetContainers() {
+			if container.InitContainer {
+				continue
+			}
+			w.Sample("kdd_pod_container_restarts_total", []Label{
+				{Name: "namespace", Value: workload.GetNamespace()},
+				{Name: "pod", Value: workload.GetWorkloadName()},
+				{Name: "container", Value: container.ContainerName},
+			}, float64(container.Restarts))
+		}
+	}
+
+	w.Family("kdd_cronjob_last_successful_age_seconds", "Seconds since the last successful run of a cronjob.", METRIC_TYPE_GAUGE)
+	for _, workload := range cronjobList {
+		cronjob, ok := workload.(models.CronjobWorkload)
+		if !ok || cronjob.Status.LastSuccessfulTime == nil {
+			continue
+		}
+		w.Sample("kdd_cronjob_last_successful_age_seconds", []Label{
+			{Name: "namespace", Value: cronjob.Namespace},
+			{Name: "cronjob", Value: cronjob.WorkloadName},
+		}, now.Sub(*cronjob.Status.LastSuccessfulTime).Seconds())
+	}
+
+	// cpu is stored in millicores, exposed in cores like kube-state-metrics
+	w.Family("kdd_container_resource_requests", "Resources requested by a container of a workload, cpu in cores and memory in bytes.", METRIC_TYPE_GAUGE)
+	forEachResource(list, func(c models.Container) (int64, int64) { return c.RequestCPU, c.RequestMemory }, func(labels []Label, value float64) {
+		w.Sample("kdd_container_resource_requests", labels, value)
+	})
+
+	w.Family("kdd_container_resource_limits", "Resource limits of a container of a workload, cpu in cores and memory in bytes.", METRIC_TYPE_GAUGE)
+	forEachResource(list, func(c models.Container) (int64, int64) { return c.LimitCPU, c.LimitMemory }, func(labels []Label, value float64) {
+		w.Sample("kdd_container_resource_limits", labels, value)
+	})
+}
+
+func workloadLabels(workload models.Workload) []Label {
+	return []Label{
+		{Name: "namespace", Value: workload.GetNamespace()},
+		{Name: "workload", Value: workload.GetWorkloadName()},
+		{Name: "type", Value: workload.GetType()},
+	}
+}
+
+// forEachReplicated calls f with the desired and ready replicas of every replicated workload
+func forEachReplicated(list []models.Workload, f func(labels []Label, desired int, ready int)) {
+	for _, workload := range list {
+		switch w := workload.(type) {
+		case models.DeploymentWorkload:
+			f(workloadLabels(w), w.Status.Desired, w.Status.Ready)
+		case models.DaemonSetWorkload:
+			f(workloadLabels(w), w.Status.Desired, w.Status.Ready)
+		case models.StatefulSetWorkload:
+			f(workloadLabels(w), w.Status.Replicas, w.Status.Ready)
+		}
+	}
+}
+
+// forEachResource calls f for every cpu and memory value of the containers, unset values are omitted
+func forEachResource(list []models.Workload, resources func(models.Container) (int64, int64), f func(labels []Label, value float64)) {
+	for _, workload := range list {
+		for _, container := range workload.GetContainers() {
+			cpu, memory := resources(container)
+			labels := func(resource string, unit string) []Label {
+				return append(workloadLabels(workload),
+					Label{Name: "container", Value: container.ContainerName},
+					Label{Name: "resource", Value: resource},
+					Label{Name: "unit", Value: unit})
+			}
+			if cpu > 0 {
+				f(labels("cpu", "core"), float64(cpu)/1000)
+			}
+			if memory > 0 {
+				f(labels("memory", "byte"), float64(memory))
+			}
+		}
+	}
+}
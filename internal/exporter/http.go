@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware records the latency of the requests, streams are excluded since they last as long as the client is connected
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		if c.IsWebsocket() || c.Writer.Header().Get("Content-Type") == "text/event-stream" {
+			return
+		}
+
+		// the route pattern keeps the number of series bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+)
+
+// DefaultRegistry - the registry served at /metrics
+var DefaultRegistry = NewRegistry()
+
+// Cluster - the cluster state of the last stored collections, updated by the controller
+var Cluster = NewClusterState()
+
+var (
+	// CollectionDuration - duration of requesting a kind from kubernetes, labels: kind
+	CollectionDuration = DefaultRegistry.NewHistogramVec("kdd_collection_duration_seconds", "Duration of collecting the objects of a kind from kubernetes.", DEFAULT_BUCKETS, "kind")
+	// CollectionErrors - failed requests of a kind to kubernetes, labels: kind
+	CollectionErrors = DefaultRegistry.NewCounterVec("kdd_collection_errors_total", "Number of failed collections of a kind from kubernetes.", "kind")
+	// StoreErrors - failed writes of collected objects to the data store, labels: kind
+	StoreErrors = DefaultRegistry.NewCounterVec("kdd_store_errors_total", "Number of failed writes of collected objects to the data store.", "kind")
+	// LastSync - time of the last successful collection, labels: none
+	LastSync = DefaultRegistry.NewGaugeVec("kdd_last_sync_timestamp_seconds", "Unix time of the last successful collection from kubernetes.")
+	// HTTPRequestDuration - latency of the http requests, labels: method, route, code
+	HTTPRequestDuration = DefaultRegistry.NewHistogramVec("kdd_http_request_duration_seconds", "Latency of the http requests served by kdd.", DEFAULT_BUCKETS, "method", "route", "code")
+	// AuditDropped - audit entries which could not be stored, labels: none
+	AuditDropped = DefaultRegistry.NewCounterVec("kdd_audit_dropped_total", "Number of audit entries which could not be stored.")
+	// AlertsFiring - firing alerts after the last evaluation, labels: rule
+	AlertsFiring = DefaultRegistry.NewGaugeVec("kdd_alerts_firing", "Number of firing alerts of an alerting rule.", "rule")
+	// AlertNotifications - notifications sent to the receivers, labels: receiver, result
+	AlertNotifications = DefaultRegistry.NewCounterVec("kdd_alert_notifications_total", "Number of alert notifications sent to a receiver, by result (success or error).", "receiver", "result")
+)
+
+func init() {
+	DefaultRegistry.Register(Cluster)
+}
+
+// RegisterDataStore exposes the size of the database of the data store
+func RegisterDataStore(r *Registry, ds *persistence.DataStore) {
+	r.NewGaugeFunc("kdd_database_size_bytes", "Size of the sqlite database in bytes, the write-ahead log is not included.", func() (float64, error) {
+		size, err := ds.Size()
+		return float64(size), err
+	})
+}
@@ -0,0 +1,324 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+/**
+	The exporter package provides the metrics of kdd in the prometheus text exposition format.
+	kdd's own metrics are recorded in metric vectors, the cluster state is rendered from the last stored collections on every scrape.
+**/
+
+// CONTENT_TYPE - the content type of the prometheus text exposition format
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	METRIC_TYPE_COUNTER   string = "counter"
+	METRIC_TYPE_GAUGE     string = "gauge"
+	METRIC_TYPE_HISTOGRAM string = "histogram"
+)
+
+// DEFAULT_BUCKETS - upper bounds of the duration histograms in seconds
+var DEFAULT_BUCKETS = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Collector - writes its metric families on every scrape
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Registry - the collectors exposed by the handler, families are written in the order of registration
+type Registry struct {
+	lock       sync.Mutex
+	collectors []Collector
+}
+
+// Label - a label of a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer - writes the families and samples of a scrape
+type Writer struct {
+	out *bufio.Writer
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make([]Collector, 0)}
+}
+
+// Register adds the collector to the registry
+func (r *Registry) Register(c Collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes the metrics of all collectors in the text exposition format
+func (r *Registry) Write(out io.Writer) error {
+	r.lock.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.lock.Unlock()
+
+	w := &Writer{out: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+
+	return w.out.Flush()
+}
+
+// Handler returns the http handler serving the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", CONTENT_TYPE)
+		if err := r.Write(rw); err != nil {
+			zap.L().Debug("could not write metrics", zap.Error(err))
+		}
+	})
+}
+
+// Family writes the header of a metric family, the samples of the family need to follow
+func (w *Writer) Family(name string, help string, metricType string) {
+	fmt.Fprintf(w.out, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+}
+
+// Sample writes a single sample
+func (w *Writer) Sample(name string, labels []Label, value float64) {
+	w.out.WriteString(name)
+	if len(labels) > 0 {
+		w.out.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.out.WriteByte(',')
+			}
+			fmt.Fprintf(w.out, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+		}
+		w.out.WriteByte('}')
+	}
+	w.out.WriteByte(' ')
+	w.out.WriteString(formatValue(value))
+	w.out.WriteByte('\n')
+}
+
+// metric - the common part of the metric vectors, series are identified by their label values
+type metric struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	lock       sync.Mutex
+	series     map[string]*series
+}
+
+type series struct {
+	labels  []string
+	value   float64
+	buckets []uint64 // cumulative counts of the histogram buckets
+	count   uint64
+}
+
+func newMetric(name string, help string, metricType string, labels []string) *metric {
+	return &metric{name: name, help: help, metricType: metricType, labels: labels, series: make(map[string]*series)}
+}
+
+// get returns the series of the label values, the lock needs to be held
+func (m *metric) get(values []string, buckets int) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects the labels %v, got %d values", m.name, m.labels, len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labels: append([]string{}, values...), buckets: make([]uint64, buckets)}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+// sorted returns the series ordered by their label values, the lock needs to be held
+func (m *metric) sorted() []*series {
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*series, len(keys))
+	for i, key := range keys {
+		result[i] = m.series[key]
+	}
+
+	return result
+}
+
+func (m *metric) labelsOf(s *series, extra ...Label) []Label {
+	labels := make([]Label, 0, len(m.labels)+len(extra))
+	for i, name := range m.labels {
+		labels = append(labels, Label{Name: name, Value: s.labels[i]})
+	}
+
+	return append(labels, extra...)
+}
+
+func (m *metric) Collect(w *Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	w.Family(m.name, m.help, m.metricType)
+	for _, s := range m.sorted() {
+		w.Sample(m.name, m.labelsOf(s), s.value)
+	}
+}
+
+// CounterVec - counters partitioned by labels
+type CounterVec struct {
+	*metric
+}
+
+// NewCounterVec creates a counter vector and registers it
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{metric: newMetric(name, help, METRIC_TYPE_COUNTER, labels)}
+	r.Register(c)
+	return c
+}
+
+// Add adds the value to the counter of the label values, counters can not decrease
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("counter %s can not decrease", c.name))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.get(labelValues, 0).value += value
+}
+
+// Inc increments the counter of the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec - gauges partitioned by labels
+type GaugeVec struct {
+	*metric
+}
+
+// NewGaugeVec creates a gauge vector and registers it
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{metric: newMetric(name, help, METRIC_TYPE_GAUGE, labels)}
+	r.Register(g)
+	return g
+}
+
+// Set sets the gauge of the label values
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.get(labelValues, 0).value = value
+}
+
+// HistogramVec - histograms partitioned by labels
+type HistogramVec struct {
+	*metric
+	bounds []float64
+}
+
+// NewHistogramVec creates a histogram vector with the sorted upper bounds of the buckets and registers it
+func (r *Registry) NewHistogramVec(name string, help string, bounds []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{metric: newMetric(name, help, METRIC_TYPE_HISTOGRAM, labels), bounds: bounds}
+	r.Register(h)
+	return h
+}
+
+// Observe adds the value to the histogram of the label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s := h.get(labelValues, len(h.bounds))
+	for i, bound := range h.bounds {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+func (h *HistogramVec) Collect(w *Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	w.Family(h.name, h.help, h.metricType)
+	for _, s := range h.sorted() {
+		for i, bound := range h.bounds {
+			w.Sample(h.name+"_bucket", h.labelsOf(s, Label{Name: "le", Value: formatValue(bound)}), float64(s.buckets[i]))
+		}
+		w.Sample(h.name+"_bucket", h.labelsOf(s, Label{Name: "le", Value: "+Inf"}), float64(s.count))
+		w.Sample(h.name+"_sum", h.labelsOf(s), s.value)
+		w.Sample(h.name+"_count", h.labelsOf(s), float64(s.count))
+	}
+}
+
+// GaugeFunc - a gauge without labels whose value is read on every scrape
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() (float64, error)
+}
+
+// NewGaugeFunc creates a gauge reading its value on every scrape and registers it, the sample is omitted when the value can not be read
+func (r *Registry) NewGaugeFunc(name string, help string, value func() (float64, error)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+	r.Register(g)
+	return g
+}
+
+func (g *GaugeFunc) Collect(w *Writer) {
+	w.Family(g.name, g.help, METRIC_TYPE_GAUGE)
+
+	value, err := g.value()
+	if err != nil {
+		zap.L().Error("could not read metric", zap.String("metric", g.name), zap.Error(err))
+		return
+	}
+	w.Sample(g.name, nil, value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var help_replacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var label_value_replacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return help_replacer.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return label_value_replacer.Replace(value)
+}
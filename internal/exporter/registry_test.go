@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	errs := r.NewCounterVec("test_errors_total", "Errors\nper kind.", "kind")
+	duration := r.NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "kind")
+	r.NewGaugeFunc("test_size_bytes", "Size.", func() (float64, error) { return 1024, nil })
+	r.NewGaugeFunc("test_broken", "Broken.", func() (float64, error) { return 0, errors.New("broken") })
+
+	errs.Inc("pod")
+	errs.Add(2, `a"b\c`)
+	duration.Observe(0.05, "pod")
+	duration.Observe(0.5, "pod")
+
+	var out bytes.Buffer
+	require.NoError(t, r.Write(&out))
+	assert.Equal(t, `# HELP test_errors_total Errors\nper kind.
+# TYPE test_errors_total counter
+test_errors_total{kind="a\"b\\c"} 2
+test_errors_total{kind="pod"} 1
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{kind="pod",le="0.1"} 1
+test_duration_seconds_bucket{kind="pod",le="1"} 2
+test_duration_seconds_bucket{kind="pod",le="+Inf"} 2
+test_duration_seconds_sum{kind="pod"} 0.55
+test_duration_seconds_count{kind="pod"} 2
+# HELP test_size_bytes Size.
+# TYPE test_size_bytes gauge
+test_size_bytes 1024
+# HELP test_broken Broken.
+# TYPE test_broken gauge
+`, out.String())
+
+	assert.Panics(t, func() { errs.Inc() })
+	assert.Panics(t, func() { errs.Add(-1, "pod") })
+}
+
+func TestClusterState(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 0, 0, 0, time.UTC)
+	lastSuccess := now.Add(-time.Minute)
+
+	workloads := models.NewCollection[string, models.Workload]()
+	workloads.Set("deployment", models.DeploymentWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "web", Namespace: "default", Containers: []models.Container{
+			{ContainerName: "app", RequestCPU: 250, RequestMemory: 64 << 20, LimitMemory: 128 << 20},
+		}},
+		Status: models.DeploymentStatus{Desired: 3, Ready: 2},
+	}, true)
+	workloads.Set("pod", models.PodWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "web-1", Namespace: "default", Containers: []models.Container{
+			{ContainerName: "app", Restarts: 4},
+			{ContainerName: "init", InitContainer: true},
+		}},
+	}, true)
+	cronjobs := models.NewCollection[string, models.Workload]()
+	cronjobs.Set("cronjob", models.CronjobWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "nightly", Namespace: "batch"},
+		Status:              models.CronjobStatus{LastSuccessfulTime: &lastSuccess},
+	}, true)
+
+	r := NewRegistry()
+	state := NewClusterState()
+	state.now = func() time.Time { return now }
+	r.Register(state)
+
+	var out bytes.Buffer
+	require.NoError(t, r.Write(&out))
+	assert.NotContains(t, out.String(), "web", "no samples before the first update")
+
+	state.Update(workloads)
+	state.UpdateCronjobs(cronjobs)
+	out.Reset()
+	require.NoError(t, r.Write(&out))
+
+	for _, sample := range []string{
+		`kdd_workload_replicas_desired{namespace="default",workload="web",type="Deployment"} 3`,
+		`kdd_workload_replicas_ready{namespace="default",workload="web",type="Deployment"} 2`,
+		`kdd_pod_container_restarts_total{namespace="default",pod="web-1",container="app"} 4`,
+		`kdd_cronjob_last_successful_age_seconds{namespace="batch",cronjob="nightly"} 60`,
+		`kdd_container_resource_requests{namespace="default",workload="web",type="Deployment",container="app",resource="cpu",unit="core"} 0.25`,
+		`kdd_container_resource_requests{namespace="default",workload="web",type="Deployment",container="app",resource="memory",unit="byte"} 6.7108864e+07`,
+		`kdd_container_resource_limits{namespace="default",workload="web",type="Deployment",container="app",resource="memory",unit="byte"} 1.34217728e+08`,
+	} {
+		assert.Contains(t, out.String(), sample+"\n")
+	}
+	assert.NotContains(t, out.String(), `container="init"}`)
+	assert.NotContains(t, out.String(), `kdd_container_resource_limits{namespace="default",workload="web",type="Deployment",container="app",resource="cpu"`)
+}
@@ -0,0 +1,180 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The health package tracks the collections from kubernetes and the database for the probes and the status api.
+	kdd is live as long as the controller keeps syncing, it is ready once current data was stored and the database answers.
+**/
+
+const (
+	DEFAULT_STALENESS        = time.Minute * 2
+	DEFAULT_LIVENESS_TIMEOUT = time.Minute * 10
+)
+
+// time the database may take to answer a probe
+const ping_timeout = time.Second * 2
+
+type CheckerConfig struct {
+	Kinds           []string                        // collected kinds, every kind needs a current collection to be ready
+	Staleness       time.Duration                   // a kind is stale when its last successful collection is older
+	LivenessTimeout time.Duration                   // the controller is considered stuck when no sync started within
+	Ping            func(ctx context.Context) error // checks the database
+}
+
+// Checker - records the syncs of the controller and the collections of the collector
+type Checker struct {
+	cfg  *CheckerConfig
+	lock sync.RWMutex
+	now  func() time.Time
+	// start of the last sync, the creation of the checker before the first sync
+	syncStarted time.Time
+	lastSync    *time.Time
+	lastError   string
+	collections map[string]*models.CollectionStatus
+}
+
+// NewChecker creates a checker, the zero values of the config are replaced by the defaults
+func NewChecker(cfg *CheckerConfig) *Checker {
+	if cfg.Staleness <= 0 {
+		cfg.Staleness = DEFAULT_STALENESS
+	}
+	if cfg.LivenessTimeout <= 0 {
+		cfg.LivenessTimeout = DEFAULT_LIVENESS_TIMEOUT
+	}
+
+	c := &Checker{cfg: cfg, now: time.Now, collections: make(map[string]*models.CollectionStatus)}
+	for _, kind := range cfg.Kinds {
+		c.collections[kind] = &models.CollectionStatus{Kind: kind}
+	}
+	c.syncStarted = c.now()
+
+	return c
+}
+
+// SyncStarted records the start of a sync
+func (c *Checker) SyncStarted() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.syncStarted = c.now()
+}
+
+// SyncFinished records the end of a sync, a sync succeeded when all kinds were collected and stored
+func (c *Checker) SyncFinished(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		c.lastError = err.Error()
+		return
+	}
+
+	now := c.now()
+	c.lastSync = &now
+	c.lastError = ""
+}
+
+// Collected records the result of collecting a kind
+func (c *Checker) Collected(kind string, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	status, ok := c.collections[kind]
+	if !ok {
+		status = &models.CollectionStatus{Kind: kind}
+		c.collections[kind] = status
+	}
+
+	now := c.now()
+	status.LastAttempt = &now
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.LastSuccess = &now
+}
+
+// Live returns an error when the controller did not start a sync within the liveness timeout
+func (c *Checker) Live() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if since := c.now().Sub(c.syncStarted); since > c.cfg.LivenessTimeout {
+		return fmt.Errorf("no sync started for %s", since.Round(time.Second))
+	}
+
+	return nil
+}
+
+// Status returns the sync status, the database is pinged on every call
+func (c *Checker) Status(ctx context.Context) models.SyncStatus {
+	ctx, cancel := context.WithTimeout(ctx, ping_timeout)
+	defer cancel()
+
+	database := models.DatabaseStatus{Reachable: true}
+	if err := c.cfg.Ping(ctx); err != nil {
+		zap.L().Error("database is not reachable", zap.Error(err))
+		database = models.DatabaseStatus{Error: err.Error()}
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	now := c.now()
+	status := models.SyncStatus{
+		Synced:      c.lastSync != nil,
+		LastSync:    c.lastSync,
+		LastError:   c.lastError,
+		Database:    database,
+		Collections: make([]models.CollectionStatus, 0, len(c.collections)),
+		CheckedAt:   now,
+	}
+
+	stale := false
+	for _, collection := range c.collections {
+		current := *collection
+		current.Stale = current.LastSuccess == nil || now.Sub(*current.LastSuccess) > c.cfg.Staleness
+		stale = stale || current.Stale
+		status.Collections = append(status.Collections, current)
+	}
+	sort.Slice(status.Collections, func(i, j int) bool { return status.Collections[i].Kind < status.Collections[j].Kind })
+
+	status.Ready = status.Synced && !stale && database.Reachable
+
+	return status
+}
+
+// Healthz responds to the liveness probe
+func (c *Checker) Healthz(ctx *gin.Context) {
+	if err := c.Live(); err != nil {
+		zap.L().Error("liveness check failed", zap.Error(err))
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz responds to the readiness probe with the sync status
+func (c *Checker) Readyz(ctx *gin.Context) {
+	status := c.Status(ctx.Request.Context())
+	if !status.Ready {
+		ctx.JSON(http.StatusServiceUnavailable, status)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
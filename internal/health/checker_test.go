@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerReadiness(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 0, 0, 0, time.UTC)
+	var pingErr error
+
+	checker := NewChecker(&CheckerConfig{
+		Kinds:     []string{"node", "pod"},
+		Staleness: time.Minute,
+		Ping:      func(context.Context) error { return pingErr },
+	})
+	checker.now = func() time.Time { return now }
+
+	// not ready before the initial collection
+	status := checker.Status(context.Background())
+	assert.False(t, status.Ready)
+	assert.False(t, status.Synced)
+	require.Len(t, status.Collections, 2)
+	assert.True(t, status.Collections[0].Stale)
+
+	checker.SyncStarted()
+	checker.Collected("node", nil)
+	checker.Collected("pod", errors.New("forbidden"))
+	checker.SyncFinished(errors.New("could not fetch data from kubernetes: forbidden"))
+	status = checker.Status(context.Background())
+	assert.False(t, status.Ready)
+	assert.Equal(t, "could not fetch data from kubernetes: forbidden", status.LastError)
+	assert.Equal(t, "forbidden", status.Collections[1].Error)
+
+	checker.Collected("pod", nil)
+	checker.SyncFinished(nil)
+	status = checker.Status(context.Background())
+	assert.True(t, status.Ready)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, now, *status.LastSync)
+
+	// the kinds become stale when the collections keep failing
+	now = now.Add(time.Minute * 2)
+	checker.Collected("node", nil)
+	checker.Collected("pod", errors.New("timeout"))
+	status = checker.Status(context.Background())
+	assert.False(t, status.Ready)
+	assert.False(t, status.Collections[0].Stale)
+	assert.True(t, status.Collections[1].Stale)
+
+	checker.Collected("pod", nil)
+	pingErr = errors.New("database is locked")
+	status = checker.Status(context.Background())
+	assert.False(t, status.Ready)
+	assert.Equal(t, "database is locked", status.Database.Error)
+}
+
+func TestCheckerLiveness(t *testing.T) {
+	now := time.Now()
+	checker := NewChecker(&CheckerConfig{LivenessTimeout: time.Minute, Ping: func(context.Context) error { return nil }})
+	checker.now = func() time.Time { return now }
+
+	assert.NoError(t, checker.Live())
+
+	now = now.Add(time.Minute * 2)
+	assert.Error(t, checker.Live())
+
+	checker.SyncStarted()
+	assert.NoError(t, checker.Live())
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentile returns the nearest-rank percentile (0-100) of the values
+func Percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]int64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
+
+// MetricStatistics - represents the statistics of samples within a time bucket
+type MetricStatistics struct {
+	Samples int
+	Min     int64
+	Avg     int64
+	Max     int64
+	P95     int64
+}
+
+// CalculateStatistics returns min, avg, max and p95 of the values
+func CalculateStatistics(values []int64) MetricStatistics {
+	if len(values) == 0 {
+		return MetricStatistics{}
+	}
+
+	stats := MetricStatistics{Samples: len(values), Min: values[0], Max: values[0]}
+	var sum int64
+	for _, v := range values {
+		sum += v
+		if v < stats.Min {
+			stats.Min = v
+		}
+		if v > stats.Max {
+			stats.Max = v
+		}
+	}
+
+	stats.Avg = sum / int64(len(values))
+	stats.P95 = Percentile(values, 95)
+
+	return stats
+}
+
+const (
+	AGGREGATION_SUM string = "sum"
+	AGGREGATION_AVG string = "avg"
+	AGGREGATION_MAX string = "max"
+	AGGREGATION_P95 string = "p95"
+)
+
+// IsValidAggregation checks if the aggregation is supported
+func IsValidAggregation(aggregation string) bool {
+	switch aggregation {
+	case AGGREGATION_SUM, AGGREGATION_AVG, AGGREGATION_MAX, AGGREGATION_P95:
+		return true
+	}
+
+	return false
+}
+
+// Aggregate combines the values with the given aggregation
+func Aggregate(values []int64, aggregation string) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	switch aggregation {
+	case AGGREGATION_AVG:
+		return CalculateStatistics(values).Avg
+	case AGGREGATION_MAX:
+		return CalculateStatistics(values).Max
+	case AGGREGATION_P95:
+		return Percentile(values, 95)
+	}
+
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum
+}
+
+// MetricBucket - represents the usage of a container within a step of a metrics query
+type MetricBucket struct {
+	PodName       string
+	ContainerName string
+	Step          int
+	CPUUsage      int64
+	MemoryUsage   int64
+}
+
+// MetricSeries - represents the values of a metric aligned to the timestamps of the query result
+type MetricSeries struct {
+	Name   string   `json:"name"`
+	Values []*int64 `json:"values"`
+}
+
+// MetricsQueryResult - represents aligned series ready for charting
+type MetricsQueryResult struct {
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Step        string         `json:"step"`
+	Aggregation string         `json:"aggregation"`
+	Timestamps  []time.Time    `json:"timestamps"`
+	Series      []MetricSeries `json:"series"`
+}
+
+// BuildMetricSeries aligns the buckets to the steps between from and to.
+// The containers of a pod are summed up, the pods are combined with the aggregation.
+// Steps without samples are returned as null.
+func BuildMetricSeries(buckets []MetricBucket, from time.Time, to time.Time, step time.Duration, aggregation string) MetricsQueryResult {
+	steps := int(to.Sub(from)/step) + 1
+
+	result := MetricsQueryResult{
+		From:        from,
+		To:          to,
+		Step:        step.String(),
+		Aggregation: aggregation,
+		Timestamps:  make([]time.Time, steps),
+		Series: []MetricSeries{
+			{Name: "cpu", Values: make([]*int64, steps)},
+			{Name: "memory", Values: make([]*int64, steps)},
+		},
+	}
+
+	for i := 0; i < steps; i++ {
+		result.Timestamps[i] = from.Add(step * time.Duration(i))
+	}
+
+	type podUsage struct {
+		cpu    int64
+		memory int64
+	}
+
+	pods := make([]map[string]*podUsage, steps)
+	for _, bucket := range buckets {
+		if bucket.Step < 0 || bucket.Step >= steps {
+			continue
+		}
+
+		if pods[bucket.Step] == nil {
+			pods[bucket.Step] = make(map[string]*podUsage)
+		}
+
+		usage, ok := pods[bucket.Step][bucket.PodName]
+		if !ok {
+			usage = &podUsage{}
+			pods[bucket.Step][bucket.PodName] = usage
+		}
+
+		usage.cpu += bucket.CPUUsage
+		usage.memory += bucket.MemoryUsage
+	}
+
+	for i, usages := range pods {
+		if len(usages) == 0 {
+			continue
+		}
+
+		cpu := make([]int64, 0, len(usages))
+		memory := make([]int64, 0, len(usages))
+		for _, usage := range usages {
+			cpu = append(cpu, usage.cpu)
+			memory = append(memory, usage.memory)
+		}
+
+		cpuValue := Aggregate(cpu, aggregation)
+		memoryValue := Aggregate(memory, aggregation)
+		result.Series[0].Values[i] = &cpuValue
+		result.Series[1].Values[i] = &memoryValue
+	}
+
+	return result
+}
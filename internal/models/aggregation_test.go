@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMetricSeries(t *testing.T) {
+	from := time.Date(2023, time.January, 22, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute * 3)
+
+	buckets := []MetricBucket{
+		{PodName: "a", ContainerName: "app", Step: 0, CPUUsage: 10, MemoryUsage: 100},
+		{PodName: "a", ContainerName: "sidecar", Step: 0, CPUUsage: 5, MemoryUsage: 50},
+		{PodName: "b", ContainerName: "app", Step: 0, CPUUsage: 20, MemoryUsage: 200},
+		{PodName: "b", ContainerName: "app", Step: 2, CPUUsage: 30, MemoryUsage: 300},
+	}
+
+	result := BuildMetricSeries(buckets, from, to, time.Minute, AGGREGATION_SUM)
+	assert.Equal(t, 4, len(result.Timestamps))
+	assert.Equal(t, from.Add(time.Minute*2), result.Timestamps[2])
+	assert.Equal(t, int64(35), *result.Series[0].Values[0])
+	assert.Equal(t, int64(350), *result.Series[1].Values[0])
+	assert.Nil(t, result.Series[0].Values[1])
+	assert.Equal(t, int64(30), *result.Series[0].Values[2])
+
+	result = BuildMetricSeries(buckets, from, to, time.Minute, AGGREGATION_MAX)
+	assert.Equal(t, int64(20), *result.Series[0].Values[0])
+
+	result = BuildMetricSeries(buckets, from, to, time.Minute, AGGREGATION_AVG)
+	assert.Equal(t, int64(17), *result.Series[0].Values[0])
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	ALERT_STATUS_FIRING   string = "firing"
+	ALERT_STATUS_RESOLVED string = "resolved"
+)
+
+// Alert - a firing or resolved alert of an alerting rule
+type Alert struct {
+	Rule     string            `json:"rule"`
+	Severity string            `json:"severity,omitempty"`
+	Status   string            `json:"status"`
+	Labels   map[string]string `json:"labels"` // identify the alert within the rule, e.g. namespace, pod & container
+	Summary  string            `json:"summary"`
+	Value    float64           `json:"value"`             // the evaluated value, e.g. the restarts or the usage in percent of the limit
+	ActiveAt time.Time         `json:"active_at"`         // the condition is true since
+	StartsAt time.Time         `json:"starts_at"`         // firing since, the condition was true for the duration of the rule
+	EndsAt   *time.Time        `json:"ends_at,omitempty"` // only set for resolved alerts
+}
+
+// AlertNotification - the alerts of a group sent to a receiver, it is sent when an alert of the group fires or is resolved
+type AlertNotification struct {
+	Status      string            `json:"status"` // firing as long as an alert of the group fires
+	Rule        string            `json:"rule"`
+	GroupKey    string            `json:"group_key"`
+	GroupLabels map[string]string `json:"group_labels"` // the labels the alerts of the rule are grouped by
+	Alerts      []Alert           `json:"alerts"`
+}
+
+// Firing returns the number of firing alerts of the notification
+func (n AlertNotification) Firing() int {
+	firing := 0
+	for _, alert := range n.Alerts {
+		if alert.Status == ALERT_STATUS_FIRING {
+			firing++
+		}
+	}
+
+	return firing
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// AuditEntry - a request to the api recorded in the audit log
+type AuditEntry struct {
+	ID         int64             `json:"id,omitempty"`          // assigned by the audit log of the data store
+	Timestamp  time.Time         `json:"timestamp"`             // start of the request
+	User       string            `json:"user"`                  // empty when the request was not authenticated
+	AuthMethod string            `json:"auth_method,omitempty"` // anonymous, token or oidc
+	Method     string            `json:"method"`
+	Route      string            `json:"route"` // route pattern, e.g. /api/v1/namespaces/:name
+	Path       string            `json:"path"`
+	Params     map[string]string `json:"params"` // path and query parameters, secrets are redacted
+	Status     int               `json:"status"`
+	LatencyMS  float64           `json:"latency_ms"`
+	Denied     bool              `json:"denied"` // the authentication or the authorization failed
+	ClientIP   string            `json:"client_ip"`
+}
+
+// AuditFilter - selects the entries of the audit log, zero values do not filter
+type AuditFilter struct {
+	User   string
+	Route  string
+	Path   string // prefix of the path
+	Status int
+	Denied *bool
+	From   time.Time
+	To     time.Time
+}
@@ -2,20 +2,37 @@ package models
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
-type CollectionCompareFunc func(a interface{}, b interface{}) bool
-type FilterFunc func(a interface{}) bool
+type CollectionCompareFunc[V any] func(a V, b V) bool
+type FilterFunc[V any] func(a V) bool
+type LessFunc[V any] func(a V, b V) bool
 
 // Collection - thread safe collection which stores data
-type Collection struct {
-	items map[string]interface{}
-	lock  sync.Mutex
+type Collection[K comparable, V any] struct {
+	items map[K]V
+	lock  sync.RWMutex
 }
 
+// NodeCollection - collection of nodes by key
+type NodeCollection = Collection[string, Node]
+
+// NamespaceCollection - collection of namespaces by key
+type NamespaceCollection = Collection[string, Namespace]
+
+// WorkloadCollection - collection of workloads by key
+type WorkloadCollection = Collection[string, Workload]
+
+// MetricCollection - collection of container metrics by key
+type MetricCollection = Collection[string, PodContainerMetric]
+
+// EventCollection - collection of events by key
+type EventCollection = Collection[string, Event]
+
 // Set - adds a key & value to collection
-func (c *Collection) Set(key string, value interface{}, replace bool) error {
+func (c *Collection[K, V]) Set(key K, value V, replace bool) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if _, ok := c.items[key]; ok && !replace {
@@ -28,69 +45,96 @@ func (c *Collection) Set(key string, value interface{}, replace bool) error {
 }
 
 // Get - returns the provided value
-func (c *Collection) Get(key string) (interface{}, bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+func (c *Collection[K, V]) Get(key K) (V, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	val, ok := c.items[key]
 	return val, ok
 }
 
-func (c *Collection) GetAll() map[string]interface{} {
-	return c.items
+// GetAll returns a copy of the items, changes to the map do not affect the collection
+func (c *Collection[K, V]) GetAll() map[K]V {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	items := make(map[K]V, len(c.items))
+	for k, v := range c.items {
+		items[k] = v
+	}
+
+	return items
 }
 
 // Len return the length of the collection
-func (c *Collection) Len() int {
+func (c *Collection[K, V]) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return len(c.items)
 }
 
-func (c *Collection) GetKeys() []string {
-	keys := make([]string, c.Len())
-	i := 0
+func (c *Collection[K, V]) GetKeys() []K {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := make([]K, 0, len(c.items))
 	for k := range c.items {
-		keys[i] = k
-		i++
+		keys = append(keys, k)
 	}
 
 	return keys
 }
 
-func (c *Collection) Filter(f FilterFunc) *Collection {
-	filteredCollection := NewCollection()
+// Filter returns a new collection with the items f returns true for
+func (c *Collection[K, V]) Filter(f FilterFunc[V]) *Collection[K, V] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	filteredCollection := NewCollection[K, V]()
 	for key, item := range c.items {
 		if f(item) {
-			filteredCollection.Set(key, item, true)
+			filteredCollection.items[key] = item
 		}
 	}
 
 	return filteredCollection
 }
 
-func (c *Collection) ToList() []interface{} {
-	vals := make([]interface{}, c.Len())
-	i := 0
+// ToList returns the values in no particular order
+func (c *Collection[K, V]) ToList() []V {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	vals := make([]V, 0, len(c.items))
 	for _, val := range c.items {
-		vals[i] = val
-		i++
+		vals = append(vals, val)
 	}
 	return vals
 }
 
-func CompareCollections(a *Collection, b *Collection, f CollectionCompareFunc) bool {
-	if a.Len() != b.Len() {
-		return false
+// SortedList returns the values sorted by less
+func (c *Collection[K, V]) SortedList(less LessFunc[V]) []V {
+	vals := c.ToList()
+	sort.SliceStable(vals, func(i, j int) bool { return less(vals[i], vals[j]) })
+	return vals
+}
+
+// MapCollection returns a new collection with f applied to every value of c
+func MapCollection[K comparable, V any, R any](c *Collection[K, V], f func(V) R) *Collection[K, R] {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	mapped := NewCollection[K, R]()
+	for key, item := range c.items {
+		mapped.items[key] = f(item)
 	}
 
-	for aKey, aVal := range a.GetAll() {
-		found := false
-		for bKey, bVal := range b.GetAll() {
-			if aKey == bKey && f(aVal, bVal) {
-				found = true
-				break
-			}
-		}
+	return mapped
+}
+
+func CompareCollections[K comparable, V any](a *Collection[K, V], b *Collection[K, V], f CollectionCompareFunc[V]) bool {
+	aItems, bItems := a.GetAll(), b.GetAll()
+	if len(aItems) != len(bItems) {
+		return false
+	}
 
-		if !found {
+	for aKey, aVal := range aItems {
+		bVal, ok := bItems[aKey]
+		if !ok || !f(aVal, bVal) {
 			return false
 		}
 	}
@@ -98,7 +142,38 @@ func CompareCollections(a *Collection, b *Collection, f CollectionCompareFunc) b
 	return true
 }
 
+// CollectionDiff - the keys which were added, modified or deleted between two collections
+type CollectionDiff[K comparable] struct {
+	Added    []K
+	Modified []K
+	Deleted  []K
+}
+
+// DiffCollections returns the keys which changed from the previous to the current collection
+func DiffCollections[K comparable, V any](previous *Collection[K, V], current *Collection[K, V], f CollectionCompareFunc[V]) CollectionDiff[K] {
+	previousItems, currentItems := previous.GetAll(), current.GetAll()
+
+	diff := CollectionDiff[K]{}
+	for key, currentVal := range currentItems {
+		previousVal, ok := previousItems[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case !f(previousVal, currentVal):
+			diff.Modified = append(diff.Modified, key)
+		}
+	}
+
+	for key := range previousItems {
+		if _, ok := currentItems[key]; !ok {
+			diff.Deleted = append(diff.Deleted, key)
+		}
+	}
+
+	return diff
+}
+
 // NewCollection - returns a new collection
-func NewCollection() *Collection {
-	return &Collection{items: make(map[string]interface{})}
+func NewCollection[K comparable, V any]() *Collection[K, V] {
+	return &Collection[K, V]{items: make(map[K]V)}
 }
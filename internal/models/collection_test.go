@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollection(t *testing.T) {
+	collection := NewCollection[string, int]()
+	assert.NoError(t, collection.Set("b", 2, false))
+	assert.NoError(t, collection.Set("a", 1, false))
+	assert.Error(t, collection.Set("a", 3, false))
+	assert.NoError(t, collection.Set("c", 3, true))
+
+	value, ok := collection.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	_, ok = collection.Get("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, 3, collection.Len())
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, collection.GetKeys())
+	assert.Equal(t, []int{1, 2, 3}, collection.SortedList(func(a, b int) bool { return a < b }))
+
+	odd := collection.Filter(func(v int) bool { return v%2 == 1 })
+	assert.ElementsMatch(t, []int{1, 3}, odd.ToList())
+
+	names := MapCollection(collection, func(v int) string { return fmt.Sprintf("item-%d", v) })
+	name, _ := names.Get("b")
+	assert.Equal(t, "item-2", name)
+
+	// the map returned by GetAll is a copy
+	all := collection.GetAll()
+	all["d"] = 4
+	assert.Equal(t, 3, collection.Len())
+}
+
+func TestCompareCollections(t *testing.T) {
+	equal := func(a, b int) bool { return a == b }
+
+	a := NewCollection[string, int]()
+	b := NewCollection[string, int]()
+	a.Set("x", 1, true)
+	b.Set("x", 1, true)
+	assert.True(t, CompareCollections(a, b, equal))
+
+	b.Set("x", 2, true)
+	assert.False(t, CompareCollections(a, b, equal))
+
+	b.Set("x", 1, true)
+	b.Set("y", 1, true)
+	assert.False(t, CompareCollections(a, b, equal))
+}
+
+// TestCollectionConcurrentUse is meant to be run with -race
+func TestCollectionConcurrentUse(t *testing.T) {
+	collection := NewCollection[string, int]()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				collection.Set(fmt.Sprintf("%d_%d", w, i), i, true)
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				collection.Get("0_0")
+				collection.Len()
+				collection.GetKeys()
+				collection.GetAll()
+				collection.ToList()
+				collection.Filter(func(v int) bool { return v%2 == 0 })
+				MapCollection(collection, func(v int) int { return v * 2 })
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 800, collection.Len())
+}
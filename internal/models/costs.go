@@ -0,0 +1,212 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**
+	Costs are allocated from the resources of the pods per hour, priced by the node the pod runs on.
+	The resources of a pod are its requests, its usage or the max of both.
+	The capacity of the nodes which is not allocated to pods is the idle cost of the cluster.
+**/
+
+const (
+	COST_BASIS_REQUESTS = "requests"
+	COST_BASIS_USAGE    = "usage"
+	COST_BASIS_MAX      = "max" // the max of requests and usage per resource
+)
+
+const (
+	COST_GROUP_NAMESPACE    = "namespace"
+	COST_GROUP_WORKLOAD     = "workload"
+	COST_GROUP_LABEL_PREFIX = "label:" // e.g. label:team groups by the value of the team label of the pods
+)
+
+const (
+	COST_IDLE        = "__idle__"        // name of the group of the capacity not allocated to pods
+	COST_UNALLOCATED = "__unallocated__" // name of the group of the pods without the label grouped by
+)
+
+const gibibyte = 1024 * 1024 * 1024
+
+// CostPrice - the price per vCPU hour and per GiB hour of memory
+type CostPrice struct {
+	CPUHour      float64
+	MemoryGBHour float64
+}
+
+// NodePrice - the price of the nodes matching the selector
+type NodePrice struct {
+	Selector LabelSelector
+	Price    CostPrice
+}
+
+// CostModel - the prices of the nodes, the first matching node price applies and the default price otherwise
+type CostModel struct {
+	Currency string
+	Default  CostPrice
+	Nodes    []NodePrice
+}
+
+// Price returns the price of a node with the labels
+func (m CostModel) Price(nodeLabels map[string]string) CostPrice {
+	for _, node := range m.Nodes {
+		if node.Selector.Matches(nodeLabels) {
+			return node.Price
+		}
+	}
+
+	return m.Default
+}
+
+// PodAllocation - the resources of a pod within an hour in core hours and GiB hours
+type PodAllocation struct {
+	Hour          time.Time
+	Namespace     string
+	PodName       string
+	WorkloadType  string // the workload the pod belongs to, the pod itself when it has no owner
+	WorkloadName  string
+	NodeName      string
+	Labels        map[string]string
+	CPURequest    float64
+	MemoryRequest float64
+	CPUUsage      float64
+	MemoryUsage   float64
+}
+
+// NodeAllocation - the capacity of a node within an hour in core hours and GiB hours
+type NodeAllocation struct {
+	Hour     time.Time
+	NodeName string
+	Labels   map[string]string
+	CPU      float64
+	Memory   float64
+}
+
+// CostAllocation - the cost of a group within the time range
+type CostAllocation struct {
+	Name           string  `json:"name"` // the namespace, namespace/type/name of the workload, the label value, __idle__ or __unallocated__
+	Namespace      string  `json:"namespace,omitempty"`
+	WorkloadType   string  `json:"workload_type,omitempty"`
+	WorkloadName   string  `json:"workload_name,omitempty"`
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+	CPUCost        float64 `json:"cpu_cost"`
+	MemoryCost     float64 `json:"memory_cost"`
+	TotalCost      float64 `json:"total_cost"`
+	Currency       string  `json:"currency"`
+}
+
+// IsValidCostBasis checks if the costs can be calculated from the basis
+func IsValidCostBasis(basis string) bool {
+	return basis == COST_BASIS_REQUESTS || basis == COST_BASIS_USAGE || basis == COST_BASIS_MAX
+}
+
+// IsValidCostGroupBy checks if the costs can be grouped by the value
+func IsValidCostGroupBy(groupBy string) bool {
+	if strings.HasPrefix(groupBy, COST_GROUP_LABEL_PREFIX) {
+		return strings.TrimPrefix(groupBy, COST_GROUP_LABEL_PREFIX) != ""
+	}
+
+	return groupBy == COST_GROUP_NAMESPACE || groupBy == COST_GROUP_WORKLOAD
+}
+
+// ToGiB converts bytes to GiB
+func ToGiB(bytes float64) float64 {
+	return bytes / gibibyte
+}
+
+// AllocateCosts returns the costs of the pods grouped by groupBy, sorted by the total cost descending.
+// The idle cost of the nodes is added as group __idle__ when idle is set.
+func AllocateCosts(model CostModel, pods []PodAllocation, nodes []NodeAllocation, groupBy string, basis string, idle bool) []CostAllocation {
+	type nodeHour struct {
+		price                         CostPrice
+		cpu, memory                   float64
+		allocatedCPU, allocatedMemory float64
+	}
+
+	nodeHours := make(map[string]*nodeHour, len(nodes))
+	for _, node := range nodes {
+		nodeHours[fmt.Sprintf("%d/%s", node.Hour.Unix(), node.NodeName)] = &nodeHour{price: model.Price(node.Labels), cpu: node.CPU, memory: node.Memory}
+	}
+
+	groups := make(map[string]*CostAllocation)
+	add := func(group CostAllocation, price CostPrice, cpu float64, memory float64) {
+		allocation, ok := groups[group.Name]
+		if !ok {
+			group.Currency = model.Currency
+			allocation = &group
+			groups[group.Name] = allocation
+		}
+
+		allocation.CPUCoreHours += cpu
+		allocation.MemoryGiBHours += memory
+		allocation.CPUCost += cpu * price.CPUHour
+		allocation.MemoryCost += memory * price.MemoryGBHour
+		allocation.TotalCost = allocation.CPUCost + allocation.MemoryCost
+	}
+
+	for _, pod := range pods {
+		cpu, memory := pod.CPURequest, pod.MemoryRequest
+		switch basis {
+		case COST_BASIS_USAGE:
+			cpu, memory = pod.CPUUsage, pod.MemoryUsage
+		case COST_BASIS_MAX:
+			cpu, memory = math.Max(pod.CPURequest, pod.CPUUsage), math.Max(pod.MemoryRequest, pod.MemoryUsage)
+		}
+
+		// pods on nodes which were not collected are priced by the default price
+		price := model.Default
+		if node, ok := nodeHours[fmt.Sprintf("%d/%s", pod.Hour.Unix(), pod.NodeName)]; ok {
+			price = node.price
+			node.allocatedCPU += cpu
+			node.allocatedMemory += memory
+		}
+
+		add(costGroup(pod, groupBy), price, cpu, memory)
+	}
+
+	if idle {
+		for _, node := range nodeHours {
+			add(CostAllocation{Name: COST_IDLE}, node.price, math.Max(node.cpu-node.allocatedCPU, 0), math.Max(node.memory-node.allocatedMemory, 0))
+		}
+	}
+
+	result := make([]CostAllocation, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalCost != result[j].TotalCost {
+			return result[i].TotalCost > result[j].TotalCost
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// costGroup returns the group of the pod
+func costGroup(pod PodAllocation, groupBy string) CostAllocation {
+	switch {
+	case groupBy == COST_GROUP_WORKLOAD:
+		return CostAllocation{
+			Name:         fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.WorkloadType, pod.WorkloadName),
+			Namespace:    pod.Namespace,
+			WorkloadType: pod.WorkloadType,
+			WorkloadName: pod.WorkloadName,
+		}
+	case strings.HasPrefix(groupBy, COST_GROUP_LABEL_PREFIX):
+		if value, ok := pod.Labels[strings.TrimPrefix(groupBy, COST_GROUP_LABEL_PREFIX)]; ok {
+			return CostAllocation{Name: value}
+		}
+		return CostAllocation{Name: COST_UNALLOCATED}
+	}
+
+	return CostAllocation{Name: pod.Namespace, Namespace: pod.Namespace}
+}
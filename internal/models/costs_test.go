@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateCosts(t *testing.T) {
+	hour := time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+	spot, err := ParseLabelSelector("pool=spot")
+	require.NoError(t, err)
+	model := CostModel{
+		Currency: "EUR",
+		Default:  CostPrice{CPUHour: 1, MemoryGBHour: 0.1},
+		Nodes:    []NodePrice{{Selector: spot, Price: CostPrice{CPUHour: 0.5, MemoryGBHour: 0.05}}},
+	}
+	nodes := []NodeAllocation{
+		{Hour: hour, NodeName: "node-1", Labels: map[string]string{}, CPU: 4, Memory: 16},
+		{Hour: hour, NodeName: "spot-1", Labels: map[string]string{"pool": "spot"}, CPU: 4, Memory: 16},
+	}
+	pods := []PodAllocation{
+		{Hour: hour, Namespace: "shop", PodName: "web-1", WorkloadType: WORKLOAD_TYPE_DEPLOYMENT, WorkloadName: "web", NodeName: "node-1",
+			Labels: map[string]string{"team": "checkout"}, CPURequest: 2, MemoryRequest: 4, CPUUsage: 3, MemoryUsage: 1},
+		{Hour: hour, Namespace: "shop", PodName: "web-2", WorkloadType: WORKLOAD_TYPE_DEPLOYMENT, WorkloadName: "web", NodeName: "spot-1",
+			Labels: map[string]string{"team": "checkout"}, CPURequest: 2, MemoryRequest: 4},
+		{Hour: hour, Namespace: "batch", PodName: "report", WorkloadType: WORKLOAD_TYPE_POD, WorkloadName: "report", NodeName: "gone",
+			Labels: map[string]string{}, CPURequest: 1},
+	}
+
+	byNamespace := AllocateCosts(model, pods, nodes, COST_GROUP_NAMESPACE, COST_BASIS_REQUESTS, false)
+	require.Len(t, byNamespace, 2, "the idle cost is not included")
+	assert.Equal(t, "shop", byNamespace[0].Name)
+	assert.InDelta(t, 2*1+4*0.1+2*0.5+4*0.05, byNamespace[0].TotalCost, 1e-9, "each pod is priced by its node")
+	assert.InDelta(t, 1, byNamespace[1].TotalCost, 1e-9, "pods on unknown nodes are priced by the default price")
+	assert.Equal(t, "EUR", byNamespace[1].Currency)
+
+	byMax := AllocateCosts(model, pods[:1], nodes[:1], COST_GROUP_WORKLOAD, COST_BASIS_MAX, true)
+	require.Len(t, byMax, 2)
+	assert.Equal(t, "shop/"+WORKLOAD_TYPE_DEPLOYMENT+"/web", byMax[0].Name)
+	assert.InDelta(t, 3, byMax[0].CPUCoreHours, 1e-9, "the usage exceeds the request")
+	assert.InDelta(t, 4, byMax[0].MemoryGiBHours, 1e-9)
+	assert.Equal(t, COST_IDLE, byMax[1].Name)
+	assert.InDelta(t, 1, byMax[1].CPUCoreHours, 1e-9, "the capacity of the node not allocated to the pod")
+	assert.InDelta(t, 12, byMax[1].MemoryGiBHours, 1e-9)
+	assert.InDelta(t, 2.2, byMax[1].TotalCost, 1e-9)
+
+	byTeam := AllocateCosts(model, pods, nodes, COST_GROUP_LABEL_PREFIX+"team", COST_BASIS_USAGE, false)
+	require.Len(t, byTeam, 2)
+	assert.Equal(t, "checkout", byTeam[0].Name)
+	assert.Equal(t, COST_UNALLOCATED, byTeam[1].Name, "pods without the label")
+	assert.Zero(t, byTeam[1].TotalCost)
+}
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+	Cron schedules are parsed like the cronjob controller of kubernetes does:
+	five fields (minute, hour, day of month, month, day of week) with lists, ranges, steps and names,
+	the macros @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly
+	and an optional CRON_TZ= or TZ= prefix. Schedules without time zone run in UTC.
+	When the day of month and the day of week are both restricted, a day matching either of them is scheduled.
+**/
+
+const (
+	CRONJOB_NEXT_RUNS    = 5               // number of upcoming runs of the analysis of a cronjob
+	CRONJOB_MISSED_GRACE = time.Minute * 2 // delay of the cronjob controller before a run is considered as missed
+)
+
+// the limit of the search for the next run, e.g. for schedules like "0 0 30 2 *" which never run
+const cron_search_years = 5
+
+var cron_macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cron_months   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cron_weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronField - the allowed values of a field as bits, star is set for * and ? which do not restrict the day
+type cronField struct {
+	bits uint64
+	star bool
+}
+
+func (f cronField) has(value int) bool {
+	return f.bits&(1<<uint(value)) != 0
+}
+
+// CronSchedule - a parsed cron expression
+type CronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	location                      *time.Location
+}
+
+// ParseCronSchedule parses a cron expression, the time zone of a CRON_TZ= or TZ= prefix overrides the passed time zone.
+// An empty time zone is UTC.
+func ParseCronSchedule(expression string, timeZone string) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "CRON_TZ=") || strings.HasPrefix(expression, "TZ=") {
+		i := strings.IndexAny(expression, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("%w: schedule %q without fields", ErrInvalidFilter, expression)
+		}
+		timeZone = expression[strings.Index(expression, "=")+1 : i]
+		expression = strings.TrimSpace(expression[i:])
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidFilter, timeZone)
+	}
+
+	if strings.HasPrefix(expression, "@") {
+		macro, ok := cron_macros[strings.ToLower(expression)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported schedule %q", ErrInvalidFilter, expression)
+		}
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: schedule %q needs 5 fields, got %d", ErrInvalidFilter, expression, len(fields))
+	}
+
+	schedule := &CronSchedule{location: location}
+	for i, parse := range []struct {
+		target   *cronField
+		min, max int
+		names    []string
+		offset   int // value of the first name
+	}{
+		{target: &schedule.minute, min: 0, max: 59},
+		{target: &schedule.hour, min: 0, max: 23},
+		{target: &schedule.dom, min: 1, max: 31},
+		{target: &schedule.month, min: 1, max: 12, names: cron_months, offset: 1},
+		{target: &schedule.dow, min: 0, max: 6, names: cron_weekdays},
+	} {
+		field, err := parseCronField(fields[i], parse.min, parse.max, parse.names, parse.offset)
+		if err != nil {
+			return nil, fmt.Errorf("%w: schedule %q: %s", ErrInvalidFilter, expression, err)
+		}
+		*parse.target = field
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of *, ?, values and ranges with optional steps, e.g. "1-5/2,10"
+func parseCronField(value string, min, max int, names []string, offset int) (cronField, error) {
+	field := cronField{}
+	for _, part := range strings.Split(value, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return field, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], s
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			start, end = min, max
+			field.star = field.star || step == 1
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = parseCronValue(bounds[0], names, offset); err != nil {
+				return field, err
+			}
+			if end, err = parseCronValue(bounds[1], names, offset); err != nil {
+				return field, err
+			}
+		default:
+			v, err := parseCronValue(rangePart, names, offset)
+			if err != nil {
+				return field, err
+			}
+			// a single value with a step runs up to the maximum, e.g. 5/15 in the minutes
+			start, end = v, v
+			if strings.Contains(part, "/") {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return field, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			field.bits |= 1 << uint(v)
+		}
+	}
+
+	return field, nil
+}
+
+func parseCronValue(value string, names []string, offset int) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			return i + offset, nil
+		}
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	return v, nil
+}
+
+// Location returns the time zone the schedule runs in
+func (s *CronSchedule) Location() *time.Location {
+	return s.location
+}
+
+// Next returns the first run after t, the zero time when the schedule does not run within the next years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cron_search_years
+
+	for t.Year() <= limit {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+		case !s.minute.has(t.Minute()):
+			// the absolute time is advanced, a wall clock minute is ambiguous when the clocks are set back
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Prev returns the last run at or before t, the zero time when the schedule did not run within the last years
+func (s *CronSchedule) Prev(t time.Time) time.Time {
+	for lookback := time.Minute; lookback <= time.Hour*24*366*cron_search_years; lookback *= 2 {
+		run := s.Next(t.Add(-lookback))
+		if run.IsZero() || run.After(t) {
+			continue
+		}
+
+		for next := s.Next(run); !next.IsZero() && !next.After(t); next = s.Next(run) {
+			run = next
+		}
+
+		return run
+	}
+
+	return time.Time{}
+}
+
+// Runs returns the runs within from and to, at most max runs
+func (s *CronSchedule) Runs(from time.Time, to time.Time, max int) []time.Time {
+	runs := make([]time.Time, 0)
+	for run := s.Next(from.Add(-time.Nanosecond)); !run.IsZero() && !run.After(to) && len(runs) < max; run = s.Next(run) {
+		runs = append(runs, run)
+	}
+
+	return runs
+}
+
+// dayMatches applies the day of month or day of week, like cron a restriction of both matches either
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	if s.dom.star || s.dow.star {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// CronjobAnalysis - the schedule of a cronjob compared with its runs
+type CronjobAnalysis struct {
+	NextRuns        []time.Time `json:"next_runs"`
+	ExpectedLastRun *time.Time  `json:"expected_last_run"` // the last run planned by the schedule
+	// Missed is set when the last scheduled time lags the schedule, suspended cronjobs never miss runs
+	Missed bool `json:"missed"`
+	// IntervalSeconds is the shortest time between the next runs
+	IntervalSeconds int64 `json:"interval_seconds"`
+	// TypicalDurationSeconds is the median duration of the completed jobs of the cronjob, nil without completed jobs
+	TypicalDurationSeconds *int64 `json:"typical_duration_seconds"`
+	// ConcurrencyRisk is set when the typical duration exceeds the interval, so the runs overlap or are skipped by the concurrency policy
+	ConcurrencyRisk bool   `json:"concurrency_risk"`
+	Error           string `json:"error,omitempty"` // the schedule could not be parsed, the other fields are not set
+}
+
+// ParsedSchedule returns the schedule of the cronjob in its time zone
+func (d CronjobWorkload) ParsedSchedule() (*CronSchedule, error) {
+	timeZone := ""
+	if d.TimeZone != nil {
+		timeZone = *d.TimeZone
+	}
+
+	return ParseCronSchedule(d.Schedule, timeZone)
+}
+
+// AnalyzeCronjob compares the schedule of the cronjob with its last scheduled time and the durations of its completed jobs
+func AnalyzeCronjob(cronjob CronjobWorkload, durations []time.Duration, now time.Time) *CronjobAnalysis {
+	schedule, err := cronjob.ParsedSchedule()
+	if err != nil {
+		return &CronjobAnalysis{NextRuns: []time.Time{}, Error: err.Error()}
+	}
+
+	analysis := &CronjobAnalysis{NextRuns: make([]time.Time, 0, CRONJOB_NEXT_RUNS)}
+	run := now
+	for i := 0; i <= CRONJOB_NEXT_RUNS; i++ {
+		next := schedule.Next(run)
+		if next.IsZero() {
+			break
+		}
+		if i > 0 {
+			if interval := int64(next.Sub(run).Seconds()); analysis.IntervalSeconds == 0 || interval < analysis.IntervalSeconds {
+				analysis.IntervalSeconds = interval
+			}
+		}
+		if i < CRONJOB_NEXT_RUNS {
+			analysis.NextRuns = append(analysis.NextRuns, next)
+		}
+		run = next
+	}
+
+	if expected := schedule.Prev(now); !expected.IsZero() {
+		analysis.ExpectedLastRun = &expected
+
+		// a cronjob which was never scheduled is measured from its creation
+		last := cronjob.CreationTimestamp
+		if cronjob.Status.LastScheduledTime != nil {
+			last = *cronjob.Status.LastScheduledTime
+		}
+		suspended := cronjob.Suspend != nil && *cronjob.Suspend
+		analysis.Missed = !suspended && last.Before(expected) && now.Sub(expected) > CRONJOB_MISSED_GRACE
+	}
+
+	if len(durations) > 0 {
+		sorted := append([]time.Duration{}, durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		median := sorted[len(sorted)/2]
+		if len(sorted)%2 == 0 {
+			median = (sorted[len(sorted)/2-1] + median) / 2
+		}
+
+		seconds := int64(median.Seconds())
+		analysis.TypicalDurationSeconds = &seconds
+		analysis.ConcurrencyRisk = analysis.IntervalSeconds > 0 && seconds > analysis.IntervalSeconds
+	}
+
+	return analysis
+}
+
+// CronjobRun - a planned run of a cronjob
+type CronjobRun struct {
+	Time              time.Time `json:"time"`
+	Namespace         string    `json:"namespace"`
+	Cronjob           string    `json:"cronjob"`
+	Schedule          string    `json:"schedule"`
+	ConcurrencyPolicy string    `json:"concurrency_policy"`
+}
+
+// CronjobCalendar returns the planned runs of the cronjobs within from and to sorted by time, at most max runs per cronjob.
+// Suspended cronjobs and cronjobs with an invalid schedule are skipped, the latter report the error in their analysis.
+func CronjobCalendar(cronjobs []Workload, from time.Time, to time.Time, max int) []CronjobRun {
+	runs := make([]CronjobRun, 0)
+	for _, w := range cronjobs {
+		cronjob, ok := w.(CronjobWorkload)
+		if !ok || (cronjob.Suspend != nil && *cronjob.Suspend) {
+			continue
+		}
+
+		schedule, err := cronjob.ParsedSchedule()
+		if err != nil {
+			continue
+		}
+
+		for _, run := range schedule.Runs(from, to, max) {
+			runs = append(runs, CronjobRun{
+				Time:              run,
+				Namespace:         cronjob.Namespace,
+				Cronjob:           cronjob.WorkloadName,
+				Schedule:          cronjob.Schedule,
+				ConcurrencyPolicy: cronjob.ConcurrencyPolicy,
+			})
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].Time.Equal(runs[j].Time) {
+			return runs[i].Time.Before(runs[j].Time)
+		}
+		if runs[i].Namespace != runs[j].Namespace {
+			return runs[i].Namespace < runs[j].Namespace
+		}
+		return runs[i].Cronjob < runs[j].Cronjob
+	})
+
+	return runs
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	start := time.Date(2023, 1, 22, 10, 30, 0, 0, time.UTC) // a sunday
+
+	tests := []struct {
+		schedule string
+		timeZone string
+		next     []string
+	}{
+		{schedule: "*/15 * * * *", next: []string{"2023-01-22T10:45:00Z", "2023-01-22T11:00:00Z"}},
+		{schedule: "5/20 8-9 * * *", next: []string{"2023-01-23T08:05:00Z", "2023-01-23T08:25:00Z"}},
+		{schedule: "0 2 * * MON-FRI", next: []string{"2023-01-23T02:00:00Z", "2023-01-24T02:00:00Z"}},
+		{schedule: "@monthly", next: []string{"2023-02-01T00:00:00Z", "2023-03-01T00:00:00Z"}},
+		{schedule: "0 0 29 feb *", next: []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+		// the day of month or the day of week matches when both are restricted
+		{schedule: "0 0 1 * 3", next: []string{"2023-01-25T00:00:00Z", "2023-02-01T00:00:00Z"}},
+		{schedule: "CRON_TZ=Europe/Vienna 0 2 * * *", next: []string{"2023-01-23T01:00:00Z", "2023-01-24T01:00:00Z"}},
+		{schedule: "0 2 * * *", timeZone: "America/New_York", next: []string{"2023-01-23T07:00:00Z", "2023-01-24T07:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseCronSchedule(tt.schedule, tt.timeZone)
+		require.NoError(t, err, tt.schedule)
+
+		run := start
+		for _, expected := range tt.next {
+			run = schedule.Next(run)
+			assert.Equal(t, expected, run.UTC().Format(time.RFC3339), tt.schedule)
+		}
+	}
+
+	for _, invalid := range []string{"* * * *", "60 * * * *", "* * * * mon-", "*/0 * * * *", "@every 5m", "TZ=Mars/Olympus * * * * *"} {
+		_, err := ParseCronSchedule(invalid, "")
+		assert.ErrorIs(t, err, ErrInvalidFilter, invalid)
+	}
+
+	never, err := ParseCronSchedule("0 0 30 2 *", "")
+	require.NoError(t, err)
+	assert.True(t, never.Next(start).IsZero())
+}
+
+func TestCronScheduleDaylightSaving(t *testing.T) {
+	// the clocks of Vienna are set from 02:00 to 03:00 on 2023-03-26 and from 03:00 to 02:00 on 2023-10-29
+	schedule, err := ParseCronSchedule("30 2 * * *", "Europe/Vienna")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2023, 3, 25, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2023-03-27T00:30:00Z", next.UTC().Format(time.RFC3339), "like kubernetes, a time skipped by the clocks does not run")
+
+	runs := schedule.Runs(time.Date(2023, 10, 28, 12, 0, 0, 0, time.UTC), time.Date(2023, 10, 30, 12, 0, 0, 0, time.UTC), 10)
+	assert.Len(t, runs, 2, "a time repeated by the clocks runs once")
+}
+
+func TestAnalyzeCronjob(t *testing.T) {
+	now := time.Date(2023, 1, 22, 10, 30, 0, 0, time.UTC)
+	lastScheduled := time.Date(2023, 1, 22, 9, 0, 0, 0, time.UTC)
+	cronjob := CronjobWorkload{
+		GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "hourly", Namespace: "default", CreationTimestamp: now.Add(-time.Hour * 24)},
+		Schedule:            "0 * * * *",
+		Status:              CronjobStatus{LastScheduledTime: &lastScheduled},
+	}
+
+	analysis := AnalyzeCronjob(cronjob, []time.Duration{time.Minute * 50, time.Minute * 70, time.Minute * 80}, now)
+	require.Empty(t, analysis.Error)
+	assert.Len(t, analysis.NextRuns, CRONJOB_NEXT_RUNS)
+	assert.Equal(t, time.Date(2023, 1, 22, 11, 0, 0, 0, time.UTC), analysis.NextRuns[0])
+	assert.Equal(t, time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC), *analysis.ExpectedLastRun)
+	assert.True(t, analysis.Missed, "the run at 10:00 was not scheduled")
+	assert.Equal(t, int64(3600), analysis.IntervalSeconds)
+	assert.Equal(t, int64(4200), *analysis.TypicalDurationSeconds)
+	assert.True(t, analysis.ConcurrencyRisk)
+
+	suspend := true
+	cronjob.Suspend = &suspend
+	assert.False(t, AnalyzeCronjob(cronjob, nil, now).Missed, "suspended cronjobs do not miss runs")
+
+	cronjob.Schedule = "every hour"
+	assert.NotEmpty(t, AnalyzeCronjob(cronjob, nil, now).Error)
+}
+
+func TestCronjobCalendar(t *testing.T) {
+	suspend := true
+	cronjobs := []Workload{
+		CronjobWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "b", Namespace: "default"}, Schedule: "0 */6 * * *"},
+		CronjobWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "a", Namespace: "default"}, Schedule: "@daily"},
+		CronjobWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "suspended", Namespace: "default"}, Schedule: "@hourly", Suspend: &suspend},
+		CronjobWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "invalid", Namespace: "default"}, Schedule: "@never"},
+	}
+
+	from := time.Date(2023, 1, 22, 0, 0, 0, 0, time.UTC)
+	runs := CronjobCalendar(cronjobs, from, from.Add(time.Hour*12), 100)
+	require.Len(t, runs, 4, "the bounds of the range are included")
+	assert.Equal(t, "a", runs[0].Cronjob, "runs at the same time are sorted by name")
+	assert.Equal(t, "b", runs[1].Cronjob)
+	assert.Equal(t, from.Add(time.Hour*12), runs[3].Time)
+
+	assert.Len(t, CronjobCalendar(cronjobs, from, from.Add(time.Hour*12), 2), 3, "the runs of a single cronjob are limited")
+}
@@ -0,0 +1,22 @@
+package models
+
+// NamespaceDetails - represents a namespace with its workloads, events and the warnings of its quotas and limit ranges
+type NamespaceDetails struct {
+	Namespace Namespace          `json:"namespace"`
+	Workloads []Workload         `json:"workloads"`
+	Events    []Event            `json:"events"`
+	Warnings  []NamespaceWarning `json:"warnings"`
+}
+
+// PodDetails - represents a pod with the metrics of its containers
+type PodDetails struct {
+	Workload Workload             `json:"workload"`
+	Metrics  []PodContainerMetric `json:"metrics"`
+}
+
+// WorkloadDetails - represents a workload with its pods and their container metrics
+type WorkloadDetails struct {
+	Workload Workload             `json:"workload"`
+	Pods     []Workload           `json:"pods"`
+	Metrics  []PodContainerMetric `json:"metrics"`
+}
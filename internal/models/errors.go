@@ -0,0 +1,23 @@
+package models
+
+import "errors"
+
+/**
+	Errors of the data store and the kubernetes api adapter wrap one of these errors, check them with errors.Is.
+	The api maps them to the http status codes.
+**/
+
+var (
+	// ErrNotFound - the requested resource does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized - the request carries no or invalid credentials
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden - the access to the resource is not allowed
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnavailable - an upstream service like the kubernetes api can't be reached
+	ErrUnavailable = errors.New("upstream unavailable")
+	// ErrInvalidFilter - a filter, selector or option of the request is invalid
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrExpired - the requested position of a stream is not kept anymore
+	ErrExpired = errors.New("expired")
+)
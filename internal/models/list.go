@@ -0,0 +1,346 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+	List requests can be paginated, sorted and projected.
+	Attributes are addressed by their dotted json path in the api representation, e.g. workload_info.workload_name or status.ready.
+**/
+
+// SortField - a single attribute to sort by
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions - pagination, sorting & field selection of a list request
+type ListOptions struct {
+	Limit  int // 0 returns all items
+	Offset int
+	Sort   []SortField
+	Fields []string
+}
+
+// ListMetadata - describes the returned page of a list
+type ListMetadata struct {
+	Total    int    `json:"total"`
+	Continue string `json:"continue,omitempty"` // token of the next page, empty on the last page
+}
+
+// Page - represents a single page of a list
+type Page[T any] struct {
+	Items    []T
+	Metadata ListMetadata
+}
+
+// Stream - a page of a list whose items are read one after another, e.g. to export a list without holding it in memory
+type Stream[T any] struct {
+	Metadata ListMetadata
+	each     func(fn func(T) error) error
+}
+
+// ParseSort parses a comma separated list of fields, a leading - sorts descending, e.g. "-restarts,workload_info.workload_name"
+func ParseSort(value string) ([]SortField, error) {
+	fields := make([]SortField, 0)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		sortField := SortField{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if sortField.Field == "" {
+			return nil, fmt.Errorf("%w: invalid sort field %s", ErrInvalidFilter, field)
+		}
+		fields = append(fields, sortField)
+	}
+
+	return fields, nil
+}
+
+// ParseFields parses a comma separated list of fields
+func ParseFields(value string) []string {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// EncodeContinue returns the opaque token of the page starting at offset
+func EncodeContinue(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// DecodeContinue returns the offset of a token created by EncodeContinue
+func DecodeContinue(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid continue token", ErrInvalidFilter)
+	}
+
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w: invalid continue token", ErrInvalidFilter)
+	}
+
+	return offset, nil
+}
+
+// NewPage returns the page of items loaded with the options out of total items
+func NewPage[T any](items []T, total int, opts ListOptions) *Page[T] {
+	page := &Page[T]{Items: items, Metadata: ListMetadata{Total: total}}
+	if next := opts.Offset + len(items); opts.Limit > 0 && next < total {
+		page.Metadata.Continue = EncodeContinue(next)
+	}
+
+	return page
+}
+
+// NewStream returns the stream of the page requested with the options out of total items, each reads the items of the page
+func NewStream[T any](total int, opts ListOptions, each func(fn func(T) error) error) *Stream[T] {
+	stream := &Stream[T]{Metadata: ListMetadata{Total: total}, each: each}
+	if next := opts.Offset + opts.Limit; opts.Limit > 0 && next < total {
+		stream.Metadata.Continue = EncodeContinue(next)
+	}
+
+	return stream
+}
+
+// StreamPage returns the stream of a page which is already loaded
+func StreamPage[T any](page *Page[T]) *Stream[T] {
+	return &Stream[T]{Metadata: page.Metadata, each: func(fn func(T) error) error {
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// Each calls fn for every item of the page, it stops at the first error of fn
+func (s *Stream[T]) Each(fn func(T) error) error {
+	return s.each(fn)
+}
+
+// Page reads all items of the stream
+func (s *Stream[T]) Page() (*Page[T], error) {
+	items := make([]T, 0)
+	if err := s.each(func(item T) error {
+		items = append(items, item)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Page[T]{Items: items, Metadata: s.Metadata}, nil
+}
+
+// PaginateList sorts the items by the fields of the options and returns the requested page.
+// It is used for lists which are not loaded from the database, the order of equal items is kept.
+func PaginateList[T any](items []T, opts ListOptions) (*Page[T], error) {
+	if len(opts.Sort) > 0 {
+		values := make([]map[string]any, len(items))
+		for i, item := range items {
+			value, err := toJSONMap(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+
+		indices := make([]int, len(items))
+		for i := range indices {
+			indices[i] = i
+		}
+
+		sort.SliceStable(indices, func(i, j int) bool {
+			for _, field := range opts.Sort {
+				a, _ := LookupField(values[indices[i]], field.Field)
+				b, _ := LookupField(values[indices[j]], field.Field)
+				if c := compareValues(a, b); c != 0 {
+					return (c < 0) != field.Desc
+				}
+			}
+			return false
+		})
+
+		sorted := make([]T, len(items))
+		for i, index := range indices {
+			sorted[i] = items[index]
+		}
+		items = sorted
+	}
+
+	total := len(items)
+	start := opts.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if opts.Limit > 0 && start+opts.Limit < total {
+		end = start + opts.Limit
+	}
+
+	return NewPage(items[start:end], total, opts), nil
+}
+
+// ProjectFields returns the items reduced to the fields, all fields are kept when no field is given
+func ProjectFields[T any](items []T, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return items, nil
+	}
+
+	projected := make([]map[string]any, len(items))
+	for i, item := range items {
+		value, err := projectItem(item, fields)
+		if err != nil {
+			return nil, err
+		}
+		projected[i] = value
+	}
+
+	return projected, nil
+}
+
+// ProjectItem returns a single item reduced to the fields, the item itself is returned when no field is given
+func ProjectItem[T any](item T, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return item, nil
+	}
+
+	return projectItem(item, fields)
+}
+
+func projectItem(item any, fields []string) (map[string]any, error) {
+	value, err := toJSONMap(item)
+	if err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]any)
+	for _, field := range fields {
+		setField(projected, value, field)
+	}
+
+	return projected, nil
+}
+
+// LookupField returns the value at the dotted json path, map keys containing dots (e.g. labels) are supported
+func LookupField(object map[string]any, path string) (any, bool) {
+	if value, ok := object[path]; ok {
+		return value, true
+	}
+
+	for i := range path {
+		if path[i] != '.' {
+			continue
+		}
+
+		if child, ok := object[path[:i]].(map[string]any); ok {
+			if value, ok := LookupField(child, path[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// setField copies the value at the dotted json path of the source to the same path of the target
+func setField(target map[string]any, source map[string]any, path string) {
+	if value, ok := source[path]; ok {
+		target[path] = value
+		return
+	}
+
+	for i := range path {
+		if path[i] != '.' {
+			continue
+		}
+
+		child, ok := source[path[:i]].(map[string]any)
+		if !ok {
+			continue
+		}
+		if _, ok := LookupField(child, path[i+1:]); !ok {
+			continue
+		}
+
+		targetChild, ok := target[path[:i]].(map[string]any)
+		if !ok {
+			targetChild = make(map[string]any)
+			target[path[:i]] = targetChild
+		}
+		setField(targetChild, child, path[i+1:])
+		return
+	}
+}
+
+func toJSONMap(item any) (map[string]any, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	value := make(map[string]any)
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// compareValues compares json values, missing values are sorted first like NULL in sqlite
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
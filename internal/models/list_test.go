@@ -0,0 +1,61 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	sort, err := ParseSort("-restarts, workload_info.workload_name,")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: "restarts", Desc: true}, {Field: "workload_info.workload_name"}}, sort)
+
+	_, err = ParseSort("-")
+	assert.Error(t, err)
+}
+
+func TestContinueToken(t *testing.T) {
+	offset, err := DecodeContinue(EncodeContinue(42))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, offset)
+
+	_, err = DecodeContinue("not a token")
+	assert.Error(t, err)
+}
+
+func TestPaginateList(t *testing.T) {
+	nodes := []Node{
+		{Name: "a", Cpu: 2, Labels: map[string]string{"topology.kubernetes.io/zone": "b"}},
+		{Name: "b", Cpu: 4, Labels: map[string]string{"topology.kubernetes.io/zone": "a"}},
+		{Name: "c", Cpu: 2},
+	}
+
+	sort, _ := ParseSort("-cpu,labels.topology.kubernetes.io/zone")
+	page, err := PaginateList(nodes, ListOptions{Limit: 2, Sort: sort})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, []string{page.Items[0].Name, page.Items[1].Name})
+	assert.Equal(t, 3, page.Metadata.Total)
+	assert.NotEmpty(t, page.Metadata.Continue)
+
+	offset, _ := DecodeContinue(page.Metadata.Continue)
+	page, err = PaginateList(nodes, ListOptions{Limit: 2, Offset: offset, Sort: sort})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", page.Items[0].Name)
+	assert.Empty(t, page.Metadata.Continue)
+}
+
+func TestProjectFields(t *testing.T) {
+	nodes := []Node{{Name: "a", Cpu: 2, Labels: map[string]string{"topology.kubernetes.io/zone": "b", "other": "x"}}}
+
+	projected, err := ProjectFields(nodes, []string{"name", "labels.topology.kubernetes.io/zone", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{{
+		"name":   "a",
+		"labels": map[string]any{"topology.kubernetes.io/zone": "b"},
+	}}, projected)
+
+	all, err := ProjectFields(nodes, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, all)
+}
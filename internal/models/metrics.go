@@ -2,6 +2,9 @@ package models
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,67 +17,109 @@ type PodContainerMetric struct {
 	CreationTimestamp time.Time `json:"creation_date"`
 }
 
-func ReduceMetrics(metrics []PodContainerMetric, rate time.Duration) []PodContainerMetric {
-	result := make([]PodContainerMetric, 0)
-	tmp := make(map[string][]PodContainerMetric)
+const (
+	AGGREGATION_MIN  string = "min"
+	AGGREGATION_LAST string = "last"
+)
 
-	/**
-		Iterate through result and split it up by pod & container name.
-	**/
+// IsValidReduceAggregation checks if the aggregation can be used to reduce metrics.
+// Besides max, min, avg and last every percentile in the form pNN (e.g. p50, p99) is supported.
+func IsValidReduceAggregation(aggregation string) bool {
+	switch aggregation {
+	case AGGREGATION_MAX, AGGREGATION_MIN, AGGREGATION_AVG, AGGREGATION_LAST:
+		return true
+	}
 
-	for _, metric := range metrics {
-		key := fmt.Sprintf("%s_%s", metric.PodName, metric.ContainerName)
-		if _, ok := tmp[key]; !ok {
-			tmp[key] = make([]PodContainerMetric, 0)
-		}
+	_, ok := parsePercentile(aggregation)
+	return ok
+}
+
+// parsePercentile returns the percentile of an aggregation in the form pNN
+func parsePercentile(aggregation string) (float64, bool) {
+	if !strings.HasPrefix(aggregation, "p") {
+		return 0, false
+	}
+
+	p, err := strconv.ParseFloat(strings.TrimPrefix(aggregation, "p"), 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+
+	return p, true
+}
+
+// reduceValues combines values ordered by time into a single value
+func reduceValues(values []int64, aggregation string) int64 {
+	if len(values) == 0 {
+		return 0
+	}
 
-		tmp[key] = append(tmp[key], metric)
+	switch aggregation {
+	case AGGREGATION_MIN:
+		return CalculateStatistics(values).Min
+	case AGGREGATION_AVG:
+		return CalculateStatistics(values).Avg
+	case AGGREGATION_LAST:
+		return values[len(values)-1]
 	}
 
+	if p, ok := parsePercentile(aggregation); ok {
+		return Percentile(values, p)
+	}
+
+	return CalculateStatistics(values).Max
+}
+
+// ReduceMetrics reduces the metrics of every container to one metric per rate.
+// The windows are aligned to wall-clock buckets, cpu and memory are aggregated independently.
+// The creation timestamp of a reduced metric is the start of its bucket.
+func ReduceMetrics(metrics []PodContainerMetric, rate time.Duration, aggregation string) []PodContainerMetric {
+	type bucket struct {
+		metric PodContainerMetric
+		cpu    []int64
+		memory []int64
+	}
+
+	sorted := make([]PodContainerMetric, len(metrics))
+	copy(sorted, metrics)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreationTimestamp.Before(sorted[j].CreationTimestamp) })
+
 	/**
-		go through pod & containers, and finding max values within rate
+		split up the metrics by pod, container & bucket
 	**/
 
-	for _, podContainers := range tmp {
-		/**
-			Get First Value
-		**/
-		if len(podContainers) > 0 {
-			startDate := podContainers[0].CreationTimestamp
-			lastMetric := podContainers[0]
-			for _, metric := range podContainers {
-				/**
-					if the timespan is created than the provided rate
-					we need to store the new date in startDate to have the beginning for the sequence again.
-					the last metric with the higher value needs than to be added to the result list.
-				**/
-
-				diff := metric.CreationTimestamp.Sub(startDate)
-				if diff > rate {
-					result = append(result, lastMetric)
-					startDate = metric.CreationTimestamp
-					/**
-						Setting lastMetric to first value!
-					**/
-					lastMetric = metric
-				}
-
-				// What need to be taken?
-				// Or maybe it should get split by memory & cpu
-				// when cpu usage is higher or when memusage is higher or
-				// we take two values? with the highest cpu & memory
-
-				if metric.CPUUsage > lastMetric.CPUUsage || metric.MemoryUsage > lastMetric.MemoryUsage {
-					lastMetric = metric
-				}
-			}
-
-			/**
-				Add the last metric to the list
-			**/
-			result = append(result, lastMetric)
+	buckets := make(map[string]*bucket)
+	for _, metric := range sorted {
+		start := metric.CreationTimestamp.Truncate(rate)
+		key := fmt.Sprintf("%s_%s_%s_%d", metric.Namespace, metric.PodName, metric.ContainerName, start.Unix())
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{metric: metric}
+			b.metric.CreationTimestamp = start
+			buckets[key] = b
 		}
+
+		b.cpu = append(b.cpu, metric.CPUUsage)
+		b.memory = append(b.memory, metric.MemoryUsage)
+	}
+
+	result := make([]PodContainerMetric, 0, len(buckets))
+	for _, b := range buckets {
+		metric := b.metric
+		metric.CPUUsage = reduceValues(b.cpu, aggregation)
+		metric.MemoryUsage = reduceValues(b.memory, aggregation)
+		result = append(result, metric)
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreationTimestamp.Equal(result[j].CreationTimestamp) {
+			return result[i].CreationTimestamp.Before(result[j].CreationTimestamp)
+		}
+		if result[i].PodName != result[j].PodName {
+			return result[i].PodName < result[j].PodName
+		}
+		return result[i].ContainerName < result[j].ContainerName
+	})
+
 	return result
 }
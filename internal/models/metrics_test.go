@@ -52,7 +52,7 @@ func TestReduceMetrics(t *testing.T) {
 		},
 	}
 
-	result := ReduceMetrics(metrics, time.Minute*5)
+	result := ReduceMetrics(metrics, time.Minute*5, AGGREGATION_MAX)
 	assert.Len(t, result, 2)
 	assert.Equal(t, int64(20), result[0].CPUUsage)
 	assert.Equal(t, int64(20), result[0].MemoryUsage)
@@ -60,3 +60,77 @@ func TestReduceMetrics(t *testing.T) {
 	assert.Equal(t, int64(15), result[1].CPUUsage)
 	assert.Equal(t, int64(13), result[1].MemoryUsage)
 }
+
+func TestReduceMetricsAggregations(t *testing.T) {
+	start := time.Date(2023, time.January, 22, 10, 0, 0, 0, time.UTC)
+	metric := func(offset time.Duration, cpu int64, memory int64) PodContainerMetric {
+		return PodContainerMetric{
+			PodName:           "a",
+			ContainerName:     "a",
+			Namespace:         "a",
+			CPUUsage:          cpu,
+			MemoryUsage:       memory,
+			CreationTimestamp: start.Add(offset),
+		}
+	}
+
+	// the first bucket has the highest cpu and the highest memory in different samples
+	metrics := []PodContainerMetric{
+		metric(time.Minute*4, 30, 10),
+		metric(time.Minute*1, 10, 40),
+		metric(time.Minute*2, 20, 20),
+		metric(time.Minute*3, 40, 30),
+		metric(time.Minute*6, 5, 5),
+	}
+
+	tests := []struct {
+		name        string
+		aggregation string
+		cpu         int64
+		memory      int64
+	}{
+		{name: "max", aggregation: AGGREGATION_MAX, cpu: 40, memory: 40},
+		{name: "min", aggregation: AGGREGATION_MIN, cpu: 10, memory: 10},
+		{name: "avg", aggregation: AGGREGATION_AVG, cpu: 25, memory: 25},
+		{name: "last", aggregation: AGGREGATION_LAST, cpu: 30, memory: 10},
+		{name: "p50", aggregation: "p50", cpu: 20, memory: 20},
+		{name: "p95", aggregation: AGGREGATION_P95, cpu: 40, memory: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ReduceMetrics(metrics, time.Minute*5, tt.aggregation)
+			assert.Len(t, result, 2)
+
+			// windows are aligned to wall-clock buckets
+			assert.Equal(t, start, result[0].CreationTimestamp)
+			assert.Equal(t, start.Add(time.Minute*5), result[1].CreationTimestamp)
+
+			assert.Equal(t, tt.cpu, result[0].CPUUsage)
+			assert.Equal(t, tt.memory, result[0].MemoryUsage)
+			assert.Equal(t, int64(5), result[1].CPUUsage)
+		})
+	}
+}
+
+func TestIsValidReduceAggregation(t *testing.T) {
+	tests := []struct {
+		aggregation string
+		valid       bool
+	}{
+		{aggregation: "max", valid: true},
+		{aggregation: "min", valid: true},
+		{aggregation: "avg", valid: true},
+		{aggregation: "last", valid: true},
+		{aggregation: "p99", valid: true},
+		{aggregation: "p99.9", valid: true},
+		{aggregation: "p0", valid: false},
+		{aggregation: "p101", valid: false},
+		{aggregation: "sum", valid: false},
+		{aggregation: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.valid, IsValidReduceAggregation(tt.aggregation), tt.aggregation)
+	}
+}
@@ -8,4 +8,6 @@ type Namespace struct {
 	Labels            map[string]string `json:"labels"`
 	Annotations       map[string]string `json:"annotations"`
 	CreationTimestamp time.Time         `json:"creation_date"`
+	ResourceQuotas    []ResourceQuota   `json:"resource_quotas"`
+	LimitRanges       []LimitRange      `json:"limit_ranges"`
 }
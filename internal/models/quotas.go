@@ -0,0 +1,137 @@
+package models
+
+import (
+	"fmt"
+)
+
+/**
+	The resource quotas and limit ranges of a namespace are collected with the namespace.
+	Warnings are raised when a quota is close to be exhausted and when the resources of the containers
+	don't comply with the limit ranges, e.g. of workloads created before the limit range.
+**/
+
+// QUOTA_WARNING_UTILIZATION - utilization in percent of the hard limit of a quota from which on a warning is raised
+const QUOTA_WARNING_UTILIZATION = 90.0
+
+const (
+	NAMESPACE_WARNING_QUOTA       = "quota"
+	NAMESPACE_WARNING_LIMIT_RANGE = "limit_range"
+)
+
+// LIMIT_RANGE_TYPE_CONTAINER - the type of the limit range items applied to every container
+const LIMIT_RANGE_TYPE_CONTAINER = "Container"
+
+// ResourceQuota - represents a resource quota of a namespace
+type ResourceQuota struct {
+	Name      string          `json:"name"`
+	Resources []QuotaResource `json:"resources"` // sorted by resource
+}
+
+// QuotaResource - the usage of a resource limited by a quota
+type QuotaResource struct {
+	Resource    string  `json:"resource"` // e.g. requests.cpu, limits.memory or pods
+	Hard        string  `json:"hard"`     // quantities as in kubernetes, e.g. 10 or 16Gi
+	Used        string  `json:"used"`
+	Utilization float64 `json:"utilization"` // percent of hard used
+}
+
+// LimitRange - represents a limit range of a namespace
+type LimitRange struct {
+	Name   string           `json:"name"`
+	Limits []LimitRangeItem `json:"limits"`
+}
+
+// LimitRangeItem - the constraints of the cpu in millicores and the memory in bytes of a type, 0 if not constrained
+type LimitRangeItem struct {
+	Type           string         `json:"type"` // Container, Pod or PersistentVolumeClaim
+	Min            ResourceValues `json:"min"`
+	Max            ResourceValues `json:"max"`
+	Default        ResourceValues `json:"default"`         // the default limits
+	DefaultRequest ResourceValues `json:"default_request"` // the default requests
+}
+
+// NamespaceWarning - a quota close to be exhausted or a container not complying with a limit range
+type NamespaceWarning struct {
+	Kind         string `json:"kind"`     // quota or limit_range
+	Name         string `json:"name"`     // the name of the resource quota or limit range
+	Resource     string `json:"resource"` // the resource of the quota, cpu or memory for limit ranges
+	WorkloadType string `json:"workload_type,omitempty"`
+	WorkloadName string `json:"workload_name,omitempty"`
+	Container    string `json:"container,omitempty"`
+	Message      string `json:"message"`
+}
+
+// NamespaceWarnings returns the warnings of the quotas of the namespace followed by the warnings of the limit ranges per workload.
+// Pods owned by a workload are skipped, the warnings of their containers are raised for the workload.
+func NamespaceWarnings(namespace Namespace, workloads []Workload) []NamespaceWarning {
+	warnings := make([]NamespaceWarning, 0)
+	for _, quota := range namespace.ResourceQuotas {
+		for _, resource := range quota.Resources {
+			if resource.Utilization < QUOTA_WARNING_UTILIZATION {
+				continue
+			}
+
+			warnings = append(warnings, NamespaceWarning{
+				Kind:     NAMESPACE_WARNING_QUOTA,
+				Name:     quota.Name,
+				Resource: resource.Resource,
+				Message:  fmt.Sprintf("%s used %s of %s (%.0f%%)", resource.Resource, resource.Used, resource.Hard, resource.Utilization),
+			})
+		}
+	}
+
+	for _, limitRange := range namespace.LimitRanges {
+		for _, item := range limitRange.Limits {
+			if item.Type != LIMIT_RANGE_TYPE_CONTAINER {
+				continue
+			}
+
+			for _, workload := range workloads {
+				if pod, ok := workload.(PodWorkload); ok && len(pod.PodOwnerRessources) > 0 {
+					continue
+				}
+
+				for _, container := range workload.GetContainers() {
+					for _, warning := range limitRangeViolations(item, container) {
+						warning.Name = limitRange.Name
+						warning.WorkloadType = workload.GetType()
+						warning.WorkloadName = workload.GetWorkloadName()
+						warnings = append(warnings, warning)
+					}
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+// limitRangeViolations returns a warning for every violation of the limit range item by the container
+func limitRangeViolations(item LimitRangeItem, container Container) []NamespaceWarning {
+	violations := make([]NamespaceWarning, 0)
+	add := func(resource string, message string) {
+		violations = append(violations, NamespaceWarning{Kind: NAMESPACE_WARNING_LIMIT_RANGE, Resource: resource, Container: container.ContainerName, Message: message})
+	}
+	check := func(resource string, request int64, limit int64, min int64, max int64, defaultLimit int64, format func(int64) string) {
+		// kubernetes defaults the limit to the max if no default is set
+		if defaultLimit == 0 {
+			defaultLimit = max
+		}
+
+		switch {
+		case limit == 0 && defaultLimit > 0:
+			add(resource, fmt.Sprintf("%s limit is not set, the limit range defaults it to %s", resource, format(defaultLimit)))
+		case max > 0 && limit > max:
+			add(resource, fmt.Sprintf("%s limit %s exceeds the max %s", resource, format(limit), format(max)))
+		}
+
+		if min > 0 && request > 0 && request < min {
+			add(resource, fmt.Sprintf("%s request %s is below the min %s", resource, format(request), format(min)))
+		}
+	}
+
+	check("cpu", container.RequestCPU, container.LimitCPU, item.Min.CPU, item.Max.CPU, item.Default.CPU, FormatCPUQuantity)
+	check("memory", container.RequestMemory, container.LimitMemory, item.Min.Memory, item.Max.Memory, item.Default.Memory, FormatMemoryQuantity)
+
+	return violations
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceWarnings(t *testing.T) {
+	namespace := Namespace{
+		Name: "shop",
+		ResourceQuotas: []ResourceQuota{{Name: "compute", Resources: []QuotaResource{
+			{Resource: "limits.memory", Hard: "16Gi", Used: "15Gi", Utilization: 93.75},
+			{Resource: "pods", Hard: "20", Used: "17", Utilization: 85},
+		}}},
+		LimitRanges: []LimitRange{{Name: "defaults", Limits: []LimitRangeItem{
+			{Type: "Pod", Max: ResourceValues{CPU: 100}},
+			{Type: LIMIT_RANGE_TYPE_CONTAINER, Min: ResourceValues{CPU: 50}, Max: ResourceValues{CPU: 2000, Memory: 1024 * mebibyte}, Default: ResourceValues{Memory: 512 * mebibyte}},
+		}}},
+	}
+	web := DeploymentWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "web", Namespace: "shop", Containers: []Container{
+		{ContainerName: "web", RequestCPU: 10, LimitCPU: 4000, RequestMemory: 256 * mebibyte, LimitMemory: 512 * mebibyte},
+		{ContainerName: "sidecar", RequestCPU: 100, LimitCPU: 500, RequestMemory: 64 * mebibyte},
+	}}}
+	owned := PodWorkload{GeneralWorkloadInfo: web.GeneralWorkloadInfo, PodOwnerRessources: []PodOwnerRessource{{Kind: "ReplicaSet", Name: "web-5d4f"}}}
+
+	warnings := NamespaceWarnings(namespace, []Workload{web, owned})
+	require.Len(t, warnings, 4, "pods below the threshold, pod limit ranges and owned pods are skipped")
+	assert.Equal(t, NamespaceWarning{Kind: NAMESPACE_WARNING_QUOTA, Name: "compute", Resource: "limits.memory", Message: "limits.memory used 15Gi of 16Gi (94%)"}, warnings[0])
+
+	messages := make([]string, 0, len(warnings)-1)
+	for _, warning := range warnings[1:] {
+		assert.Equal(t, NAMESPACE_WARNING_LIMIT_RANGE, warning.Kind)
+		assert.Equal(t, "defaults", warning.Name)
+		assert.Equal(t, WORKLOAD_TYPE_DEPLOYMENT, warning.WorkloadType)
+		messages = append(messages, warning.Container+": "+warning.Message)
+	}
+	assert.Equal(t, []string{
+		"web: cpu limit 4000m exceeds the max 2000m",
+		"web: cpu request 10m is below the min 50m",
+		"sidecar: memory limit is not set, the limit range defaults it to 512Mi",
+	}, messages)
+}
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+/**
+	The recommendations compare the usage of the containers of a workload with their requests and limits.
+	The requests are derived from the p95 usage and the limits from the max usage, both increased by the headroom.
+	The samples of all pods of a workload are combined per container name.
+**/
+
+const (
+	RECOMMENDATION_OPTIMAL           = "optimal"
+	RECOMMENDATION_OVER_PROVISIONED  = "over_provisioned"
+	RECOMMENDATION_UNDER_PROVISIONED = "under_provisioned"
+	RECOMMENDATION_MISSING_LIMITS    = "missing_limits"
+	RECOMMENDATION_INSUFFICIENT_DATA = "insufficient_data"
+)
+
+const (
+	DEFAULT_RECOMMENDATION_HEADROOM = 20.0 // percent added to the usage
+	RECOMMENDATION_MIN_SAMPLES      = 12   // samples of a container required for a recommendation
+	RECOMMENDATION_OVER_FACTOR      = 2.0  // a request is over-provisioned when it exceeds the recommendation by this factor
+	RECOMMENDATION_LIMIT_PERCENT    = 90.0 // a limit is under-provisioned when the max usage reaches this percentage of it
+	RECOMMENDATION_MIN_CPU          = 10   // millicores
+	RECOMMENDATION_MIN_MEMORY       = 16 * mebibyte
+)
+
+const mebibyte = 1024 * 1024
+
+// recommendation_severity orders the classifications, the most severe classification of its containers classifies a workload
+var recommendation_severity = map[string]int{
+	RECOMMENDATION_OPTIMAL:           0,
+	RECOMMENDATION_INSUFFICIENT_DATA: 1,
+	RECOMMENDATION_OVER_PROVISIONED:  2,
+	RECOMMENDATION_MISSING_LIMITS:    3,
+	RECOMMENDATION_UNDER_PROVISIONED: 4,
+}
+
+// IsValidRecommendationClassification checks if the classification is supported
+func IsValidRecommendationClassification(classification string) bool {
+	_, ok := recommendation_severity[classification]
+	return ok
+}
+
+// ContainerSamples - the usage samples of a container, cpu in millicores and memory in bytes.
+// The peaks are the max usage of the samples, the samples of the rollup tiers are the p95 of their buckets.
+type ContainerSamples struct {
+	CPU        []int64
+	Memory     []int64
+	CPUPeak    int64
+	MemoryPeak int64
+}
+
+// Add appends a sample with the peak usage within the sample
+func (s *ContainerSamples) Add(cpu int64, memory int64, cpuPeak int64, memoryPeak int64) {
+	s.CPU = append(s.CPU, cpu)
+	s.Memory = append(s.Memory, memory)
+	if cpuPeak > s.CPUPeak {
+		s.CPUPeak = cpuPeak
+	}
+	if memoryPeak > s.MemoryPeak {
+		s.MemoryPeak = memoryPeak
+	}
+}
+
+// ResourceUsage - the usage of a resource within the time range
+type ResourceUsage struct {
+	P95 int64 `json:"p95"`
+	Max int64 `json:"max"`
+}
+
+// ResourceValues - cpu in millicores and memory in bytes, 0 when not set
+type ResourceValues struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// ResourceRequirements - the requests and limits of a container
+type ResourceRequirements struct {
+	Requests ResourceValues `json:"requests"`
+	Limits   ResourceValues `json:"limits"`
+}
+
+// ContainerRecommendation - the recommended resources of a container
+type ContainerRecommendation struct {
+	ContainerName  string               `json:"container_name"`
+	Samples        int                  `json:"samples"`
+	CPUUsage       ResourceUsage        `json:"cpu_usage"`
+	MemoryUsage    ResourceUsage        `json:"memory_usage"`
+	Current        ResourceRequirements `json:"current"`
+	Recommended    ResourceRequirements `json:"recommended"` // zero values with insufficient data
+	Classification string               `json:"classification"`
+	Reasons        []string             `json:"reasons"`
+}
+
+// WorkloadRecommendation - the recommended resources of the containers of a workload
+type WorkloadRecommendation struct {
+	Namespace      string                    `json:"namespace"`
+	WorkloadType   string                    `json:"workload_type"`
+	WorkloadName   string                    `json:"workload_name"`
+	Classification string                    `json:"classification"` // the most severe classification of the containers
+	Containers     []ContainerRecommendation `json:"containers"`
+	Patch          json.RawMessage           `json:"patch,omitempty"` // strategic merge patch of the recommended resources
+}
+
+// Merge appends the samples of another pod of the workload
+func (s *ContainerSamples) Merge(other *ContainerSamples) {
+	s.CPU = append(s.CPU, other.CPU...)
+	s.Memory = append(s.Memory, other.Memory...)
+	if other.CPUPeak > s.CPUPeak {
+		s.CPUPeak = other.CPUPeak
+	}
+	if other.MemoryPeak > s.MemoryPeak {
+		s.MemoryPeak = other.MemoryPeak
+	}
+}
+
+// RecommendWorkload returns the recommendations of the containers of the workload, init containers are skipped.
+// The samples are the usage of the containers of all pods of the workload by container name, headroom is in percent.
+func RecommendWorkload(w Workload, samples map[string]*ContainerSamples, headroom float64) WorkloadRecommendation {
+	result := WorkloadRecommendation{
+		Namespace:      w.GetNamespace(),
+		WorkloadType:   w.GetType(),
+		WorkloadName:   w.GetWorkloadName(),
+		Classification: RECOMMENDATION_OPTIMAL,
+		Containers:     make([]ContainerRecommendation, 0),
+	}
+
+	for _, container := range w.GetContainers() {
+		if container.InitContainer {
+			continue
+		}
+
+		var containerSamples ContainerSamples
+		if s, ok := samples[container.ContainerName]; ok {
+			containerSamples = *s
+		}
+
+		recommendation := RecommendContainer(container, containerSamples, headroom)
+		if recommendation_severity[recommendation.Classification] > recommendation_severity[result.Classification] {
+			result.Classification = recommendation.Classification
+		}
+		result.Containers = append(result.Containers, recommendation)
+	}
+
+	return result
+}
+
+// RecommendContainer compares the usage of the container with its requests and limits, headroom is in percent
+func RecommendContainer(container Container, samples ContainerSamples, headroom float64) ContainerRecommendation {
+	result := ContainerRecommendation{
+		ContainerName: container.ContainerName,
+		Samples:       len(samples.CPU),
+		Current: ResourceRequirements{
+			Requests: ResourceValues{CPU: container.RequestCPU, Memory: container.RequestMemory},
+			Limits:   ResourceValues{CPU: container.LimitCPU, Memory: container.LimitMemory},
+		},
+		Reasons: make([]string, 0),
+	}
+
+	if result.Samples < RECOMMENDATION_MIN_SAMPLES {
+		result.Classification = RECOMMENDATION_INSUFFICIENT_DATA
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%d of %d samples", result.Samples, RECOMMENDATION_MIN_SAMPLES))
+		return result
+	}
+
+	result.CPUUsage = resourceUsage(samples.CPU, samples.CPUPeak)
+	result.MemoryUsage = resourceUsage(samples.Memory, samples.MemoryPeak)
+
+	cpuRequest := roundUp(withHeadroom(result.CPUUsage.P95, headroom), 1, RECOMMENDATION_MIN_CPU)
+	memoryRequest := roundUp(withHeadroom(result.MemoryUsage.P95, headroom), mebibyte, RECOMMENDATION_MIN_MEMORY)
+	result.Recommended = ResourceRequirements{
+		Requests: ResourceValues{CPU: cpuRequest, Memory: memoryRequest},
+		Limits: ResourceValues{
+			CPU:    roundUp(withHeadroom(result.CPUUsage.Max, headroom), 1, cpuRequest),
+			Memory: roundUp(withHeadroom(result.MemoryUsage.Max, headroom), mebibyte, memoryRequest),
+		},
+	}
+
+	classifications := make(map[string]bool)
+	check := func(resource string, unit func(int64) string, usage ResourceUsage, request int64, limit int64, recommended int64) {
+		switch {
+		case request == 0:
+			classifications[RECOMMENDATION_UNDER_PROVISIONED] = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("no %s request", resource))
+		case usage.P95 > request:
+			classifications[RECOMMENDATION_UNDER_PROVISIONED] = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s usage p95 %s exceeds the request %s", resource, unit(usage.P95), unit(request)))
+		case float64(request) > float64(recommended)*RECOMMENDATION_OVER_FACTOR:
+			classifications[RECOMMENDATION_OVER_PROVISIONED] = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s request %s exceeds the recommendation %s", resource, unit(request), unit(recommended)))
+		}
+
+		switch {
+		case limit == 0:
+			classifications[RECOMMENDATION_MISSING_LIMITS] = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("no %s limit", resource))
+		case float64(usage.Max) >= float64(limit)*RECOMMENDATION_LIMIT_PERCENT/100:
+			classifications[RECOMMENDATION_UNDER_PROVISIONED] = true
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s usage max %s reaches the limit %s", resource, unit(usage.Max), unit(limit)))
+		}
+	}
+	check("cpu", FormatCPUQuantity, result.CPUUsage, container.RequestCPU, container.LimitCPU, cpuRequest)
+	check("memory", FormatMemoryQuantity, result.MemoryUsage, container.RequestMemory, container.LimitMemory, memoryRequest)
+
+	result.Classification = RECOMMENDATION_OPTIMAL
+	for classification := range classifications {
+		if recommendation_severity[classification] > recommendation_severity[result.Classification] {
+			result.Classification = classification
+		}
+	}
+
+	return result
+}
+
+// resourceUsage returns the p95 and the max of the samples, the max is at least the peak
+func resourceUsage(values []int64, peak int64) ResourceUsage {
+	stats := CalculateStatistics(values)
+	if peak > stats.Max {
+		stats.Max = peak
+	}
+
+	return ResourceUsage{P95: stats.P95, Max: stats.Max}
+}
+
+// withHeadroom increases the value by headroom percent
+func withHeadroom(value int64, headroom float64) int64 {
+	return int64(math.Ceil(float64(value) * (1 + headroom/100)))
+}
+
+// roundUp rounds the value up to a multiple of step, it is at least min
+func roundUp(value int64, step int64, min int64) int64 {
+	if value%step != 0 {
+		value += step - value%step
+	}
+	if value < min {
+		return min
+	}
+
+	return value
+}
+
+// FormatCPUQuantity formats millicores as kubernetes quantity, e.g. 250m
+func FormatCPUQuantity(millicores int64) string {
+	return fmt.Sprintf("%dm", millicores)
+}
+
+// FormatMemoryQuantity formats bytes as kubernetes quantity, in Mi when the bytes are a multiple of it
+func FormatMemoryQuantity(bytes int64) string {
+	if bytes%mebibyte == 0 {
+		return fmt.Sprintf("%dMi", bytes/mebibyte)
+	}
+
+	return fmt.Sprintf("%d", bytes)
+}
+
+// RecommendationPatch returns the strategic merge patch applying the recommended resources to the workload.
+// Containers without a recommendation are not patched, the patch is nil when no container is patched.
+func RecommendationPatch(recommendation WorkloadRecommendation) (json.RawMessage, error) {
+	containers := make([]map[string]any, 0, len(recommendation.Containers))
+	for _, container := range recommendation.Containers {
+		if container.Classification == RECOMMENDATION_INSUFFICIENT_DATA {
+			continue
+		}
+
+		recommended := container.Recommended
+		containers = append(containers, map[string]any{
+			"name": container.ContainerName,
+			"resources": map[string]any{
+				"requests": map[string]string{"cpu": FormatCPUQuantity(recommended.Requests.CPU), "memory": FormatMemoryQuantity(recommended.Requests.Memory)},
+				"limits":   map[string]string{"cpu": FormatCPUQuantity(recommended.Limits.CPU), "memory": FormatMemoryQuantity(recommended.Limits.Memory)},
+			},
+		})
+	}
+
+	if len(containers) == 0 {
+		return nil, nil
+	}
+
+	var patch map[string]any
+	podSpec := map[string]any{"containers": containers}
+	switch recommendation.WorkloadType {
+	case WORKLOAD_TYPE_POD:
+		patch = map[string]any{"spec": podSpec}
+	case WORKLOAD_TYPE_DEPLOYMENT, WORKLOAD_TYPE_STATEFULSET, WORKLOAD_TYPE_DEAMONSET:
+		patch = map[string]any{"spec": map[string]any{"template": map[string]any{"spec": podSpec}}}
+	default:
+		return nil, fmt.Errorf("%w: no patch for workload type %s", ErrInvalidFilter, recommendation.WorkloadType)
+	}
+
+	return json.Marshal(patch)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendContainer(t *testing.T) {
+	samples := func(cpu int64, memory int64) ContainerSamples {
+		var s ContainerSamples
+		for i := 0; i < 20; i++ {
+			s.Add(cpu, memory, cpu, memory)
+		}
+		s.Add(cpu, memory, cpu*2, memory*2) // a single peak doesn't change the p95
+		return s
+	}
+
+	container := Container{ContainerName: "web", RequestCPU: 500, LimitCPU: 1000, RequestMemory: 512 * mebibyte, LimitMemory: 1024 * mebibyte}
+	over := RecommendContainer(container, samples(100, 100*mebibyte), DEFAULT_RECOMMENDATION_HEADROOM)
+	assert.Equal(t, RECOMMENDATION_OVER_PROVISIONED, over.Classification, over.Reasons)
+	assert.Equal(t, ResourceUsage{P95: 100, Max: 200}, over.CPUUsage)
+	assert.Equal(t, ResourceValues{CPU: 120, Memory: 120 * mebibyte}, over.Recommended.Requests)
+	assert.Equal(t, ResourceValues{CPU: 240, Memory: 240 * mebibyte}, over.Recommended.Limits)
+
+	under := RecommendContainer(container, samples(600, 100*mebibyte), DEFAULT_RECOMMENDATION_HEADROOM)
+	assert.Equal(t, RECOMMENDATION_UNDER_PROVISIONED, under.Classification, "the p95 exceeds the request and the max reaches the limit")
+	assert.Contains(t, under.Reasons, "cpu usage max 1200m reaches the limit 1000m")
+
+	unlimited := container
+	unlimited.LimitMemory = 0
+	assert.Equal(t, RECOMMENDATION_MISSING_LIMITS, RecommendContainer(unlimited, samples(400, 400*mebibyte), 0).Classification)
+	assert.Equal(t, RECOMMENDATION_OPTIMAL, RecommendContainer(container, samples(400, 400*mebibyte), 0).Classification)
+
+	tiny := RecommendContainer(container, samples(1, 1024), 0)
+	assert.Equal(t, ResourceValues{CPU: RECOMMENDATION_MIN_CPU, Memory: RECOMMENDATION_MIN_MEMORY}, tiny.Recommended.Requests)
+
+	missing := RecommendContainer(container, ContainerSamples{}, 0)
+	assert.Equal(t, RECOMMENDATION_INSUFFICIENT_DATA, missing.Classification)
+	assert.Zero(t, missing.Recommended)
+}
+
+func TestRecommendWorkload(t *testing.T) {
+	var web ContainerSamples
+	for i := 0; i < RECOMMENDATION_MIN_SAMPLES; i++ {
+		web.Add(100, 100*mebibyte, 100, 100*mebibyte)
+	}
+
+	deployment := DeploymentWorkload{GeneralWorkloadInfo: GeneralWorkloadInfo{WorkloadName: "web", Namespace: "default", Containers: []Container{
+		{ContainerName: "init", InitContainer: true},
+		{ContainerName: "web", RequestCPU: 1000, LimitCPU: 2000, RequestMemory: 1024 * mebibyte, LimitMemory: 2048 * mebibyte},
+		{ContainerName: "sidecar", RequestCPU: 10},
+	}}}
+
+	recommendation := RecommendWorkload(deployment, map[string]*ContainerSamples{"web": &web}, 50)
+	require.Len(t, recommendation.Containers, 2, "init containers are skipped")
+	assert.Equal(t, RECOMMENDATION_OVER_PROVISIONED, recommendation.Containers[0].Classification)
+	assert.Equal(t, RECOMMENDATION_INSUFFICIENT_DATA, recommendation.Containers[1].Classification)
+	assert.Equal(t, RECOMMENDATION_OVER_PROVISIONED, recommendation.Classification, "the most severe classification of the containers")
+
+	patch, err := RecommendationPatch(recommendation)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"template":{"spec":{"containers":[
+		{"name":"web","resources":{"requests":{"cpu":"150m","memory":"150Mi"},"limits":{"cpu":"150m","memory":"150Mi"}}}
+	]}}}}`, string(patch), "containers without a recommendation are not patched")
+}
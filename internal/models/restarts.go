@@ -0,0 +1,185 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+/**
+	The restart counts of containers only grow, the collected state does not tell when a container restarted.
+	Every increase observed by a collection is recorded with the reason of the last termination.
+	The rates and crash loops are derived from the recorded increases.
+**/
+
+const (
+	DEFAULT_CRASHLOOP_GAP       = time.Minute * 10 // the back-off of kubernetes is at most 5 minutes
+	DEFAULT_CRASHLOOP_THRESHOLD = 3
+)
+
+// ContainerRestart - an increase of the restarts of a container
+type ContainerRestart struct {
+	Timestamp     time.Time `json:"timestamp"` // end of the last termination, the time of the collection when unknown
+	Namespace     string    `json:"namespace"`
+	PodName       string    `json:"pod_name"`
+	ContainerName string    `json:"container_name"`
+	WorkloadType  string    `json:"workload_type"` // the workload the pod belongs to, the pod itself when it has no owner
+	WorkloadName  string    `json:"workload_name"`
+	Restarts      int       `json:"restarts"` // restarts of the container after the increase
+	Increase      int       `json:"increase"`
+	Reason        string    `json:"reason"` // reason of the last termination, e.g. OOMKilled or Error
+	ExitCode      int32     `json:"exit_code"`
+}
+
+// RestartFilter - selects recorded restarts, empty fields are not filtered
+type RestartFilter struct {
+	Namespace    string
+	WorkloadType string
+	WorkloadName string
+	PodName      string
+	From         time.Time
+	To           time.Time
+}
+
+// WorkloadRestarts - the restarts of the containers of a workload within a time range
+type WorkloadRestarts struct {
+	Namespace    string         `json:"namespace"`
+	WorkloadType string         `json:"workload_type"`
+	WorkloadName string         `json:"workload_name"`
+	Restarts     int            `json:"restarts"`
+	RatePerHour  float64        `json:"rate_per_hour"`
+	Pods         int            `json:"pods"` // number of pods with restarts
+	Reasons      map[string]int `json:"reasons"`
+	LastRestart  time.Time      `json:"last_restart"` // zero time without restarts
+}
+
+// CrashLoop - a period in which a container restarted repeatedly
+type CrashLoop struct {
+	Namespace     string         `json:"namespace"`
+	PodName       string         `json:"pod_name"`
+	ContainerName string         `json:"container_name"`
+	WorkloadType  string         `json:"workload_type"`
+	WorkloadName  string         `json:"workload_name"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"` // the last restart of the period
+	Restarts      int            `json:"restarts"`
+	Reasons       map[string]int `json:"reasons"`
+}
+
+// WorkloadRestartHistory - the restarts of a workload within a time range
+type WorkloadRestartHistory struct {
+	Summary    WorkloadRestarts   `json:"summary"`
+	Restarts   []ContainerRestart `json:"restarts"` // newest first
+	CrashLoops []CrashLoop        `json:"crash_loops"`
+}
+
+// AggregateRestarts sums up the restarts per workload, sorted by the number of restarts descending.
+// The rate is calculated for the time range from - to.
+func AggregateRestarts(restarts []ContainerRestart, from time.Time, to time.Time) []WorkloadRestarts {
+	groups := make(map[string][]ContainerRestart)
+	order := make([]string, 0)
+	for _, restart := range restarts {
+		key := restart.Namespace + "/" + restart.WorkloadType + "/" + restart.WorkloadName
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], restart)
+	}
+
+	result := make([]WorkloadRestarts, len(order))
+	for i, key := range order {
+		first := groups[key][0]
+		result[i] = SummarizeRestarts(first.Namespace, first.WorkloadType, first.WorkloadName, groups[key], from, to)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Restarts != result[j].Restarts {
+			return result[i].Restarts > result[j].Restarts
+		}
+		return result[i].LastRestart.After(result[j].LastRestart)
+	})
+
+	return result
+}
+
+// SummarizeRestarts sums up the restarts of a workload, the rate is calculated for the time range from - to
+func SummarizeRestarts(namespace string, workloadType string, workloadName string, restarts []ContainerRestart, from time.Time, to time.Time) WorkloadRestarts {
+	summary := WorkloadRestarts{Namespace: namespace, WorkloadType: workloadType, WorkloadName: workloadName, Reasons: make(map[string]int)}
+	pods := make(map[string]bool)
+	for _, restart := range restarts {
+		summary.Restarts += restart.Increase
+		summary.Reasons[restartReason(restart)] += restart.Increase
+		pods[restart.PodName] = true
+		if restart.Timestamp.After(summary.LastRestart) {
+			summary.LastRestart = restart.Timestamp
+		}
+	}
+
+	summary.Pods = len(pods)
+	if hours := to.Sub(from).Hours(); hours > 0 {
+		summary.RatePerHour = float64(summary.Restarts) / hours
+	}
+
+	return summary
+}
+
+// DetectCrashLoops returns the periods in which a container restarted at least threshold times without a pause longer than gap.
+// The crash loops are sorted by their start.
+func DetectCrashLoops(restarts []ContainerRestart, gap time.Duration, threshold int) []CrashLoop {
+	containers := make(map[string][]ContainerRestart)
+	for _, restart := range restarts {
+		key := restart.Namespace + "/" + restart.PodName + "/" + restart.ContainerName
+		containers[key] = append(containers[key], restart)
+	}
+
+	result := make([]CrashLoop, 0)
+	for _, history := range containers {
+		sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+
+		var current *CrashLoop
+		flush := func() {
+			if current != nil && current.Restarts >= threshold {
+				result = append(result, *current)
+			}
+			current = nil
+		}
+
+		for _, restart := range history {
+			if current != nil && restart.Timestamp.Sub(current.End) > gap {
+				flush()
+			}
+			if current == nil {
+				current = &CrashLoop{
+					Namespace:     restart.Namespace,
+					PodName:       restart.PodName,
+					ContainerName: restart.ContainerName,
+					WorkloadType:  restart.WorkloadType,
+					WorkloadName:  restart.WorkloadName,
+					Start:         restart.Timestamp,
+					Reasons:       make(map[string]int),
+				}
+			}
+
+			current.End = restart.Timestamp
+			current.Restarts += restart.Increase
+			current.Reasons[restartReason(restart)] += restart.Increase
+		}
+		flush()
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Start.Equal(result[j].Start) {
+			return result[i].Start.Before(result[j].Start)
+		}
+		return result[i].PodName+"/"+result[i].ContainerName < result[j].PodName+"/"+result[j].ContainerName
+	})
+
+	return result
+}
+
+func restartReason(restart ContainerRestart) string {
+	if restart.Reason == "" {
+		return "Unknown"
+	}
+
+	return restart.Reason
+}
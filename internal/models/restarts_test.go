@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartHistory(t *testing.T) {
+	start := time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+	restart := func(pod string, workload string, minutes int, increase int, reason string) ContainerRestart {
+		return ContainerRestart{
+			Timestamp:     start.Add(time.Minute * time.Duration(minutes)),
+			Namespace:     "default",
+			PodName:       pod,
+			ContainerName: "web",
+			WorkloadType:  WORKLOAD_TYPE_DEPLOYMENT,
+			WorkloadName:  workload,
+			Increase:      increase,
+			Reason:        reason,
+		}
+	}
+
+	restarts := []ContainerRestart{
+		restart("web-1", "web", 0, 1, "Error"),
+		restart("web-1", "web", 2, 1, "Error"),
+		restart("web-1", "web", 7, 1, "OOMKilled"),
+		restart("web-1", "web", 30, 1, "Error"), // the pause ends the crash loop
+		restart("web-2", "web", 31, 1, ""),
+		restart("db-1", "db", 5, 1, "Error"),
+	}
+
+	aggregated := AggregateRestarts(restarts, start, start.Add(time.Hour*2))
+	require.Len(t, aggregated, 2)
+	assert.Equal(t, "web", aggregated[0].WorkloadName)
+	assert.Equal(t, 5, aggregated[0].Restarts)
+	assert.Equal(t, 2, aggregated[0].Pods)
+	assert.Equal(t, 2.5, aggregated[0].RatePerHour)
+	assert.Equal(t, map[string]int{"Error": 3, "OOMKilled": 1, "Unknown": 1}, aggregated[0].Reasons)
+	assert.Equal(t, start.Add(time.Minute*31), aggregated[0].LastRestart)
+
+	loops := DetectCrashLoops(restarts, DEFAULT_CRASHLOOP_GAP, DEFAULT_CRASHLOOP_THRESHOLD)
+	require.Len(t, loops, 1)
+	assert.Equal(t, "web-1", loops[0].PodName)
+	assert.Equal(t, start, loops[0].Start)
+	assert.Equal(t, start.Add(time.Minute*7), loops[0].End)
+	assert.Equal(t, 3, loops[0].Restarts)
+
+	assert.Len(t, DetectCrashLoops(restarts, time.Hour, DEFAULT_CRASHLOOP_THRESHOLD), 1, "a longer gap joins the restarts of the container")
+	assert.Len(t, DetectCrashLoops(restarts, DEFAULT_CRASHLOOP_GAP, 1), 4)
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	CHANGE_TYPE_ADDED    string = "ADDED"
+	CHANGE_TYPE_MODIFIED string = "MODIFIED"
+	CHANGE_TYPE_DELETED  string = "DELETED"
+)
+
+// WorkloadRevision - represents a stored version of a workload
+type WorkloadRevision struct {
+	Key               string
+	Revision          int
+	WorkloadName      string
+	WorkloadType      string
+	Namespace         string
+	Data              json.RawMessage
+	Deleted           bool
+	CreationTimestamp time.Time
+}
+
+// FieldChange - represents a single changed field between two revisions
+type FieldChange struct {
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"old_value"`
+	NewValue interface{} `json:"new_value"`
+}
+
+// WorkloadChange - represents the changes introduced by a revision
+type WorkloadChange struct {
+	Revision          int           `json:"revision"`
+	Type              string        `json:"type"`
+	Changes           []FieldChange `json:"changes"`
+	CreationTimestamp time.Time     `json:"creation_date"`
+}
+
+// BuildWorkloadChanges creates the change list for revisions sorted by revision number.
+func BuildWorkloadChanges(revisions []WorkloadRevision) ([]WorkloadChange, error) {
+	result := make([]WorkloadChange, len(revisions))
+	var previous json.RawMessage
+
+	for i, revision := range revisions {
+		change := WorkloadChange{
+			Revision:          revision.Revision,
+			CreationTimestamp: revision.CreationTimestamp,
+			Changes:           make([]FieldChange, 0),
+		}
+
+		switch {
+		case revision.Deleted:
+			change.Type = CHANGE_TYPE_DELETED
+		case previous == nil:
+			change.Type = CHANGE_TYPE_ADDED
+		default:
+			change.Type = CHANGE_TYPE_MODIFIED
+		}
+
+		/**
+			a tombstone stores the last known state, so there is nothing to compare.
+			a workload which is recreated after deletion is compared to an empty state.
+		**/
+		if !revision.Deleted {
+			changes, err := DiffJSON(previous, revision.Data)
+			if err != nil {
+				return nil, err
+			}
+			change.Changes = changes
+			previous = revision.Data
+		} else {
+			previous = nil
+		}
+
+		result[i] = change
+	}
+
+	return result, nil
+}
+
+// DiffJSON compares two json documents and returns the changed fields.
+func DiffJSON(oldData []byte, newData []byte) ([]FieldChange, error) {
+	oldFields, err := flattenJSON(oldData)
+	if err != nil {
+		return nil, err
+	}
+
+	newFields, err := flattenJSON(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]FieldChange, 0)
+	for field, newValue := range newFields {
+		oldValue, ok := oldFields[field]
+		if (!ok && newValue != nil) || (ok && !reflect.DeepEqual(oldValue, newValue)) {
+			changes = append(changes, FieldChange{Field: field, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for field, oldValue := range oldFields {
+		if _, ok := newFields[field]; !ok && oldValue != nil {
+			changes = append(changes, FieldChange{Field: field, OldValue: oldValue, NewValue: nil})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+func flattenJSON(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if len(data) == 0 {
+		return fields, nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	flattenValue("", doc, fields)
+
+	return fields, nil
+}
+
+func flattenValue(prefix string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			fields[prefix] = v
+		}
+		for key, item := range v {
+			path := key
+			if prefix != "" {
+				path = fmt.Sprintf("%s.%s", prefix, key)
+			}
+			flattenValue(path, item, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[prefix] = v
+		}
+		for i, item := range v {
+			flattenValue(fmt.Sprintf("%s[%s]", prefix, elementIdentifier(i, item)), item, fields)
+		}
+	default:
+		fields[prefix] = v
+	}
+}
+
+// elementIdentifier uses the name of a list element if available, so reordered containers are not reported as changes.
+func elementIdentifier(i int, item interface{}) string {
+	if obj, ok := item.(map[string]interface{}); ok {
+		for _, key := range []string{"container_name", "name"} {
+			if name, ok := obj[key].(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+
+	return fmt.Sprintf("%d", i)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSON(t *testing.T) {
+	changes, err := DiffJSON(
+		[]byte(`{"name":"web","labels":{"app":"web","tier":"front"},"containers":[{"image":"nginx:1.23"}]}`),
+		[]byte(`{"name":"web","labels":{"app":"web"},"containers":[{"image":"nginx:1.24"}],"replicas":2}`),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Field: "containers[0].image", OldValue: "nginx:1.23", NewValue: "nginx:1.24"},
+		{Field: "labels.tier", OldValue: "front", NewValue: nil},
+		{Field: "replicas", OldValue: nil, NewValue: float64(2)},
+	}, changes)
+
+	added, err := DiffJSON(nil, []byte(`{"name":"web"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{{Field: "name", NewValue: "web"}}, added, "an object without a previous state is compared to an empty one")
+
+	_, err = DiffJSON([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestBuildWorkloadChanges(t *testing.T) {
+	at := time.Unix(1674381600, 0)
+	changes, err := BuildWorkloadChanges([]WorkloadRevision{
+		{Revision: 1, Data: json.RawMessage(`{"image":"nginx:1.23"}`), CreationTimestamp: at},
+		{Revision: 2, Data: json.RawMessage(`{"image":"nginx:1.24"}`), CreationTimestamp: at},
+		{Revision: 3, Data: json.RawMessage(`{"image":"nginx:1.24"}`), Deleted: true, CreationTimestamp: at},
+		{Revision: 4, Data: json.RawMessage(`{"image":"nginx:1.25"}`), CreationTimestamp: at},
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	assert.Equal(t, []string{CHANGE_TYPE_ADDED, CHANGE_TYPE_MODIFIED, CHANGE_TYPE_DELETED, CHANGE_TYPE_ADDED},
+		[]string{changes[0].Type, changes[1].Type, changes[2].Type, changes[3].Type})
+	assert.Equal(t, []FieldChange{{Field: "image", OldValue: "nginx:1.23", NewValue: "nginx:1.24"}}, changes[1].Changes)
+	assert.Empty(t, changes[2].Changes, "a tombstone has nothing to compare")
+	assert.Equal(t, []FieldChange{{Field: "image", NewValue: "nginx:1.25"}}, changes[3].Changes, "a recreated workload is compared to an empty state")
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+/**
+	The namespace scope restricts the objects a client of kdd can see.
+	A nil scope is unrestricted, the data store and the kubernetes api adapter filter their results by the scope.
+	Cluster scoped objects like nodes are not restricted.
+**/
+
+// NamespaceScope - the namespaces a client is allowed to see
+type NamespaceScope struct {
+	all        bool
+	namespaces map[string]bool
+}
+
+// AllNamespaces returns the scope of a client allowed to see every namespace
+func AllNamespaces() *NamespaceScope {
+	return &NamespaceScope{all: true}
+}
+
+// NewNamespaceScope returns the scope of the namespaces, no namespace is allowed without namespaces
+func NewNamespaceScope(namespaces ...string) *NamespaceScope {
+	s := &NamespaceScope{namespaces: make(map[string]bool, len(namespaces))}
+	for _, namespace := range namespaces {
+		s.namespaces[namespace] = true
+	}
+
+	return s
+}
+
+// Restricted checks if the scope hides namespaces
+func (s *NamespaceScope) Restricted() bool {
+	return s != nil && !s.all
+}
+
+// Allows checks if the namespace is within the scope
+func (s *NamespaceScope) Allows(namespace string) bool {
+	return !s.Restricted() || s.namespaces[namespace]
+}
+
+// Check returns ErrForbidden when the namespace is outside of the scope
+func (s *NamespaceScope) Check(namespace string) error {
+	if !s.Allows(namespace) {
+		return fmt.Errorf("namespace %s: %w", namespace, ErrForbidden)
+	}
+
+	return nil
+}
+
+// Namespaces returns the sorted namespaces of a restricted scope, nil when the scope is not restricted
+func (s *NamespaceScope) Namespaces() []string {
+	if !s.Restricted() {
+		return nil
+	}
+
+	namespaces := make([]string, 0, len(s.namespaces))
+	for namespace := range s.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+func (s *NamespaceScope) String() string {
+	if !s.Restricted() {
+		return "*"
+	}
+
+	return fmt.Sprint(s.Namespaces())
+}
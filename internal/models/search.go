@@ -0,0 +1,24 @@
+package models
+
+const (
+	SEARCH_KIND_NODE      string = "node"
+	SEARCH_KIND_NAMESPACE string = "namespace"
+	SEARCH_KIND_WORKLOAD  string = "workload"
+)
+
+// SearchResult - represents a single object matching a search query
+type SearchResult struct {
+	Kind         string            `json:"kind"`
+	Key          string            `json:"key"`
+	Name         string            `json:"name"`
+	Namespace    string            `json:"namespace,omitempty"`
+	WorkloadType string            `json:"workload_type,omitempty"`
+	Highlights   map[string]string `json:"highlights"` // matching fields, html escaped with the matches wrapped in <mark></mark>
+}
+
+// SearchResults - represents the search results grouped by kind
+type SearchResults struct {
+	Nodes      []SearchResult `json:"nodes"`
+	Namespaces []SearchResult `json:"namespaces"`
+	Workloads  []SearchResult `json:"workloads"`
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+const (
+	SELECTOR_OPERATOR_IN             string = "In"
+	SELECTOR_OPERATOR_NOT_IN         string = "NotIn"
+	SELECTOR_OPERATOR_EXISTS         string = "Exists"
+	SELECTOR_OPERATOR_DOES_NOT_EXIST string = "DoesNotExist"
+	SELECTOR_OPERATOR_GT             string = "Gt"
+	SELECTOR_OPERATOR_LT             string = "Lt"
+)
+
+// LabelSelectorRequirement - represents a single expression of a label selector
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// LabelSelector - represents a kubernetes label selector, all labels and expressions need to match
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"match_labels"`
+	MatchExpressions []LabelSelectorRequirement `json:"match_expressions"`
+}
+
+// NewLabelSelector converts a kubernetes label selector, a nil selector is returned as empty selector
+func NewLabelSelector(selector *metav1.LabelSelector) LabelSelector {
+	if selector == nil {
+		return LabelSelector{}
+	}
+
+	result := LabelSelector{MatchLabels: selector.MatchLabels}
+	for _, expression := range selector.MatchExpressions {
+		result.MatchExpressions = append(result.MatchExpressions, LabelSelectorRequirement{
+			Key:      expression.Key,
+			Operator: string(expression.Operator),
+			Values:   expression.Values,
+		})
+	}
+
+	return result
+}
+
+// ParseLabelSelector parses the kubernetes selector syntax, e.g. "app in (a,b),tier!=db"
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	requirements, err := labels.ParseToRequirements(selector)
+	if err != nil {
+		return LabelSelector{}, err
+	}
+
+	result := LabelSelector{}
+	for _, r := range requirements {
+		requirement := LabelSelectorRequirement{Key: r.Key(), Values: r.Values().List()}
+		switch r.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			requirement.Operator = SELECTOR_OPERATOR_IN
+		case selection.NotEquals, selection.NotIn:
+			requirement.Operator = SELECTOR_OPERATOR_NOT_IN
+		case selection.Exists:
+			requirement.Operator = SELECTOR_OPERATOR_EXISTS
+		case selection.DoesNotExist:
+			requirement.Operator = SELECTOR_OPERATOR_DOES_NOT_EXIST
+		case selection.GreaterThan:
+			requirement.Operator = SELECTOR_OPERATOR_GT
+		case selection.LessThan:
+			requirement.Operator = SELECTOR_OPERATOR_LT
+		}
+		result.MatchExpressions = append(result.MatchExpressions, requirement)
+	}
+
+	return result, nil
+}
+
+// IsEmpty checks if the selector has no requirements, an empty selector matches everything
+func (s LabelSelector) IsEmpty() bool {
+	return len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0
+}
+
+// Requirements returns the match labels and the expressions as requirements
+func (s LabelSelector) Requirements() []LabelSelectorRequirement {
+	requirements := make([]LabelSelectorRequirement, 0, len(s.MatchLabels)+len(s.MatchExpressions))
+	for key, value := range s.MatchLabels {
+		requirements = append(requirements, LabelSelectorRequirement{Key: key, Operator: SELECTOR_OPERATOR_IN, Values: []string{value}})
+	}
+
+	return append(requirements, s.MatchExpressions...)
+}
+
+// Matches checks if the labels fulfill all requirements of the selector
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range s.Requirements() {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Matches checks if the labels fulfill the requirement
+func (r LabelSelectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case SELECTOR_OPERATOR_IN:
+		return ok && r.hasValue(value)
+	case SELECTOR_OPERATOR_NOT_IN:
+		return !ok || !r.hasValue(value)
+	case SELECTOR_OPERATOR_EXISTS:
+		return ok
+	case SELECTOR_OPERATOR_DOES_NOT_EXIST:
+		return !ok
+	case SELECTOR_OPERATOR_GT, SELECTOR_OPERATOR_LT:
+		if !ok || len(r.Values) != 1 {
+			return false
+		}
+
+		labelValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+
+		requiredValue, err := strconv.ParseInt(r.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+
+		if r.Operator == SELECTOR_OPERATOR_GT {
+			return labelValue > requiredValue
+		}
+		return labelValue < requiredValue
+	}
+
+	return false
+}
+
+func (r LabelSelectorRequirement) hasValue(value string) bool {
+	for _, v := range r.Values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
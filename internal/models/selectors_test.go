@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "a", "tier": "web", "replicas": "3"}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{selector: "", matches: true},
+		{selector: "app=a", matches: true},
+		{selector: "app==b", matches: false},
+		{selector: "app in (a,b),tier!=db", matches: true},
+		{selector: "app notin (a,b)", matches: false},
+		{selector: "tier", matches: true},
+		{selector: "!tier", matches: false},
+		{selector: "missing!=x", matches: true},
+		{selector: "missing in (x)", matches: false},
+		{selector: "replicas>2", matches: true},
+		{selector: "replicas<2", matches: false},
+	}
+
+	for _, tt := range tests {
+		selector, err := ParseLabelSelector(tt.selector)
+		assert.NoError(t, err, tt.selector)
+		assert.Equal(t, tt.matches, selector.Matches(labels), tt.selector)
+	}
+
+	_, err := ParseLabelSelector("app in (a")
+	assert.Error(t, err)
+}
+
+func TestLabelSelectorMatchLabelsAndExpressions(t *testing.T) {
+	selector := LabelSelector{
+		MatchLabels: map[string]string{"app": "a"},
+		MatchExpressions: []LabelSelectorRequirement{
+			{Key: "tier", Operator: SELECTOR_OPERATOR_NOT_IN, Values: []string{"db"}},
+		},
+	}
+
+	assert.True(t, selector.Matches(map[string]string{"app": "a", "tier": "web"}))
+	assert.True(t, selector.Matches(map[string]string{"app": "a"}))
+	assert.False(t, selector.Matches(map[string]string{"app": "a", "tier": "db"}))
+	assert.False(t, selector.Matches(map[string]string{"tier": "web"}))
+}
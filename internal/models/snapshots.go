@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Snapshot - represents a stored full snapshot of the cluster state
+type Snapshot struct {
+	ID                int64     `json:"id"`
+	Nodes             int       `json:"nodes"`
+	Namespaces        int       `json:"namespaces"`
+	Workloads         int       `json:"workloads"`
+	CreationTimestamp time.Time `json:"creation_date"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SyncStatus - the state of the synchronization with kubernetes and of the database
+type SyncStatus struct {
+	Ready       bool               `json:"ready"`           // ready to serve current data
+	Synced      bool               `json:"synced"`          // the initial collection finished
+	LastSync    *time.Time         `json:"last_sync"`       // end of the last successful sync
+	LastError   string             `json:"error,omitempty"` // error of the last sync, empty when it succeeded
+	Database    DatabaseStatus     `json:"database"`
+	Collections []CollectionStatus `json:"collections"`
+	CheckedAt   time.Time          `json:"checked_at"`
+}
+
+// CollectionStatus - the state of collecting a kind from kubernetes
+type CollectionStatus struct {
+	Kind        string     `json:"kind"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastAttempt *time.Time `json:"last_attempt"`
+	Error       string     `json:"error,omitempty"` // error of the last attempt
+	Stale       bool       `json:"stale"`           // the last success is older than the staleness threshold
+}
+
+// DatabaseStatus - the state of the database
+type DatabaseStatus struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+const (
+	WATCH_ADDED    string = "ADDED"
+	WATCH_MODIFIED string = "MODIFIED"
+	WATCH_DELETED  string = "DELETED"
+	// sent before the stream is closed because the client fell behind the kept history, the lists need to be reloaded
+	WATCH_EXPIRED string = "EXPIRED"
+	// sent periodically on idle websocket streams
+	WATCH_HEARTBEAT string = "HEARTBEAT"
+)
+
+const (
+	WATCH_KIND_NODE      string = "node"
+	WATCH_KIND_NAMESPACE string = "namespace"
+	WATCH_KIND_WORKLOAD  string = "workload"
+	WATCH_KIND_METRIC    string = "metric"
+)
+
+// WATCH_KINDS - the kinds which can be watched
+var WATCH_KINDS = []string{WATCH_KIND_NODE, WATCH_KIND_NAMESPACE, WATCH_KIND_WORKLOAD, WATCH_KIND_METRIC}
+
+// WatchEvent - a change of a stored object, metric samples are only ADDED
+type WatchEvent struct {
+	ID        string    `json:"id"` // resume token of the event
+	Type      string    `json:"type"`
+	Kind      string    `json:"kind"`
+	Key       string    `json:"key"`
+	Namespace string    `json:"namespace,omitempty"` // the name for namespaces, empty for nodes
+	Object    any       `json:"object"`              // the node, namespace, workload or metric, the last known state for DELETED
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsValidWatchKind checks if the kind can be watched
+func IsValidWatchKind(kind string) bool {
+	for _, k := range WATCH_KINDS {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,7 +2,10 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
+
+	core_v1 "k8s.io/api/core/v1"
 )
 
 const (
@@ -14,35 +17,19 @@ const (
 	WORKLOAD_TYPE_CRONJOB     string = "Cronjob"
 )
 
-// TODO we should have on generic function to sort by name
-// ByWorkloadName implements sort.Interface based on the Workload name field.
-type ByWorkloadName []Workload
-
-func (a ByWorkloadName) Len() int           { return len(a) }
-func (a ByWorkloadName) Less(i, j int) bool { return a[i].GetWorkloadName() < a[j].GetWorkloadName() }
-func (a ByWorkloadName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-
-type ByContainerMetricsTimestamp []PodContainerMetric
+// WorkloadNameLess sorts workloads by name
+func WorkloadNameLess(a, b Workload) bool { return a.GetWorkloadName() < b.GetWorkloadName() }
 
-func (a ByContainerMetricsTimestamp) Len() int { return len(a) }
-func (a ByContainerMetricsTimestamp) Less(i, j int) bool {
-	return a[i].CreationTimestamp.Before(a[j].CreationTimestamp)
+// MetricTimestampLess sorts container metrics by creation timestamp
+func MetricTimestampLess(a, b PodContainerMetric) bool {
+	return a.CreationTimestamp.Before(b.CreationTimestamp)
 }
-func (a ByContainerMetricsTimestamp) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
-
-// ByNamespaceName implements sort.Interface based on the Namespace name field.
-type ByNamespaceName []Namespace
-
-func (a ByNamespaceName) Len() int           { return len(a) }
-func (a ByNamespaceName) Less(i, j int) bool { return a[i].Name < a[j].Name }
-func (a ByNamespaceName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-// ByNodeName implements sort.Interface based on the Node name field.
-type ByNodeName []Node
+// NamespaceNameLess sorts namespaces by name
+func NamespaceNameLess(a, b Namespace) bool { return a.Name < b.Name }
 
-func (a ByNodeName) Len() int           { return len(a) }
-func (a ByNodeName) Less(i, j int) bool { return a[i].Name < a[j].Name }
-func (a ByNodeName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+// NodeNameLess sorts nodes by name
+func NodeNameLess(a, b Node) bool { return a.Name < b.Name }
 
 type Workload interface {
 	GetWorkloadName() string
@@ -52,6 +39,7 @@ type Workload interface {
 	GetLabels() map[string]string
 	GetAnnotations() map[string]string
 	GetSelector() map[string]string
+	GetLabelSelector() LabelSelector
 	// I guess it is not the best solution to use interface{} type for returning workload status
 	GetWorkloadStatus() interface{}
 	GetCreationTimestamp() time.Time
@@ -59,13 +47,22 @@ type Workload interface {
 
 // Workload - represents a single workload
 type GeneralWorkloadInfo struct {
-	WorkloadName      string            `json:"workload_name"` // Name of the Deplyoment or Deamonset
-	Namespace         string            `json:"namespace"`     // Namespace
-	Labels            map[string]string `json:"labels"`
-	Annotations       map[string]string `json:"annotations"`
-	Selector          map[string]string `json:"selector"`
-	Containers        []Container       `json:"containers"` // Containers used by workload
-	CreationTimestamp time.Time         `json:"creation_date"`
+	WorkloadName        string                     `json:"workload_name"` // Name of the Deplyoment or Deamonset
+	Namespace           string                     `json:"namespace"`     // Namespace
+	Labels              map[string]string          `json:"labels"`
+	Annotations         map[string]string          `json:"annotations"`
+	Selector            map[string]string          `json:"selector"`
+	SelectorExpressions []LabelSelectorRequirement `json:"selector_expressions,omitempty"` // match expressions, the match labels are stored in Selector
+	Containers          []Container                `json:"containers"`                     // Containers used by workload
+	CreationTimestamp   time.Time                  `json:"creation_date"`
+}
+
+// GetLabelSelector returns the full label selector of the workload
+func (g GeneralWorkloadInfo) GetLabelSelector() LabelSelector {
+	return LabelSelector{
+		MatchLabels:      g.Selector,
+		MatchExpressions: g.SelectorExpressions,
+	}
 }
 
 // DeploymentWorkload - represents a deployment workload
@@ -279,10 +276,11 @@ func (d StatefulSetWorkload) GetCreationTimestamp() time.Time {
 }
 
 type PodOwnerRessource struct {
-	APIVersion string `json:"api_version"`
-	Kind       string `json:"kind"`
-	UID        string `json:"uid"`
-	Name       string `json:"name"`
+	APIVersion string             `json:"api_version"`
+	Kind       string             `json:"kind"`
+	UID        string             `json:"uid"`
+	Name       string             `json:"name"`
+	Owner      *PodOwnerRessource `json:"owner,omitempty"` // the owner of a job, e.g. its cronjob
 }
 
 // PodWorkload - represents a pod
@@ -291,6 +289,7 @@ type PodWorkload struct {
 	Status              string              `json:"status"`
 	Restarts            int                 `json:"restarts"`
 	PodOwnerRessources  []PodOwnerRessource `json:"pod_owner_ressources"`
+	NodeName            string              `json:"node_name"` // empty until the pod is scheduled
 }
 
 func (p PodWorkload) MarshalJSON() ([]byte, error) {
@@ -300,12 +299,14 @@ func (p PodWorkload) MarshalJSON() ([]byte, error) {
 		Type                string              `json:"type"`
 		Restarts            int                 `json:"restarts"`
 		PodOwnerRessources  []PodOwnerRessource `json:"pod_owner_ressources"`
+		NodeName            string              `json:"node_name"`
 	}{
 		GeneralWorkloadInfo: p.GeneralWorkloadInfo,
 		Status:              p.Status,
 		Type:                p.GetType(),
 		Restarts:            p.Restarts,
 		PodOwnerRessources:  p.PodOwnerRessources,
+		NodeName:            p.NodeName,
 	})
 }
 
@@ -357,6 +358,7 @@ func (d PodWorkload) GetWorkloadStatus() interface{} {
 type JobWorkload struct {
 	GeneralWorkloadInfo `json:"workload_info"`
 	Status              JobStatus `json:"status"`
+	CronjobName         string    `json:"cronjob_name,omitempty"` // the cronjob which created the job
 }
 
 func (d JobWorkload) MarshalJSON() ([]byte, error) {
@@ -364,10 +366,12 @@ func (d JobWorkload) MarshalJSON() ([]byte, error) {
 		GeneralWorkloadInfo `json:"workload_info"`
 		Status              JobStatus `json:"status"`
 		Type                string    `json:"type"`
+		CronjobName         string    `json:"cronjob_name,omitempty"`
 	}{
 		GeneralWorkloadInfo: d.GeneralWorkloadInfo,
 		Status:              d.Status,
 		Type:                d.GetType(),
+		CronjobName:         d.CronjobName,
 	})
 }
 
@@ -433,20 +437,25 @@ type CronjobWorkload struct {
 	FailedJobsHistory     *int32        `json:"failed_jobs_history"`
 	SuccessfulJobsHistory *int32        `json:"successful_jobs_history"`
 	Schedule              string        `json:"schedule"`
+	TimeZone              *string       `json:"time_zone"`
 	Status                CronjobStatus `json:"status"`
+	// Analysis is computed from the schedule when the cronjob is loaded by the api, it is not part of the kubernetes object
+	Analysis *CronjobAnalysis `json:"analysis,omitempty"`
 }
 
 func (d CronjobWorkload) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		GeneralWorkloadInfo   `json:"workload_info"`
-		Status                CronjobStatus `json:"status"`
-		Type                  string        `json:"type"`
-		Suspend               *bool         `json:"suspend"`
-		ConcurrencyPolicy     string        `json:"concurrency_policy"`
-		BackoffLimit          *int32        `json:"backoff_limit"`
-		FailedJobsHistory     *int32        `json:"failed_jobs_history"`
-		SuccessfulJobsHistory *int32        `json:"successful_jobs_history"`
-		Schedule              string        `json:"schedule"`
+		Status                CronjobStatus    `json:"status"`
+		Type                  string           `json:"type"`
+		Suspend               *bool            `json:"suspend"`
+		ConcurrencyPolicy     string           `json:"concurrency_policy"`
+		BackoffLimit          *int32           `json:"backoff_limit"`
+		FailedJobsHistory     *int32           `json:"failed_jobs_history"`
+		SuccessfulJobsHistory *int32           `json:"successful_jobs_history"`
+		Schedule              string           `json:"schedule"`
+		TimeZone              *string          `json:"time_zone"`
+		Analysis              *CronjobAnalysis `json:"analysis,omitempty"`
 	}{
 		GeneralWorkloadInfo:   d.GeneralWorkloadInfo,
 		Status:                d.Status,
@@ -457,6 +466,8 @@ func (d CronjobWorkload) MarshalJSON() ([]byte, error) {
 		FailedJobsHistory:     d.FailedJobsHistory,
 		SuccessfulJobsHistory: d.SuccessfulJobsHistory,
 		Schedule:              d.Schedule,
+		TimeZone:              d.TimeZone,
+		Analysis:              d.Analysis,
 	})
 }
 
@@ -525,5 +536,63 @@ type Container struct {
 	LimitCPU      int64  `json:"limit_cpu"`      // Limit CPU
 	LimitMemory   int64  `json:"limit_memory"`   // Limit Memory
 	Restarts      int    `json:"restarts"`
-	InitContainer bool   `json:"init_container"` // Init Container (yes, no)
+	InitContainer bool   `json:"init_container"`           // Init Container (yes, no)
+	WaitingReason string `json:"waiting_reason,omitempty"` // reason the container is waiting, e.g. CrashLoopBackOff
+	// LastTermination is the previous termination of a restarted container
+	LastTermination *ContainerTermination `json:"last_termination,omitempty"`
+}
+
+// ContainerTermination - represents the termination of a container
+type ContainerTermination struct {
+	Reason     string    `json:"reason"` // e.g. OOMKilled or Error
+	ExitCode   int32     `json:"exit_code"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// NewContainerTermination converts the terminated state of a container status, nil when the container did not terminate
+func NewContainerTermination(state *core_v1.ContainerStateTerminated) *ContainerTermination {
+	if state == nil {
+		return nil
+	}
+
+	return &ContainerTermination{Reason: state.Reason, ExitCode: state.ExitCode, FinishedAt: state.FinishedAt.Time}
+}
+
+// UnmarshalWorkload creates the workload from its json representation.
+func UnmarshalWorkload(data []byte) (Workload, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Type {
+	case WORKLOAD_TYPE_DEPLOYMENT:
+		var w DeploymentWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	case WORKLOAD_TYPE_DEAMONSET:
+		var w DaemonSetWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	case WORKLOAD_TYPE_STATEFULSET:
+		var w StatefulSetWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	case WORKLOAD_TYPE_POD:
+		var w PodWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	case WORKLOAD_TYPE_JOB:
+		var w JobWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	case WORKLOAD_TYPE_CRONJOB:
+		var w CronjobWorkload
+		err := json.Unmarshal(data, &w)
+		return w, err
+	}
+
+	return nil, fmt.Errorf("unsupported type: %s", header.Type)
 }
@@ -0,0 +1,313 @@
+// clientgen generates the go client of the api out of the OpenAPI document, see pkg/client
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"strings"
+
+	"gitlab.com/patrick.erber/kdd/internal/openapi"
+	"go.uber.org/zap"
+)
+
+// initialisms are written in upper case in go names
+var initialisms = map[string]string{"id": "ID", "api": "API", "uid": "UID", "cpu": "CPU", "url": "URL", "json": "JSON"}
+
+func main() {
+	output := flag.String("o", "client_gen.go", "file the client is written to")
+	pkg := flag.String("package", "client", "package of the generated client")
+	flag.Parse()
+
+	logger, _ := zap.NewDevelopment()
+	zap.ReplaceGlobals(logger)
+
+	source, err := generate(openapi.Spec(), *pkg)
+	if err != nil {
+		zap.L().Fatal("could not generate client", zap.Error(err))
+	}
+
+	if err := os.WriteFile(*output, source, 0644); err != nil {
+		zap.L().Fatal("could not write client", zap.String("output", *output), zap.Error(err))
+	}
+}
+
+type generator struct {
+	spec *openapi.Document
+	buf  bytes.Buffer
+}
+
+func generate(spec *openapi.Document, pkg string) ([]byte, error) {
+	g := &generator{spec: spec}
+
+	g.printf("// Code generated by internal/openapi/clientgen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\"context\"\n\"encoding/json\"\n\"fmt\"\n\"net/url\"\n\"strconv\"\n\"time\"\n)\n\n")
+	g.printf("// BASE_PATH - the path the api is served at\nconst BASE_PATH = %q\n\n", spec.Servers[0].URL)
+	// keeps the imports used when no operation needs them
+	g.printf("var (\n_ = json.Marshal\n_ = fmt.Errorf\n_ = strconv.Itoa\n_ = time.RFC3339\n)\n\n")
+
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := spec.Components.Schemas[name]
+		if len(schema.OneOf) > 0 {
+			if err := g.union(name, schema); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		g.object(name, schema)
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		// streams are not supported by the client, their events are generated as types only
+		if op := spec.Paths[path].Get; op != nil && op.ResponseSchema(200) != nil {
+			g.operation(path, op)
+		}
+	}
+
+	return format.Source(g.buf.Bytes())
+}
+
+func (g *generator) printf(f string, args ...any) {
+	fmt.Fprintf(&g.buf, f, args...)
+}
+
+// object writes the struct of an object schema
+func (g *generator) object(name string, schema *openapi.Schema) {
+	g.printf("type %s struct {\n", name)
+	for _, property := range sortedKeys(schema.Properties) {
+		tag := property
+		if !contains(schema.Required, property) {
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:\"%s\"`\n", goName(property), g.goType(schema.Properties[property]), tag)
+	}
+	g.printf("}\n\n")
+}
+
+// union writes a struct with a field per schema of the oneOf, the discriminator selects the field on unmarshaling
+func (g *generator) union(name string, schema *openapi.Schema) error {
+	if schema.Discriminator == nil {
+		return fmt.Errorf("oneOf without discriminator is not supported: %s", name)
+	}
+
+	values := sortedKeys(schema.Discriminator.Mapping)
+
+	g.printf("// %s - one of the schemas selected by %s, exactly one field is set\n", name, schema.Discriminator.PropertyName)
+	g.printf("type %s struct {\n", name)
+	for _, value := range values {
+		variant := (&openapi.Schema{Ref: schema.Discriminator.Mapping[value]}).RefName()
+		g.printf("%s *%s\n", variant, variant)
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (u *%s) UnmarshalJSON(data []byte) error {\n", name)
+	g.printf("var discriminator struct {\nValue string `json:\"%s\"`\n}\n", schema.Discriminator.PropertyName)
+	g.printf("if err := json.Unmarshal(data, &discriminator); err != nil {\nreturn err\n}\n\n")
+	g.printf("*u = %s{}\nswitch discriminator.Value {\n", name)
+	for _, value := range values {
+		variant := (&openapi.Schema{Ref: schema.Discriminator.Mapping[value]}).RefName()
+		g.printf("case %q:\nu.%s = &%s{}\nreturn json.Unmarshal(data, u.%s)\n", value, variant, variant, variant)
+	}
+	g.printf("}\n\nreturn fmt.Errorf(\"unknown %s %%q\", discriminator.Value)\n}\n\n", schema.Discriminator.PropertyName)
+
+	g.printf("func (u %s) MarshalJSON() ([]byte, error) {\nswitch {\n", name)
+	for _, value := range values {
+		variant := (&openapi.Schema{Ref: schema.Discriminator.Mapping[value]}).RefName()
+		g.printf("case u.%s != nil:\nreturn json.Marshal(u.%s)\n", variant, variant)
+	}
+	g.printf("}\n\nreturn []byte(\"null\"), nil\n}\n\n")
+
+	return nil
+}
+
+// operation writes the parameters and the method of the operation
+func (g *generator) operation(path string, op *openapi.Operation) {
+	name := goName(op.OperationID)
+	result := g.goType(op.ResponseSchema(200))
+
+	if envelope := op.ResponseSchema(200); envelope.Properties["data"] != nil {
+		result = fmt.Sprintf("Response[%s]", g.goType(envelope.Properties["data"]))
+	}
+
+	parameters := clientParameters(op)
+	args := "ctx context.Context"
+	if len(parameters) > 0 {
+		g.printf("// %sParams - the parameters of %s\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, param := range parameters {
+			if param.Description != "" {
+				g.printf("// %s\n", param.Description)
+			}
+			g.printf("%s %s\n", goName(param.Name), paramType(param))
+		}
+		g.printf("}\n\n")
+		args += fmt.Sprintf(", params %sParams", name)
+	}
+
+	g.printf("// %s - %s\n", name, op.Summary)
+	g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, args, result)
+	g.printf("query := url.Values{}\n")
+	for _, param := range parameters {
+		if param.In != "query" {
+			continue
+		}
+		field := "params." + goName(param.Name)
+		switch paramType(param) {
+		case "time.Time":
+			g.printf("if !%s.IsZero() {\nquery.Set(%q, %s.Format(time.RFC3339))\n}\n", field, param.Name, field)
+		case "int":
+			g.printf("if %s != 0 {\nquery.Set(%q, strconv.Itoa(%s))\n}\n", field, param.Name, field)
+		case "*bool":
+			g.printf("if %s != nil {\nquery.Set(%q, strconv.FormatBool(*%s))\n}\n", field, param.Name, field)
+		default:
+			g.printf("if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+		}
+	}
+
+	g.printf("\nvar result %s\n", result)
+	g.printf("if err := c.get(ctx, %s, query, &result); err != nil {\nreturn nil, err\n}\n\nreturn &result, nil\n}\n\n", pathExpression(path))
+}
+
+// clientParameters returns the parameters of the operation offered by the client.
+// The client decodes json responses only, so the export format of lists is not offered.
+func clientParameters(op *openapi.Operation) []openapi.Parameter {
+	result := make([]openapi.Parameter, 0, len(op.Parameters))
+	for _, param := range op.Parameters {
+		if param.In == "query" && param.Name == "format" {
+			continue
+		}
+		result = append(result, param)
+	}
+
+	return result
+}
+
+// goType returns the go type of the schema
+func (g *generator) goType(schema *openapi.Schema) string {
+	if schema.Ref != "" {
+		return schema.RefName()
+	}
+
+	if len(schema.AllOf) == 1 {
+		return "*" + g.goType(schema.AllOf[0])
+	}
+
+	pointer := ""
+	if schema.Nullable {
+		pointer = "*"
+	}
+
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			return pointer + "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return pointer + "string"
+	case "integer":
+		if schema.Format == "int32" {
+			return pointer + "int32"
+		}
+		return pointer + "int64"
+	case "number":
+		return pointer + "float64"
+	case "boolean":
+		return pointer + "bool"
+	case "array":
+		return "[]" + g.goType(schema.Items)
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "map[string]" + g.goType(schema.AdditionalProperties)
+		}
+		return "map[string]any"
+	}
+
+	return "any"
+}
+
+// paramType returns the go type of the parameter
+func paramType(param openapi.Parameter) string {
+	switch {
+	case param.Schema.Format == "date-time":
+		return "time.Time"
+	case param.Schema.Type == "integer":
+		return "int"
+	case param.Schema.Type == "boolean":
+		// nil does not send the parameter, false needs to be sent explicitly
+		return "*bool"
+	}
+
+	return "string"
+}
+
+// pathExpression returns the go expression building the path with the escaped path parameters
+func pathExpression(path string) string {
+	parts := make([]string, 0)
+	literal := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parts = append(parts, fmt.Sprintf("%q", literal+"/"))
+			parts = append(parts, fmt.Sprintf("url.PathEscape(params.%s)", goName(strings.Trim(segment, "{}"))))
+			literal = ""
+			continue
+		}
+		literal += "/" + segment
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+// goName returns the exported go name of a json name, e.g. workload_info -> WorkloadInfo, labelSelector -> LabelSelector
+func goName(name string) string {
+	var result strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if initialism, ok := initialisms[part]; ok {
+			result.WriteString(initialism)
+			continue
+		}
+		result.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return result.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/openapi"
+)
+
+// TestClientUpToDate fails when the api changed without regenerating the client with go generate ./pkg/client
+func TestClientUpToDate(t *testing.T) {
+	source, err := generate(openapi.Spec(), "client")
+	require.NoError(t, err)
+
+	current, err := os.ReadFile("../../../pkg/client/client_gen.go")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(source), string(current))
+}
+
+func TestPathExpression(t *testing.T) {
+	assert.Equal(t, `"/nodes"`, pathExpression("/nodes"))
+	assert.Equal(t, `"/workloads/pods/" + url.PathEscape(params.Namespace) + "/" + url.PathEscape(params.Name) + "/changes"`, pathExpression("/workloads/pods/{namespace}/{name}/changes"))
+}
@@ -0,0 +1,337 @@
+package openapi
+
+import (
+	"reflect"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// route - describes a GET route of the api, the path uses the OpenAPI {param} syntax
+type route struct {
+	path    string
+	id      string
+	summary string
+	tag     string
+	params  []Parameter
+	data    reflect.Type // type of the data of the response envelope, nil for raw responses
+	list    bool         // the data is a page of a list with metadata
+	stream  bool         // the data is sent as server-sent events
+}
+
+var (
+	param_at             = query("at", "point in time (RFC3339) to load the state of", dateTime())
+	param_from           = query("from", "start of the time range (RFC3339), defaults to 7 days before to", dateTime())
+	param_to             = query("to", "end of the time range (RFC3339), defaults to at or now", dateTime())
+	param_rate           = query("rate", "window the metrics are reduced to, e.g. 5m", &Schema{Type: "string"})
+	param_agg            = query("agg", "aggregation used to reduce the metrics: max, min, avg, last or a percentile like p95", &Schema{Type: "string"})
+	param_selector       = query("labelSelector", "kubernetes label selector, e.g. app in (a,b),tier!=db", &Schema{Type: "string"})
+	param_namespace      = query("namespace", "namespace to filter by", &Schema{Type: "string"})
+	param_name           = query("name", "name to filter by", &Schema{Type: "string"})
+	param_limit          = query("limit", "maximum number of items of the page, 0 returns all items", &Schema{Type: "integer", Format: "int64"})
+	param_continue       = query("continue", "token of the next page returned in the metadata", &Schema{Type: "string"})
+	param_sort           = query("sort", "comma separated json paths to sort by, a leading - sorts descending", &Schema{Type: "string"})
+	param_fields         = query("fields", "comma separated json paths the items are reduced to, the reduced items only contain these fields", &Schema{Type: "string"})
+	param_format         = query("format", "export format of the list instead of the json envelope, alternatively requested by the Accept header", &Schema{Type: "string", Enum: []string{"json", "csv", "ndjson", "xlsx"}})
+	param_path_name      = path("name", "name of the resource")
+	param_path_namespace = path("namespace", "namespace of the resource")
+	param_workload_type  = Parameter{
+		Name:        "workloadType",
+		In:          "path",
+		Description: "type of the workload",
+		Required:    true,
+		Schema:      &Schema{Type: "string", Enum: []string{"deployments", "statefulsets", "daemonsets", "jobs", "cronjobs"}},
+	}
+)
+
+var (
+	list_params     = []Parameter{param_limit, param_continue, param_sort, param_fields, param_format}
+	metrics_params  = []Parameter{param_from, param_to, param_rate, param_agg}
+	restarts_params = []Parameter{
+		query("from", "start of the time range (RFC3339), defaults to 24 hours before to", dateTime()),
+		query("to", "end of the time range (RFC3339), defaults to now", dateTime()),
+	}
+	crashloop_params = []Parameter{
+		query("gap", "longest pause between the restarts of a crash loop, defaults to 10m", &Schema{Type: "string"}),
+		query("threshold", "minimum number of restarts of a crash loop, defaults to 3", &Schema{Type: "integer", Format: "int64"}),
+	}
+)
+
+var (
+	workloads_type = reflect.TypeOf([]models.Workload{})
+	changes_type   = reflect.TypeOf([]models.WorkloadChange{})
+)
+
+// operations - every route registered in router.InitRouter below /api/v1
+var operations = []route{
+	{
+		path: "/nodes", id: "getNodes", summary: "List the nodes", tag: "nodes",
+		params: params([]Parameter{param_at, param_selector}, list_params),
+		data:   reflect.TypeOf([]models.Node{}), list: true,
+	},
+	{
+		path: "/namespaces", id: "getNamespaces", summary: "List the namespaces", tag: "namespaces",
+		params: params([]Parameter{param_at, param_selector}, list_params),
+		data:   reflect.TypeOf([]models.Namespace{}), list: true,
+	},
+	{
+		path: "/namespaces/{name}", id: "getNamespace", summary: "Get a namespace with its workloads and events", tag: "namespaces",
+		params: []Parameter{param_path_name, param_at},
+		data:   reflect.TypeOf(models.NamespaceDetails{}),
+	},
+	{
+		path: "/workloads", id: "getWorkloads", summary: "List the workloads", tag: "workloads",
+		params: params([]Parameter{param_at, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/workloads/deployments", id: "getDeployments", summary: "List the deployments", tag: "workloads",
+		params: params([]Parameter{param_at, param_namespace, param_name, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/workloads/pods/{namespace}/{name}", id: "getPod", summary: "Get a pod with the metrics of its containers", tag: "workloads",
+		params: params([]Parameter{param_path_namespace, param_path_name, param_at}, metrics_params),
+		data:   reflect.TypeOf(models.PodDetails{}),
+	},
+	{
+		path: "/workloads/pods/{namespace}/{name}/changes", id: "getPodChanges", summary: "List the changes of a pod", tag: "workloads",
+		params: params([]Parameter{param_path_namespace, param_path_name}, list_params),
+		data:   changes_type, list: true,
+	},
+	{
+		path: "/workloads/pods/{namespace}/{name}/restarts", id: "getPodRestarts", summary: "Get the restart history of the containers of a pod with its crash loops", tag: "restarts",
+		params: params([]Parameter{param_path_namespace, param_path_name}, restarts_params, crashloop_params),
+		data:   reflect.TypeOf(models.WorkloadRestartHistory{}),
+	},
+	{
+		path: "/workloads/{workloadType}/{namespace}/{name}", id: "getWorkload", summary: "Get a workload with its pods and their container metrics", tag: "workloads",
+		params: params([]Parameter{param_workload_type, param_path_namespace, param_path_name, param_at}, metrics_params),
+		data:   reflect.TypeOf(models.WorkloadDetails{}),
+	},
+	{
+		path: "/workloads/{workloadType}/{namespace}/{name}/changes", id: "getWorkloadChanges", summary: "List the changes of a workload", tag: "workloads",
+		params: params([]Parameter{param_workload_type, param_path_namespace, param_path_name}, list_params),
+		data:   changes_type, list: true,
+	},
+	{
+		path: "/workloads/{workloadType}/{namespace}/{name}/restarts", id: "getWorkloadRestarts", summary: "Get the restart history of the containers of a workload with its rate and crash loops", tag: "restarts",
+		params: params([]Parameter{param_workload_type, param_path_namespace, param_path_name}, restarts_params, crashloop_params),
+		data:   reflect.TypeOf(models.WorkloadRestartHistory{}),
+	},
+	{
+		path: "/restarts/top", id: "getTopRestarters", summary: "List the workloads with the most container restarts, 10 per page by default", tag: "restarts",
+		params: params([]Parameter{param_namespace}, restarts_params, list_params),
+		data:   reflect.TypeOf([]models.WorkloadRestarts{}), list: true,
+	},
+	{
+		path: "/restarts/crashloops", id: "getCrashLoops", summary: "List the periods in which containers restarted repeatedly", tag: "restarts",
+		params: params([]Parameter{param_namespace}, restarts_params, crashloop_params, list_params),
+		data:   reflect.TypeOf([]models.CrashLoop{}), list: true,
+	},
+	{
+		path: "/recommendations", id: "getRecommendations", summary: "List the right-sizing recommendations of the containers of the deployments, statefulsets, daemonsets and pods without owner", tag: "recommendations",
+		params: params([]Parameter{
+			query("from", "start of the time range (RFC3339) the usage is evaluated for, defaults to 7 days before to", dateTime()),
+			query("to", "end of the time range (RFC3339), defaults to now", dateTime()),
+			param_namespace,
+			query("workload_type", "type of the workload", &Schema{Type: "string", Enum: []string{"deployments", "statefulsets", "daemonsets", "pods"}}),
+			query("classification", "classification of the workload to filter by", &Schema{Type: "string", Enum: []string{
+				models.RECOMMENDATION_OPTIMAL, models.RECOMMENDATION_INSUFFICIENT_DATA, models.RECOMMENDATION_OVER_PROVISIONED,
+				models.RECOMMENDATION_MISSING_LIMITS, models.RECOMMENDATION_UNDER_PROVISIONED,
+			}}),
+			query("headroom", "percent added to the usage for the recommended requests and limits, defaults to 20", &Schema{Type: "number", Format: "double"}),
+			query("patch", "adds the strategic merge patch applying the recommended resources to every workload", &Schema{Type: "boolean"}),
+		}, list_params),
+		data: reflect.TypeOf([]models.WorkloadRecommendation{}), list: true,
+	},
+	{
+		path: "/costs", id: "getCosts", summary: "List the costs of the pods per group sorted by the total cost, including the idle cost of the cluster unless restricted to namespaces", tag: "costs",
+		params: params([]Parameter{
+			param_from,
+			query("to", "end of the time range (RFC3339), defaults to now", dateTime()),
+			param_namespace,
+			query("groupBy", "namespace, workload or label:<key> to group by the value of a label of the pods, defaults to namespace", &Schema{Type: "string"}),
+			query("basis", "resources the costs are calculated from, max is the max of requests and usage, defaults to requests", &Schema{Type: "string", Enum: []string{
+				models.COST_BASIS_REQUESTS, models.COST_BASIS_USAGE, models.COST_BASIS_MAX,
+			}}),
+		}, list_params),
+		data: reflect.TypeOf([]models.CostAllocation{}), list: true,
+	},
+	{
+		path: "/workloads/statefulsets", id: "getStatefulSets", summary: "List the statefulsets", tag: "workloads",
+		params: params([]Parameter{param_at, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/workloads/jobs", id: "getJobs", summary: "List the jobs", tag: "workloads",
+		params: params([]Parameter{param_namespace, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/workloads/cronjobs", id: "getCronjobs", summary: "List the cronjobs", tag: "workloads",
+		params: params([]Parameter{param_namespace, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/cronjobs/calendar", id: "getCronjobCalendar", summary: "List the planned runs of the cronjobs sorted by time, suspended cronjobs are skipped", tag: "workloads",
+		params: params([]Parameter{
+			query("from", "start of the time range (RFC3339), defaults to now", dateTime()),
+			query("to", "end of the time range (RFC3339), defaults to 24 hours after from, at most 31 days after from", dateTime()),
+			param_namespace,
+			param_selector,
+		}, list_params),
+		data: reflect.TypeOf([]models.CronjobRun{}), list: true,
+	},
+	{
+		path: "/workloads/pods", id: "getPods", summary: "List the pods", tag: "workloads",
+		params: params([]Parameter{param_at, param_namespace, param_name, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/workloads/daemonsets", id: "getDaemonSets", summary: "List the daemonsets", tag: "workloads",
+		params: params([]Parameter{param_at, param_selector}, list_params),
+		data:   workloads_type, list: true,
+	},
+	{
+		path: "/container-metrics", id: "getContainerMetrics", summary: "List the reduced container metrics", tag: "metrics",
+		params: params([]Parameter{param_at}, metrics_params, list_params),
+		data:   reflect.TypeOf([]models.PodContainerMetric{}), list: true,
+	},
+	{
+		path: "/metrics/query", id: "queryMetrics", summary: "Query the usage as aligned series", tag: "metrics",
+		params: []Parameter{
+			param_from,
+			param_to,
+			query("step", "distance of the points, e.g. 1m, at least 10s", &Schema{Type: "string"}),
+			query("aggregation", "aggregation across the selected pods", &Schema{Type: "string", Enum: []string{models.AGGREGATION_SUM, models.AGGREGATION_AVG, models.AGGREGATION_MAX, models.AGGREGATION_P95}}),
+			param_namespace,
+			query("container", "container to filter by", &Schema{Type: "string"}),
+			query("pod", "pod to filter by", &Schema{Type: "string"}),
+			query("workload", "workload whose pods are selected, requires namespace and workload_type", &Schema{Type: "string"}),
+			query("workload_type", "type of the workload", &Schema{Type: "string", Enum: []string{"deployments", "statefulsets", "daemonsets", "jobs", "cronjobs"}}),
+		},
+		data: reflect.TypeOf(models.MetricsQueryResult{}),
+	},
+	{
+		path: "/snapshots", id: "getSnapshots", summary: "List the snapshots of the history", tag: "snapshots",
+		params: list_params,
+		data:   reflect.TypeOf([]models.Snapshot{}), list: true,
+	},
+	{
+		path: "/search", id: "search", summary: "Search nodes, namespaces and workloads", tag: "search",
+		params: []Parameter{
+			{Name: "q", In: "query", Description: "terms which all need to match", Required: true, Schema: &Schema{Type: "string"}},
+			query("limit", "maximum number of results per kind", &Schema{Type: "integer", Format: "int64"}),
+		},
+		data: reflect.TypeOf(models.SearchResults{}),
+	},
+	{
+		path: "/watch", id: "watch", summary: "Stream the changes of the stored objects as server-sent events, a websocket is used on upgrade requests", tag: "watch",
+		params: []Parameter{
+			query("kinds", "comma separated kinds to watch: node, namespace, workload, metric, all kinds by default", &Schema{Type: "string"}),
+			query("namespace", "namespace to filter by, nodes are not sent when set", &Schema{Type: "string"}),
+			query("resume", "id of the last received event, the stream continues after it", &Schema{Type: "string"}),
+			{Name: "Last-Event-ID", In: "header", Description: "alternative to resume, sent by reconnecting EventSources", Schema: &Schema{Type: "string"}},
+		},
+		data: reflect.TypeOf(models.WatchEvent{}), stream: true,
+	},
+	{
+		path: "/audit", id: "getAuditLog", summary: "List the recorded api requests, newest first and 100 per page by default", tag: "audit",
+		params: params([]Parameter{
+			query("user", "name of the principal to filter by", &Schema{Type: "string"}),
+			query("route", "route pattern to filter by, e.g. /api/v1/namespaces/:name", &Schema{Type: "string"}),
+			query("path", "prefix of the request path to filter by", &Schema{Type: "string"}),
+			query("status", "http status to filter by", &Schema{Type: "integer", Format: "int64"}),
+			query("denied", "only the requests denied by the authentication or authorization when true", &Schema{Type: "boolean"}),
+			query("from", "start of the time range (RFC3339)", dateTime()),
+			query("to", "end of the time range (RFC3339)", dateTime()),
+		}, list_params),
+		data: reflect.TypeOf([]models.AuditEntry{}), list: true,
+	},
+	{
+		path: "/status", id: "getStatus", summary: "Get the state of the synchronization with kubernetes and of the database", tag: "meta",
+		data: reflect.TypeOf(models.SyncStatus{}),
+	},
+	{
+		path: "/openapi.json", id: "getOpenAPI", summary: "Get this OpenAPI document", tag: "meta",
+	},
+}
+
+// operation returns the OpenAPI operation of the route
+func (r route) operation(g *generator) *Operation {
+	op := &Operation{
+		OperationID: r.id,
+		Summary:     r.summary,
+		Tags:        []string{r.tag},
+		Parameters:  r.params,
+		Responses: map[string]Response{
+			"default": jsonResponse("failed request", Ref("ErrorResponse")),
+		},
+	}
+
+	if r.data == nil {
+		op.Responses["200"] = jsonResponse("ok", &Schema{Type: "object"})
+		return op
+	}
+
+	if r.stream {
+		op.Responses["200"] = Response{
+			Description: "stream of events, the event field is the type and the data field the json of the event",
+			Content:     map[string]MediaType{"text/event-stream": {Schema: g.schemaFor(r.data)}},
+		}
+		return op
+	}
+
+	envelope := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "integer"},
+			"msg":  {Type: "string"},
+			"data": g.schemaFor(r.data),
+		},
+		Required: []string{"code", "data", "msg"},
+	}
+	if r.list {
+		// the items are not nullable, lists are always returned as array
+		envelope.Properties["data"].Nullable = false
+		envelope.Properties["metadata"] = g.schemaFor(reflect.TypeOf(models.ListMetadata{}))
+		envelope.Required = append(envelope.Required, "metadata")
+	}
+	op.Responses["200"] = jsonResponse("ok", envelope)
+	if r.list {
+		// the exports contain the items only, total and continue are sent in the X-Total-Count and X-Continue headers
+		op.Responses["200"].Content["text/csv"] = MediaType{Schema: &Schema{Type: "string"}}
+		op.Responses["200"].Content["application/x-ndjson"] = MediaType{Schema: g.schemaFor(r.data.Elem())}
+		op.Responses["200"].Content["application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+
+	return op
+}
+
+func jsonResponse(description string, schema *Schema) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+func query(name string, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func path(name string, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+func dateTime() *Schema {
+	return &Schema{Type: "string", Format: "date-time"}
+}
+
+func params(groups ...[]Parameter) []Parameter {
+	result := make([]Parameter, 0)
+	for _, group := range groups {
+		result = append(result, group...)
+	}
+
+	return result
+}
@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	Schemas are generated out of the go types following the rules of encoding/json.
+	Named structs become components, fields without omitempty are required and slices, maps & pointers are nullable.
+	Workloads are a oneOf of the concrete workload types, discriminated by their type.
+**/
+
+var (
+	time_type     = reflect.TypeOf(time.Time{})
+	raw_type      = reflect.TypeOf(json.RawMessage{})
+	workload_type = reflect.TypeOf((*models.Workload)(nil)).Elem()
+)
+
+// the concrete workloads, their MarshalJSON adds the type to the struct fields
+var workload_types = []models.Workload{
+	models.DeploymentWorkload{},
+	models.DaemonSetWorkload{},
+	models.StatefulSetWorkload{},
+	models.PodWorkload{},
+	models.JobWorkload{},
+	models.CronjobWorkload{},
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: make(map[string]*Schema)}
+}
+
+// schemaFor returns the schema of the type, named structs are added to the components and referenced
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case time_type:
+		return &Schema{Type: "string", Format: "date-time"}
+	case raw_type:
+		return &Schema{Nullable: true}
+	case workload_type:
+		return g.workload()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schemaFor(t.Elem()))
+	case reflect.Interface:
+		return &Schema{Nullable: true}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Int32, reflect.Uint32, reflect.Int16, reflect.Uint16, reflect.Int8, reflect.Uint8:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// registered before the fields are generated to support recursive types
+			g.schemas[t.Name()] = &Schema{}
+			*g.schemas[t.Name()] = *g.object(t)
+		}
+		return Ref(t.Name())
+	}
+
+	return &Schema{Nullable: true}
+}
+
+// object returns the schema of the struct fields
+func (g *generator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t)
+	sort.Strings(schema.Required)
+
+	return schema
+}
+
+func (g *generator) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		// untagged embedded structs are flattened
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// workload returns the reference to the workload union, the concrete workloads are added to the components
+func (g *generator) workload() *Schema {
+	const name = "Workload"
+	if _, ok := g.schemas[name]; ok {
+		return Ref(name)
+	}
+
+	union := &Schema{Discriminator: &Discriminator{PropertyName: "type", Mapping: make(map[string]string)}}
+	g.schemas[name] = union
+
+	for _, workload := range workload_types {
+		t := reflect.TypeOf(workload)
+		ref := g.schemaFor(t)
+
+		concrete := g.schemas[t.Name()]
+		concrete.Properties["type"] = &Schema{Type: "string", Enum: []string{workload.GetType()}}
+		concrete.Required = append(concrete.Required, "type")
+		sort.Strings(concrete.Required)
+
+		union.OneOf = append(union.OneOf, ref)
+		union.Discriminator.Mapping[workload.GetType()] = ref.Ref
+	}
+
+	return Ref(name)
+}
+
+// nullable returns the schema allowing null, references are wrapped as OpenAPI 3.0 ignores siblings of $ref
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+
+	schema.Nullable = true
+	return schema
+}
@@ -0,0 +1,177 @@
+package openapi
+
+/**
+	The OpenAPI 3 document of the /api/v1 routes.
+	The schemas are generated out of the models, the operations are described in operations.go.
+	The document is served at /api/v1/openapi.json and used to generate the client in pkg/client.
+**/
+
+const (
+	OPENAPI_VERSION = "3.0.3"
+	API_VERSION     = "v1"
+	BASE_PATH       = "/api/v1"
+)
+
+// Document - the root of an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem - the operations of a path, only GET routes are registered
+type PathItem struct {
+	Get *Operation `json:"get,omitempty"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme - a way to authenticate, see internal/auth
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// SecurityRequirement - the schemes required by a request, one of the requirements needs to be satisfied
+type SecurityRequirement map[string][]string
+
+// Schema - the subset of the OpenAPI schema object used by the api
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Discriminator        *Discriminator     `json:"discriminator,omitempty"`
+}
+
+type Discriminator struct {
+	PropertyName string            `json:"propertyName"`
+	Mapping      map[string]string `json:"mapping,omitempty"`
+}
+
+// Ref returns a schema referencing the component
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// RefName returns the name of the component referenced by the schema
+func (s *Schema) RefName() string {
+	const prefix = "#/components/schemas/"
+	if len(s.Ref) > len(prefix) {
+		return s.Ref[len(prefix):]
+	}
+
+	return ""
+}
+
+// Spec returns the OpenAPI document of the api
+func Spec() *Document {
+	g := newGenerator()
+
+	document := &Document{
+		OpenAPI: OPENAPI_VERSION,
+		Info: Info{
+			Title:       "kdd",
+			Description: "Kubernetes dashboard api, every response is wrapped into an envelope with code, msg and data.",
+			Version:     API_VERSION,
+		},
+		Servers: []Server{{URL: BASE_PATH}},
+		Paths:   make(map[string]*PathItem),
+	}
+
+	g.schemas["ErrorDetails"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"reason":  {Type: "string", Enum: []string{"internal_error", "invalid_filter", "unauthorized", "forbidden", "not_found", "expired", "unavailable"}},
+			"details": {Type: "string"},
+		},
+		Required: []string{"details", "reason"},
+	}
+	g.schemas["ErrorResponse"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":  {Type: "integer"},
+			"msg":   {Type: "string"},
+			"data":  {Nullable: true},
+			"error": Ref("ErrorDetails"),
+		},
+		Required: []string{"code", "error", "msg"},
+	}
+
+	for _, op := range operations {
+		document.Paths[op.path] = &PathItem{Get: op.operation(g)}
+	}
+
+	document.Components.Schemas = g.schemas
+	// anonymous access is a setting of the server, the empty requirement makes the authentication optional
+	document.Components.SecuritySchemes = map[string]*SecurityScheme{
+		"bearerAuth": {Type: "http", Scheme: "bearer", Description: "static api token or OIDC id token"},
+		"cookieAuth": {Type: "apiKey", In: "cookie", Name: "kdd_session", Description: "session created by the OIDC login at /auth/login"},
+	}
+	document.Security = []SecurityRequirement{{"bearerAuth": {}}, {"cookieAuth": {}}, {}}
+
+	return document
+}
+
+// Operation returns the operation of the path, nil if the path is not documented
+func (d *Document) Operation(path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+
+	return item.Get
+}
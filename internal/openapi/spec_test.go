@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+func TestSpecValidatesWorkloads(t *testing.T) {
+	spec := Spec()
+
+	pod, err := json.Marshal(models.PodWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "web-1", Namespace: "default", CreationTimestamp: time.Now()},
+		Status:              "Running",
+	})
+	require.NoError(t, err)
+	assert.NoError(t, spec.ValidateJSON(Ref("Workload"), pod))
+
+	var value map[string]any
+	require.NoError(t, json.Unmarshal(pod, &value))
+
+	delete(value, "restarts")
+	assert.ErrorContains(t, spec.Validate(Ref("Workload"), value), "missing required property restarts")
+
+	value["restarts"] = 1
+	value["replicas"] = 1
+	assert.ErrorContains(t, spec.Validate(Ref("Workload"), value), "unknown property replicas")
+
+	delete(value, "replicas")
+	value["type"] = "ReplicaSet"
+	assert.ErrorContains(t, spec.Validate(Ref("Workload"), value), "unknown type")
+}
+
+func TestSpecSchemas(t *testing.T) {
+	spec := Spec()
+
+	job := spec.Components.Schemas["JobStatus"]
+	require.NotNil(t, job)
+	assert.True(t, job.Properties["start_time"].Nullable)
+	assert.Equal(t, "date-time", job.Properties["start_time"].Format)
+
+	// omitempty fields are optional
+	result := spec.Components.Schemas["SearchResult"]
+	assert.NotContains(t, result.Required, "namespace")
+	assert.Contains(t, result.Required, "kind")
+
+	for path, item := range spec.Paths {
+		require.NotNil(t, item.Get, path)
+		_, stream := item.Get.Responses["200"].Content["text/event-stream"]
+		assert.True(t, stream || item.Get.ResponseSchema(200) != nil, path)
+		assert.NotNil(t, item.Get.ResponseSchema(404), path)
+	}
+}
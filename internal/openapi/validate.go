@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**
+	Validation of json values against the schemas of the document, used by the contract tests.
+	Objects are validated strictly: properties which are not described by the schema are rejected.
+**/
+
+// ValidateJSON validates the raw json value against the schema
+func (d *Document) ValidateJSON(schema *Schema, raw []byte) error {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+
+	return d.Validate(schema, value)
+}
+
+// Validate validates the decoded json value against the schema
+func (d *Document) Validate(schema *Schema, value any) error {
+	return d.validate(schema, value, "$")
+}
+
+// ResponseSchema returns the json schema of the response of the operation for the http status
+func (o *Operation) ResponseSchema(status int) *Schema {
+	response, ok := o.Responses[fmt.Sprint(status)]
+	if !ok {
+		response = o.Responses["default"]
+	}
+
+	content, ok := response.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	return content.Schema
+}
+
+func (d *Document) validate(schema *Schema, value any, at string) error {
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[schema.RefName()]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, schema.Ref)
+		}
+		return d.validate(resolved, value, at)
+	}
+
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.AllOf) == 0 && len(schema.OneOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", at)
+	}
+
+	for _, s := range schema.AllOf {
+		if err := d.validate(s, value, at); err != nil {
+			return err
+		}
+	}
+
+	if len(schema.OneOf) > 0 {
+		return d.validateOneOf(schema, value, at)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		return d.validateObject(schema, value, at)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", at, value)
+		}
+		for i, item := range items {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", at, value)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Errorf("%s: invalid date-time %s", at, s)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			return fmt.Errorf("%s: %s is not one of %s", at, s, strings.Join(schema.Enum, ", "))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer, got %v", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", at, value)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %s", at, schema.Type)
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(schema *Schema, value any, at string) error {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: expected object, got %T", at, value)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: missing required property %s", at, name)
+		}
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		property, ok := schema.Properties[key]
+		if !ok {
+			property = schema.AdditionalProperties
+		}
+		if property == nil {
+			if schema.Properties == nil {
+				// free form object
+				continue
+			}
+			return fmt.Errorf("%s: unknown property %s", at, key)
+		}
+		if err := d.validate(property, object[key], fmt.Sprintf("%s.%s", at, key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOneOf validates the value against the schema selected by the discriminator
+func (d *Document) validateOneOf(schema *Schema, value any, at string) error {
+	if schema.Discriminator == nil {
+		for _, s := range schema.OneOf {
+			if d.validate(s, value, at) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: does not match any schema", at)
+	}
+
+	object, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: expected object, got %T", at, value)
+	}
+
+	discriminator, _ := object[schema.Discriminator.PropertyName].(string)
+	ref, ok := schema.Discriminator.Mapping[discriminator]
+	if !ok {
+		return fmt.Errorf("%s: unknown %s %q", at, schema.Discriminator.PropertyName, discriminator)
+	}
+
+	return d.validate(&Schema{Ref: ref}, value, at)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,186 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	The audit log is append-only, a trigger rejects updates of recorded entries.
+	Entries are only removed when they are older than the retention.
+	The timestamps are stored in milliseconds and the latency in microseconds.
+**/
+
+const audit_sql_fields = "key, timestamp, user, auth_method, method, route, path, params, status, latency, denied, client_ip"
+
+// audit_schema is executed statement by statement, the trigger contains a semicolon
+var audit_schema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		key INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		user TEXT NOT NULL,
+		auth_method TEXT NOT NULL,
+		method TEXT NOT NULL,
+		route TEXT NOT NULL,
+		path TEXT NOT NULL,
+		params TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		latency INTEGER NOT NULL,
+		denied INTEGER NOT NULL,
+		client_ip TEXT NOT NULL
+	)`,
+	"CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp)",
+	"CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user)",
+	`CREATE TRIGGER IF NOT EXISTS audit_log_append_only BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END`,
+}
+
+var audit_sort_columns = sortColumns{
+	columns: map[string]string{
+		"id":         "key",
+		"timestamp":  "timestamp",
+		"user":       "user",
+		"route":      "route",
+		"status":     "status",
+		"latency_ms": "latency",
+	},
+	jsonColumns: map[string]string{"params": "params"},
+	defaultSort: "timestamp",
+}
+
+func createAuditLog(db *sql.DB) error {
+	for _, q := range audit_schema {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AppendAuditEntries appends the entries to the audit log within one transaction
+func (d *DataStore) AppendAuditEntries(entries []models.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO audit_log (timestamp, user, auth_method, method, route, path, params, status, latency, denied, client_ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		params, err := json.Marshal(entry.Params)
+		if err != nil {
+			return err
+		}
+
+		latency := int64(entry.LatencyMS * 1000)
+		if _, err := stmt.Exec(entry.Timestamp.UnixMilli(), entry.User, entry.AuthMethod, entry.Method, entry.Route, entry.Path, string(params),
+			entry.Status, latency, entry.Denied, entry.ClientIP); err != nil {
+			zap.L().Error("could not append audit entry", zap.Error(err))
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveAuditEntriesBefore removes the entries of the audit log recorded before t
+func (d *DataStore) RemoveAuditEntriesBefore(t time.Time) error {
+	_, err := d.db.Exec("DELETE FROM audit_log WHERE timestamp < ?", t.UnixMilli())
+	return err
+}
+
+// ListAuditEntries returns the requested page of the audit entries matching the filter
+func (d *DataStore) ListAuditEntries(filter models.AuditFilter, opts models.ListOptions) (*models.Page[models.AuditEntry], error) {
+	stream, err := d.StreamAuditEntries(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Page()
+}
+
+// StreamAuditEntries returns the requested page of the audit entries matching the filter, the entries are read while the stream is iterated
+func (d *DataStore) StreamAuditEntries(filter models.AuditFilter, opts models.ListOptions) (*models.Stream[models.AuditEntry], error) {
+	conditions, values := auditConditions(filter)
+
+	return streamRows(d, "audit_log", audit_sql_fields, conditions, values, audit_sort_columns, opts, scanAuditEntries)
+}
+
+func scanAuditEntries(rows *sql.Rows, add func(entry models.AuditEntry)) error {
+	for rows.Next() {
+		var entry models.AuditEntry
+		var timestamp int64
+		var params []byte
+		var latency int64
+
+		if err := rows.Scan(&entry.ID, &timestamp, &entry.User, &entry.AuthMethod, &entry.Method, &entry.Route, &entry.Path, &params,
+			&entry.Status, &latency, &entry.Denied, &entry.ClientIP); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return err
+		}
+		if err := json.Unmarshal(params, &entry.Params); err != nil {
+			zap.L().Error("could not unmarshal audit params", zap.Error(err))
+			return err
+		}
+
+		entry.Timestamp = time.UnixMilli(timestamp)
+		entry.LatencyMS = float64(latency) / 1000
+		add(entry)
+	}
+
+	return nil
+}
+
+// auditConditions translates the filter into conditions on the audit log
+func auditConditions(filter models.AuditFilter) ([]string, []any) {
+	conditions := make([]string, 0)
+	values := make([]any, 0)
+
+	if filter.User != "" {
+		conditions = append(conditions, "user = ?")
+		values = append(values, filter.User)
+	}
+	if filter.Route != "" {
+		conditions = append(conditions, "route = ?")
+		values = append(values, filter.Route)
+	}
+	if filter.Path != "" {
+		conditions = append(conditions, "path LIKE ? ESCAPE '\\'")
+		values = append(values, strings.TrimPrefix(likePattern(filter.Path), "%"))
+	}
+	if filter.Status != 0 {
+		conditions = append(conditions, "status = ?")
+		values = append(values, filter.Status)
+	}
+	if filter.Denied != nil {
+		conditions = append(conditions, "denied = ?")
+		values = append(values, *filter.Denied)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		values = append(values, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		values = append(values, filter.To.UnixMilli())
+	}
+
+	return conditions, values
+}
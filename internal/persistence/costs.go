@@ -0,0 +1,298 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	The allocations record the requested resources of the running pods and the capacity of the nodes per hour.
+	Every collection adds the time since the previous collection, the state of the collection is used for the whole interval.
+	The usage of the pods is taken from the hourly tier of the metrics, the allocations are kept as long as it.
+**/
+
+// MAX_ALLOCATION_INTERVAL - longer intervals between two collections are not allocated, e.g. while kdd was not running
+const MAX_ALLOCATION_INTERVAL = time.Minute * 5
+
+var allocations_schema = []string{
+	`CREATE TABLE IF NOT EXISTS pod_allocations (
+		hour INTEGER NOT NULL,
+		namespace TEXT NOT NULL,
+		pod_name TEXT NOT NULL,
+		workload_type TEXT NOT NULL,
+		workload_name TEXT NOT NULL,
+		node_name TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		seconds INTEGER NOT NULL,
+		cpu_request INTEGER NOT NULL,
+		memory_request INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL,
+		PRIMARY KEY (hour, namespace, pod_name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS node_allocations (
+		hour INTEGER NOT NULL,
+		node_name TEXT NOT NULL,
+		labels TEXT NOT NULL,
+		seconds INTEGER NOT NULL,
+		cpu INTEGER NOT NULL,
+		memory INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL,
+		PRIMARY KEY (hour, node_name)
+	)`,
+}
+
+func createAllocations(db *sql.DB) error {
+	for _, q := range allocations_schema {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordAllocations adds the requests of the running pods and the capacity of the nodes for the time since the previous collection.
+// The cpu is recorded in millicore seconds and the memory in byte seconds.
+func (d *DataStore) RecordAllocations(nodes *models.NodeCollection, workloads *models.WorkloadCollection, now time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var last sql.NullInt64
+	if err := tx.QueryRow("SELECT MAX(last_seen) FROM (SELECT MAX(last_seen) AS last_seen FROM node_allocations UNION ALL SELECT MAX(last_seen) FROM pod_allocations)").Scan(&last); err != nil {
+		return err
+	}
+
+	// the first collection only records the allocated objects
+	var elapsed int64
+	if last.Valid {
+		if interval := now.Sub(time.Unix(last.Int64, 0)); interval > 0 && interval <= MAX_ALLOCATION_INTERVAL {
+			elapsed = int64(interval / time.Second)
+		}
+	}
+	hour := now.Truncate(time.Hour).Unix()
+
+	nodeStmt, err := tx.Prepare("INSERT INTO node_allocations (hour, node_name, labels, seconds, cpu, memory, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?) " +
+		"ON CONFLICT (hour, node_name) DO UPDATE SET labels = excluded.labels, seconds = seconds + excluded.seconds, " +
+		"cpu = cpu + excluded.cpu, memory = memory + excluded.memory, last_seen = excluded.last_seen")
+	if err != nil {
+		return err
+	}
+	defer nodeStmt.Close()
+
+	for _, node := range nodes.GetAll() {
+		labels, err := json.Marshal(node.Labels)
+		if err != nil {
+			return err
+		}
+
+		if _, err := nodeStmt.Exec(hour, node.Name, string(labels), elapsed, node.Cpu*elapsed, node.Memory*elapsed, now.Unix()); err != nil {
+			zap.L().Error("could not record node allocation", zap.String("node", node.Name), zap.Error(err))
+			return err
+		}
+	}
+
+	podStmt, err := tx.Prepare("INSERT INTO pod_allocations (hour, namespace, pod_name, workload_type, workload_name, node_name, labels, seconds, cpu_request, memory_request, last_seen) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+		"ON CONFLICT (hour, namespace, pod_name) DO UPDATE SET node_name = excluded.node_name, labels = excluded.labels, seconds = seconds + excluded.seconds, " +
+		"cpu_request = cpu_request + excluded.cpu_request, memory_request = memory_request + excluded.memory_request, last_seen = excluded.last_seen")
+	if err != nil {
+		return err
+	}
+	defer podStmt.Close()
+
+	owners := workloadsByNamespace(workloads)
+	for _, w := range workloads.GetAll() {
+		pod, ok := w.(models.PodWorkload)
+		if !ok || pod.Status != "Running" || pod.NodeName == "" {
+			continue
+		}
+
+		labels, err := json.Marshal(pod.Labels)
+		if err != nil {
+			return err
+		}
+
+		workloadType, workloadName := ownerWorkload(pod, owners[pod.Namespace])
+		cpu, memory := podRequests(pod)
+		if _, err := podStmt.Exec(hour, pod.Namespace, pod.WorkloadName, workloadType, workloadName, pod.NodeName, string(labels),
+			elapsed, cpu*elapsed, memory*elapsed, now.Unix()); err != nil {
+			zap.L().Error("could not record pod allocation", zap.String("pod", pod.WorkloadName), zap.Error(err))
+			return err
+		}
+	}
+
+	expired := now.Add(-d.metricsRetention.OneHour).Unix()
+	for _, table := range []string{"pod_allocations", "node_allocations"} {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE hour < ?", table), expired); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// podRequests returns the effective requests of the pod like the scheduler, the init containers run before the containers
+func podRequests(pod models.PodWorkload) (int64, int64) {
+	var cpu, memory, initCPU, initMemory int64
+	for _, container := range pod.Containers {
+		if container.InitContainer {
+			if container.RequestCPU > initCPU {
+				initCPU = container.RequestCPU
+			}
+			if container.RequestMemory > initMemory {
+				initMemory = container.RequestMemory
+			}
+			continue
+		}
+
+		cpu += container.RequestCPU
+		memory += container.RequestMemory
+	}
+
+	if initCPU > cpu {
+		cpu = initCPU
+	}
+	if initMemory > memory {
+		memory = initMemory
+	}
+
+	return cpu, memory
+}
+
+// GetAllocations returns the allocations of the pods in the namespace (all namespaces when empty) and of the nodes
+// within the hours starting between from and to.
+func (d *DataStore) GetAllocations(namespace string, from time.Time, to time.Time) ([]models.PodAllocation, []models.NodeAllocation, error) {
+	if namespace != "" {
+		if err := d.scope.Check(namespace); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	usage, err := d.getHourlyUsage(d.allocationConditions("creation_timestamp", namespace, from, to))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where, values := d.allocationConditions("hour", namespace, from, to)
+	rows, err := d.read.Query("SELECT hour, namespace, pod_name, workload_type, workload_name, node_name, labels, seconds, cpu_request, memory_request "+
+		"FROM pod_allocations WHERE "+where+" ORDER BY hour, namespace, pod_name", values...)
+	if err != nil {
+		zap.L().Error("could not load pod allocations", zap.Error(err))
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	pods := make([]models.PodAllocation, 0)
+	for rows.Next() {
+		var pod models.PodAllocation
+		var hour, seconds, cpu, memory int64
+		var rawLabels []byte
+		if err := rows.Scan(&hour, &pod.Namespace, &pod.PodName, &pod.WorkloadType, &pod.WorkloadName, &pod.NodeName, &rawLabels, &seconds, &cpu, &memory); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(rawLabels, &pod.Labels); err != nil {
+			zap.L().Error("could not unmarshal labels", zap.Error(err))
+			continue
+		}
+
+		pod.Hour = time.Unix(hour, 0)
+		pod.CPURequest = float64(cpu) / 1000 / 3600
+		pod.MemoryRequest = models.ToGiB(float64(memory)) / 3600
+		if avg, ok := usage[fmt.Sprintf("%d/%s/%s", hour, pod.Namespace, pod.PodName)]; ok {
+			pod.CPUUsage = avg[0] / 1000 * float64(seconds) / 3600
+			pod.MemoryUsage = models.ToGiB(avg[1]) * float64(seconds) / 3600
+		}
+		pods = append(pods, pod)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	nodes, err := d.getNodeAllocations(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pods, nodes, nil
+}
+
+// allocationConditions returns the conditions selecting the hours starting between from and to of the namespace, restricted to the scope
+func (d *DataStore) allocationConditions(hourColumn string, namespace string, from time.Time, to time.Time) (string, []any) {
+	conditions := []string{hourColumn + " >= ?", hourColumn + " < ?"}
+	values := []any{from.Truncate(time.Hour).Unix(), to.Unix()}
+	if namespace != "" {
+		conditions = append(conditions, "namespace = ?")
+		values = append(values, namespace)
+	}
+	conditions, values = d.appendScopeCondition(conditions, values, "namespace")
+
+	return strings.Join(conditions, " AND "), values
+}
+
+// getHourlyUsage returns the average cpu and memory usage of the pods by hour/namespace/pod from the hourly tier
+func (d *DataStore) getHourlyUsage(where string, values []any) (map[string][2]float64, error) {
+	rows, err := d.read.Query("SELECT creation_timestamp, namespace, pod_name, SUM(cpu_avg), SUM(memory_avg) FROM container_metrics_1h WHERE "+where+
+		" GROUP BY creation_timestamp, namespace, pod_name", values...)
+	if err != nil {
+		zap.L().Error("could not load hourly usage", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string][2]float64)
+	for rows.Next() {
+		var hour int64
+		var namespace, podName string
+		var cpu, memory float64
+		if err := rows.Scan(&hour, &namespace, &podName, &cpu, &memory); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+		usage[fmt.Sprintf("%d/%s/%s", hour, namespace, podName)] = [2]float64{cpu, memory}
+	}
+
+	return usage, rows.Err()
+}
+
+// getNodeAllocations returns the capacity of the nodes within the hours starting between from and to, nodes are not restricted by the scope
+func (d *DataStore) getNodeAllocations(from time.Time, to time.Time) ([]models.NodeAllocation, error) {
+	rows, err := d.read.Query("SELECT hour, node_name, labels, cpu, memory FROM node_allocations WHERE hour >= ? AND hour < ? ORDER BY hour, node_name",
+		from.Truncate(time.Hour).Unix(), to.Unix())
+	if err != nil {
+		zap.L().Error("could not load node allocations", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := make([]models.NodeAllocation, 0)
+	for rows.Next() {
+		var node models.NodeAllocation
+		var hour, cpu, memory int64
+		var rawLabels []byte
+		if err := rows.Scan(&hour, &node.NodeName, &rawLabels, &cpu, &memory); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+		if err := json.Unmarshal(rawLabels, &node.Labels); err != nil {
+			zap.L().Error("could not unmarshal labels", zap.Error(err))
+			continue
+		}
+
+		node.Hour = time.Unix(hour, 0)
+		node.CPU = float64(cpu) / 1000 / 3600
+		node.Memory = models.ToGiB(float64(memory)) / 3600
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
@@ -1,28 +1,46 @@
 package persistence
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"gitlab.com/patrick.erber/kdd/internal/config"
 	"gitlab.com/patrick.erber/kdd/internal/models"
 	"go.uber.org/zap"
 )
 
 /**
  This package is used for our long time storage.
- Beside the different workloads, we will store for one week the metrics of the current pods.
+ Beside the different workloads, we will store the metrics of the current pods as raw samples and rollups.
 
 **/
 
+/**
+	All writes go through a single connection, every replace runs in one transaction and is split up into batches.
+	The database runs in WAL mode, readers use their own connection pool and never see half-written state.
+**/
+
 type DataStore struct {
-	db *sql.DB
+	db               *sql.DB
+	read             *sql.DB
+	metricsRetention config.MetricsRetentionConfig
+	fullTextSearch   bool
+	scope            *models.NamespaceScope // restricts the reads of a view created by WithScope, nil for all namespaces
 }
 
+// sqlite_max_variables is the maximum number of host parameters of a single statement
+const sqlite_max_variables = 32766
+
+// generation_tables are swept by generation after a replace
+var generation_tables = []string{"nodes", "namespaces", "workloads"}
+
 const sqlite3_schema string = `
 CREATE TABLE IF NOT EXISTS nodes (
 	key TEXT NOT NULL PRIMARY KEY,
@@ -35,7 +53,8 @@ CREATE TABLE IF NOT EXISTS nodes (
 	kubelet_version TEXT NOT NULL, 
 	labels TEXT NOT NULL, 
 	annotations TEXT NOT NULL,
-	creation_timestamp INTEGER NOT NULL
+	creation_timestamp INTEGER NOT NULL,
+	generation INTEGER NOT NULL DEFAULT 0
 ); 
 CREATE TABLE IF NOT EXISTS namespaces (
 	key TEXT NOT NULL PRIMARY KEY,
@@ -43,7 +62,10 @@ CREATE TABLE IF NOT EXISTS namespaces (
 	name TEXT NOT NULL, 
 	labels TEXT NOT NULL, 
 	annotations TEXT NOT NULL,
-	creation_timestamp INTEGER NOT NULL
+	creation_timestamp INTEGER NOT NULL,
+	resource_quotas TEXT NOT NULL DEFAULT '[]',
+	limit_ranges TEXT NOT NULL DEFAULT '[]',
+	generation INTEGER NOT NULL DEFAULT 0
 ); 
 CREATE TABLE IF NOT EXISTS workloads (
 	key TEXT NOT NULL PRIMARY KEY,
@@ -57,7 +79,15 @@ CREATE TABLE IF NOT EXISTS workloads (
 	containers TEXT NOT NULL,
 	restarts INT,
 	status TEXT NOT NULL, 
-	creation_timestamp INTEGER NOT NULL
+	creation_timestamp INTEGER NOT NULL,
+	selector_expressions TEXT NOT NULL DEFAULT '[]',
+	node_name TEXT NOT NULL DEFAULT '',
+	generation INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS workload_labels (
+	workload_key TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL
 );
 CREATE TABLE IF NOT EXISTS container_metrics (
 	key TEXT NOT NULL,
@@ -68,36 +98,141 @@ CREATE TABLE IF NOT EXISTS container_metrics (
 	memory_usage INTEGER NOT NULL,
 	creation_timestamp INTEGER NOT NULL
 );
+CREATE TABLE IF NOT EXISTS container_metrics_1m (
+	key TEXT NOT NULL,
+	pod_name TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	container_name TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	cpu_min INTEGER NOT NULL,
+	cpu_avg INTEGER NOT NULL,
+	cpu_max INTEGER NOT NULL,
+	cpu_p95 INTEGER NOT NULL,
+	memory_min INTEGER NOT NULL,
+	memory_avg INTEGER NOT NULL,
+	memory_max INTEGER NOT NULL,
+	memory_p95 INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS container_metrics_5m (
+	key TEXT NOT NULL,
+	pod_name TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	container_name TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	cpu_min INTEGER NOT NULL,
+	cpu_avg INTEGER NOT NULL,
+	cpu_max INTEGER NOT NULL,
+	cpu_p95 INTEGER NOT NULL,
+	memory_min INTEGER NOT NULL,
+	memory_avg INTEGER NOT NULL,
+	memory_max INTEGER NOT NULL,
+	memory_p95 INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS container_metrics_1h (
+	key TEXT NOT NULL,
+	pod_name TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	container_name TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	cpu_min INTEGER NOT NULL,
+	cpu_avg INTEGER NOT NULL,
+	cpu_max INTEGER NOT NULL,
+	cpu_p95 INTEGER NOT NULL,
+	memory_min INTEGER NOT NULL,
+	memory_avg INTEGER NOT NULL,
+	memory_max INTEGER NOT NULL,
+	memory_p95 INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS metric_rollups (
+	tier TEXT NOT NULL PRIMARY KEY,
+	last_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS workload_revisions (
+	key TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	workload_name TEXT NOT NULL,
+	workload_type TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	data TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	deleted INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS node_revisions (
+	key TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	deleted INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS namespace_revisions (
+	key TEXT NOT NULL,
+	revision INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	deleted INTEGER NOT NULL,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	creation_timestamp INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS snapshot_objects (
+	snapshot_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	key TEXT NOT NULL,
+	data TEXT NOT NULL
+);
 CREATE INDEX IF NOT EXISTS idx_nodes_name ON nodes(name);
 CREATE INDEX IF NOT EXISTS idx_namespaces_name ON namespaces(name);
 CREATE INDEX IF NOT EXISTS idx_workloads_namespacename ON workloads(namespace);
+CREATE INDEX IF NOT EXISTS idx_workload_labels_name_value ON workload_labels(name, value);
+CREATE INDEX IF NOT EXISTS idx_workload_labels_workload_key ON workload_labels(workload_key);
 CREATE INDEX IF NOT EXISTS idx_container_metrics_pod_name ON container_metrics(pod_name);
 CREATE INDEX IF NOT EXISTS idx_container_metrics_container_name ON container_metrics(container_name);
-CREATE UNIQUE INDEX IF NOT EXISTS idx_container_metrics_unique_key_creation_timestamp ON container_metrics(key, creation_timestamp)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_container_metrics_unique_key_creation_timestamp ON container_metrics(key, creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_container_metrics_creation_timestamp ON container_metrics(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_container_metrics_1m_unique_key_creation_timestamp ON container_metrics_1m(key, creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_container_metrics_1m_creation_timestamp ON container_metrics_1m(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_container_metrics_5m_unique_key_creation_timestamp ON container_metrics_5m(key, creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_container_metrics_5m_creation_timestamp ON container_metrics_5m(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_container_metrics_1h_unique_key_creation_timestamp ON container_metrics_1h(key, creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_container_metrics_1h_creation_timestamp ON container_metrics_1h(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workload_revisions_key_revision ON workload_revisions(key, revision);
+CREATE INDEX IF NOT EXISTS idx_workload_revisions_workload ON workload_revisions(namespace, workload_type, workload_name);
+CREATE INDEX IF NOT EXISTS idx_workload_revisions_creation_timestamp ON workload_revisions(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_node_revisions_key_revision ON node_revisions(key, revision);
+CREATE INDEX IF NOT EXISTS idx_node_revisions_creation_timestamp ON node_revisions(creation_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_namespace_revisions_key_revision ON namespace_revisions(key, revision);
+CREATE INDEX IF NOT EXISTS idx_namespace_revisions_creation_timestamp ON namespace_revisions(creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_snapshots_creation_timestamp ON snapshots(creation_timestamp);
+CREATE INDEX IF NOT EXISTS idx_snapshot_objects_snapshot_kind ON snapshot_objects(snapshot_id, kind)
 `
 
-const workloads_sql_fields = "key, workload_name, workload_type, namespace, labels, annotations, selector, containers, status, restarts, owner_ressources, creation_timestamp"
-
-func filterWorkloadBySelector(selectorFilter map[string]string) models.FilterFunc {
-	return func(a interface{}) bool {
-		workload := a.(models.Workload)
-		found := 0
-		for selectorKey, selectorValue := range selectorFilter {
-			for workloadKey, workloadValue := range workload.GetLabels() {
-				if workloadKey == selectorKey && workloadValue == selectorValue {
-					found++
-					break
-				}
-			}
-		}
+const nodes_sql_fields = "key, name, cpu, memory, os_image, kubelet_version, labels, annotations, creation_timestamp, roles, status"
 
-		return len(selectorFilter) == found
+const namespaces_sql_fields = "key, name, status, labels, annotations, creation_timestamp, resource_quotas, limit_ranges"
+
+const workloads_sql_fields = "key, workload_name, workload_type, namespace, labels, annotations, selector, containers, status, restarts, owner_ressources, creation_timestamp, selector_expressions, node_name"
+
+func filterWorkloadByLabelSelector(selector models.LabelSelector) models.FilterFunc[models.Workload] {
+	return func(w models.Workload) bool {
+		return selector.Matches(w.GetLabels())
 	}
 }
 
-func filterPodByOwnerRessource(ownerRessourceName string) models.FilterFunc {
-	return func(a interface{}) bool {
-		workload := a.(models.PodWorkload)
+func filterPodByOwnerRessource(ownerRessourceName string) models.FilterFunc[models.Workload] {
+	return func(w models.Workload) bool {
+		workload, ok := w.(models.PodWorkload)
+		if !ok {
+			return false
+		}
 		for _, r := range workload.PodOwnerRessources {
 			if r.Name == ownerRessourceName {
 				return true
@@ -108,11 +243,15 @@ func filterPodByOwnerRessource(ownerRessourceName string) models.FilterFunc {
 	}
 }
 
-func filterCronjobsPodsByOwnerRessource(workloadName string) models.FilterFunc {
-	return func(a interface{}) bool {
-		workload := a.(models.PodWorkload)
+// filterCronjobsPodsByOwnerRessource matches the pods of the jobs owned by the cronjob
+func filterCronjobsPodsByOwnerRessource(workloadName string) models.FilterFunc[models.Workload] {
+	return func(w models.Workload) bool {
+		workload, ok := w.(models.PodWorkload)
+		if !ok {
+			return false
+		}
 		for _, r := range workload.PodOwnerRessources {
-			if strings.HasPrefix(r.Name, fmt.Sprintf("%s-", workloadName)) && r.Kind == "Job" {
+			if r.Kind == "Job" && r.Owner != nil && r.Owner.Kind == "CronJob" && r.Owner.Name == workloadName {
 				return true
 			}
 		}
@@ -121,12 +260,15 @@ func filterCronjobsPodsByOwnerRessource(workloadName string) models.FilterFunc {
 }
 
 // NewSQLiteDataStore creates a new instance of the data store.
-func NewSQLiteDataStore(filename string) (*DataStore, error) {
-	db, err := sql.Open("sqlite3", filename)
+func NewSQLiteDataStore(filename string, metricsRetention config.MetricsRetentionConfig) (*DataStore, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000&_txlock=immediate", filename))
 	if err != nil {
 		return nil, err
 	}
 
+	// sqlite allows only one writer at a time
+	db.SetMaxOpenConns(1)
+
 	// dirty workaround to call the commands separated.
 	// This can be fixed by go-sqlite3 package
 	for _, q := range strings.Split(sqlite3_schema, ";") {
@@ -140,20 +282,102 @@ func NewSQLiteDataStore(filename string) (*DataStore, error) {
 		}
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
+	if err := createAuditLog(db); err != nil {
+		return nil, err
+	}
+
+	if err := createRestartHistory(db); err != nil {
+		return nil, err
+	}
+
+	if err := createAllocations(db); err != nil {
+		return nil, err
+	}
+
+	fullTextSearch, err := createSearchIndex(db)
+	if err != nil {
+		return nil, err
+	}
+
+	read, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro&_journal_mode=WAL&_busy_timeout=5000", filename))
+	if err != nil {
+		return nil, err
+	}
+
+	read.SetMaxOpenConns(runtime.NumCPU())
+
 	return &DataStore{
-		db: db,
+		db:               db,
+		read:             read,
+		metricsRetention: metricsRetention,
+		fullTextSearch:   fullTextSearch,
 	}, nil
 }
 
-func (d *DataStore) ReplaceNodes(collection *models.Collection) error {
-	cntFields := 11
-	sqlStmtHead := "REPLACE INTO nodes (key, name, cpu, memory, os_image, kubelet_version, labels, annotations, creation_timestamp, status, roles) VALUES "
-	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+// migrate updates tables created by older versions
+func migrate(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "workloads", "selector_expressions", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing(db, "workloads", "node_name", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	for _, column := range []string{"resource_quotas", "limit_ranges"} {
+		if err := addColumnIfMissing(db, "namespaces", column, "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range generation_tables {
+		if err := addColumnIfMissing(db, table, "generation", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_generation ON %s(generation)", table, table)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addColumnIfMissing(db *sql.DB, table string, column string, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (d *DataStore) ReplaceNodes(collection *models.NodeCollection) error {
+	cntFields := 12
+	sqlStmtHead := "REPLACE INTO nodes (key, name, cpu, memory, os_image, kubelet_version, labels, annotations, creation_timestamp, status, roles, generation) VALUES "
+	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	rows := collection.Len()
 	values := make([]any, rows*cntFields)
 	i := 0
-	for key, value := range collection.GetAll() {
-		node := value.(models.Node)
+	for key, node := range collection.GetAll() {
 		labels, err := json.Marshal(node.Labels)
 		if err != nil {
 			return err
@@ -178,18 +402,19 @@ func (d *DataStore) ReplaceNodes(collection *models.Collection) error {
 		i += cntFields
 	}
 
-	if err := d.replace(sqlStmtHead, sqlStmtVals, rows, values); err != nil {
-		zap.L().Error("could not replace nodes", zap.Error(err))
-		return err
-	}
+	return d.replaceTable("nodes", sqlStmtHead, sqlStmtVals, cntFields, values, func(tx *sql.Tx) error {
+		if err := d.rebuildSearchIndex(tx, models.SEARCH_KIND_NODE); err != nil {
+			return err
+		}
 
-	return d.cleanUpAfterReplace("nodes", collection.GetKeys())
+		return d.recordNodeRevisions(tx, collection)
+	})
 }
 
-func (d *DataStore) GetAllNodes() (*models.Collection, error) {
-	collection := models.NewCollection()
-	sqlStmt := "SELECT key, name, cpu, memory, os_image, kubelet_version, labels, annotations, creation_timestamp, roles, status FROM nodes"
-	stmt, err := d.db.Prepare(sqlStmt)
+func (d *DataStore) GetAllNodes() (*models.NodeCollection, error) {
+	collection := models.NewCollection[string, models.Node]()
+	sqlStmt := fmt.Sprintf("SELECT %s FROM nodes", nodes_sql_fields)
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
@@ -200,6 +425,15 @@ func (d *DataStore) GetAllNodes() (*models.Collection, error) {
 	}
 
 	defer rows.Close()
+	if err := scanNodes(rows, func(key string, node models.Node) { collection.Set(key, node, false) }); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// scanNodes reads the nodes of the rows in order, add is called for every node
+func scanNodes(rows *sql.Rows, add func(key string, node models.Node)) error {
 	for rows.Next() {
 		var key string
 		var name string
@@ -217,7 +451,7 @@ func (d *DataStore) GetAllNodes() (*models.Collection, error) {
 
 		if err := rows.Scan(&key, &name, &cpu, &memory, &os_image, &kubelet_version, &rawLabels, &rawAnnotations, &creationTimestamp, &roles, &status); err != nil {
 			zap.L().Error("Could not scan result from sqllite database", zap.Error(err))
-			return nil, err
+			return err
 		}
 		if err := json.Unmarshal(rawLabels, &labels); err != nil {
 			zap.L().Error("could not unmarshal labels", zap.Error(err))
@@ -242,22 +476,20 @@ func (d *DataStore) GetAllNodes() (*models.Collection, error) {
 			CreationTimestamp: time.Unix(creationTimestamp, 0),
 		}
 
-		collection.Set(key, ns, false)
+		add(key, ns)
 	}
 
-	return collection, nil
-
+	return nil
 }
 
-func (d *DataStore) ReplaceNamespaces(collection *models.Collection) error {
-	cntFields := 6
-	sqlStmtHead := "REPLACE INTO namespaces (key, name, status, labels, annotations, creation_timestamp) VALUES "
-	sqlStmtVals := "(?, ?, ?, ?, ?, ?)"
+func (d *DataStore) ReplaceNamespaces(collection *models.NamespaceCollection) error {
+	cntFields := 9
+	sqlStmtHead := "REPLACE INTO namespaces (key, name, status, labels, annotations, creation_timestamp, resource_quotas, limit_ranges, generation) VALUES "
+	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	rows := collection.Len()
 	values := make([]any, rows*cntFields)
 	i := 0
-	for key, value := range collection.GetAll() {
-		namespace := value.(models.Namespace)
+	for key, namespace := range collection.GetAll() {
 		labels, err := json.Marshal(namespace.Labels)
 		if err != nil {
 			return err
@@ -266,6 +498,14 @@ func (d *DataStore) ReplaceNamespaces(collection *models.Collection) error {
 		if err != nil {
 			return err
 		}
+		resourceQuotas, err := json.Marshal(namespace.ResourceQuotas)
+		if err != nil {
+			return err
+		}
+		limitRanges, err := json.Marshal(namespace.LimitRanges)
+		if err != nil {
+			return err
+		}
 		creationTimestamp := namespace.CreationTimestamp.Unix()
 
 		values[i] = key
@@ -274,21 +514,24 @@ func (d *DataStore) ReplaceNamespaces(collection *models.Collection) error {
 		values[i+3] = string(labels)
 		values[i+4] = string(annotations)
 		values[i+5] = strconv.FormatInt(creationTimestamp, 10)
+		values[i+6] = string(resourceQuotas)
+		values[i+7] = string(limitRanges)
 		i += cntFields
 	}
 
-	if err := d.replace(sqlStmtHead, sqlStmtVals, rows, values); err != nil {
-		zap.L().Error("could not replace namespaces", zap.Error(err))
-		return err
-	}
+	return d.replaceTable("namespaces", sqlStmtHead, sqlStmtVals, cntFields, values, func(tx *sql.Tx) error {
+		if err := d.rebuildSearchIndex(tx, models.SEARCH_KIND_NAMESPACE); err != nil {
+			return err
+		}
 
-	return d.cleanUpAfterReplace("namespaces", collection.GetKeys())
+		return d.recordNamespaceRevisions(tx, collection)
+	})
 }
 
-func (d *DataStore) GetAllNamespaces() (*models.Collection, error) {
-	collection := models.NewCollection()
-	sqlStmt := "SELECT key, name, status, labels, annotations, creation_timestamp FROM namespaces"
-	stmt, err := d.db.Prepare(sqlStmt)
+func (d *DataStore) GetAllNamespaces() (*models.NamespaceCollection, error) {
+	collection := models.NewCollection[string, models.Namespace]()
+	sqlStmt := fmt.Sprintf("SELECT %s FROM namespaces", namespaces_sql_fields)
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
@@ -299,6 +542,15 @@ func (d *DataStore) GetAllNamespaces() (*models.Collection, error) {
 	}
 
 	defer rows.Close()
+	if err := scanNamespaces(rows, func(key string, namespace models.Namespace) { collection.Set(key, namespace, false) }); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// scanNamespaces reads the namespaces of the rows in order, add is called for every namespace
+func scanNamespaces(rows *sql.Rows, add func(key string, namespace models.Namespace)) error {
 	for rows.Next() {
 		var key string
 		var name string
@@ -306,12 +558,16 @@ func (d *DataStore) GetAllNamespaces() (*models.Collection, error) {
 		var creationTimestamp int64
 		var rawLabels []byte
 		var rawAnnotations []byte
+		var rawResourceQuotas []byte
+		var rawLimitRanges []byte
 		labels := make(map[string]string)
 		annotations := make(map[string]string)
+		resourceQuotas := make([]models.ResourceQuota, 0)
+		limitRanges := make([]models.LimitRange, 0)
 
-		if err := rows.Scan(&key, &name, &status, &rawLabels, &rawAnnotations, &creationTimestamp); err != nil {
+		if err := rows.Scan(&key, &name, &status, &rawLabels, &rawAnnotations, &creationTimestamp, &rawResourceQuotas, &rawLimitRanges); err != nil {
 			zap.L().Error("Could not scan result from sqllite database", zap.Error(err))
-			return nil, err
+			return err
 		}
 		if err := json.Unmarshal(rawLabels, &labels); err != nil {
 			zap.L().Error("could not unmarshal labels", zap.Error(err))
@@ -322,24 +578,39 @@ func (d *DataStore) GetAllNamespaces() (*models.Collection, error) {
 			zap.L().Error("could not unmarshal annotations", zap.Error(err))
 			continue
 		}
+
+		if err := json.Unmarshal(rawResourceQuotas, &resourceQuotas); err != nil {
+			zap.L().Error("could not unmarshal resource quotas", zap.Error(err))
+			continue
+		}
+
+		if err := json.Unmarshal(rawLimitRanges, &limitRanges); err != nil {
+			zap.L().Error("could not unmarshal limit ranges", zap.Error(err))
+			continue
+		}
 		ns := models.Namespace{
 			Name:              name,
 			Status:            status,
 			Labels:            labels,
 			Annotations:       annotations,
 			CreationTimestamp: time.Unix(creationTimestamp, 0),
+			ResourceQuotas:    resourceQuotas,
+			LimitRanges:       limitRanges,
 		}
 
-		collection.Set(key, ns, false)
+		add(key, ns)
 	}
 
-	return collection, nil
-
+	return nil
 }
 
 func (d *DataStore) GetNamespace(name string) (*models.Namespace, error) {
-	sqlStmt := "SELECT key, name, status, labels, annotations, creation_timestamp FROM namespaces WHERE name=? LIMIT 1"
-	stmt, err := d.db.Prepare(sqlStmt)
+	if err := d.scope.Check(name); err != nil {
+		return nil, err
+	}
+
+	sqlStmt := fmt.Sprintf("SELECT %s FROM namespaces WHERE name=? LIMIT 1", namespaces_sql_fields)
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
@@ -350,51 +621,27 @@ func (d *DataStore) GetNamespace(name string) (*models.Namespace, error) {
 	}
 
 	defer rows.Close()
-	if rows.Next() {
-		var key string
-		var name string
-		var status string
-		var creationTimestamp int64
-		var rawLabels []byte
-		var rawAnnotations []byte
-		labels := make(map[string]string)
-		annotations := make(map[string]string)
-
-		if err := rows.Scan(&key, &name, &status, &rawLabels, &rawAnnotations, &creationTimestamp); err != nil {
-			zap.L().Error("Could not scan result from sqllite database", zap.Error(err))
-			return nil, err
-		}
-		if err := json.Unmarshal(rawLabels, &labels); err != nil {
-			zap.L().Error("could not unmarshal labels", zap.Error(err))
-			return nil, err
-		}
-
-		if err := json.Unmarshal(rawAnnotations, &annotations); err != nil {
-			zap.L().Error("could not unmarshal annotations", zap.Error(err))
-			return nil, err
-		}
-		return &models.Namespace{
-			Name:              name,
-			Status:            status,
-			Labels:            labels,
-			Annotations:       annotations,
-			CreationTimestamp: time.Unix(creationTimestamp, 0),
-		}, nil
+	var namespace *models.Namespace
+	if err := scanNamespaces(rows, func(key string, ns models.Namespace) { namespace = &ns }); err != nil {
+		return nil, err
+	}
+	if namespace != nil {
+		return namespace, nil
 	}
 
-	return nil, fmt.Errorf(fmt.Sprintf("namespace %s could not be found", name))
+	return nil, fmt.Errorf("namespace %s: %w", name, models.ErrNotFound)
 
 }
 
-func (d *DataStore) ReplaceWorkloads(collection *models.Collection) error {
-	cntFields := 12
-	sqlStmtHead := fmt.Sprintf("REPLACE INTO workloads (%s) VALUES ", workloads_sql_fields)
-	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+func (d *DataStore) ReplaceWorkloads(collection *models.WorkloadCollection) error {
+	cntFields := 15
+	sqlStmtHead := fmt.Sprintf("REPLACE INTO workloads (%s, generation) VALUES ", workloads_sql_fields)
+	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	rows := collection.Len()
 	values := make([]any, rows*cntFields)
+	labelValues := make([]any, 0)
 	i := 0
-	for key, value := range collection.GetAll() {
-		workload := value.(models.Workload)
+	for key, workload := range collection.GetAll() {
 		labels, err := json.Marshal(workload.GetLabels())
 		if err != nil {
 			return err
@@ -407,6 +654,10 @@ func (d *DataStore) ReplaceWorkloads(collection *models.Collection) error {
 		if err != nil {
 			return err
 		}
+		selectorExpressions, err := json.Marshal(workload.GetLabelSelector().MatchExpressions)
+		if err != nil {
+			return err
+		}
 		containers, err := json.Marshal(workload.GetContainers())
 		if err != nil {
 			return err
@@ -434,47 +685,82 @@ func (d *DataStore) ReplaceWorkloads(collection *models.Collection) error {
 				return err
 			}
 			values[i+10] = owners
+			values[i+13] = value.NodeName
 		} else {
 			values[i+9] = "0"
 			values[i+10] = "[]"
+			values[i+13] = ""
 		}
 
 		values[i+11] = strconv.FormatInt(creationTimestamp, 10)
+		values[i+12] = string(selectorExpressions)
 		i += cntFields
+
+		for name, value := range workload.GetLabels() {
+			labelValues = append(labelValues, key, name, value)
+		}
 	}
 
-	if err := d.replace(sqlStmtHead, sqlStmtVals, rows, values); err != nil {
-		zap.L().Error("could not replace workloads", zap.Error(err))
+	restarts, err := d.getRestartCounts()
+	if err != nil {
+		zap.L().Error("could not load restart counts", zap.Error(err))
 		return err
 	}
+	now := time.Now()
+
+	return d.replaceTable("workloads", sqlStmtHead, sqlStmtVals, cntFields, values, func(tx *sql.Tx) error {
+		if err := d.recordRestarts(tx, restarts, collection, now); err != nil {
+			return err
+		}
 
-	return d.cleanUpAfterReplace("workloads", collection.GetKeys())
+		if err := d.replaceWorkloadLabels(tx, labelValues); err != nil {
+			zap.L().Error("could not replace workload labels", zap.Error(err))
+			return err
+		}
+
+		if err := d.rebuildSearchIndex(tx, models.SEARCH_KIND_WORKLOAD); err != nil {
+			return err
+		}
+
+		return d.recordWorkloadRevisions(tx, collection)
+	})
 }
 
-func (d *DataStore) GetAllWorkloads() (*models.Collection, error) {
-	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads", workloads_sql_fields)
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return nil, err
+// replaceWorkloadLabels rebuilds the normalized labels table used for label selector lookups
+func (d *DataStore) replaceWorkloadLabels(tx *sql.Tx, values []any) error {
+	if _, err := tx.Exec("DELETE FROM workload_labels"); err != nil {
+		return err
 	}
 
-	rows, err := stmt.Query()
-	if err != nil {
-		return nil, err
+	if len(values) == 0 {
+		return nil
 	}
 
-	defer rows.Close()
-	return d.createWorkloadCollection(rows)
+	return d.replace(tx, "INSERT INTO workload_labels (workload_key, name, value) VALUES ", "(?, ?, ?)", 3, values)
+}
+
+func (d *DataStore) GetAllWorkloads() (*models.WorkloadCollection, error) {
+	return d.getWorkloadsWhere(nil, nil)
 }
 
-func (d *DataStore) GetAllByWorkloadType(t string) (*models.Collection, error) {
-	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads WHERE workload_type=?", workloads_sql_fields)
-	stmt, err := d.db.Prepare(sqlStmt)
+func (d *DataStore) GetAllByWorkloadType(t string) (*models.WorkloadCollection, error) {
+	return d.getWorkloadsWhere([]string{"workload_type = ?"}, []any{t})
+}
+
+// getWorkloadsWhere returns the workloads matching the conditions within the scope of the data store
+func (d *DataStore) getWorkloadsWhere(conditions []string, values []any) (*models.WorkloadCollection, error) {
+	conditions, values = d.appendScopeCondition(conditions, values, "namespace")
+
+	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads", workloads_sql_fields)
+	if len(conditions) > 0 {
+		sqlStmt = fmt.Sprintf("%s WHERE %s", sqlStmt, strings.Join(conditions, " AND "))
+	}
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := stmt.Query(t)
+	rows, err := stmt.Query(values...)
 	if err != nil {
 		return nil, err
 	}
@@ -483,8 +769,17 @@ func (d *DataStore) GetAllByWorkloadType(t string) (*models.Collection, error) {
 	return d.createWorkloadCollection(rows)
 }
 
-func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection, error) {
-	collection := models.NewCollection()
+func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.WorkloadCollection, error) {
+	collection := models.NewCollection[string, models.Workload]()
+	if err := scanWorkloads(rows, func(key string, workload models.Workload) { collection.Set(key, workload, false) }); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// scanWorkloads reads the workloads of the rows in order, add is called for every workload
+func scanWorkloads(rows *sql.Rows, add func(key string, workload models.Workload)) error {
 	for rows.Next() {
 		var key string
 		var workloadName string
@@ -493,19 +788,22 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 		var rawLabels []byte
 		var rawAnnotations []byte
 		var rawSelector []byte
+		var rawSelectorExpressions []byte
 		var rawContainers []byte
 		var rawStatus []byte
 		var rawOwnerRessources []byte
 		var creationTimestamp int
 		var restarts int
+		var nodeName string
 		containers := make([]models.Container, 0)
 		labels := make(map[string]string)
 		annotations := make(map[string]string)
 		selector := make(map[string]string)
+		selectorExpressions := make([]models.LabelSelectorRequirement, 0)
 
-		if err := rows.Scan(&key, &workloadName, &workloadType, &namespace, &rawLabels, &rawAnnotations, &rawSelector, &rawContainers, &rawStatus, &restarts, &rawOwnerRessources, &creationTimestamp); err != nil {
+		if err := rows.Scan(&key, &workloadName, &workloadType, &namespace, &rawLabels, &rawAnnotations, &rawSelector, &rawContainers, &rawStatus, &restarts, &rawOwnerRessources, &creationTimestamp, &rawSelectorExpressions, &nodeName); err != nil {
 			zap.L().Error("Could not scan result from sqllite database", zap.Error(err))
-			return nil, err
+			return err
 		}
 		if err := json.Unmarshal(rawLabels, &labels); err != nil {
 			zap.L().Error("could not unmarshal labels", zap.Error(err))
@@ -519,19 +817,24 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 			zap.L().Error("could not unmarshal selector", zap.Error(err))
 			continue
 		}
+		if err := json.Unmarshal(rawSelectorExpressions, &selectorExpressions); err != nil {
+			zap.L().Error("could not unmarshal selector expressions", zap.Error(err))
+			continue
+		}
 		if err := json.Unmarshal(rawContainers, &containers); err != nil {
 			zap.L().Error("could not unmarshal containers", zap.Error(err))
 			continue
 		}
 
 		workloadInfo := models.GeneralWorkloadInfo{
-			WorkloadName:      workloadName,
-			Namespace:         namespace,
-			Labels:            labels,
-			Annotations:       annotations,
-			Selector:          selector,
-			Containers:        containers,
-			CreationTimestamp: time.Unix(int64(creationTimestamp), 0),
+			WorkloadName:        workloadName,
+			Namespace:           namespace,
+			Labels:              labels,
+			Annotations:         annotations,
+			Selector:            selector,
+			SelectorExpressions: selectorExpressions,
+			Containers:          containers,
+			CreationTimestamp:   time.Unix(int64(creationTimestamp), 0),
 		}
 
 		/**
@@ -549,7 +852,7 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 				GeneralWorkloadInfo: workloadInfo,
 				Status:              status,
 			}
-			collection.Set(key, wl, false)
+			add(key, wl)
 		case models.WORKLOAD_TYPE_DEAMONSET:
 			var status models.DaemonSetStatus
 			if err := json.Unmarshal(rawStatus, &status); err != nil {
@@ -560,7 +863,7 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 				GeneralWorkloadInfo: workloadInfo,
 				Status:              status,
 			}
-			collection.Set(key, wl, false)
+			add(key, wl)
 		case models.WORKLOAD_TYPE_STATEFULSET:
 			var status models.StatefulSetStatus
 			if err := json.Unmarshal(rawStatus, &status); err != nil {
@@ -571,7 +874,7 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 				GeneralWorkloadInfo: workloadInfo,
 				Status:              status,
 			}
-			collection.Set(key, wl, false)
+			add(key, wl)
 		case models.WORKLOAD_TYPE_POD:
 			var status string
 			if err := json.Unmarshal(rawStatus, &status); err != nil {
@@ -590,121 +893,46 @@ func (*DataStore) createWorkloadCollection(rows *sql.Rows) (*models.Collection,
 				Status:              status,
 				Restarts:            restarts,
 				PodOwnerRessources:  ownerRessources,
+				NodeName:            nodeName,
 			}
-			collection.Set(key, wl, false)
+			add(key, wl)
 		default:
 			zap.L().Error(fmt.Sprintf("unsupported type: %s", workloadType))
 		}
 	}
 
-	return collection, nil
+	return nil
 }
 
-func (d *DataStore) GetWorkloadsByNamespace(namespace string) (*models.Collection, error) {
-	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads WHERE namespace=?", workloads_sql_fields)
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := stmt.Query(namespace)
-	if err != nil {
+func (d *DataStore) GetWorkloadsByNamespace(namespace string) (*models.WorkloadCollection, error) {
+	if err := d.scope.Check(namespace); err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
-	return d.createWorkloadCollection(rows)
+	return d.getWorkloadsWhere([]string{"namespace = ?"}, []any{namespace})
 }
 
-func (d *DataStore) GetMetricsForPodsInNamespace(namespace string, workloads []models.Workload) (*models.Collection, error) {
-	podNames := make([]any, len(workloads))
-	for i, workload := range workloads {
-		podNames[i] = workload.GetWorkloadName()
-		if workload.GetType() != models.WORKLOAD_TYPE_POD {
-			return nil, fmt.Errorf("the workload needs to be a pod. workload_type: %s", workload.GetType())
-		}
-	}
-
-	if len(podNames) == 0 {
-		return nil, fmt.Errorf("no pods given")
-	}
-
-	sqlStmt := "SELECT key, pod_name, container_name, namespace, cpu_usage, memory_usage, creation_timestamp FROM container_metrics WHERE namespace=? AND pod_name IN "
-
-	var sb strings.Builder
-	sb.WriteString(sqlStmt)
-
-	sb.WriteRune('(')
-	for i := 0; i < len(podNames); i++ {
-		sb.WriteRune('?')
-		if i != len(podNames)-1 {
-			sb.WriteString(", ")
-		} else {
-			sb.WriteRune(')')
-		}
-	}
-
-	stmt, err := d.db.Prepare(sb.String())
-	if err != nil {
-		return nil, err
-	}
-
-	whereValues := make([]any, 0)
-	whereValues = append(whereValues, namespace)
-	whereValues = append(whereValues, podNames...)
-
-	rows, err := stmt.Query(whereValues...)
-	if err != nil {
+func (d *DataStore) GetWorkloadBy(filters map[string]string) (models.Workload, error) {
+	if err := d.checkScopeFilter(filters); err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
-
-	resultCollection := models.NewCollection()
-
-	for rows.Next() {
-		var key string
-		var podName string
-		var containerName string
-		var namespace string
-		var cpuUsage int64
-		var memoryUsage int64
-		var creationTimestamp int
-
-		if err := rows.Scan(&key, &podName, &containerName, &namespace, &cpuUsage, &memoryUsage, &creationTimestamp); err != nil {
-			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
-			return nil, err
-		}
-
-		resultCollection.Set(fmt.Sprintf("%s_%s_%d", podName, namespace, creationTimestamp), models.PodContainerMetric{
-			PodName:           podName,
-			Namespace:         namespace,
-			ContainerName:     containerName,
-			CPUUsage:          cpuUsage,
-			MemoryUsage:       memoryUsage,
-			CreationTimestamp: time.Unix(int64(creationTimestamp), 0),
-		}, true)
-	}
-
-	return resultCollection, nil
-}
-
-func (d *DataStore) GetWorkloadBy(filters map[string]string) (models.Workload, error) {
 	sqlParams := make([]string, len(filters))
 	values := make([]any, len(filters))
 	i := 0
 	for key, val := range filters {
 		if key != "namespace" && key != "workload_name" && key != "workload_type" {
-			return nil, fmt.Errorf("invalid parameters found")
+			return nil, fmt.Errorf("%w: unsupported filter %s", models.ErrInvalidFilter, key)
 		}
 
 		sqlParams[i] = fmt.Sprintf("%s = ?", key)
 		values[i] = val
 		i++
 	}
+	sqlParams, values = d.appendScopeCondition(sqlParams, values, "namespace")
 
 	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads WHERE %s LIMIT 1", workloads_sql_fields, strings.Join(sqlParams, " AND "))
-	stmt, err := d.db.Prepare(sqlStmt)
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
@@ -722,28 +950,29 @@ func (d *DataStore) GetWorkloadBy(filters map[string]string) (models.Workload, e
 	}
 
 	if collectionResult.Len() < 1 {
-		return nil, fmt.Errorf("no result found")
+		return nil, fmt.Errorf("workload: %w", models.ErrNotFound)
 	}
 
-	return collectionResult.ToList()[0].(models.Workload), nil
+	return collectionResult.ToList()[0], nil
 }
 
-func (d *DataStore) GetWorkloadsBy(filters map[string]string) (*models.Collection, error) {
-	sqlParams := make([]string, len(filters))
-	values := make([]any, len(filters))
-	i := 0
-	for key, val := range filters {
-		if key != "namespace" && key != "workload_name" && key != "workload_type" {
-			return nil, fmt.Errorf("invalid parameters found")
-		}
+func (d *DataStore) GetWorkloadsBy(filters map[string]string) (*models.WorkloadCollection, error) {
+	return d.GetWorkloadsMatching(filters, models.LabelSelector{})
+}
 
-		sqlParams[i] = fmt.Sprintf("%s = ?", key)
-		values[i] = val
-		i++
+// GetWorkloadsMatching returns the workloads matching the filters and the label selector.
+func (d *DataStore) GetWorkloadsMatching(filters map[string]string, selector models.LabelSelector) (*models.WorkloadCollection, error) {
+	conditions, values, err := d.workloadConditions(filters, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads", workloads_sql_fields)
+	if len(conditions) > 0 {
+		sqlStmt = fmt.Sprintf("%s WHERE %s", sqlStmt, strings.Join(conditions, " AND "))
 	}
 
-	sqlStmt := fmt.Sprintf("SELECT %s FROM workloads WHERE %s", workloads_sql_fields, strings.Join(sqlParams, " AND "))
-	stmt, err := d.db.Prepare(sqlStmt)
+	stmt, err := d.read.Prepare(sqlStmt)
 	if err != nil {
 		return nil, err
 	}
@@ -758,7 +987,108 @@ func (d *DataStore) GetWorkloadsBy(filters map[string]string) (*models.Collectio
 	return d.createWorkloadCollection(rows)
 }
 
-func (d *DataStore) GetPodsForWorkload(w models.Workload) (*models.Collection, error) {
+// workloadConditions translates the filters, the label selector and the scope into conditions on the workloads table
+func (d *DataStore) workloadConditions(filters map[string]string, selector models.LabelSelector) ([]string, []any, error) {
+	if err := d.checkScopeFilter(filters); err != nil {
+		return nil, nil, err
+	}
+
+	conditions := make([]string, 0, len(filters))
+	values := make([]any, 0, len(filters))
+	for key, val := range filters {
+		if key != "namespace" && key != "workload_name" && key != "workload_type" {
+			return nil, nil, fmt.Errorf("%w: unsupported filter %s", models.ErrInvalidFilter, key)
+		}
+
+		conditions = append(conditions, fmt.Sprintf("%s = ?", key))
+		values = append(values, val)
+	}
+
+	selectorConditions, selectorValues, err := labelSelectorConditions(selector, workload_label_source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conditions, values = d.appendScopeCondition(append(conditions, selectorConditions...), append(values, selectorValues...), "namespace")
+	return conditions, values, nil
+}
+
+// labelSource - a subquery returning the keys of the objects with a label, selected by its name
+type labelSource struct {
+	subquery string
+	value    string // column of the label value
+}
+
+var workload_label_source = labelSource{subquery: "SELECT workload_key FROM workload_labels WHERE name = ?", value: "value"}
+
+// jsonLabelSource returns the label source of a table storing the labels as json object
+func jsonLabelSource(table string) labelSource {
+	return labelSource{subquery: fmt.Sprintf("SELECT t.key FROM %s t, json_each(t.labels) l WHERE l.key = ?", table), value: "l.value"}
+}
+
+// labelSelectorConditions translates every requirement of the selector into a condition
+func labelSelectorConditions(selector models.LabelSelector, source labelSource) ([]string, []any, error) {
+	conditions := make([]string, 0)
+	values := make([]any, 0)
+	for _, requirement := range selector.Requirements() {
+		condition, conditionValues, err := labelRequirementCondition(requirement, source)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conditions = append(conditions, condition)
+		values = append(values, conditionValues...)
+	}
+
+	return conditions, values, nil
+}
+
+// labelRequirementCondition translates a label selector requirement into a condition on the labels of the source
+func labelRequirementCondition(requirement models.LabelSelectorRequirement, source labelSource) (string, []any, error) {
+	values := []any{requirement.Key}
+	subquery := source.subquery
+
+	switch requirement.Operator {
+	case models.SELECTOR_OPERATOR_IN, models.SELECTOR_OPERATOR_NOT_IN:
+		if len(requirement.Values) == 0 {
+			return "", nil, fmt.Errorf("%w: operator %s requires values for label %s", models.ErrInvalidFilter, requirement.Operator, requirement.Key)
+		}
+
+		subquery = fmt.Sprintf("%s AND %s IN (%s)", subquery, source.value, strings.TrimSuffix(strings.Repeat("?, ", len(requirement.Values)), ", "))
+		for _, v := range requirement.Values {
+			values = append(values, v)
+		}
+	case models.SELECTOR_OPERATOR_GT, models.SELECTOR_OPERATOR_LT:
+		if len(requirement.Values) != 1 {
+			return "", nil, fmt.Errorf("%w: operator %s requires a single value for label %s", models.ErrInvalidFilter, requirement.Operator, requirement.Key)
+		}
+
+		value, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: operator %s requires an integer value for label %s", models.ErrInvalidFilter, requirement.Operator, requirement.Key)
+		}
+
+		comparison := ">"
+		if requirement.Operator == models.SELECTOR_OPERATOR_LT {
+			comparison = "<"
+		}
+
+		// only integer values can be compared
+		subquery = fmt.Sprintf("%[1]s AND CAST(%[2]s AS INTEGER) || '' = %[2]s AND CAST(%[2]s AS INTEGER) %[3]s ?", subquery, source.value, comparison)
+		values = append(values, value)
+	case models.SELECTOR_OPERATOR_EXISTS, models.SELECTOR_OPERATOR_DOES_NOT_EXIST:
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported operator %s", models.ErrInvalidFilter, requirement.Operator)
+	}
+
+	if requirement.Operator == models.SELECTOR_OPERATOR_NOT_IN || requirement.Operator == models.SELECTOR_OPERATOR_DOES_NOT_EXIST {
+		return fmt.Sprintf("key NOT IN (%s)", subquery), values, nil
+	}
+
+	return fmt.Sprintf("key IN (%s)", subquery), values, nil
+}
+
+func (d *DataStore) GetPodsForWorkload(w models.Workload) (*models.WorkloadCollection, error) {
 	filter := make(map[string]string)
 	filter["namespace"] = w.GetNamespace()
 	filter["workload_type"] = models.WORKLOAD_TYPE_POD
@@ -767,83 +1097,101 @@ func (d *DataStore) GetPodsForWorkload(w models.Workload) (*models.Collection, e
 		return nil, err
 	}
 
+	return filterPodsForWorkload(w, collection), nil
+}
+
+func filterPodsForWorkload(w models.Workload, collection *models.WorkloadCollection) *models.WorkloadCollection {
 	// FIXME: When also implementing replica set, we can use replica set to identify it and can get rid of this typecheck!
 	if w.GetType() == models.WORKLOAD_TYPE_DEPLOYMENT {
-		return collection.Filter(filterWorkloadBySelector(w.GetSelector())), nil
+		return collection.Filter(filterWorkloadByLabelSelector(w.GetLabelSelector()))
 	} else if w.GetType() == models.WORKLOAD_TYPE_CRONJOB {
-		return collection.Filter(filterCronjobsPodsByOwnerRessource(w.GetWorkloadName())), nil
+		return collection.Filter(filterCronjobsPodsByOwnerRessource(w.GetWorkloadName()))
 	} else {
-		return collection.Filter(filterPodByOwnerRessource(w.GetWorkloadName())), nil
+		return collection.Filter(filterPodByOwnerRessource(w.GetWorkloadName()))
 	}
 }
 
-func (d *DataStore) replace(sqlStmtHead string, sqlStmtVals string, rows int, values []any) error {
-	// Preparing replace query and execute.
-	var querySb strings.Builder
-	querySb.WriteString(sqlStmtHead)
+// replaceTable replaces all rows of the table within one transaction.
+// The last value of every row is set to the new generation, rows of older generations are removed afterwards.
+func (d *DataStore) replaceTable(tableName string, sqlStmtHead string, sqlStmtVals string, cntFields int, values []any, afterReplace func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	for i := 0; i < rows; i++ {
-		querySb.WriteString(sqlStmtVals)
-		if i != rows-1 {
-			querySb.WriteString(", ")
-		} else {
-			querySb.WriteRune('\n')
-		}
+	var generation int64
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(generation), 0) + 1 FROM %s", tableName)).Scan(&generation); err != nil {
+		return err
 	}
 
-	stmt, err := d.db.Prepare(querySb.String())
-	if err != nil {
+	for i := cntFields - 1; i < len(values); i += cntFields {
+		values[i] = generation
+	}
+
+	if err := d.replace(tx, sqlStmtHead, sqlStmtVals, cntFields, values); err != nil {
+		zap.L().Error("could not replace rows", zap.String("table", tableName), zap.Error(err))
 		return err
 	}
 
-	if _, err := stmt.Exec(values...); err != nil {
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE generation <> ?", tableName), generation); err != nil {
+		zap.L().Error("could not remove stale rows", zap.String("table", tableName), zap.Error(err))
 		return err
 	}
 
-	return nil
+	if err := afterReplace(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (d *DataStore) cleanUpAfterReplace(tableName string, values []string) error {
-	cnt := len(values)
-	var querySb strings.Builder
+// replace executes the statement in batches, so the number of variables per statement stays below the sqlite limit
+func (d *DataStore) replace(tx *sql.Tx, sqlStmtHead string, sqlStmtVals string, cntFields int, values []any) error {
+	rows := len(values) / cntFields
+	batchSize := sqlite_max_variables / cntFields
 
-	// creates placeholder for delete query
-	for i := 0; i < cnt; i++ {
-		querySb.WriteRune('?')
-		if i != cnt-1 {
-			querySb.WriteString(", ")
+	var batchStmt *sql.Stmt
+	for start := 0; start < rows; start += batchSize {
+		end := start + batchSize
+		if end > rows {
+			end = rows
 		}
-	}
 
-	// preparing & execute cleanup query
-	query := fmt.Sprintf("DELETE FROM %s WHERE key NOT IN (%s)", tableName, querySb.String())
-	stmt, err := d.db.Prepare(query)
-	if err != nil {
-		return err
-	}
+		// full batches share one prepared statement
+		stmt := batchStmt
+		if stmt == nil || end-start != batchSize {
+			var querySb strings.Builder
+			querySb.WriteString(sqlStmtHead)
+			querySb.WriteString(strings.TrimSuffix(strings.Repeat(sqlStmtVals+", ", end-start), ", "))
 
-	// casting string to any
-	keys := make([]any, cnt)
-	for i := 0; i < cnt; i++ {
-		keys[i] = values[i]
-	}
+			s, err := tx.Prepare(querySb.String())
+			if err != nil {
+				return err
+			}
+			defer s.Close()
 
-	if _, err := stmt.Exec(keys...); err != nil {
-		return err
+			stmt = s
+			if end-start == batchSize {
+				batchStmt = s
+			}
+		}
+
+		if _, err := stmt.Exec(values[start*cntFields : end*cntFields]...); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (d *DataStore) UpdateMetrics(collection *models.Collection) error {
+func (d *DataStore) UpdateMetrics(collection *models.MetricCollection) error {
 	cntFields := 7
 	sqlStmtHead := "REPLACE INTO container_metrics (key, pod_name, container_name, namespace, cpu_usage, memory_usage, creation_timestamp) VALUES "
 	sqlStmtVals := "(?, ?, ?, ?, ?, ?, ?)"
-	rows := collection.Len()
-	values := make([]any, rows*cntFields)
+	values := make([]any, collection.Len()*cntFields)
 	i := 0
-	for key, value := range collection.GetAll() {
-		metric := value.(models.PodContainerMetric)
+	for key, metric := range collection.GetAll() {
 
 		creationTimestamp := metric.CreationTimestamp.Unix()
 
@@ -857,70 +1205,44 @@ func (d *DataStore) UpdateMetrics(collection *models.Collection) error {
 		i += cntFields
 	}
 
-	if err := d.replace(sqlStmtHead, sqlStmtVals, rows, values); err != nil {
-		zap.L().Error("could not replace metrics", zap.Error(err))
+	tx, err := d.db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return d.removeOldMetrics()
-}
-
-func (d *DataStore) removeOldMetrics() error {
-	dt := time.Now().Add(-time.Hour * 24 * 7).Unix()
-	query := "DELETE FROM container_metrics WHERE creation_timestamp < ?"
-
-	stmt, err := d.db.Prepare(query)
-	if err != nil {
+	if err := d.replace(tx, sqlStmtHead, sqlStmtVals, cntFields, values); err != nil {
+		zap.L().Error("could not replace metrics", zap.Error(err))
 		return err
 	}
 
-	if _, err := stmt.Exec(dt); err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-	return nil
+
+	return d.removeExpiredMetrics(time.Now())
 }
 
-func (d *DataStore) GetAllMetrics() (*models.Collection, error) {
-	collection := models.NewCollection()
-	sqlStmt := "SELECT key, pod_name, container_name, namespace, cpu_usage, memory_usage, creation_timestamp FROM container_metrics"
-	stmt, err := d.db.Prepare(sqlStmt)
-	if err != nil {
-		return nil, err
+// Size returns the size of the database in bytes, the write-ahead log is not included
+func (d *DataStore) Size() (int64, error) {
+	var pageCount, pageSize int64
+	if err := d.read.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, err
 	}
-
-	rows, err := stmt.Query()
-	if err != nil {
-		return nil, err
+	if err := d.read.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-		var key string
-		var podName string
-		var containerName string
-		var namespace string
-		var cpuUsage int64
-		var memoryUsage int64
-		var creationTimestamp int64
-
-		if err := rows.Scan(&key, &podName, &containerName, &namespace, &cpuUsage, &memoryUsage, &creationTimestamp); err != nil {
-			zap.L().Error("Could not scan result from sqllite database", zap.Error(err))
-			return nil, err
-		}
-
-		collection.Set(key, models.PodContainerMetric{
-			PodName:           podName,
-			Namespace:         namespace,
-			ContainerName:     containerName,
-			CPUUsage:          cpuUsage,
-			MemoryUsage:       memoryUsage,
-			CreationTimestamp: time.Unix(creationTimestamp, 0),
-		}, false)
-	}
+	return pageCount * pageSize, nil
+}
 
-	return collection, nil
+// Ping checks that the database can be read
+func (d *DataStore) Ping(ctx context.Context) error {
+	var tables int
+	return d.read.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master").Scan(&tables)
 }
 
 func (d *DataStore) CloseConnections() {
+	d.read.Close()
 	d.db.Close()
 }
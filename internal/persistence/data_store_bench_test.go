@@ -0,0 +1,109 @@
+package persistence
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+const bench_pods = 50000
+
+func newBenchDataStore(b *testing.B) *DataStore {
+	ds, err := NewSQLiteDataStore(filepath.Join(b.TempDir(), "bench.sqlite"), config.MetricsRetentionConfig{
+		Raw:         time.Hour * 24,
+		OneMinute:   time.Hour * 24 * 7,
+		FiveMinutes: time.Hour * 24 * 30,
+		OneHour:     time.Hour * 24 * 365,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(ds.CloseConnections)
+
+	return ds
+}
+
+func benchPods(generation int) *models.WorkloadCollection {
+	collection := models.NewCollection[string, models.Workload]()
+	for i := 0; i < bench_pods; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i%100)
+		name := fmt.Sprintf("pod-%d", i)
+		collection.Set(fmt.Sprintf("%s_%s", namespace, name), models.PodWorkload{
+			GeneralWorkloadInfo: models.GeneralWorkloadInfo{
+				WorkloadName: name,
+				Namespace:    namespace,
+				Labels:       map[string]string{"app": name},
+				Annotations:  map[string]string{},
+				Selector:     map[string]string{},
+				Containers: []models.Container{
+					{ContainerName: "app", Image: "nginx", ImageVersion: "1.23"},
+				},
+				CreationTimestamp: time.Unix(1674381600, 0),
+			},
+			Status: "Running",
+			// every iteration changes a part of the pods
+			Restarts: (i + generation) / 10 % 2,
+		}, true)
+	}
+
+	return collection
+}
+
+func benchMetrics(t time.Time) *models.MetricCollection {
+	collection := models.NewCollection[string, models.PodContainerMetric]()
+	for i := 0; i < bench_pods; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i%100)
+		name := fmt.Sprintf("pod-%d", i)
+		collection.Set(fmt.Sprintf("%s_%s_app", namespace, name), models.PodContainerMetric{
+			PodName:           name,
+			Namespace:         namespace,
+			ContainerName:     "app",
+			CPUUsage:          int64(i % 1000),
+			MemoryUsage:       int64(i * 1024),
+			CreationTimestamp: t,
+		}, true)
+	}
+
+	return collection
+}
+
+func BenchmarkReplaceWorkloads(b *testing.B) {
+	ds := newBenchDataStore(b)
+	collections := []*models.WorkloadCollection{benchPods(0), benchPods(1)}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ds.ReplaceWorkloads(collections[i%2]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	workloads, err := ds.GetAllWorkloads()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if workloads.Len() != bench_pods {
+		b.Fatalf("expected %d workloads, got %d", bench_pods, workloads.Len())
+	}
+}
+
+func BenchmarkUpdateMetrics(b *testing.B) {
+	ds := newBenchDataStore(b)
+	now := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		collection := benchMetrics(now.Add(time.Second * 10 * time.Duration(i)))
+		b.StartTimer()
+
+		if err := ds.UpdateMetrics(collection); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// newTestDataStore returns a data store backed by a temporary sqlite database
+func newTestDataStore(t *testing.T) *DataStore {
+	ds, err := NewSQLiteDataStore(filepath.Join(t.TempDir(), "test.sqlite"), config.MetricsRetentionConfig{
+		Raw:         time.Hour * 24,
+		OneMinute:   time.Hour * 24 * 7,
+		FiveMinutes: time.Hour * 24 * 30,
+		OneHour:     time.Hour * 24 * 365,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	return ds
+}
+
+// testPods returns a collection of running pods in the default namespace
+func testPods(pods ...models.PodWorkload) *models.WorkloadCollection {
+	collection := models.NewCollection[string, models.Workload]()
+	for _, pod := range pods {
+		collection.Set(pod.Namespace+"_"+pod.WorkloadName, pod, true)
+	}
+
+	return collection
+}
+
+// testPod returns a running pod with a single container
+func testPod(name string, image string) models.PodWorkload {
+	return models.PodWorkload{
+		GeneralWorkloadInfo: models.GeneralWorkloadInfo{
+			WorkloadName:      name,
+			Namespace:         "default",
+			Labels:            map[string]string{"app": name},
+			Annotations:       map[string]string{},
+			Selector:          map[string]string{},
+			Containers:        []models.Container{{ContainerName: "app", Image: image, ImageVersion: "1.23"}},
+			CreationTimestamp: time.Unix(1674381600, 0),
+		},
+		Status: "Running",
+	}
+}
+
+func TestReplaceTableGeneration(t *testing.T) {
+	ds := newTestDataStore(t)
+	generations := func() map[string]int64 {
+		rows, err := ds.db.Query("SELECT workload_name, generation FROM workloads")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		result := make(map[string]int64)
+		for rows.Next() {
+			var name string
+			var generation int64
+			require.NoError(t, rows.Scan(&name, &generation))
+			result[name] = generation
+		}
+		return result
+	}
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), testPod("db", "postgres"))))
+	assert.Equal(t, map[string]int64{"web": 1, "db": 1}, generations())
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), testPod("cache", "redis"))))
+	assert.Equal(t, map[string]int64{"web": 2, "cache": 2}, generations(), "rows of older generations are removed")
+
+	// a failed replace keeps the previous rows, the stale rows are only removed within the transaction
+	failed := errors.New("failed")
+	err := ds.replaceTable("workloads", "REPLACE INTO workloads (key, workload_name, workload_type, namespace, labels, annotations, selector, containers, status, restarts, owner_ressources, creation_timestamp, generation) VALUES ",
+		"(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", 13,
+		[]any{"default_api", "api", models.WORKLOAD_TYPE_POD, "default", "{}", "{}", "{}", "[]", `"Running"`, 0, "[]", 0, nil},
+		func(tx *sql.Tx) error {
+			var rows int
+			require.NoError(t, tx.QueryRow("SELECT COUNT(*) FROM workloads").Scan(&rows))
+			assert.Equal(t, 1, rows, "the stale rows are removed before afterReplace")
+			return failed
+		})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, map[string]int64{"web": 2, "cache": 2}, generations())
+
+	// the rows are written in batches below the variable limit of sqlite
+	many := make([]models.PodWorkload, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		many = append(many, testPod(fmt.Sprintf("pod-%d", i), "nginx"))
+	}
+	require.NoError(t, ds.ReplaceWorkloads(testPods(many...)))
+	current := generations()
+	assert.Len(t, current, 5000)
+	assert.Equal(t, int64(3), current["pod-0"])
+}
+
+func TestStreamWorkloads(t *testing.T) {
+	ds := newTestDataStore(t)
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("a", "nginx"), testPod("b", "nginx"), testPod("c", "nginx"))))
+
+	opts := models.ListOptions{Limit: 2}
+	stream, err := ds.StreamWorkloads(map[string]string{}, models.LabelSelector{}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stream.Metadata.Total)
+	assert.Equal(t, models.EncodeContinue(2), stream.Metadata.Continue)
+
+	names := make([]string, 0)
+	require.NoError(t, stream.Each(func(workload models.Workload) error {
+		names = append(names, workload.GetWorkloadName())
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	// the rows are not read any further after an error, e.g. of a disconnected client
+	failed := errors.New("client disconnected")
+	read := 0
+	assert.ErrorIs(t, stream.Each(func(workload models.Workload) error {
+		read++
+		return failed
+	}), failed)
+	assert.Equal(t, 1, read)
+
+	page, err := ds.ListWorkloads(map[string]string{}, models.LabelSelector{}, opts)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, stream.Metadata, page.Metadata)
+}
+
+func TestScopedWorkloadReaders(t *testing.T) {
+	ds := newTestDataStore(t)
+
+	shop := testPod("web-shop", "nginx")
+	shop.Namespace = "shop"
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), shop)))
+
+	scoped := ds.WithScope(models.NewNamespaceScope("shop"))
+
+	workloads, err := scoped.GetAllWorkloads()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop_web-shop"}, workloads.GetKeys())
+
+	workloads, err = scoped.GetAllByWorkloadType(models.WORKLOAD_TYPE_POD)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop_web-shop"}, workloads.GetKeys())
+
+	_, err = scoped.GetWorkloadsByNamespace("default")
+	assert.ErrorIs(t, err, models.ErrForbidden)
+
+	workloads, err = ds.GetAllWorkloads()
+	require.NoError(t, err)
+	assert.Len(t, workloads.GetKeys(), 2, "the data store itself is not restricted")
+}
@@ -0,0 +1,236 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	Lists are sorted and paginated within sqlite.
+	The sortable attributes are addressed by their json path in the api representation and mapped to columns.
+	Keys of json columns (e.g. labels.app) are sorted by with json_extract.
+**/
+
+// ErrUnsupportedSortField is returned when a list is sorted by an attribute which is not sortable
+var ErrUnsupportedSortField = fmt.Errorf("%w: unsupported sort field", models.ErrInvalidFilter)
+
+// sortColumns - the sortable attributes of a table
+type sortColumns struct {
+	columns     map[string]string // json path to column expression
+	jsonColumns map[string]string // json path prefix to json column, the rest of the path is the key
+	defaultSort string
+}
+
+var node_sort_columns = sortColumns{
+	columns: map[string]string{
+		"name":            "name",
+		"status":          "status",
+		"cpu":             "cpu",
+		"memory":          "memory",
+		"os_image":        "os_image",
+		"kubelet_version": "kubelet_version",
+		"roles":           "roles",
+		"creation_date":   "creation_timestamp",
+	},
+	jsonColumns: map[string]string{"labels": "labels", "annotations": "annotations"},
+	defaultSort: "name",
+}
+
+var namespace_sort_columns = sortColumns{
+	columns: map[string]string{
+		"name":          "name",
+		"status":        "status",
+		"creation_date": "creation_timestamp",
+	},
+	jsonColumns: map[string]string{"labels": "labels", "annotations": "annotations"},
+	defaultSort: "name",
+}
+
+var workload_sort_columns = sortColumns{
+	columns: map[string]string{
+		"workload_info.workload_name": "workload_name",
+		"workload_info.namespace":     "namespace",
+		"workload_info.creation_date": "creation_timestamp",
+		"type":                        "workload_type",
+		"restarts":                    "restarts",
+		"node_name":                   "node_name",
+		// the status of pods is stored as json string
+		"status": "json_extract(status, '$')",
+	},
+	jsonColumns: map[string]string{
+		"workload_info.labels":      "labels",
+		"workload_info.annotations": "annotations",
+		"status":                    "status",
+	},
+	defaultSort: "workload_name",
+}
+
+// orderClause returns the ORDER BY clause of the sort fields, the key is used as last order to get a stable pagination
+func (s sortColumns) orderClause(fields []models.SortField) (string, []any, error) {
+	orders := make([]string, 0, len(fields)+2)
+	values := make([]any, 0)
+	for _, field := range fields {
+		column, value, err := s.column(field.Field)
+		if err != nil {
+			return "", nil, err
+		}
+
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
+		}
+
+		orders = append(orders, fmt.Sprintf("%s %s", column, direction))
+		if value != nil {
+			values = append(values, value)
+		}
+	}
+
+	orders = append(orders, fmt.Sprintf("%s ASC", s.defaultSort), "key ASC")
+
+	return fmt.Sprintf(" ORDER BY %s", strings.Join(orders, ", ")), values, nil
+}
+
+// column returns the expression of the attribute and the json path to bind if it is a key of a json column
+func (s sortColumns) column(field string) (string, any, error) {
+	if column, ok := s.columns[field]; ok {
+		return column, nil, nil
+	}
+
+	for prefix, column := range s.jsonColumns {
+		if key := strings.TrimPrefix(field, prefix+"."); key != field && key != "" {
+			return fmt.Sprintf("json_extract(%s, ?)", column), fmt.Sprintf("$.\"%s\"", strings.ReplaceAll(key, "\"", "\\\"")), nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w %s", ErrUnsupportedSortField, field)
+}
+
+// limitClause returns the LIMIT clause of the requested page
+func limitClause(opts models.ListOptions) (string, []any) {
+	if opts.Limit <= 0 && opts.Offset <= 0 {
+		return "", nil
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return " LIMIT ? OFFSET ?", []any{limit, opts.Offset}
+}
+
+// streamRows counts the rows of the table matching the conditions, the requested page of them is queried and scanned when the stream is iterated
+func streamRows[T any](d *DataStore, table string, fields string, conditions []string, values []any, sort sortColumns, opts models.ListOptions,
+	scan func(rows *sql.Rows, add func(item T)) error) (*models.Stream[T], error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = fmt.Sprintf(" WHERE %s", strings.Join(conditions, " AND "))
+	}
+
+	var total int
+	if err := d.read.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s%s", table, where), values...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	order, orderValues, err := sort.orderClause(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	limit, limitValues := limitClause(opts)
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s%s", fields, table, where, order, limit)
+	queryValues := append(append(append([]any{}, values...), orderValues...), limitValues...)
+
+	return models.NewStream(total, opts, func(fn func(T) error) error {
+		rows, err := d.read.Query(query, queryValues...)
+		if err != nil {
+			zap.L().Error("could not list rows", zap.String("table", table), zap.Error(err))
+			return err
+		}
+		defer rows.Close()
+
+		// closing the rows ends the scan after the first error of fn
+		var fnErr error
+		err = scan(rows, func(item T) {
+			if fnErr = fn(item); fnErr != nil {
+				_ = rows.Close()
+			}
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+
+		return err
+	}), nil
+}
+
+// ListNodes returns the requested page of the nodes matching the label selector.
+func (d *DataStore) ListNodes(selector models.LabelSelector, opts models.ListOptions) (*models.Page[models.Node], error) {
+	stream, err := d.StreamNodes(selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Page()
+}
+
+// StreamNodes returns the requested page of the nodes matching the label selector, the nodes are read while the stream is iterated.
+func (d *DataStore) StreamNodes(selector models.LabelSelector, opts models.ListOptions) (*models.Stream[models.Node], error) {
+	conditions, values, err := labelSelectorConditions(selector, jsonLabelSource("nodes"))
+	if err != nil {
+		return nil, err
+	}
+
+	return streamRows(d, "nodes", nodes_sql_fields, conditions, values, node_sort_columns, opts, func(rows *sql.Rows, add func(models.Node)) error {
+		return scanNodes(rows, func(key string, node models.Node) { add(node) })
+	})
+}
+
+// ListNamespaces returns the requested page of the namespaces matching the label selector.
+func (d *DataStore) ListNamespaces(selector models.LabelSelector, opts models.ListOptions) (*models.Page[models.Namespace], error) {
+	stream, err := d.StreamNamespaces(selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Page()
+}
+
+// StreamNamespaces returns the requested page of the namespaces matching the label selector, the namespaces are read while the stream is iterated.
+func (d *DataStore) StreamNamespaces(selector models.LabelSelector, opts models.ListOptions) (*models.Stream[models.Namespace], error) {
+	conditions, values, err := labelSelectorConditions(selector, jsonLabelSource("namespaces"))
+	if err != nil {
+		return nil, err
+	}
+	conditions, values = d.appendScopeCondition(conditions, values, "name")
+
+	return streamRows(d, "namespaces", namespaces_sql_fields, conditions, values, namespace_sort_columns, opts, func(rows *sql.Rows, add func(models.Namespace)) error {
+		return scanNamespaces(rows, func(key string, namespace models.Namespace) { add(namespace) })
+	})
+}
+
+// ListWorkloads returns the requested page of the workloads matching the filters and the label selector.
+func (d *DataStore) ListWorkloads(filters map[string]string, selector models.LabelSelector, opts models.ListOptions) (*models.Page[models.Workload], error) {
+	stream, err := d.StreamWorkloads(filters, selector, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Page()
+}
+
+// StreamWorkloads returns the requested page of the workloads matching the filters and the label selector, the workloads are read while the stream is iterated.
+func (d *DataStore) StreamWorkloads(filters map[string]string, selector models.LabelSelector, opts models.ListOptions) (*models.Stream[models.Workload], error) {
+	conditions, values, err := d.workloadConditions(filters, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return streamRows(d, "workloads", workloads_sql_fields, conditions, values, workload_sort_columns, opts, func(rows *sql.Rows, add func(models.Workload)) error {
+		return scanWorkloads(rows, func(key string, workload models.Workload) { add(workload) })
+	})
+}
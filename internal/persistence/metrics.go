@@ -0,0 +1,396 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	Raw container metrics are rolled up into 1m, 5m and 1h tiers.
+	Every tier has its own retention, queries are answered from the finest tier which covers the requested time range.
+**/
+
+// raw_metrics_resolution is the collection interval of the controller
+const raw_metrics_resolution = time.Second * 10
+
+// rollup_delay is the time a bucket stays open after its end, the samples of the metrics api lag behind the collection.
+// Samples arriving after the bucket was rolled up are kept in the raw tier but are not added to the rolled up bucket.
+const rollup_delay = time.Minute * 2
+
+// MAX_METRIC_POINTS limits the number of samples per container returned for a time range
+const MAX_METRIC_POINTS = 2500
+
+type metricsTier struct {
+	name         string
+	table        string
+	resolution   time.Duration
+	retention    time.Duration
+	cpuColumn    string
+	memoryColumn string
+	cpuAvg       string
+	memoryAvg    string
+	cpuP95       string
+	memoryP95    string
+}
+
+func (d *DataStore) rawMetricsTier() metricsTier {
+	return metricsTier{
+		name:         "raw",
+		table:        "container_metrics",
+		resolution:   raw_metrics_resolution,
+		retention:    d.metricsRetention.Raw,
+		cpuColumn:    "cpu_usage",
+		memoryColumn: "memory_usage",
+		cpuAvg:       "cpu_usage",
+		memoryAvg:    "memory_usage",
+		cpuP95:       "cpu_usage",
+		memoryP95:    "memory_usage",
+	}
+}
+
+func (d *DataStore) rollupMetricsTiers() []metricsTier {
+	return []metricsTier{
+		{name: "1m", table: "container_metrics_1m", resolution: time.Minute, retention: d.metricsRetention.OneMinute, cpuColumn: "cpu_max", memoryColumn: "memory_max", cpuAvg: "cpu_avg", memoryAvg: "memory_avg", cpuP95: "cpu_p95", memoryP95: "memory_p95"},
+		{name: "5m", table: "container_metrics_5m", resolution: time.Minute * 5, retention: d.metricsRetention.FiveMinutes, cpuColumn: "cpu_max", memoryColumn: "memory_max", cpuAvg: "cpu_avg", memoryAvg: "memory_avg", cpuP95: "cpu_p95", memoryP95: "memory_p95"},
+		{name: "1h", table: "container_metrics_1h", resolution: time.Hour, retention: d.metricsRetention.OneHour, cpuColumn: "cpu_max", memoryColumn: "memory_max", cpuAvg: "cpu_avg", memoryAvg: "memory_avg", cpuP95: "cpu_p95", memoryP95: "memory_p95"},
+	}
+}
+
+// selectMetricsTier returns the finest tier which still contains samples for from and doesn't exceed MAX_METRIC_POINTS
+func (d *DataStore) selectMetricsTier(from time.Time, to time.Time, now time.Time) metricsTier {
+	tiers := append([]metricsTier{d.rawMetricsTier()}, d.rollupMetricsTiers()...)
+	for _, tier := range tiers {
+		if from.Before(now.Add(-tier.retention)) {
+			continue
+		}
+
+		if int(to.Sub(from)/tier.resolution) > MAX_METRIC_POINTS {
+			continue
+		}
+
+		return tier
+	}
+
+	return tiers[len(tiers)-1]
+}
+
+// MetricsQuery - filter for loading container metrics
+type MetricsQuery struct {
+	From      time.Time
+	To        time.Time
+	Namespace string
+	Pods      []string
+	Container string
+}
+
+// where returns the conditions and values of the query
+func (q MetricsQuery) where() (string, []any) {
+	var sb strings.Builder
+	sb.WriteString("creation_timestamp >= ? AND creation_timestamp <= ?")
+	whereValues := []any{q.From.Unix(), q.To.Unix()}
+
+	if q.Namespace != "" {
+		sb.WriteString(" AND namespace = ?")
+		whereValues = append(whereValues, q.Namespace)
+	}
+
+	if len(q.Pods) > 0 {
+		sb.WriteString(fmt.Sprintf(" AND pod_name IN (%s)", strings.TrimSuffix(strings.Repeat("?, ", len(q.Pods)), ", ")))
+		for _, pod := range q.Pods {
+			whereValues = append(whereValues, pod)
+		}
+	}
+
+	if q.Container != "" {
+		sb.WriteString(" AND container_name = ?")
+		whereValues = append(whereValues, q.Container)
+	}
+
+	return sb.String(), whereValues
+}
+
+// metricsWhere returns the conditions and values of the query restricted to the scope
+func (d *DataStore) metricsWhere(query MetricsQuery) (string, []any, error) {
+	if query.Namespace != "" {
+		if err := d.scope.Check(query.Namespace); err != nil {
+			return "", nil, err
+		}
+	}
+
+	where, whereValues := query.where()
+	if condition, values := d.scopeCondition("namespace"); condition != "" {
+		where = fmt.Sprintf("%s AND %s", where, condition)
+		whereValues = append(whereValues, values...)
+	}
+
+	return where, whereValues, nil
+}
+
+// GetMetrics returns the container metrics matching the query from the tier matching the time range.
+func (d *DataStore) GetMetrics(query MetricsQuery) (*models.MetricCollection, error) {
+	tier := d.selectMetricsTier(query.From, query.To, time.Now())
+
+	where, whereValues, err := d.metricsWhere(query)
+	if err != nil {
+		return nil, err
+	}
+	sqlStmt := fmt.Sprintf("SELECT key, pod_name, container_name, namespace, %s, %s, creation_timestamp FROM %s WHERE %s",
+		tier.cpuColumn, tier.memoryColumn, tier.table, where)
+
+	stmt, err := d.read.Prepare(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(whereValues...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	collection := models.NewCollection[string, models.PodContainerMetric]()
+	for rows.Next() {
+		var key string
+		var podName string
+		var containerName string
+		var namespace string
+		var cpuUsage int64
+		var memoryUsage int64
+		var creationTimestamp int64
+
+		if err := rows.Scan(&key, &podName, &containerName, &namespace, &cpuUsage, &memoryUsage, &creationTimestamp); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		collection.Set(fmt.Sprintf("%s_%d", key, creationTimestamp), models.PodContainerMetric{
+			PodName:           podName,
+			Namespace:         namespace,
+			ContainerName:     containerName,
+			CPUUsage:          cpuUsage,
+			MemoryUsage:       memoryUsage,
+			CreationTimestamp: time.Unix(creationTimestamp, 0),
+		}, true)
+	}
+
+	return collection, nil
+}
+
+// GetMetricsForPodsInNamespace returns the container metrics of the pods within the time range.
+func (d *DataStore) GetMetricsForPodsInNamespace(namespace string, workloads []models.Workload, from time.Time, to time.Time) (*models.MetricCollection, error) {
+	podNames := make([]string, len(workloads))
+	for i, workload := range workloads {
+		podNames[i] = workload.GetWorkloadName()
+		if workload.GetType() != models.WORKLOAD_TYPE_POD {
+			return nil, fmt.Errorf("%w: the workload needs to be a pod. workload_type: %s", models.ErrInvalidFilter, workload.GetType())
+		}
+	}
+
+	if len(podNames) == 0 {
+		return nil, fmt.Errorf("%w: no pods given", models.ErrInvalidFilter)
+	}
+
+	return d.GetMetrics(MetricsQuery{
+		From:      from,
+		To:        to,
+		Namespace: namespace,
+		Pods:      podNames,
+	})
+}
+
+// GetMetricBuckets returns the average usage of every container per step between from and to of the query.
+func (d *DataStore) GetMetricBuckets(query MetricsQuery, step time.Duration) ([]models.MetricBucket, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("%w: step needs to be positive", models.ErrInvalidFilter)
+	}
+
+	tier := d.selectMetricsTier(query.From, query.To, time.Now())
+	seconds := int64(step / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	where, whereValues, err := d.metricsWhere(query)
+	if err != nil {
+		return nil, err
+	}
+	sqlStmt := fmt.Sprintf("SELECT pod_name, container_name, (creation_timestamp - ?) / ? AS step, CAST(AVG(%s) AS INTEGER), CAST(AVG(%s) AS INTEGER) "+
+		"FROM %s WHERE %s GROUP BY pod_name, container_name, step ORDER BY step",
+		tier.cpuAvg, tier.memoryAvg, tier.table, where)
+
+	stmt, err := d.read.Prepare(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(append([]any{query.From.Unix(), seconds}, whereValues...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	buckets := make([]models.MetricBucket, 0)
+	for rows.Next() {
+		var bucket models.MetricBucket
+		if err := rows.Scan(&bucket.PodName, &bucket.ContainerName, &bucket.Step, &bucket.CPUUsage, &bucket.MemoryUsage); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// RollupMetrics aggregates the raw samples of the buckets completed before rollup_delay into the rollup tiers and removes expired samples.
+func (d *DataStore) RollupMetrics(now time.Time) error {
+	for _, tier := range d.rollupMetricsTiers() {
+		if err := d.rollupTier(tier, now); err != nil {
+			zap.L().Error("could not rollup metrics", zap.String("tier", tier.name), zap.Error(err))
+			return err
+		}
+	}
+
+	return d.removeExpiredMetrics(now)
+}
+
+func (d *DataStore) rollupTier(tier metricsTier, now time.Time) error {
+	to := now.Add(-rollup_delay).Truncate(tier.resolution)
+
+	var lastTimestamp sql.NullInt64
+	err := d.db.QueryRow("SELECT last_timestamp FROM metric_rollups WHERE tier = ?", tier.name).Scan(&lastTimestamp)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// start with the oldest raw sample on the first rollup
+	if !lastTimestamp.Valid {
+		if err := d.db.QueryRow("SELECT MIN(creation_timestamp) FROM container_metrics").Scan(&lastTimestamp); err != nil {
+			return err
+		}
+
+		if !lastTimestamp.Valid {
+			return nil
+		}
+		lastTimestamp.Int64 = time.Unix(lastTimestamp.Int64, 0).Truncate(tier.resolution).Unix()
+	}
+
+	from := time.Unix(lastTimestamp.Int64, 0)
+	if !from.Before(to) {
+		return nil
+	}
+
+	// load the raw samples in chunks to limit the memory usage
+	chunk := time.Hour
+	if tier.resolution > chunk {
+		chunk = tier.resolution
+	}
+
+	for start := from; start.Before(to); start = start.Add(chunk) {
+		end := start.Add(chunk)
+		if end.After(to) {
+			end = to
+		}
+
+		if err := d.rollupRange(tier, start, end); err != nil {
+			return err
+		}
+	}
+
+	_, err = d.db.Exec("REPLACE INTO metric_rollups (tier, last_timestamp) VALUES (?, ?)", tier.name, to.Unix())
+	return err
+}
+
+type metricsBucket struct {
+	key           string
+	podName       string
+	namespace     string
+	containerName string
+	timestamp     int64
+	cpu           []int64
+	memory        []int64
+}
+
+func (d *DataStore) rollupRange(tier metricsTier, from time.Time, to time.Time) error {
+	rows, err := d.read.Query("SELECT key, pod_name, namespace, container_name, cpu_usage, memory_usage, creation_timestamp FROM container_metrics WHERE creation_timestamp >= ? AND creation_timestamp < ?", from.Unix(), to.Unix())
+	if err != nil {
+		return err
+	}
+
+	buckets := make(map[string]*metricsBucket)
+	for rows.Next() {
+		var key string
+		var podName string
+		var namespace string
+		var containerName string
+		var cpuUsage int64
+		var memoryUsage int64
+		var creationTimestamp int64
+
+		if err := rows.Scan(&key, &podName, &namespace, &containerName, &cpuUsage, &memoryUsage, &creationTimestamp); err != nil {
+			rows.Close()
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return err
+		}
+
+		timestamp := time.Unix(creationTimestamp, 0).Truncate(tier.resolution).Unix()
+		bucketKey := fmt.Sprintf("%s_%d", key, timestamp)
+		bucket, ok := buckets[bucketKey]
+		if !ok {
+			bucket = &metricsBucket{key: key, podName: podName, namespace: namespace, containerName: containerName, timestamp: timestamp}
+			buckets[bucketKey] = bucket
+		}
+
+		bucket.cpu = append(bucket.cpu, cpuUsage)
+		bucket.memory = append(bucket.memory, memoryUsage)
+	}
+	rows.Close()
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("REPLACE INTO %s (key, pod_name, namespace, container_name, samples, cpu_min, cpu_avg, cpu_max, cpu_p95, memory_min, memory_avg, memory_max, memory_p95, creation_timestamp) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", tier.table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, bucket := range buckets {
+		cpu := models.CalculateStatistics(bucket.cpu)
+		memory := models.CalculateStatistics(bucket.memory)
+		if _, err := stmt.Exec(bucket.key, bucket.podName, bucket.namespace, bucket.containerName, cpu.Samples,
+			cpu.Min, cpu.Avg, cpu.Max, cpu.P95, memory.Min, memory.Avg, memory.Max, memory.P95, bucket.timestamp); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// removeExpiredMetrics removes the samples of every tier which are older than the configured retention
+func (d *DataStore) removeExpiredMetrics(now time.Time) error {
+	tiers := append([]metricsTier{d.rawMetricsTier()}, d.rollupMetricsTiers()...)
+	for _, tier := range tiers {
+		query := fmt.Sprintf("DELETE FROM %s WHERE creation_timestamp < ?", tier.table)
+		if _, err := d.db.Exec(query, now.Add(-tier.retention).Unix()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
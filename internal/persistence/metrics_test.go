@@ -0,0 +1,100 @@
+package persistence
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+type rollupRow struct {
+	timestamp              int64
+	samples                int
+	cpuMin, cpuAvg, cpuMax int64
+}
+
+func rollupRows(t *testing.T, ds *DataStore, table string) []rollupRow {
+	rows, err := ds.db.Query(fmt.Sprintf("SELECT creation_timestamp, samples, cpu_min, cpu_avg, cpu_max FROM %s ORDER BY creation_timestamp", table))
+	require.NoError(t, err)
+	defer rows.Close()
+
+	result := make([]rollupRow, 0)
+	for rows.Next() {
+		var row rollupRow
+		require.NoError(t, rows.Scan(&row.timestamp, &row.samples, &row.cpuMin, &row.cpuAvg, &row.cpuMax))
+		result = append(result, row)
+	}
+
+	return result
+}
+
+// storeSamples stores a sample of the web container every 10 seconds between from and to, the cpu is the index of the sample
+func storeSamples(t *testing.T, ds *DataStore, from time.Time, to time.Time) {
+	for i, at := 0, from; at.Before(to); i, at = i+1, at.Add(raw_metrics_resolution) {
+		metrics := models.NewCollection[string, models.PodContainerMetric]()
+		metrics.Set("default_web_app", models.PodContainerMetric{
+			PodName:           "web",
+			Namespace:         "default",
+			ContainerName:     "app",
+			CPUUsage:          int64(i),
+			MemoryUsage:       1024,
+			CreationTimestamp: at,
+		}, true)
+		require.NoError(t, ds.UpdateMetrics(metrics))
+	}
+}
+
+func TestRollupMetrics(t *testing.T) {
+	ds := newTestDataStore(t)
+	start := time.Now().Truncate(time.Hour).Add(-time.Hour * 2)
+	storeSamples(t, ds, start, start.Add(time.Minute*10))
+
+	require.NoError(t, ds.RollupMetrics(start.Add(time.Minute*10)))
+	assert.Len(t, rollupRows(t, ds, "container_metrics_1m"), 8, "the buckets within the rollup delay are not completed")
+
+	require.NoError(t, ds.RollupMetrics(start.Add(time.Minute*10+rollup_delay)))
+
+	minutes := rollupRows(t, ds, "container_metrics_1m")
+	require.Len(t, minutes, 10)
+	assert.Equal(t, rollupRow{timestamp: start.Unix(), samples: 6, cpuMin: 0, cpuAvg: 2, cpuMax: 5}, minutes[0])
+	assert.Equal(t, rollupRow{timestamp: start.Add(time.Minute * 9).Unix(), samples: 6, cpuMin: 54, cpuAvg: 56, cpuMax: 59}, minutes[9])
+
+	fiveMinutes := rollupRows(t, ds, "container_metrics_5m")
+	require.Len(t, fiveMinutes, 2)
+	assert.Equal(t, rollupRow{timestamp: start.Add(time.Minute * 5).Unix(), samples: 30, cpuMin: 30, cpuAvg: 44, cpuMax: 59}, fiveMinutes[1])
+
+	assert.Empty(t, rollupRows(t, ds, "container_metrics_1h"), "the hour is not completed")
+
+	// the next rollup continues after the last completed bucket, later samples of completed buckets are not added
+	storeSamples(t, ds, start.Add(time.Minute*9+time.Second*5), start.Add(time.Minute*9+time.Second*6))
+	storeSamples(t, ds, start.Add(time.Minute*10), start.Add(time.Minute*11))
+	require.NoError(t, ds.RollupMetrics(start.Add(time.Minute*11+rollup_delay)))
+	minutes = rollupRows(t, ds, "container_metrics_1m")
+	require.Len(t, minutes, 11)
+	assert.Equal(t, 6, minutes[9].samples)
+	assert.Equal(t, 6, minutes[10].samples)
+}
+
+func TestSelectMetricsTier(t *testing.T) {
+	ds := newTestDataStore(t)
+	now := time.Now()
+
+	tests := []struct {
+		from time.Duration
+		tier string
+	}{
+		{from: time.Hour, tier: "raw"},
+		{from: time.Hour * 8, tier: "1m"},        // more than MAX_METRIC_POINTS raw samples
+		{from: time.Hour * 48, tier: "5m"},       // beyond the retention of the raw samples
+		{from: time.Hour * 24 * 10, tier: "1h"},  // beyond the retention of the 1m samples, too many 5m samples
+		{from: time.Hour * 24 * 400, tier: "1h"}, // beyond every retention
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.tier, ds.selectMetricsTier(now.Add(-test.from), now, now).name, test.from.String())
+	}
+}
@@ -0,0 +1,172 @@
+package persistence
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	The recommendations are calculated for the deployments, statefulsets, daemonsets and the pods without owner.
+	The samples are loaded from the tier matching the time range, so pods replaced by a rollout are part of the usage.
+	Pods which no longer exist are accounted to their workload by the names kubernetes generates for them.
+**/
+
+// GetRecommendations returns the right-sizing recommendations of the workloads in the namespace (all namespaces when empty).
+// The workloads are filtered by the type when set, headroom is in percent.
+func (d *DataStore) GetRecommendations(namespace string, workloadType string, from time.Time, to time.Time, headroom float64) ([]models.WorkloadRecommendation, error) {
+	filters := make(map[string]string)
+	if namespace != "" {
+		filters["namespace"] = namespace
+	}
+
+	// the pods are needed to account the samples to their workloads
+	collection, err := d.GetWorkloadsBy(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	samples, err := d.getContainerSamples(MetricsQuery{From: from, To: to, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := workloadsByNamespace(collection)
+	pods := make(map[string]models.PodWorkload)
+	for _, w := range collection.GetAll() {
+		if pod, ok := w.(models.PodWorkload); ok {
+			pods[pod.Namespace+"/"+pod.WorkloadName] = pod
+		}
+	}
+
+	usage := make(map[string]map[string]*models.ContainerSamples)
+	for podKey, containers := range samples {
+		podNamespace, podName, _ := strings.Cut(podKey, "/")
+
+		var ownerType, ownerName string
+		if pod, ok := pods[podKey]; ok {
+			ownerType, ownerName = ownerWorkload(pod, workloads[podNamespace])
+		} else if owner := generatedPodOwner(podName, workloads[podNamespace]); owner != nil {
+			ownerType, ownerName = owner.GetType(), owner.GetWorkloadName()
+		} else {
+			continue
+		}
+
+		key := recommendationKey(ownerType, podNamespace, ownerName)
+		if usage[key] == nil {
+			usage[key] = make(map[string]*models.ContainerSamples)
+		}
+		for containerName, s := range containers {
+			merged, ok := usage[key][containerName]
+			if !ok {
+				merged = &models.ContainerSamples{}
+				usage[key][containerName] = merged
+			}
+			merged.Merge(s)
+		}
+	}
+
+	result := make([]models.WorkloadRecommendation, 0)
+	for _, w := range collection.GetAll() {
+		switch w.GetType() {
+		case models.WORKLOAD_TYPE_DEPLOYMENT, models.WORKLOAD_TYPE_STATEFULSET, models.WORKLOAD_TYPE_DEAMONSET:
+		case models.WORKLOAD_TYPE_POD:
+			// pods with an owner are part of the recommendation of their workload
+			if ownerType, _ := ownerWorkload(w.(models.PodWorkload), workloads[w.GetNamespace()]); ownerType != models.WORKLOAD_TYPE_POD {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if workloadType != "" && w.GetType() != workloadType {
+			continue
+		}
+
+		result = append(result, models.RecommendWorkload(w, usage[recommendationKey(w.GetType(), w.GetNamespace(), w.GetWorkloadName())], headroom))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return recommendationKey(result[i].WorkloadType, result[i].Namespace, result[i].WorkloadName) <
+			recommendationKey(result[j].WorkloadType, result[j].Namespace, result[j].WorkloadName)
+	})
+
+	return result, nil
+}
+
+func recommendationKey(workloadType string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, workloadType, name)
+}
+
+// getContainerSamples returns the samples of the containers by namespace/pod and container name
+func (d *DataStore) getContainerSamples(query MetricsQuery) (map[string]map[string]*models.ContainerSamples, error) {
+	tier := d.selectMetricsTier(query.From, query.To, time.Now())
+
+	where, whereValues, err := d.metricsWhere(query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.read.Query(fmt.Sprintf("SELECT namespace, pod_name, container_name, %s, %s, %s, %s FROM %s WHERE %s",
+		tier.cpuP95, tier.memoryP95, tier.cpuColumn, tier.memoryColumn, tier.table, where), whereValues...)
+	if err != nil {
+		zap.L().Error("could not load container samples", zap.String("tier", tier.name), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make(map[string]map[string]*models.ContainerSamples)
+	for rows.Next() {
+		var namespace, podName, containerName string
+		var cpu, memory, cpuPeak, memoryPeak int64
+		if err := rows.Scan(&namespace, &podName, &containerName, &cpu, &memory, &cpuPeak, &memoryPeak); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		podKey := namespace + "/" + podName
+		if samples[podKey] == nil {
+			samples[podKey] = make(map[string]*models.ContainerSamples)
+		}
+		s, ok := samples[podKey][containerName]
+		if !ok {
+			s = &models.ContainerSamples{}
+			samples[podKey][containerName] = s
+		}
+		s.Add(cpu, memory, cpuPeak, memoryPeak)
+	}
+
+	return samples, rows.Err()
+}
+
+// generatedPodOwner returns the workload which generated the name of the pod, the workload with the longest name wins.
+// Deployments name their pods <name>-<hash>-<suffix>, statefulsets <name>-<ordinal> and daemonsets <name>-<suffix>.
+func generatedPodOwner(podName string, workloads []models.Workload) models.Workload {
+	var owner models.Workload
+	for _, w := range workloads {
+		suffix := strings.TrimPrefix(podName, w.GetWorkloadName()+"-")
+		if suffix == podName || suffix == "" {
+			continue
+		}
+
+		var generated bool
+		switch w.GetType() {
+		case models.WORKLOAD_TYPE_DEPLOYMENT:
+			generated = strings.Count(suffix, "-") == 1
+		case models.WORKLOAD_TYPE_STATEFULSET:
+			generated = strings.Trim(suffix, "0123456789") == ""
+		case models.WORKLOAD_TYPE_DEAMONSET:
+			generated = !strings.Contains(suffix, "-")
+		}
+
+		if generated && (owner == nil || len(w.GetWorkloadName()) > len(owner.GetWorkloadName())) {
+			owner = w
+		}
+	}
+
+	return owner
+}
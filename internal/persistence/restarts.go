@@ -0,0 +1,247 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	The restart history records every increase of the restart count of a container between two collections.
+	The previous counts are read from the stored pods before they are replaced.
+	The first collection is the baseline, the restarts of pods seen for the first time afterwards are recorded completely.
+**/
+
+const restarts_sql_fields = "timestamp, namespace, pod_name, container_name, workload_type, workload_name, restarts, increase, reason, exit_code"
+
+var restarts_schema = []string{
+	`CREATE TABLE IF NOT EXISTS container_restarts (
+		timestamp INTEGER NOT NULL,
+		namespace TEXT NOT NULL,
+		pod_name TEXT NOT NULL,
+		container_name TEXT NOT NULL,
+		workload_type TEXT NOT NULL,
+		workload_name TEXT NOT NULL,
+		restarts INTEGER NOT NULL,
+		increase INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		exit_code INTEGER NOT NULL
+	)`,
+	"CREATE INDEX IF NOT EXISTS idx_container_restarts_timestamp ON container_restarts(timestamp)",
+	"CREATE INDEX IF NOT EXISTS idx_container_restarts_workload ON container_restarts(namespace, workload_type, workload_name)",
+}
+
+func createRestartHistory(db *sql.DB) error {
+	for _, q := range restarts_schema {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getRestartCounts returns the stored restarts by pod key and container name, nil before the first collection was stored
+func (d *DataStore) getRestartCounts() (map[string]map[string]int, error) {
+	var stored bool
+	if err := d.db.QueryRow("SELECT EXISTS(SELECT 1 FROM workloads)").Scan(&stored); err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, nil
+	}
+
+	rows, err := d.db.Query("SELECT key, containers FROM workloads WHERE workload_type = ?", models.WORKLOAD_TYPE_POD)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[string]int)
+	for rows.Next() {
+		var key string
+		var raw []byte
+		if err := rows.Scan(&key, &raw); err != nil {
+			return nil, err
+		}
+
+		var containers []models.Container
+		if err := json.Unmarshal(raw, &containers); err != nil {
+			return nil, err
+		}
+
+		counts[key] = make(map[string]int, len(containers))
+		for _, container := range containers {
+			counts[key][container.ContainerName] = container.Restarts
+		}
+	}
+
+	return counts, rows.Err()
+}
+
+// recordRestarts records the increases of the restarts of the pods in the collection compared to the previous counts
+func (d *DataStore) recordRestarts(tx *sql.Tx, previous map[string]map[string]int, collection *models.WorkloadCollection, now time.Time) error {
+	if previous == nil {
+		return nil
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO container_restarts (" + restarts_sql_fields + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// the owners are only looked up for pods with restarts
+	var workloads map[string][]models.Workload
+
+	for key, w := range collection.GetAll() {
+		pod, ok := w.(models.PodWorkload)
+		if !ok {
+			continue
+		}
+
+		for _, container := range pod.Containers {
+			// a pod which was replaced by a pod with the same name starts again at zero
+			increase := container.Restarts - previous[key][container.ContainerName]
+			if increase < 0 {
+				increase = container.Restarts
+			}
+			if increase <= 0 {
+				continue
+			}
+
+			if workloads == nil {
+				workloads = workloadsByNamespace(collection)
+			}
+			workloadType, workloadName := ownerWorkload(pod, workloads[pod.Namespace])
+
+			timestamp, reason, exitCode := now, "", int32(0)
+			if termination := container.LastTermination; termination != nil {
+				reason, exitCode = termination.Reason, termination.ExitCode
+				if !termination.FinishedAt.IsZero() && termination.FinishedAt.Before(now) {
+					timestamp = termination.FinishedAt
+				}
+			}
+
+			if _, err := stmt.Exec(timestamp.Unix(), pod.Namespace, pod.WorkloadName, container.ContainerName, workloadType, workloadName,
+				container.Restarts, increase, reason, exitCode); err != nil {
+				zap.L().Error("could not record container restart", zap.String("pod", pod.WorkloadName), zap.Error(err))
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// workloadsByNamespace returns the workloads which own pods by their namespace
+func workloadsByNamespace(collection *models.WorkloadCollection) map[string][]models.Workload {
+	result := make(map[string][]models.Workload)
+	for _, w := range collection.SortedList(models.WorkloadNameLess) {
+		if w.GetType() != models.WORKLOAD_TYPE_POD {
+			result[w.GetNamespace()] = append(result[w.GetNamespace()], w)
+		}
+	}
+
+	return result
+}
+
+// ownerWorkload returns the workload the pod is accounted to, the pod itself when no workload owns it
+func ownerWorkload(pod models.PodWorkload, workloads []models.Workload) (string, string) {
+	for _, w := range workloads {
+		if filterPodsForWorkload(w, podCollection(pod)).Len() > 0 {
+			return w.GetType(), w.GetWorkloadName()
+		}
+	}
+
+	// jobs are not collected, the pods of a job are accounted to the cronjob owning it and else to the job
+	for _, owner := range pod.PodOwnerRessources {
+		if owner.Kind != "Job" {
+			continue
+		}
+		if owner.Owner != nil && owner.Owner.Kind == "CronJob" {
+			return models.WORKLOAD_TYPE_CRONJOB, owner.Owner.Name
+		}
+		return models.WORKLOAD_TYPE_JOB, owner.Name
+	}
+
+	return models.WORKLOAD_TYPE_POD, pod.WorkloadName
+}
+
+func podCollection(pod models.PodWorkload) *models.WorkloadCollection {
+	collection := models.NewCollection[string, models.Workload]()
+	collection.Set(pod.WorkloadName, pod, true)
+	return collection
+}
+
+// ListContainerRestarts returns the recorded restarts matching the filter, newest first
+func (d *DataStore) ListContainerRestarts(filter models.RestartFilter) ([]models.ContainerRestart, error) {
+	if filter.Namespace != "" {
+		if err := d.scope.Check(filter.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	conditions, values := restartConditions(filter)
+	conditions, values = d.appendScopeCondition(conditions, values, "namespace")
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := d.read.Query("SELECT "+restarts_sql_fields+" FROM container_restarts"+where+" ORDER BY timestamp DESC, pod_name ASC, container_name ASC", values...)
+	if err != nil {
+		zap.L().Error("could not list container restarts", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	restarts := make([]models.ContainerRestart, 0)
+	for rows.Next() {
+		var restart models.ContainerRestart
+		var timestamp int64
+		if err := rows.Scan(&timestamp, &restart.Namespace, &restart.PodName, &restart.ContainerName, &restart.WorkloadType, &restart.WorkloadName,
+			&restart.Restarts, &restart.Increase, &restart.Reason, &restart.ExitCode); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		restart.Timestamp = time.Unix(timestamp, 0)
+		restarts = append(restarts, restart)
+	}
+
+	return restarts, rows.Err()
+}
+
+// restartConditions translates the filter into conditions on the restart history
+func restartConditions(filter models.RestartFilter) ([]string, []any) {
+	conditions := make([]string, 0)
+	values := make([]any, 0)
+
+	for _, equal := range []struct{ column, value string }{
+		{column: "namespace", value: filter.Namespace},
+		{column: "workload_type", value: filter.WorkloadType},
+		{column: "workload_name", value: filter.WorkloadName},
+		{column: "pod_name", value: filter.PodName},
+	} {
+		if equal.value != "" {
+			conditions = append(conditions, equal.column+" = ?")
+			values = append(values, equal.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		values = append(values, filter.From.Unix())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		values = append(values, filter.To.Unix())
+	}
+
+	return conditions, values
+}
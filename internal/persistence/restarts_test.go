@@ -0,0 +1,58 @@
+package persistence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// cronjobPod returns a pod of the job, owned by the cronjob when it is not empty
+func cronjobPod(name string, job string, cronjob string, restarts int) models.PodWorkload {
+	pod := testPod(name, "backup")
+	pod.Containers[0].Restarts = restarts
+	owner := models.PodOwnerRessource{APIVersion: "batch/v1", Kind: "Job", UID: job, Name: job}
+	if cronjob != "" {
+		owner.Owner = &models.PodOwnerRessource{APIVersion: "batch/v1", Kind: "CronJob", UID: cronjob, Name: cronjob}
+	}
+	pod.PodOwnerRessources = []models.PodOwnerRessource{owner}
+
+	return pod
+}
+
+func TestRecordRestartsOfCronjobs(t *testing.T) {
+	ds := newTestDataStore(t)
+	collect := func(restarts int) {
+		require.NoError(t, ds.ReplaceWorkloads(testPods(
+			cronjobPod("backup-28000000-abcde", "backup-28000000", "backup", restarts),
+			cronjobPod("backup-db-28000000-fghij", "backup-db-28000000", "backup-db", restarts),
+			cronjobPod("manual-xyz", "manual", "", restarts),
+		)))
+	}
+	collect(0)
+	collect(1)
+
+	owners := func(filter models.RestartFilter) []string {
+		restarts, err := ds.ListContainerRestarts(filter)
+		require.NoError(t, err)
+
+		pods := make([]string, 0)
+		for _, restart := range restarts {
+			pods = append(pods, restart.WorkloadType+"/"+restart.WorkloadName+"/"+restart.PodName)
+		}
+		return pods
+	}
+
+	// the pods are accounted to the cronjob owning their job, not to a cronjob with a matching name prefix
+	assert.Equal(t, []string{models.WORKLOAD_TYPE_CRONJOB + "/backup/backup-28000000-abcde"}, owners(models.RestartFilter{WorkloadType: models.WORKLOAD_TYPE_CRONJOB, WorkloadName: "backup"}))
+	assert.Equal(t, []string{models.WORKLOAD_TYPE_CRONJOB + "/backup-db/backup-db-28000000-fghij"}, owners(models.RestartFilter{WorkloadType: models.WORKLOAD_TYPE_CRONJOB, WorkloadName: "backup-db"}))
+	assert.Equal(t, []string{models.WORKLOAD_TYPE_JOB + "/manual/manual-xyz"}, owners(models.RestartFilter{WorkloadType: models.WORKLOAD_TYPE_JOB}))
+
+	cronjob := models.CronjobWorkload{GeneralWorkloadInfo: models.GeneralWorkloadInfo{WorkloadName: "backup", Namespace: "default", CreationTimestamp: time.Unix(1674381600, 0)}}
+	pods, err := ds.GetPodsForWorkload(cronjob)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default_backup-28000000-abcde"}, pods.GetKeys())
+}
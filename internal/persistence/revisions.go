@@ -0,0 +1,296 @@
+package persistence
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	Revisions are the change records of the stored objects.
+	A new revision is written whenever the stored json of an object changes and a tombstone when it disappears.
+	Volatile fields like restart counts change without a change of the object, they are stored with a revision
+	but don't create a new one. The restarts are recorded by the restart history instead.
+**/
+
+// revisionTable describes a revision table and the columns used to identify the object
+type revisionTable struct {
+	name     string
+	kind     string
+	fields   []string
+	volatile []string // json paths excluded from the hash, arrays are traversed, e.g. workload_info.containers.restarts
+}
+
+var workloadRevisionTable = revisionTable{
+	name:   "workload_revisions",
+	kind:   snapshot_kind_workload,
+	fields: []string{"workload_name", "workload_type", "namespace"},
+	volatile: []string{
+		"restarts",
+		"workload_info.containers.restarts",
+		"workload_info.containers.waiting_reason",
+		"workload_info.containers.last_termination",
+	},
+}
+
+var nodeRevisionTable = revisionTable{
+	name:   "node_revisions",
+	kind:   snapshot_kind_node,
+	fields: []string{"name"},
+}
+
+var namespaceRevisionTable = revisionTable{
+	name:     "namespace_revisions",
+	kind:     snapshot_kind_namespace,
+	fields:   []string{"name"},
+	volatile: []string{"resource_quotas.resources.used", "resource_quotas.resources.utilization"},
+}
+
+var revisionTables = []revisionTable{workloadRevisionTable, nodeRevisionTable, namespaceRevisionTable}
+
+// revisionRecord is the current state of an object which should be recorded
+type revisionRecord struct {
+	fields []string
+	data   []byte
+}
+
+type revisionState struct {
+	revision int
+	fields   []string
+	data     string
+	hash     string
+	deleted  bool
+}
+
+func (t revisionTable) sqlFields() string {
+	return fmt.Sprintf("key, revision, %s, data, hash, deleted, creation_timestamp", strings.Join(t.fields, ", "))
+}
+
+func (d *DataStore) recordWorkloadRevisions(tx *sql.Tx, collection *models.WorkloadCollection) error {
+	records := make(map[string]revisionRecord)
+	for key, workload := range collection.GetAll() {
+		data, err := json.Marshal(workload)
+		if err != nil {
+			return err
+		}
+
+		records[key] = revisionRecord{
+			fields: []string{workload.GetWorkloadName(), workload.GetType(), workload.GetNamespace()},
+			data:   data,
+		}
+	}
+
+	return d.recordRevisions(tx, workloadRevisionTable, records)
+}
+
+func (d *DataStore) recordNodeRevisions(tx *sql.Tx, collection *models.NodeCollection) error {
+	records := make(map[string]revisionRecord)
+	for key, node := range collection.GetAll() {
+		data, err := json.Marshal(node)
+		if err != nil {
+			return err
+		}
+
+		records[key] = revisionRecord{fields: []string{node.Name}, data: data}
+	}
+
+	return d.recordRevisions(tx, nodeRevisionTable, records)
+}
+
+func (d *DataStore) recordNamespaceRevisions(tx *sql.Tx, collection *models.NamespaceCollection) error {
+	records := make(map[string]revisionRecord)
+	for key, namespace := range collection.GetAll() {
+		data, err := json.Marshal(namespace)
+		if err != nil {
+			return err
+		}
+
+		records[key] = revisionRecord{fields: []string{namespace.Name}, data: data}
+	}
+
+	return d.recordRevisions(tx, namespaceRevisionTable, records)
+}
+
+// recordRevisions stores a new revision for every changed object and a tombstone for every removed object.
+func (d *DataStore) recordRevisions(tx *sql.Tx, table revisionTable, records map[string]revisionRecord) error {
+	latest, err := d.getLatestRevisions(tx, table)
+	if err != nil {
+		return err
+	}
+
+	cntFields := len(table.fields) + 6
+	sqlStmtHead := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table.name, table.sqlFields())
+	sqlStmtVals := fmt.Sprintf("(%s)", strings.TrimSuffix(strings.Repeat("?, ", cntFields), ", "))
+	creationTimestamp := time.Now().Unix()
+	values := make([]any, 0)
+
+	appendRow := func(key string, revision int, fields []string, data string, hash string, deleted bool) {
+		values = append(values, key, revision)
+		for _, field := range fields {
+			values = append(values, field)
+		}
+		values = append(values, data, hash, deleted, creationTimestamp)
+	}
+
+	for key, record := range records {
+		hash, err := hashRevisionData(record.data, table.volatile)
+		if err != nil {
+			return err
+		}
+
+		revision := 1
+		if previous, ok := latest[key]; ok {
+			if !previous.deleted && previous.hash == hash {
+				continue
+			}
+			revision = previous.revision + 1
+		}
+
+		appendRow(key, revision, record.fields, string(record.data), hash, false)
+	}
+
+	for key, previous := range latest {
+		if _, ok := records[key]; ok || previous.deleted {
+			continue
+		}
+
+		// tombstone keeps the last known state of the object
+		appendRow(key, previous.revision+1, previous.fields, previous.data, previous.hash, true)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := d.replace(tx, sqlStmtHead, sqlStmtVals, cntFields, values); err != nil {
+		zap.L().Error("could not record revisions", zap.String("table", table.name), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (d *DataStore) getLatestRevisions(tx *sql.Tx, table revisionTable) (map[string]revisionState, error) {
+	fields := make([]string, len(table.fields))
+	for i, field := range table.fields {
+		fields[i] = fmt.Sprintf("r.%s", field)
+	}
+
+	sqlStmt := fmt.Sprintf("SELECT r.key, r.revision, %s, r.data, r.hash, r.deleted FROM %s r "+
+		"INNER JOIN (SELECT key, MAX(revision) AS revision FROM %s GROUP BY key) l ON r.key = l.key AND r.revision = l.revision",
+		strings.Join(fields, ", "), table.name, table.name)
+	stmt, err := tx.Prepare(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	result := make(map[string]revisionState)
+	for rows.Next() {
+		var key string
+		state := revisionState{fields: make([]string, len(table.fields))}
+		dest := []any{&key, &state.revision}
+		for i := range state.fields {
+			dest = append(dest, &state.fields[i])
+		}
+		dest = append(dest, &state.data, &state.hash, &state.deleted)
+
+		if err := rows.Scan(dest...); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+		result[key] = state
+	}
+
+	return result, nil
+}
+
+// GetWorkloadRevisions returns all stored revisions of a workload ordered by revision.
+func (d *DataStore) GetWorkloadRevisions(namespace string, workloadType string, workloadName string) ([]models.WorkloadRevision, error) {
+	if err := d.scope.Check(namespace); err != nil {
+		return nil, err
+	}
+
+	sqlStmt := fmt.Sprintf("SELECT %s FROM workload_revisions WHERE namespace=? AND workload_type=? AND workload_name=? ORDER BY creation_timestamp, revision", workloadRevisionTable.sqlFields())
+	stmt, err := d.read.Prepare(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(namespace, workloadType, workloadName)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := make([]models.WorkloadRevision, 0)
+	for rows.Next() {
+		var revision models.WorkloadRevision
+		var data []byte
+		var hash string
+		var creationTimestamp int64
+
+		if err := rows.Scan(&revision.Key, &revision.Revision, &revision.WorkloadName, &revision.WorkloadType, &revision.Namespace, &data, &hash, &revision.Deleted, &creationTimestamp); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		revision.Data = data
+		revision.CreationTimestamp = time.Unix(creationTimestamp, 0)
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+// hashRevisionData returns the hash of the data without the volatile fields
+func hashRevisionData(data []byte, volatile []string) (string, error) {
+	if len(volatile) > 0 {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", err
+		}
+		for _, path := range volatile {
+			removeJSONPath(doc, strings.Split(path, "."))
+		}
+
+		// maps are marshalled with sorted keys, the hash is stable
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// removeJSONPath removes the field at the path from the decoded json document, the path is applied to every item of arrays
+func removeJSONPath(doc any, path []string) {
+	switch v := doc.(type) {
+	case []any:
+		for _, item := range v {
+			removeJSONPath(item, path)
+		}
+	case map[string]any:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		removeJSONPath(v[path[0]], path[1:])
+	}
+}
@@ -0,0 +1,97 @@
+package persistence
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+func TestRecordRevisions(t *testing.T) {
+	ds := newTestDataStore(t)
+
+	web := testPod("web", "nginx")
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web, testPod("db", "postgres"))))
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web, testPod("db", "postgres"))))
+
+	revisions, err := ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1, "an unchanged object is not recorded again")
+
+	web.Containers[0].ImageVersion = "1.24"
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web)))
+
+	revisions, err = ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[1].Revision)
+	assert.False(t, revisions[1].Deleted)
+
+	var stored models.PodWorkload
+	require.NoError(t, json.Unmarshal(revisions[1].Data, &struct {
+		Info *models.GeneralWorkloadInfo `json:"workload_info"`
+	}{Info: &stored.GeneralWorkloadInfo}))
+	assert.Equal(t, "1.24", stored.Containers[0].ImageVersion)
+
+	deleted, err := ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "db")
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	assert.True(t, deleted[1].Deleted, "a removed object gets a tombstone")
+	assert.JSONEq(t, string(deleted[0].Data), string(deleted[1].Data), "the tombstone keeps the last known state")
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web)))
+	deleted, err = ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "db")
+	require.NoError(t, err)
+	assert.Len(t, deleted, 2, "a deleted object gets a single tombstone")
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web, testPod("db", "postgres"))))
+	deleted, err = ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "db")
+	require.NoError(t, err)
+	require.Len(t, deleted, 3)
+	assert.False(t, deleted[2].Deleted, "a recreated object continues its revisions")
+	assert.Equal(t, 3, deleted[2].Revision)
+}
+
+func TestRecordRevisionsVolatileFields(t *testing.T) {
+	ds := newTestDataStore(t)
+
+	web := testPod("web", "nginx")
+	require.NoError(t, ds.ReplaceWorkloads(testPods(web)))
+
+	restarted := testPod("web", "nginx")
+	restarted.Restarts = 4
+	restarted.Containers[0].Restarts = 4
+	restarted.Containers[0].WaitingReason = "CrashLoopBackOff"
+	require.NoError(t, ds.ReplaceWorkloads(testPods(restarted)))
+
+	revisions, err := ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1, "restarts don't create a revision")
+
+	restarted.Status = "Failed"
+	require.NoError(t, ds.ReplaceWorkloads(testPods(restarted)))
+
+	revisions, err = ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Contains(t, string(revisions[1].Data), `"restarts":4`, "the volatile fields are stored with the next revision")
+}
+
+func TestHashRevisionData(t *testing.T) {
+	volatile := []string{"restarts", "workload_info.containers.restarts"}
+	hash := func(data string) string {
+		h, err := hashRevisionData([]byte(data), volatile)
+		require.NoError(t, err)
+		return h
+	}
+
+	base := hash(`{"restarts":1,"workload_info":{"containers":[{"name":"app","restarts":1},{"name":"sidecar","restarts":0}]}}`)
+	assert.Equal(t, base, hash(`{"restarts":7,"workload_info":{"containers":[{"name":"app","restarts":5},{"name":"sidecar","restarts":2}]}}`))
+	assert.NotEqual(t, base, hash(`{"restarts":1,"workload_info":{"containers":[{"name":"web","restarts":1},{"name":"sidecar","restarts":0}]}}`))
+
+	_, err := hashRevisionData([]byte(`{`), volatile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// WithScope returns a view of the data store which only reads the objects of the namespaces in the scope.
+// The view shares the connections, the writes are not restricted.
+func (d *DataStore) WithScope(scope *models.NamespaceScope) *DataStore {
+	scoped := *d
+	scoped.scope = scope
+	return &scoped
+}
+
+// scopeCondition returns the condition restricting the column to the namespaces of the scope, empty when the scope is not restricted
+func (d *DataStore) scopeCondition(column string) (string, []any) {
+	if !d.scope.Restricted() {
+		return "", nil
+	}
+
+	namespaces := d.scope.Namespaces()
+	if len(namespaces) == 0 {
+		return "0 = 1", nil
+	}
+
+	values := make([]any, len(namespaces))
+	for i, namespace := range namespaces {
+		values[i] = namespace
+	}
+
+	return fmt.Sprintf("%s IN (%s)", column, strings.TrimSuffix(strings.Repeat("?, ", len(namespaces)), ", ")), values
+}
+
+// appendScopeCondition appends the condition of the scope to the conditions
+func (d *DataStore) appendScopeCondition(conditions []string, values []any, column string) ([]string, []any) {
+	condition, conditionValues := d.scopeCondition(column)
+	if condition == "" {
+		return conditions, values
+	}
+
+	return append(conditions, condition), append(values, conditionValues...)
+}
+
+// checkScopeFilter returns ErrForbidden when the namespace filter selects a namespace outside of the scope
+func (d *DataStore) checkScopeFilter(filters map[string]string) error {
+	if namespace, ok := filters["namespace"]; ok {
+		return d.scope.Check(namespace)
+	}
+
+	return nil
+}
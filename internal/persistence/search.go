@@ -0,0 +1,248 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	The search index contains the searchable text of every node, namespace and workload.
+	It is rebuilt from the stored tables within the transaction replacing them.
+	FTS5 is only available when go-sqlite3 is built with the sqlite_fts5 tag, otherwise a plain table is searched with LIKE.
+**/
+
+const (
+	search_highlight_start = "<mark>"
+	search_highlight_end   = "</mark>"
+)
+
+// the matches are marked by control characters first, they are replaced by the highlight after the value is html escaped
+const (
+	search_match_start = "\x02"
+	search_match_end   = "\x03"
+)
+
+var search_highlight_replacer = strings.NewReplacer(search_match_start, search_highlight_start, search_match_end, search_highlight_end)
+
+// search_fields are the searchable columns of the search index, in the order of the table definition
+var search_fields = []string{"name", "namespace", "containers", "images", "labels", "annotations"}
+
+// search_index_sources selects the searchable text of every kind from the stored tables
+var search_index_sources = map[string]string{
+	models.SEARCH_KIND_WORKLOAD: "SELECT key, workload_type, workload_name, namespace, " +
+		"COALESCE((SELECT group_concat(json_extract(c.value, '$.container_name'), ' ') FROM json_each(workloads.containers) c), ''), " +
+		"COALESCE((SELECT group_concat(json_extract(c.value, '$.image') || ':' || json_extract(c.value, '$.image_version'), ' ') FROM json_each(workloads.containers) c), ''), " +
+		"COALESCE((SELECT group_concat(l.key || '=' || l.value, ' ') FROM json_each(workloads.labels) l), ''), " +
+		"COALESCE((SELECT group_concat(a.key || '=' || a.value, ' ') FROM json_each(workloads.annotations) a), '') " +
+		"FROM workloads",
+	models.SEARCH_KIND_NODE: "SELECT key, '', name, '', '', '', " +
+		"COALESCE((SELECT group_concat(l.key || '=' || l.value, ' ') FROM json_each(nodes.labels) l), ''), " +
+		"COALESCE((SELECT group_concat(a.key || '=' || a.value, ' ') FROM json_each(nodes.annotations) a), '') " +
+		"FROM nodes",
+	models.SEARCH_KIND_NAMESPACE: "SELECT key, '', name, '', '', '', " +
+		"COALESCE((SELECT group_concat(l.key || '=' || l.value, ' ') FROM json_each(namespaces.labels) l), ''), " +
+		"COALESCE((SELECT group_concat(a.key || '=' || a.value, ' ') FROM json_each(namespaces.annotations) a), '') " +
+		"FROM namespaces",
+}
+
+// createSearchIndex creates the search index and returns if full text search is available
+func createSearchIndex(db *sql.DB) (bool, error) {
+	columns := fmt.Sprintf("kind UNINDEXED, key UNINDEXED, workload_type UNINDEXED, %s", strings.Join(search_fields, ", "))
+	_, err := db.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(%s)", columns))
+	if err == nil {
+		return true, nil
+	}
+
+	if !strings.Contains(err.Error(), "no such module: fts5") {
+		return false, err
+	}
+
+	zap.L().Warn("sqlite was built without fts5, falling back to a plain search index")
+	columns = fmt.Sprintf("kind TEXT NOT NULL, key TEXT NOT NULL, workload_type TEXT NOT NULL, %s TEXT NOT NULL", strings.Join(search_fields, " TEXT NOT NULL, "))
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS search_index (%s)", columns)); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// rebuildSearchIndex replaces the indexed objects of the kind by the current content of the stored table
+func (d *DataStore) rebuildSearchIndex(tx *sql.Tx, kind string) error {
+	if _, err := tx.Exec("DELETE FROM search_index WHERE kind = ?", kind); err != nil {
+		return err
+	}
+
+	sqlStmt := fmt.Sprintf("INSERT INTO search_index (kind, key, workload_type, %s) SELECT ?, * FROM (%s)", strings.Join(search_fields, ", "), search_index_sources[kind])
+	if _, err := tx.Exec(sqlStmt, kind); err != nil {
+		zap.L().Error("could not rebuild search index", zap.String("kind", kind), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Search returns the objects matching all terms of the query grouped by kind, at most limit per kind.
+func (d *DataStore) Search(query string, limit int) (*models.SearchResults, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: empty search query", models.ErrInvalidFilter)
+	}
+
+	results := &models.SearchResults{}
+	for _, kind := range []string{models.SEARCH_KIND_NODE, models.SEARCH_KIND_NAMESPACE, models.SEARCH_KIND_WORKLOAD} {
+		var kindResults []models.SearchResult
+		var err error
+		if d.fullTextSearch {
+			kindResults, err = d.searchFullText(kind, terms, limit)
+		} else {
+			kindResults, err = d.searchLike(kind, terms, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case models.SEARCH_KIND_NODE:
+			results.Nodes = kindResults
+		case models.SEARCH_KIND_NAMESPACE:
+			results.Namespaces = kindResults
+		case models.SEARCH_KIND_WORKLOAD:
+			results.Workloads = kindResults
+		}
+	}
+
+	return results, nil
+}
+
+// searchScopeCondition returns the condition restricting the search results of the kind to the scope, nodes are not restricted
+func (d *DataStore) searchScopeCondition(kind string) (string, []any) {
+	switch kind {
+	case models.SEARCH_KIND_NAMESPACE:
+		return d.scopeCondition("name")
+	case models.SEARCH_KIND_WORKLOAD:
+		return d.scopeCondition("namespace")
+	}
+
+	return "", nil
+}
+
+func (d *DataStore) searchFullText(kind string, terms []string, limit int) ([]models.SearchResult, error) {
+	// every term is searched as prefix, quoting prevents fts5 from interpreting operators
+	matches := make([]string, len(terms))
+	for i, term := range terms {
+		matches[i] = fmt.Sprintf("\"%s\"*", strings.ReplaceAll(term, "\"", "\"\""))
+	}
+
+	snippets := make([]string, len(search_fields))
+	for i := range search_fields {
+		// the first three columns are kind, key & workload_type
+		snippets[i] = fmt.Sprintf("snippet(search_index, %d, '%s', '%s', '...', 32)", i+3, search_match_start, search_match_end)
+	}
+
+	where := "search_index MATCH ? AND kind = ?"
+	values := []any{strings.Join(matches, " "), kind}
+	if condition, conditionValues := d.searchScopeCondition(kind); condition != "" {
+		where = fmt.Sprintf("%s AND %s", where, condition)
+		values = append(values, conditionValues...)
+	}
+
+	sqlStmt := fmt.Sprintf("SELECT key, workload_type, name, namespace, %s FROM search_index WHERE %s ORDER BY rank LIMIT ?", strings.Join(snippets, ", "), where)
+	rows, err := d.read.Query(sqlStmt, append(values, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return scanSearchResults(kind, rows, func(value string) (string, bool) {
+		if !strings.Contains(value, search_match_start) {
+			return "", false
+		}
+		return highlightMatches(value), true
+	})
+}
+
+func (d *DataStore) searchLike(kind string, terms []string, limit int) ([]models.SearchResult, error) {
+	conditions := make([]string, len(terms))
+	values := []any{kind}
+	for i, term := range terms {
+		fieldConditions := make([]string, len(search_fields))
+		for j, field := range search_fields {
+			fieldConditions[j] = fmt.Sprintf("%s LIKE ? ESCAPE '\\'", field)
+			values = append(values, likePattern(term))
+		}
+		conditions[i] = fmt.Sprintf("(%s)", strings.Join(fieldConditions, " OR "))
+	}
+	if condition, conditionValues := d.searchScopeCondition(kind); condition != "" {
+		conditions = append(conditions, condition)
+		values = append(values, conditionValues...)
+	}
+	values = append(values, limit)
+
+	sqlStmt := fmt.Sprintf("SELECT key, workload_type, name, namespace, %s FROM search_index WHERE kind = ? AND %s ORDER BY name LIMIT ?",
+		strings.Join(search_fields, ", "), strings.Join(conditions, " AND "))
+	rows, err := d.read.Query(sqlStmt, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile(fmt.Sprintf("(?i)(%s)", strings.Join(quoted, "|")))
+
+	return scanSearchResults(kind, rows, func(value string) (string, bool) {
+		if !pattern.MatchString(value) {
+			return "", false
+		}
+		return highlightMatches(pattern.ReplaceAllString(value, fmt.Sprintf("%s$1%s", search_match_start, search_match_end))), true
+	})
+}
+
+// scanSearchResults reads the search rows, highlight returns the highlighted value of a field and if it matched
+func scanSearchResults(kind string, rows *sql.Rows, highlight func(value string) (string, bool)) ([]models.SearchResult, error) {
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		result := models.SearchResult{Kind: kind, Highlights: make(map[string]string)}
+		fields := make([]string, len(search_fields))
+		dest := []any{&result.Key, &result.WorkloadType, &result.Name, &result.Namespace}
+		for i := range fields {
+			dest = append(dest, &fields[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		for i, field := range search_fields {
+			if value, ok := highlight(fields[i]); ok {
+				result.Highlights[field] = value
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// highlightMatches escapes the value for html and wraps the marked matches in the highlight.
+// The values are cluster metadata like annotations, only the highlight is markup.
+func highlightMatches(value string) string {
+	return search_highlight_replacer.Replace(html.EscapeString(value))
+}
+
+func likePattern(term string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+	return fmt.Sprintf("%%%s%%", replacer.Replace(term))
+}
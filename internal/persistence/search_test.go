@@ -0,0 +1,102 @@
+package persistence
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// searchModes returns the search implementations available in the build, fts5 requires the sqlite_fts5 tag
+func searchModes(ds *DataStore) []bool {
+	if ds.fullTextSearch {
+		return []bool{true, false}
+	}
+
+	return []bool{false}
+}
+
+func TestSearchEscapesHighlights(t *testing.T) {
+	ds := newTestDataStore(t)
+
+	pod := testPod("web", "nginx")
+	pod.Annotations = map[string]string{"note": `<img src=x onerror=alert(1)> nginx`}
+	require.NoError(t, ds.ReplaceWorkloads(testPods(pod)))
+
+	for _, fullText := range searchModes(ds) {
+		ds.fullTextSearch = fullText
+
+		results, err := ds.Search("onerror", 10)
+		require.NoError(t, err)
+		require.Len(t, results.Workloads, 1)
+		assert.Equal(t, `note=&lt;img src=x <mark>onerror</mark>=alert(1)&gt; nginx`, results.Workloads[0].Highlights["annotations"], "full text %t", fullText)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ds := newTestDataStore(t)
+
+	nodes := models.NewCollection[string, models.Node]()
+	nodes.Set("node-1", models.Node{Name: "node-1", Labels: map[string]string{"pool": "web"}, Annotations: map[string]string{}}, true)
+	require.NoError(t, ds.ReplaceNodes(nodes))
+
+	namespaces := models.NewCollection[string, models.Namespace]()
+	for _, name := range []string{"default", "shop"} {
+		namespaces.Set(name, models.Namespace{Name: name, Labels: map[string]string{"team": "web"}, Annotations: map[string]string{}}, true)
+	}
+	require.NoError(t, ds.ReplaceNamespaces(namespaces))
+
+	shop := testPod("web-shop", "nginx")
+	shop.Namespace = "shop"
+	discount := testPod("discount_100%", "redis")
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), testPod("db", "postgres"), shop, discount)))
+
+	names := func(results []models.SearchResult) []string {
+		result := make([]string, 0, len(results))
+		for _, r := range results {
+			result = append(result, r.Namespace+"/"+r.Name)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	for _, fullText := range searchModes(ds) {
+		ds.fullTextSearch = fullText
+
+		results, err := ds.Search("web", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/node-1"}, names(results.Nodes), "full text %t", fullText)
+		assert.Equal(t, []string{"/default", "/shop"}, names(results.Namespaces), "full text %t", fullText)
+		assert.Equal(t, []string{"default/web", "shop/web-shop"}, names(results.Workloads), "full text %t", fullText)
+
+		results, err = ds.Search("web nginx shop", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"shop/web-shop"}, names(results.Workloads), "all terms have to match, full text %t", fullText)
+		assert.Equal(t, "<mark>nginx</mark>:1.23", results.Workloads[0].Highlights["images"], "full text %t", fullText)
+
+		results, err = ds.Search("web", 1)
+		require.NoError(t, err)
+		assert.Len(t, results.Workloads, 1, "the results are limited per kind, full text %t", fullText)
+
+		results, err = ds.WithScope(models.NewNamespaceScope("shop")).Search("web", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/node-1"}, names(results.Nodes), "nodes are not restricted by the scope, full text %t", fullText)
+		assert.Equal(t, []string{"/shop"}, names(results.Namespaces), "full text %t", fullText)
+		assert.Equal(t, []string{"shop/web-shop"}, names(results.Workloads), "full text %t", fullText)
+	}
+
+	// like patterns are escaped
+	ds.fullTextSearch = false
+	results, err := ds.Search("_100%", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default/discount_100%"}, names(results.Workloads))
+	results, err = ds.Search("discoun__100", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results.Workloads, "_ doesn't match any character")
+
+	_, err = ds.Search("  ", 10)
+	assert.ErrorIs(t, err, models.ErrInvalidFilter)
+}
@@ -0,0 +1,346 @@
+package persistence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"go.uber.org/zap"
+)
+
+/**
+	Snapshots are periodic full copies of the stored objects.
+	The state at a point in time is built from the latest snapshot before that point and the revisions recorded afterwards.
+**/
+
+const (
+	snapshot_kind_node      = "node"
+	snapshot_kind_namespace = "namespace"
+	snapshot_kind_workload  = "workload"
+)
+
+// ErrNoSnapshot is returned when the requested point in time is not covered by the stored history
+var ErrNoSnapshot = fmt.Errorf("no snapshot available for the requested point in time: %w", models.ErrNotFound)
+
+// CreateSnapshot stores the current state of all objects as a full snapshot.
+func (d *DataStore) CreateSnapshot() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO snapshots (creation_timestamp) VALUES (?)", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, table := range revisionTables {
+		sqlStmt := fmt.Sprintf("INSERT INTO snapshot_objects (snapshot_id, kind, key, data) SELECT ?, ?, r.key, r.data FROM %s r "+
+			"INNER JOIN (SELECT key, MAX(revision) AS revision FROM %s GROUP BY key) l ON r.key = l.key AND r.revision = l.revision WHERE r.deleted = 0",
+			table.name, table.name)
+		if _, err := tx.Exec(sqlStmt, id, table.kind); err != nil {
+			zap.L().Error("could not create snapshot", zap.String("table", table.name), zap.Error(err))
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLatestSnapshotTimestamp returns the creation time of the latest snapshot or nil if no snapshot exists.
+func (d *DataStore) GetLatestSnapshotTimestamp() (*time.Time, error) {
+	var creationTimestamp sql.NullInt64
+	if err := d.read.QueryRow("SELECT MAX(creation_timestamp) FROM snapshots").Scan(&creationTimestamp); err != nil {
+		return nil, err
+	}
+
+	if !creationTimestamp.Valid {
+		return nil, nil
+	}
+
+	t := time.Unix(creationTimestamp.Int64, 0)
+	return &t, nil
+}
+
+// GetSnapshots returns the available snapshots ordered by creation time.
+func (d *DataStore) GetSnapshots() ([]models.Snapshot, error) {
+	sqlStmt := "SELECT s.id, s.creation_timestamp, " +
+		"COALESCE(SUM(o.kind = ?), 0), COALESCE(SUM(o.kind = ?), 0), COALESCE(SUM(o.kind = ?), 0) " +
+		"FROM snapshots s LEFT JOIN snapshot_objects o ON o.snapshot_id = s.id GROUP BY s.id ORDER BY s.creation_timestamp"
+	stmt, err := d.read.Prepare(sqlStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(snapshot_kind_node, snapshot_kind_namespace, snapshot_kind_workload)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snapshots := make([]models.Snapshot, 0)
+	for rows.Next() {
+		var snapshot models.Snapshot
+		var creationTimestamp int64
+		if err := rows.Scan(&snapshot.ID, &creationTimestamp, &snapshot.Nodes, &snapshot.Namespaces, &snapshot.Workloads); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		snapshot.CreationTimestamp = time.Unix(creationTimestamp, 0)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// RemoveHistoryBefore removes snapshots and revisions which are not required to answer requests after the given time.
+func (d *DataStore) RemoveHistoryBefore(t time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM snapshot_objects WHERE snapshot_id IN (SELECT id FROM snapshots WHERE creation_timestamp < ?)", t.Unix()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM snapshots WHERE creation_timestamp < ?", t.Unix()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM container_restarts WHERE timestamp < ?", t.Unix()); err != nil {
+		return err
+	}
+
+	var oldest sql.NullInt64
+	if err := tx.QueryRow("SELECT MIN(creation_timestamp) FROM snapshots").Scan(&oldest); err != nil {
+		return err
+	}
+
+	if oldest.Valid {
+		/**
+			revisions covered by the oldest snapshot are not needed anymore.
+			the latest revision of an existing object is kept, it is required to detect further changes.
+		**/
+		for _, table := range revisionTables {
+			sqlStmt := fmt.Sprintf("DELETE FROM %s WHERE creation_timestamp <= ? AND "+
+				"(deleted = 1 OR revision < (SELECT MAX(l.revision) FROM %s l WHERE l.key = %s.key))",
+				table.name, table.name, table.name)
+			if _, err := tx.Exec(sqlStmt, oldest.Int64); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getObjectsAt returns the json representation of every object of the revision table at the given time.
+func (d *DataStore) getObjectsAt(table revisionTable, at time.Time) (map[string][]byte, error) {
+	var snapshotID int64
+	var snapshotTimestamp int64
+	err := d.read.QueryRow("SELECT id, creation_timestamp FROM snapshots WHERE creation_timestamp <= ? ORDER BY creation_timestamp DESC LIMIT 1", at.Unix()).Scan(&snapshotID, &snapshotTimestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSnapshot
+	} else if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]byte)
+	rows, err := d.read.Query("SELECT key, data FROM snapshot_objects WHERE snapshot_id = ? AND kind = ?", snapshotID, table.kind)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+		objects[key] = data
+	}
+
+	// apply all changes recorded after the snapshot
+	sqlStmt := fmt.Sprintf("SELECT key, data, deleted FROM %s WHERE creation_timestamp > ? AND creation_timestamp <= ? ORDER BY creation_timestamp, revision", table.name)
+	changes, err := d.read.Query(sqlStmt, snapshotTimestamp, at.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	defer changes.Close()
+	for changes.Next() {
+		var key string
+		var data []byte
+		var deleted bool
+		if err := changes.Scan(&key, &data, &deleted); err != nil {
+			zap.L().Error("Could not scan result from sqlite database", zap.Error(err))
+			return nil, err
+		}
+
+		if deleted {
+			delete(objects, key)
+		} else {
+			objects[key] = data
+		}
+	}
+
+	return objects, nil
+}
+
+// GetNodesAt returns the nodes at the given point in time.
+func (d *DataStore) GetNodesAt(at time.Time) (*models.NodeCollection, error) {
+	objects, err := d.getObjectsAt(nodeRevisionTable, at)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewCollection[string, models.Node]()
+	for key, data := range objects {
+		var node models.Node
+		if err := json.Unmarshal(data, &node); err != nil {
+			zap.L().Error("could not unmarshal node", zap.Error(err))
+			continue
+		}
+		collection.Set(key, node, false)
+	}
+
+	return collection, nil
+}
+
+// GetNamespacesAt returns the namespaces at the given point in time.
+func (d *DataStore) GetNamespacesAt(at time.Time) (*models.NamespaceCollection, error) {
+	objects, err := d.getObjectsAt(namespaceRevisionTable, at)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewCollection[string, models.Namespace]()
+	for key, data := range objects {
+		var namespace models.Namespace
+		if err := json.Unmarshal(data, &namespace); err != nil {
+			zap.L().Error("could not unmarshal namespace", zap.Error(err))
+			continue
+		}
+		if !d.scope.Allows(namespace.Name) {
+			continue
+		}
+		collection.Set(key, namespace, false)
+	}
+
+	return collection, nil
+}
+
+// GetNamespaceAt returns the namespace at the given point in time.
+func (d *DataStore) GetNamespaceAt(name string, at time.Time) (*models.Namespace, error) {
+	if err := d.scope.Check(name); err != nil {
+		return nil, err
+	}
+
+	collection, err := d.GetNamespacesAt(at)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespace := range collection.ToList() {
+		if namespace.Name == name {
+			return &namespace, nil
+		}
+	}
+
+	return nil, fmt.Errorf("namespace %s: %w", name, models.ErrNotFound)
+}
+
+// GetWorkloadsAt returns the workloads matching the filters at the given point in time.
+func (d *DataStore) GetWorkloadsAt(at time.Time, filters map[string]string) (*models.WorkloadCollection, error) {
+	for key := range filters {
+		if key != "namespace" && key != "workload_name" && key != "workload_type" {
+			return nil, fmt.Errorf("%w: unsupported filter %s", models.ErrInvalidFilter, key)
+		}
+	}
+	if err := d.checkScopeFilter(filters); err != nil {
+		return nil, err
+	}
+
+	objects, err := d.getObjectsAt(workloadRevisionTable, at)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := models.NewCollection[string, models.Workload]()
+	for key, data := range objects {
+		workload, err := models.UnmarshalWorkload(data)
+		if err != nil {
+			zap.L().Error("could not unmarshal workload", zap.Error(err))
+			continue
+		}
+
+		if v, ok := filters["namespace"]; ok && workload.GetNamespace() != v {
+			continue
+		}
+		if !d.scope.Allows(workload.GetNamespace()) {
+			continue
+		}
+		if v, ok := filters["workload_name"]; ok && workload.GetWorkloadName() != v {
+			continue
+		}
+		if v, ok := filters["workload_type"]; ok && workload.GetType() != v {
+			continue
+		}
+
+		collection.Set(key, workload, false)
+	}
+
+	return collection, nil
+}
+
+// GetWorkloadsMatchingAt returns the workloads matching the filters and the label selector at the given point in time.
+func (d *DataStore) GetWorkloadsMatchingAt(at time.Time, filters map[string]string, selector models.LabelSelector) (*models.WorkloadCollection, error) {
+	collection, err := d.GetWorkloadsAt(at, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.Filter(filterWorkloadByLabelSelector(selector)), nil
+}
+
+// GetWorkloadAt returns the first workload matching the filters at the given point in time.
+func (d *DataStore) GetWorkloadAt(at time.Time, filters map[string]string) (models.Workload, error) {
+	collection, err := d.GetWorkloadsAt(at, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.Len() < 1 {
+		return nil, fmt.Errorf("workload: %w", models.ErrNotFound)
+	}
+
+	return collection.ToList()[0], nil
+}
+
+// GetPodsForWorkloadAt returns the pods of the workload at the given point in time.
+func (d *DataStore) GetPodsForWorkloadAt(w models.Workload, at time.Time) (*models.WorkloadCollection, error) {
+	filter := make(map[string]string)
+	filter["namespace"] = w.GetNamespace()
+	filter["workload_type"] = models.WORKLOAD_TYPE_POD
+	collection, err := d.GetWorkloadsAt(at, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterPodsForWorkload(w, collection), nil
+}
@@ -0,0 +1,95 @@
+package persistence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// ageHistory moves the recorded revisions and snapshots into the past
+func ageHistory(t *testing.T, ds *DataStore, age time.Duration) {
+	for _, table := range []string{"snapshots", "workload_revisions", "node_revisions", "namespace_revisions"} {
+		_, err := ds.db.Exec("UPDATE "+table+" SET creation_timestamp = creation_timestamp - ?", int64(age/time.Second))
+		require.NoError(t, err)
+	}
+}
+
+func podImages(collection *models.WorkloadCollection) map[string]string {
+	images := make(map[string]string)
+	for _, w := range collection.GetAll() {
+		images[w.GetWorkloadName()] = w.GetContainers()[0].Image
+	}
+
+	return images
+}
+
+func TestSnapshots(t *testing.T) {
+	ds := newTestDataStore(t)
+	now := time.Now()
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), testPod("db", "postgres"))))
+	require.NoError(t, ds.CreateSnapshot())
+	ageHistory(t, ds, time.Hour)
+
+	// changes after the snapshot are applied to it
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "httpd"), testPod("cache", "redis"))))
+
+	before, err := ds.GetWorkloadsAt(now.Add(-time.Minute*30), map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "nginx", "db": "postgres"}, podImages(before))
+
+	after, err := ds.GetWorkloadsAt(now.Add(time.Second), map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "httpd", "cache": "redis"}, podImages(after), "the tombstone removes db")
+
+	_, err = ds.GetWorkloadsAt(now.Add(-time.Hour*2), map[string]string{})
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+
+	snapshots, err := ds.GetSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+}
+
+func TestRemoveHistoryBefore(t *testing.T) {
+	ds := newTestDataStore(t)
+	now := time.Now()
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "nginx"), testPod("db", "postgres"))))
+	require.NoError(t, ds.CreateSnapshot())
+	ageHistory(t, ds, time.Hour)
+
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "httpd"))))
+	require.NoError(t, ds.CreateSnapshot())
+
+	require.NoError(t, ds.RemoveHistoryBefore(now.Add(-time.Minute)))
+
+	snapshots, err := ds.GetSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1, "the snapshot before the time is removed")
+
+	_, err = ds.GetWorkloadsAt(now.Add(-time.Minute*30), map[string]string{})
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+
+	current, err := ds.GetWorkloadsAt(now.Add(time.Second), map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "httpd"}, podImages(current))
+
+	revisions, err := ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1, "revisions covered by the oldest snapshot are removed")
+	assert.Equal(t, 2, revisions[0].Revision, "the latest revision is kept to detect further changes")
+
+	deleted, err := ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "db")
+	require.NoError(t, err)
+	assert.Empty(t, deleted, "tombstones covered by the oldest snapshot are removed")
+
+	// the kept revision still detects changes
+	require.NoError(t, ds.ReplaceWorkloads(testPods(testPod("web", "httpd"))))
+	revisions, err = ds.GetWorkloadRevisions("default", models.WORKLOAD_TYPE_POD, "web")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
@@ -3,33 +3,67 @@ package v1
 import (
 	"fmt"
 	"net/http"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
 	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/export"
+	"gitlab.com/patrick.erber/kdd/internal/health"
 	"gitlab.com/patrick.erber/kdd/internal/models"
 	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+const (
+	HEADER_TOTAL_COUNT = "X-Total-Count" // total number of items of an exported list
+	HEADER_CONTINUE    = "X-Continue"    // token of the next page of an exported list, not set on the last page
 )
 
 type API struct {
-	ds *persistence.DataStore
-	ka *adapters.KubeAPIAdapter
+	ds      *persistence.DataStore
+	ka      *adapters.KubeAPIAdapter
+	broker  *watch.Broker
+	checker *health.Checker
+	costs   models.CostModel
 }
 
-func NewAPI(ds *persistence.DataStore, ka *adapters.KubeAPIAdapter) *API {
+func NewAPI(ds *persistence.DataStore, ka *adapters.KubeAPIAdapter, broker *watch.Broker, checker *health.Checker, costs models.CostModel) *API {
+	return &API{
+		ds:      ds,
+		ka:      ka,
+		broker:  broker,
+		checker: checker,
+		costs:   costs,
+	}
+}
+
+// scoped returns the api restricted to the namespaces the principal of the request is allowed to see
+func (a *API) scoped(c *gin.Context) *API {
+	scope := authz.GetScope(c)
+	if !scope.Restricted() {
+		return a
+	}
+
 	return &API{
-		ds: ds,
-		ka: ka,
+		ds:      a.ds.WithScope(scope),
+		ka:      a.ka.WithScope(scope),
+		broker:  a.broker,
+		checker: a.checker,
+		costs:   a.costs,
 	}
 }
 
 type Response struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code     int                  `json:"code"`
+	Msg      string               `json:"msg"`
+	Data     interface{}          `json:"data"`
+	Metadata *models.ListMetadata `json:"metadata,omitempty"` // only set for lists
+	Error    *ErrorDetails        `json:"error,omitempty"`    // only set for failed requests
 }
 
 func (a *API) Response(c *gin.Context, httpCode, errCode int, data interface{}) {
@@ -40,152 +74,477 @@ func (a *API) Response(c *gin.Context, httpCode, errCode int, data interface{})
 	})
 }
 
+// parseAt returns the point in time requested by the at query parameter or nil for the current state
+func parseAt(c *gin.Context) (*time.Time, error) {
+	if c.Query("at") == "" {
+		return nil, nil
+	}
+
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		zap.L().Error("Could not parse value for at", zap.String("query_at", c.Query("at")))
+		return nil, fmt.Errorf("%w: invalid at: %s", models.ErrInvalidFilter, c.Query("at"))
+	}
+
+	return &at, nil
+}
+
+// parseTimeRange returns the time range requested by the from and to query parameters.
+// to defaults to the requested point in time or now, from defaults to 7 days before to.
+func parseTimeRange(c *gin.Context, at *time.Time) (time.Time, time.Time, error) {
+	to := time.Now()
+	if at != nil {
+		to = *at
+	}
+
+	if c.Query("to") != "" {
+		t, err := time.Parse(time.RFC3339, c.Query("to"))
+		if err != nil {
+			zap.L().Error("Could not parse value for to", zap.String("query_to", c.Query("to")))
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid to: %s", models.ErrInvalidFilter, c.Query("to"))
+		}
+		to = t
+	}
+
+	from := to.Add(-time.Hour * 24 * 7)
+	if c.Query("from") != "" {
+		t, err := time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			zap.L().Error("Could not parse value for from", zap.String("query_from", c.Query("from")))
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid from: %s", models.ErrInvalidFilter, c.Query("from"))
+		}
+		from = t
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidFilter)
+	}
+
+	return from, to, nil
+}
+
+// parseReduceOptions returns the window and aggregation used to reduce metrics, requested by the rate and agg query parameters
+func parseReduceOptions(c *gin.Context) (time.Duration, string, error) {
+	rate := time.Minute * 5
+	if c.Query("rate") != "" {
+		r, err := time.ParseDuration(c.Query("rate"))
+		if err != nil || r <= 0 {
+			zap.L().Error("Could not parse value for rate", zap.String("query_rate", c.Query("rate")))
+			return 0, "", fmt.Errorf("%w: invalid rate: %s", models.ErrInvalidFilter, c.Query("rate"))
+		}
+		rate = r
+	}
+
+	agg := models.AGGREGATION_MAX
+	if c.Query("agg") != "" {
+		agg = c.Query("agg")
+	}
+
+	if !models.IsValidReduceAggregation(agg) {
+		zap.L().Error("Invalid value for agg", zap.String("query_agg", c.Query("agg")))
+		return 0, "", fmt.Errorf("%w: invalid aggregation: %s", models.ErrInvalidFilter, agg)
+	}
+
+	return rate, agg, nil
+}
+
+// listResponse responds with a page of a list, the items are reduced to the requested fields.
+// The page is exported instead of the json envelope when requested by the format query parameter or the Accept header.
+func listResponse[T any](a *API, c *gin.Context, page *models.Page[T], opts models.ListOptions) {
+	streamResponse(a, c, models.StreamPage(page), opts)
+}
+
+// streamResponse is listResponse of a stream, exported items are written while they are read from the database
+func streamResponse[T any](a *API, c *gin.Context, stream *models.Stream[T], opts models.ListOptions) {
+	format, err := export.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+	if format != export.FORMAT_JSON {
+		exportList(c, format, stream, opts)
+		return
+	}
+
+	page, err := stream.Page()
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	data, err := models.ProjectFields(page.Items, opts.Fields)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:     SUCCESS,
+		Msg:      GetErrorMsg(SUCCESS),
+		Data:     data,
+		Metadata: &page.Metadata,
+	})
+}
+
+// exportList streams the page in the format, the metadata of the page is sent as headers
+func exportList[T any](c *gin.Context, format string, stream *models.Stream[T], opts models.ListOptions) {
+	name := exportName(c)
+	c.Header("Content-Type", export.MediaType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	c.Header("Vary", "Accept")
+	c.Header(HEADER_TOTAL_COUNT, strconv.Itoa(stream.Metadata.Total))
+	if stream.Metadata.Continue != "" {
+		c.Header(HEADER_CONTINUE, stream.Metadata.Continue)
+	}
+	c.Status(http.StatusOK)
+
+	// the status is already sent, a failed export can only be recognized by the truncated file
+	if err := export.WriteEach(c.Writer, format, name, stream.Each, opts.Fields); err != nil {
+		zap.L().Error("could not export list", zap.String("path", c.Request.URL.Path), zap.String("format", format), zap.Error(err))
+	}
+}
+
+// exportName returns the name of the exported list, the last static segment of the route, e.g. pods or changes
+func exportName(c *gin.Context) string {
+	segments := strings.Split(c.FullPath(), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != "" && !strings.HasPrefix(segments[i], ":") {
+			return segments[i]
+		}
+	}
+
+	return "export"
+}
+
+// parseListOptions returns the pagination, sorting & field selection requested by the limit, continue, sort and fields query parameters
+func parseListOptions(c *gin.Context) (models.ListOptions, error) {
+	opts := models.ListOptions{Fields: models.ParseFields(c.Query("fields"))}
+
+	if c.Query("limit") != "" {
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 0 {
+			zap.L().Error("Could not parse value for limit", zap.String("query_limit", c.Query("limit")))
+			return opts, fmt.Errorf("%w: invalid limit: %s", models.ErrInvalidFilter, c.Query("limit"))
+		}
+		opts.Limit = limit
+	}
+
+	if c.Query("continue") != "" {
+		offset, err := models.DecodeContinue(c.Query("continue"))
+		if err != nil {
+			zap.L().Error("Could not parse value for continue", zap.String("query_continue", c.Query("continue")))
+			return opts, err
+		}
+		opts.Offset = offset
+	}
+
+	sort, err := models.ParseSort(c.Query("sort"))
+	if err != nil {
+		zap.L().Error("Could not parse value for sort", zap.String("query_sort", c.Query("sort")))
+		return opts, err
+	}
+	opts.Sort = sort
+
+	return opts, nil
+}
+
+// parseLabelSelector returns the selector requested by the labelSelector query parameter, e.g. "app in (a,b),tier!=db"
+func parseLabelSelector(c *gin.Context) (models.LabelSelector, error) {
+	selector, err := models.ParseLabelSelector(c.Query("labelSelector"))
+	if err != nil {
+		zap.L().Error("Could not parse value for labelSelector", zap.String("query_label_selector", c.Query("labelSelector")), zap.Error(err))
+		return models.LabelSelector{}, fmt.Errorf("%w: %s", models.ErrInvalidFilter, err.Error())
+	}
+
+	return selector, nil
+}
+
+// listWorkloads returns the requested page of the workloads matching the filters and the selector, from the history when a point in time is given
+func (a *API) listWorkloads(filters map[string]string, selector models.LabelSelector, at *time.Time, opts models.ListOptions) (*models.Stream[models.Workload], error) {
+	if at != nil {
+		collection, err := a.ds.GetWorkloadsMatchingAt(*at, filters, selector)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := models.PaginateList(collection.SortedList(models.WorkloadNameLess), opts)
+		if err != nil {
+			return nil, err
+		}
+		return models.StreamPage(page), nil
+	}
+
+	return a.ds.StreamWorkloads(filters, selector, opts)
+}
+
+// getWorkloadsBy loads the workloads matching the filters and the selector, from the history when a point in time is given
+func (a *API) getWorkloadsBy(filters map[string]string, selector models.LabelSelector, at *time.Time) (*models.WorkloadCollection, error) {
+	if at != nil {
+		return a.ds.GetWorkloadsMatchingAt(*at, filters, selector)
+	}
+
+	return a.ds.GetWorkloadsMatching(filters, selector)
+}
+
 func (a *API) GetNodes(c *gin.Context) {
-	collection, err := a.ds.GetAllNodes()
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	nodes := make([]models.Node, len(result))
-	for i := 0; i < len(result); i++ {
-		nodes[i] = result[i].(models.Node)
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	var stream *models.Stream[models.Node]
+	if at != nil {
+		var collection *models.NodeCollection
+		collection, err = a.ds.GetNodesAt(*at)
+		if err == nil {
+			collection = collection.Filter(func(item models.Node) bool {
+				return selector.Matches(item.Labels)
+			})
+			var page *models.Page[models.Node]
+			if page, err = models.PaginateList(collection.SortedList(models.NodeNameLess), opts); err == nil {
+				stream = models.StreamPage(page)
+			}
+		}
+	} else {
+		stream, err = a.ds.StreamNodes(selector, opts)
+	}
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
-	sort.Sort(models.ByNodeName(nodes))
-	a.Response(c, http.StatusOK, SUCCESS, nodes)
+
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetNamespaces(c *gin.Context) {
-	collection, err := a.ds.GetAllNamespaces()
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	namespaces := make([]models.Namespace, len(result))
-	for i := 0; i < len(result); i++ {
-		namespaces[i] = result[i].(models.Namespace)
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
-	sort.Sort(models.ByNamespaceName(namespaces))
 
-	a.Response(c, http.StatusOK, SUCCESS, namespaces)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	var stream *models.Stream[models.Namespace]
+	if at != nil {
+		var collection *models.NamespaceCollection
+		collection, err = a.ds.GetNamespacesAt(*at)
+		if err == nil {
+			collection = collection.Filter(func(item models.Namespace) bool {
+				return selector.Matches(item.Labels)
+			})
+			var page *models.Page[models.Namespace]
+			if page, err = models.PaginateList(collection.SortedList(models.NamespaceNameLess), opts); err == nil {
+				stream = models.StreamPage(page)
+			}
+		}
+	} else {
+		stream, err = a.ds.StreamNamespaces(selector, opts)
+	}
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetNamespace(c *gin.Context) {
+	a = a.scoped(c)
+
 	name := c.Param("name")
 	if name == "" {
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: name is required", models.ErrInvalidFilter))
 		return
 	}
 
-	namespace, err := a.ds.GetNamespace(name)
-	if err != nil && err.Error() == fmt.Sprintf("namespace %s could not be found", name) {
-		a.Response(c, http.StatusNotFound, NOT_FOUND, "namespace %s could not be found")
-		return
-	} else if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, "an internal server error occurred")
+	at, err := parseAt(c)
+	if err != nil {
+		a.Error(c, err)
 		return
 	}
 
-	workloadsCollection, err := a.ds.GetWorkloadsByNamespace(name)
+	var namespace *models.Namespace
+	if at != nil {
+		namespace, err = a.ds.GetNamespaceAt(name, *at)
+	} else {
+		namespace, err = a.ds.GetNamespace(name)
+	}
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, "an internal server error occurred")
+		a.Error(c, err)
 		return
 	}
 
-	eventsCollection, err := a.ka.GetEventsForNamespace(name)
+	workloadsCollection, err := a.getWorkloadsBy(map[string]string{"namespace": name}, models.LabelSelector{}, at)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, "an internal server error occurred")
+		a.Error(c, err)
 		return
 	}
 
-	// sorting workload result
-	workloadResult := workloadsCollection.ToList()
-	workloads := make([]models.Workload, len(workloadResult))
-	for i := 0; i < len(workloadResult); i++ {
-		workloads[i] = workloadResult[i].(models.Workload)
+	// events are not stored, they are only available for the current state
+	eventsCollection := models.NewCollection[string, models.Event]()
+	if at == nil {
+		eventsCollection, err = a.ka.GetEventsForNamespace(name)
+		if err != nil {
+			a.Error(c, err)
+			return
+		}
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
-
-	// casting event list
-	eventResult := eventsCollection.ToList()
-	events := make([]models.Event, len(eventResult))
-	for i := 0; i < len(eventResult); i++ {
-		events[i] = eventResult[i].(models.Event)
-	}
+	// sorting workload result
+	workloads := workloadsCollection.SortedList(models.WorkloadNameLess)
 
-	a.Response(c, http.StatusOK, SUCCESS, struct {
-		Namespace models.Namespace  `json:"namespace"`
-		Workloads []models.Workload `json:"workloads"`
-		Events    []models.Event    `json:"events"`
-	}{
+	a.Response(c, http.StatusOK, SUCCESS, models.NamespaceDetails{
 		Namespace: *namespace,
 		Workloads: workloads,
-		Events:    events,
+		Events:    eventsCollection.ToList(),
+		Warnings:  models.NamespaceWarnings(*namespace, workloads),
 	})
 }
 
 func (a *API) GetWorkloads(c *gin.Context) {
-	collection, err := a.ds.GetAllWorkloads()
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	stream, err := a.listWorkloads(map[string]string{}, selector, at, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetJobs(c *gin.Context) {
+	a = a.scoped(c)
+
+	// jobs are loaded from the kubernetes api and can't be requested for a point in time
+	if c.Query("at") != "" {
+		a.Error(c, fmt.Errorf("%w: jobs can not be requested for a point in time", models.ErrInvalidFilter))
+		return
+	}
+
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
 	collection, err := a.ka.GetJobs(c.Query("namespace"))
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
+	collection = collection.Filter(func(item models.Workload) bool {
+		return selector.Matches(item.GetLabels())
+	})
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	page, err := models.PaginateList(collection.SortedList(models.WorkloadNameLess), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	listResponse(a, c, page, opts)
 }
 
 func (a *API) GetCronjobs(c *gin.Context) {
+	a = a.scoped(c)
+
+	// jobs are loaded from the kubernetes api and can't be requested for a point in time
+	if c.Query("at") != "" {
+		a.Error(c, fmt.Errorf("%w: jobs can not be requested for a point in time", models.ErrInvalidFilter))
+		return
+	}
+
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
 	collection, err := a.ka.GetCronjobs(c.Query("namespace"))
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
+	collection = collection.Filter(func(item models.Workload) bool {
+		return selector.Matches(item.GetLabels())
+	})
+	collection = a.ka.AnalyzeCronjobs(collection, c.Query("namespace"), time.Now())
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	page, err := models.PaginateList(collection.SortedList(models.WorkloadNameLess), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	listResponse(a, c, page, opts)
 }
 
 func (a *API) GetDeployments(c *gin.Context) {
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
 	f := make(map[string]string)
 	f["workload_type"] = models.WORKLOAD_TYPE_DEPLOYMENT
 
@@ -194,28 +553,39 @@ func (a *API) GetDeployments(c *gin.Context) {
 	}
 
 	if c.Query("name") != "" {
-		f["name"] = c.Query("name")
+		f["workload_name"] = c.Query("name")
 	}
 
-	collection, err := a.ds.GetWorkloadsBy(f)
+	selector, err := parseLabelSelector(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
+	stream, err := a.listWorkloads(f, selector, at, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetPods(c *gin.Context) {
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
 	f := make(map[string]string)
 	f["workload_type"] = models.WORKLOAD_TYPE_POD
 
@@ -224,28 +594,33 @@ func (a *API) GetPods(c *gin.Context) {
 	}
 
 	if c.Query("name") != "" {
-		f["name"] = c.Query("name")
+		f["workload_name"] = c.Query("name")
 	}
 
-	collection, err := a.ds.GetWorkloadsBy(f)
+	selector, err := parseLabelSelector(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
+	stream, err := a.listWorkloads(f, selector, at, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetPod(c *gin.Context) {
+	a = a.scoped(c)
+
 	f := make(map[string]string)
 
 	f["workload_type"] = models.WORKLOAD_TYPE_POD
@@ -253,211 +628,506 @@ func (a *API) GetPod(c *gin.Context) {
 	if c.Param("namespace") != "" {
 		f["namespace"] = c.Param("namespace")
 	} else {
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: namespace is required", models.ErrInvalidFilter))
 		return
 	}
 
 	if c.Param("name") != "" {
 		f["workload_name"] = c.Param("name")
 	} else {
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: name is required", models.ErrInvalidFilter))
 		return
 	}
 
-	workload, err := a.ds.GetWorkloadBy(f)
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	rate := time.Minute * 5
-	if c.Query("rate") != "" {
-		rate, err = time.ParseDuration(c.Query("rate"))
-		if err != nil {
-			zap.L().Error("Could not parse value for rate", zap.String("query_rate", c.Query("rate")))
-			a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
-			return
-		}
+	var workload models.Workload
+	if at != nil {
+		workload, err = a.ds.GetWorkloadAt(*at, f)
+	} else {
+		workload, err = a.ds.GetWorkloadBy(f)
+	}
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	a.Response(c, http.StatusOK, SUCCESS, struct {
-		Workload models.Workload             `json:"workload"`
-		Metrics  []models.PodContainerMetric `json:"metrics"`
-	}{
+	from, to, err := parseTimeRange(c, at)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	rate, agg, err := parseReduceOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	a.Response(c, http.StatusOK, SUCCESS, models.PodDetails{
 		Workload: workload,
-		Metrics:  a.getPodMetrics(c.Param("namespace"), []models.Workload{workload}, rate),
+		Metrics:  a.getPodMetrics(c.Param("namespace"), []models.Workload{workload}, rate, agg, from, to),
 	})
 }
 
 func (a *API) GetWorkload(c *gin.Context) {
+	a = a.scoped(c)
+
 	f := make(map[string]string)
 
-	switch c.Param("workloadType") {
-	case "deployments":
-		f["workload_type"] = models.WORKLOAD_TYPE_DEPLOYMENT
-	case "statefulsets":
-		f["workload_type"] = models.WORKLOAD_TYPE_STATEFULSET
-	case "daemonsets":
-		f["workload_type"] = models.WORKLOAD_TYPE_DEAMONSET
-	case "jobs":
-		f["workload_type"] = models.WORKLOAD_TYPE_JOB
-	case "cronjobs":
-		f["workload_type"] = models.WORKLOAD_TYPE_CRONJOB
-	default:
+	workloadType, ok := workloadTypeFromParam(c.Param("workloadType"))
+	if !ok || workloadType == models.WORKLOAD_TYPE_POD {
 		zap.L().Error("invalid workload type passed!")
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: invalid workload type %s", models.ErrInvalidFilter, c.Param("workloadType")))
 		return
 	}
+	f["workload_type"] = workloadType
 
 	if c.Param("namespace") != "" {
 		f["namespace"] = c.Param("namespace")
 	} else {
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: namespace is required", models.ErrInvalidFilter))
 		return
 	}
 
 	if c.Param("name") != "" {
 		f["workload_name"] = c.Param("name")
 	} else {
-		a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+		a.Error(c, fmt.Errorf("%w: name is required", models.ErrInvalidFilter))
+		return
+	}
+
+	at, err := parseAt(c)
+	if err != nil {
+		a.Error(c, err)
 		return
 	}
 
 	var workload models.Workload
 
 	if f["workload_type"] != models.WORKLOAD_TYPE_JOB && f["workload_type"] != models.WORKLOAD_TYPE_CRONJOB {
-		w, err := a.ds.GetWorkloadBy(f)
+		var w models.Workload
+		if at != nil {
+			w, err = a.ds.GetWorkloadAt(*at, f)
+		} else {
+			w, err = a.ds.GetWorkloadBy(f)
+		}
 		if err != nil {
-			a.Response(c, http.StatusInternalServerError, ERROR, nil)
+			a.Error(c, err)
 			return
 		}
 		workload = w
 	} else {
+		// jobs are loaded from the kubernetes api and can't be requested for a point in time
+		if at != nil {
+			a.Error(c, fmt.Errorf("%w: jobs can not be requested for a point in time", models.ErrInvalidFilter))
+			return
+		}
+
 		w, err := a.ka.GetWorkloadBy(f)
 		if err != nil {
-			a.Response(c, http.StatusInternalServerError, ERROR, nil)
+			a.Error(c, err)
 			return
 		}
+		if cronjob, ok := w.(models.CronjobWorkload); ok {
+			w = a.ka.AnalyzeCronjob(cronjob, time.Now())
+		}
 		workload = w
 	}
 
-	podsCollection, err := a.ds.GetPodsForWorkload(workload)
+	var podsCollection *models.WorkloadCollection
+	if at != nil {
+		podsCollection, err = a.ds.GetPodsForWorkloadAt(workload, *at)
+	} else {
+		podsCollection, err = a.ds.GetPodsForWorkload(workload)
+	}
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
 	// sorting result
-	result := podsCollection.ToList()
-	pods := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		pods[i] = result[i].(models.Workload)
-	}
+	pods := podsCollection.SortedList(models.WorkloadNameLess)
 
-	sort.Sort(models.ByWorkloadName(pods))
+	from, to, err := parseTimeRange(c, at)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	rate := time.Minute * 5
-	if c.Query("rate") != "" {
-		rate, err = time.ParseDuration(c.Query("rate"))
-		if err != nil {
-			zap.L().Error("Could not parse value for rate", zap.String("query_rate", c.Query("rate")))
-			a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
-			return
-		}
+	rate, agg, err := parseReduceOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	a.Response(c, http.StatusOK, SUCCESS, struct {
-		Workload models.Workload             `json:"workload"`
-		Pods     []models.Workload           `json:"pods"`
-		Metrics  []models.PodContainerMetric `json:"metrics"`
-	}{
+	a.Response(c, http.StatusOK, SUCCESS, models.WorkloadDetails{
 		Workload: workload,
 		Pods:     pods,
-		Metrics:  a.getPodMetrics(c.Param("namespace"), pods, rate),
+		Metrics:  a.getPodMetrics(c.Param("namespace"), pods, rate, agg, from, to),
 	})
 }
 
-func (a *API) getPodMetrics(namespace string, pods []models.Workload, rate time.Duration) []models.PodContainerMetric {
-	result, err := a.ds.GetMetricsForPodsInNamespace(namespace, pods)
+func (a *API) GetWorkloadChanges(c *gin.Context) {
+	a = a.scoped(c)
+
+	workloadType, ok := workloadTypeFromParam(c.Param("workloadType"))
+	if !ok {
+		zap.L().Error("invalid workload type passed!")
+		a.Error(c, fmt.Errorf("%w: invalid workload type %s", models.ErrInvalidFilter, c.Param("workloadType")))
+		return
+	}
+
+	a.workloadChanges(c, workloadType)
+}
+
+func (a *API) GetPodChanges(c *gin.Context) {
+	a = a.scoped(c)
+
+	a.workloadChanges(c, models.WORKLOAD_TYPE_POD)
+}
+
+func (a *API) workloadChanges(c *gin.Context, workloadType string) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+	if namespace == "" || name == "" {
+		a.Error(c, fmt.Errorf("%w: namespace and name are required", models.ErrInvalidFilter))
+		return
+	}
+
+	opts, err := parseListOptions(c)
 	if err != nil {
-		zap.L().Error("could not load metrics for pods")
-		return make([]models.PodContainerMetric, 0)
+		a.Error(c, err)
+		return
 	}
 
-	list := result.ToList()
-	metrics := make([]models.PodContainerMetric, len(list))
-	for i := 0; i < len(list); i++ {
-		metrics[i] = list[i].(models.PodContainerMetric)
+	revisions, err := a.ds.GetWorkloadRevisions(namespace, workloadType, name)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByContainerMetricsTimestamp(metrics))
+	if len(revisions) == 0 {
+		a.Error(c, fmt.Errorf("workload %s/%s: %w", namespace, name, models.ErrNotFound))
+		return
+	}
 
-	return models.ReduceMetrics(metrics, rate)
+	changes, err := models.BuildWorkloadChanges(revisions)
+	if err != nil {
+		a.Error(c, fmt.Errorf("could not build workload changes: %w", err))
+		return
+	}
+
+	page, err := models.PaginateList(changes, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+// workloadTypeFromParam maps the workload type used in routes to the stored workload type
+func workloadTypeFromParam(param string) (string, bool) {
+	switch param {
+	case "deployments":
+		return models.WORKLOAD_TYPE_DEPLOYMENT, true
+	case "statefulsets":
+		return models.WORKLOAD_TYPE_STATEFULSET, true
+	case "daemonsets":
+		return models.WORKLOAD_TYPE_DEAMONSET, true
+	case "jobs":
+		return models.WORKLOAD_TYPE_JOB, true
+	case "cronjobs":
+		return models.WORKLOAD_TYPE_CRONJOB, true
+	case "pods":
+		return models.WORKLOAD_TYPE_POD, true
+	}
+
+	return "", false
+}
+
+func (a *API) getPodMetrics(namespace string, pods []models.Workload, rate time.Duration, agg string, from time.Time, to time.Time) []models.PodContainerMetric {
+	result, err := a.ds.GetMetricsForPodsInNamespace(namespace, pods, from, to)
+	if err != nil {
+		zap.L().Error("could not load metrics for pods")
+		return make([]models.PodContainerMetric, 0)
+	}
+
+	metrics := result.SortedList(models.MetricTimestampLess)
+
+	return models.ReduceMetrics(metrics, rate, agg)
 }
 
 func (a *API) GetStatefulSets(c *gin.Context) {
-	collection, err := a.ds.GetAllByWorkloadType(models.WORKLOAD_TYPE_STATEFULSET)
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	stream, err := a.listWorkloads(map[string]string{"workload_type": models.WORKLOAD_TYPE_STATEFULSET}, selector, at, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetDaemonSet(c *gin.Context) {
-	collection, err := a.ds.GetAllByWorkloadType(models.WORKLOAD_TYPE_DEAMONSET)
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
 		return
 	}
 
-	// sorting result
-	result := collection.ToList()
-	workloads := make([]models.Workload, len(result))
-	for i := 0; i < len(result); i++ {
-		workloads[i] = result[i].(models.Workload)
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByWorkloadName(workloads))
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	stream, err := a.listWorkloads(map[string]string{"workload_type": models.WORKLOAD_TYPE_DEAMONSET}, selector, at, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	a.Response(c, http.StatusOK, SUCCESS, workloads)
+	streamResponse(a, c, stream, opts)
 }
 
 func (a *API) GetContainerMetrics(c *gin.Context) {
-	collection, err := a.ds.GetAllMetrics()
+	a = a.scoped(c)
+
+	at, err := parseAt(c)
 	if err != nil {
-		a.Response(c, http.StatusInternalServerError, ERROR, nil)
+		a.Error(c, err)
+		return
+	}
+
+	from, to, err := parseTimeRange(c, at)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	collection, err := a.ds.GetMetrics(persistence.MetricsQuery{From: from, To: to})
+	if err != nil {
+		a.Error(c, err)
 		return
 	}
 
 	// sorting result
-	result := collection.ToList()
-	metrics := make([]models.PodContainerMetric, len(result))
-	for i := 0; i < len(result); i++ {
-		metrics[i] = result[i].(models.PodContainerMetric)
+	metrics := collection.SortedList(models.MetricTimestampLess)
+
+	rate, agg, err := parseReduceOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	sort.Sort(models.ByContainerMetricsTimestamp(metrics))
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
 
-	rate := time.Minute * 5
-	if c.Query("rate") != "" {
-		rate, err = time.ParseDuration(c.Query("rate"))
+	page, err := models.PaginateList(models.ReduceMetrics(metrics, rate, agg), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+// QueryMetrics returns the usage between from and to as aligned series, aggregated across the selected pods
+func (a *API) QueryMetrics(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseTimeRange(c, nil)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	// by default the time range is split up into 250 steps
+	step := (to.Sub(from) / 250).Truncate(time.Second)
+	if c.Query("step") != "" {
+		step, err = time.ParseDuration(c.Query("step"))
 		if err != nil {
-			zap.L().Error("Could not parse value for rate", zap.String("query_rate", c.Query("rate")))
-			a.Response(c, http.StatusBadRequest, BAD_REQUEST, nil)
+			zap.L().Error("Could not parse value for step", zap.String("query_step", c.Query("step")))
+			a.Error(c, fmt.Errorf("%w: invalid step: %s", models.ErrInvalidFilter, c.Query("step")))
+			return
+		}
+		step = step.Truncate(time.Second)
+	}
+	if step < time.Second*10 {
+		step = time.Second * 10
+	}
+
+	if int(to.Sub(from)/step) > persistence.MAX_METRIC_POINTS {
+		a.Error(c, fmt.Errorf("%w: the time range exceeds %d steps", models.ErrInvalidFilter, persistence.MAX_METRIC_POINTS))
+		return
+	}
+
+	aggregation := models.AGGREGATION_SUM
+	if c.Query("aggregation") != "" {
+		aggregation = c.Query("aggregation")
+	}
+	if !models.IsValidAggregation(aggregation) {
+		a.Error(c, fmt.Errorf("%w: invalid aggregation: %s", models.ErrInvalidFilter, aggregation))
+		return
+	}
+
+	query := persistence.MetricsQuery{
+		From:      from,
+		To:        to,
+		Namespace: c.Query("namespace"),
+		Container: c.Query("container"),
+	}
+
+	if c.Query("pod") != "" {
+		query.Pods = []string{c.Query("pod")}
+	} else if c.Query("workload") != "" {
+		pods, err := a.getPodNamesForWorkload(c.Query("namespace"), c.Query("workload_type"), c.Query("workload"))
+		if err != nil {
+			a.Error(c, err)
 			return
 		}
+
+		// a workload without pods has no usage
+		if len(pods) == 0 {
+			a.Response(c, http.StatusOK, SUCCESS, models.BuildMetricSeries(nil, from, to, step, aggregation))
+			return
+		}
+		query.Pods = pods
+	}
+
+	buckets, err := a.ds.GetMetricBuckets(query, step)
+	if err != nil {
+		a.Error(c, fmt.Errorf("could not query metrics: %w", err))
+		return
+	}
+
+	a.Response(c, http.StatusOK, SUCCESS, models.BuildMetricSeries(buckets, from, to, step, aggregation))
+}
+
+// getPodNamesForWorkload returns the names of the pods which currently belong to the workload
+func (a *API) getPodNamesForWorkload(namespace string, workloadTypeParam string, name string) ([]string, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("%w: namespace is required to filter by workload", models.ErrInvalidFilter)
+	}
+
+	workloadType, ok := workloadTypeFromParam(workloadTypeParam)
+	if !ok || workloadType == models.WORKLOAD_TYPE_POD {
+		return nil, fmt.Errorf("%w: invalid workload_type: %s", models.ErrInvalidFilter, workloadTypeParam)
+	}
+
+	f := map[string]string{
+		"namespace":     namespace,
+		"workload_type": workloadType,
+		"workload_name": name,
+	}
+
+	var workload models.Workload
+	var err error
+	if workloadType == models.WORKLOAD_TYPE_JOB || workloadType == models.WORKLOAD_TYPE_CRONJOB {
+		workload, err = a.ka.GetWorkloadBy(f)
+	} else {
+		workload, err = a.ds.GetWorkloadBy(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := a.ds.GetPodsForWorkload(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.MapCollection(collection, models.Workload.GetWorkloadName).ToList(), nil
+}
+
+// Search returns the nodes, namespaces and workloads matching all terms of the q query parameter
+func (a *API) Search(c *gin.Context) {
+	a = a.scoped(c)
+
+	if strings.TrimSpace(c.Query("q")) == "" {
+		a.Error(c, fmt.Errorf("%w: q is required", models.ErrInvalidFilter))
+		return
+	}
+
+	limit := 50
+	if c.Query("limit") != "" {
+		l, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || l < 1 {
+			zap.L().Error("Could not parse value for limit", zap.String("query_limit", c.Query("limit")))
+			a.Error(c, fmt.Errorf("%w: invalid limit: %s", models.ErrInvalidFilter, c.Query("limit")))
+			return
+		}
+		limit = l
+	}
+
+	results, err := a.ds.Search(c.Query("q"), limit)
+	if err != nil {
+		a.Error(c, fmt.Errorf("could not search: %w", err))
+		return
+	}
+
+	a.Response(c, http.StatusOK, SUCCESS, results)
+}
+
+// GetStatus returns the state of the synchronization with kubernetes, the ui shows it as a banner when kdd is not ready
+func (a *API) GetStatus(c *gin.Context) {
+	a.Response(c, http.StatusOK, SUCCESS, a.checker.Status(c.Request.Context()))
+}
+
+func (a *API) GetSnapshots(c *gin.Context) {
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	snapshots, err := a.ds.GetSnapshots()
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	page, err := models.PaginateList(snapshots, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
 	}
 
-	a.Response(c, http.StatusOK, SUCCESS, models.ReduceMetrics(metrics, rate))
+	listResponse(a, c, page, opts)
 }
@@ -0,0 +1,223 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+var test_created = time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+
+var test_costs = models.CostModel{Currency: "USD", Default: models.CostPrice{CPUHour: 0.03, MemoryGBHour: 0.004}}
+
+// newTestAPI returns the api backed by a temporary data store and a kubernetes api without objects, the data store is seeded by seed
+func newTestAPI(t *testing.T, broker *watch.Broker, seed func(ds *persistence.DataStore)) *API {
+	gin.SetMode(gin.TestMode)
+
+	ds, err := persistence.NewSQLiteDataStore(filepath.Join(t.TempDir(), "api.sqlite"), config.MetricsRetentionConfig{
+		Raw:         time.Hour * 24,
+		OneMinute:   time.Hour * 24 * 7,
+		FiveMinutes: time.Hour * 24 * 30,
+		OneHour:     time.Hour * 24 * 365,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	if seed != nil {
+		seed(ds)
+	}
+
+	kube := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"items":[]}`)
+	}))
+	t.Cleanup(kube.Close)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+
+	checker := health.NewChecker(&health.CheckerConfig{Ping: func(ctx context.Context) error { return nil }})
+
+	return NewAPI(ds, adapters.NewKubeAPIAdapter(&adapters.KubeAPIAdapterConfig{ClientSet: clientSet}), broker, checker, test_costs)
+}
+
+// newTestRouter returns a router serving the handler at the route
+func newTestRouter(route string, handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	r.GET(route, handler)
+
+	return r
+}
+
+// get serves the request of the url by the handler registered at the route
+func get(route string, handler gin.HandlerFunc, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newTestRouter(route, handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+	return w
+}
+
+// testWorkloadInfo returns the info of a workload in the default namespace with a single container requesting 100m cpu
+func testWorkloadInfo(name string, labels map[string]string) models.GeneralWorkloadInfo {
+	return models.GeneralWorkloadInfo{
+		WorkloadName:      name,
+		Namespace:         "default",
+		Labels:            labels,
+		Annotations:       map[string]string{},
+		Selector:          map[string]string{"app": "web"},
+		Containers:        []models.Container{{ContainerName: "web", Image: "nginx", ImageVersion: "1.23", RequestCPU: 100}},
+		CreationTimestamp: test_created,
+	}
+}
+
+func TestExport(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), func(ds *persistence.DataStore) {
+		nodes := models.NewCollection[string, models.Node]()
+		nodes.Set("node-1", models.Node{Name: "node-1", Labels: map[string]string{}, Annotations: map[string]string{}, CreationTimestamp: test_created}, true)
+		require.NoError(t, ds.ReplaceNodes(nodes))
+
+		workloads := models.NewCollection[string, models.Workload]()
+		workloads.Set("deployment_web_default", models.DeploymentWorkload{GeneralWorkloadInfo: testWorkloadInfo("web", map[string]string{"app": "web"})}, true)
+		for _, name := range []string{"web-1", "web-2"} {
+			workloads.Set("pod_"+name+"_default", models.PodWorkload{GeneralWorkloadInfo: testWorkloadInfo(name, map[string]string{"app": "web"}), Status: "Running", Restarts: 3}, true)
+		}
+		require.NoError(t, ds.ReplaceWorkloads(workloads))
+	})
+
+	tests := []struct {
+		route       string
+		handler     gin.HandlerFunc
+		url         string
+		accept      string
+		contentType string
+		body        string // the exported text, empty for binary formats
+		continued   bool   // the export is a page followed by another one
+	}{
+		{
+			route: "/api/v1/workloads", handler: api.GetWorkloads, url: "/api/v1/workloads?sort=type&fields=type,workload_info.workload_name", accept: "text/csv", contentType: "text/csv",
+			body: "type,workload_info.workload_name\nDeployment,web\nPod,web-1\nPod,web-2\n",
+		},
+		{
+			route: "/api/v1/workloads/pods", handler: api.GetPods, url: "/api/v1/workloads/pods?fields=workload_info.workload_name,restarts&format=csv&limit=1", contentType: "text/csv",
+			body: "workload_info.workload_name,restarts\nweb-1,3\n", continued: true,
+		},
+		{
+			route: "/api/v1/nodes", handler: api.GetNodes, url: "/api/v1/nodes?fields=name", accept: "application/x-ndjson", contentType: "application/x-ndjson",
+			body: "{\"name\":\"node-1\"}\n",
+		},
+		{
+			route: "/api/v1/nodes", handler: api.GetNodes, url: "/api/v1/nodes?format=xlsx", contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			newTestRouter(test.route, test.handler).ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, test.contentType, w.Header().Get("Content-Type"))
+			assert.NotEmpty(t, w.Header().Get(HEADER_TOTAL_COUNT))
+			assert.Equal(t, test.continued, w.Header().Get(HEADER_CONTINUE) != "")
+			assert.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment"))
+			if test.body != "" {
+				assert.Equal(t, test.body, w.Body.String())
+			}
+		})
+	}
+
+	w := get("/api/v1/workloads/pods", api.GetPods, "/api/v1/workloads/pods?format=csv")
+	assert.Equal(t, `attachment; filename="pods.csv"`, w.Header().Get("Content-Disposition"))
+}
+
+func TestNamespaceWarnings(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), func(ds *persistence.DataStore) {
+		namespaces := models.NewCollection[string, models.Namespace]()
+		namespaces.Set("default", models.Namespace{
+			Name:              "default",
+			Status:            "Active",
+			Labels:            map[string]string{},
+			Annotations:       map[string]string{},
+			CreationTimestamp: test_created,
+			ResourceQuotas: []models.ResourceQuota{{Name: "compute", Resources: []models.QuotaResource{
+				{Resource: "pods", Hard: "10", Used: "1", Utilization: 10},
+				{Resource: "requests.cpu", Hard: "400m", Used: "380m", Utilization: 95},
+			}}},
+			LimitRanges: []models.LimitRange{{Name: "defaults", Limits: []models.LimitRangeItem{
+				{Type: models.LIMIT_RANGE_TYPE_CONTAINER, Max: models.ResourceValues{CPU: 200}},
+			}}},
+		}, true)
+		require.NoError(t, ds.ReplaceNamespaces(namespaces))
+
+		workloads := models.NewCollection[string, models.Workload]()
+		workloads.Set("deployment_web_default", models.DeploymentWorkload{GeneralWorkloadInfo: testWorkloadInfo("web", map[string]string{"app": "web"})}, true)
+		workloads.Set("statefulset_db_default", models.StatefulSetWorkload{GeneralWorkloadInfo: testWorkloadInfo("db", map[string]string{"app": "db"})}, true)
+		workloads.Set("pod_web-1_default", models.PodWorkload{
+			GeneralWorkloadInfo: testWorkloadInfo("web-1", map[string]string{"app": "web"}),
+			Status:              "Running",
+			PodOwnerRessources:  []models.PodOwnerRessource{{APIVersion: "apps/v1", Kind: "ReplicaSet", UID: "1", Name: "web-5d4f"}},
+		}, true)
+		require.NoError(t, ds.ReplaceWorkloads(workloads))
+	})
+
+	w := get("/api/v1/namespaces/:name", api.GetNamespace, "/api/v1/namespaces/default")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var details struct {
+		Data struct {
+			Namespace models.Namespace          `json:"namespace"`
+			Warnings  []models.NamespaceWarning `json:"warnings"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	require.Len(t, details.Data.Namespace.ResourceQuotas, 1)
+	require.Len(t, details.Data.Namespace.LimitRanges, 1)
+
+	require.Len(t, details.Data.Warnings, 3, "the quota and the deployment and statefulset without a cpu limit, owned pods are skipped")
+	assert.Equal(t, models.NamespaceWarning{
+		Kind:     models.NAMESPACE_WARNING_QUOTA,
+		Name:     "compute",
+		Resource: "requests.cpu",
+		Message:  "requests.cpu used 380m of 400m (95%)",
+	}, details.Data.Warnings[0])
+	for _, warning := range details.Data.Warnings[1:] {
+		assert.Equal(t, models.NAMESPACE_WARNING_LIMIT_RANGE, warning.Kind)
+		assert.Equal(t, "cpu limit is not set, the limit range defaults it to 200m", warning.Message)
+	}
+}
+
+func TestQueryMetricsByWorkload(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), nil)
+
+	for _, url := range []string{
+		"/api/v1/metrics/query?workload=web&workload_type=deployments",
+		"/api/v1/metrics/query?namespace=default&workload=web&workload_type=unknown",
+		"/api/v1/metrics/query?namespace=default&workload=web-1&workload_type=pods",
+	} {
+		w := get("/api/v1/metrics/query", api.QueryMetrics, url)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		assert.Contains(t, w.Body.String(), `"reason":"invalid_filter"`, url)
+	}
+}
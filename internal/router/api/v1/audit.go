@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// DEFAULT_AUDIT_LIMIT is the page size of the audit log when no limit is requested
+const DEFAULT_AUDIT_LIMIT = 100
+
+// GetAuditLog lists the recorded api requests, newest first by default.
+// The log contains the requests of all principals, it is only readable by principals allowed to see all namespaces.
+func (a *API) GetAuditLog(c *gin.Context) {
+	if authz.GetScope(c).Restricted() {
+		a.Error(c, fmt.Errorf("audit log: %w", models.ErrForbidden))
+		return
+	}
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+	if c.Query("limit") == "" {
+		opts.Limit = DEFAULT_AUDIT_LIMIT
+	}
+	if len(opts.Sort) == 0 {
+		opts.Sort = []models.SortField{{Field: "timestamp", Desc: true}}
+	}
+
+	stream, err := a.ds.StreamAuditEntries(filter, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	streamResponse(a, c, stream, opts)
+}
+
+// parseAuditFilter returns the filter requested by the user, route, path, status, denied, from and to query parameters
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{User: c.Query("user"), Route: c.Query("route"), Path: c.Query("path")}
+
+	if c.Query("status") != "" {
+		status, err := strconv.Atoi(c.Query("status"))
+		if err != nil {
+			zap.L().Error("Could not parse value for status", zap.String("query_status", c.Query("status")))
+			return filter, fmt.Errorf("%w: invalid status: %s", models.ErrInvalidFilter, c.Query("status"))
+		}
+		filter.Status = status
+	}
+
+	if c.Query("denied") != "" {
+		denied, err := strconv.ParseBool(c.Query("denied"))
+		if err != nil {
+			zap.L().Error("Could not parse value for denied", zap.String("query_denied", c.Query("denied")))
+			return filter, fmt.Errorf("%w: invalid denied: %s", models.ErrInvalidFilter, c.Query("denied"))
+		}
+		filter.Denied = &denied
+	}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if c.Query(name) == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, c.Query(name))
+		if err != nil {
+			zap.L().Error("Could not parse value for "+name, zap.String("query_"+name, c.Query(name)))
+			return filter, fmt.Errorf("%w: invalid %s: %s", models.ErrInvalidFilter, name, c.Query(name))
+		}
+		*target = t
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// GetCosts lists the costs of the pods grouped by namespace, workload or the value of a label, within the last 7 days by default.
+// The idle cost of the cluster is included unless the costs are restricted to namespaces.
+func (a *API) GetCosts(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseTimeRange(c, nil)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	groupBy := models.COST_GROUP_NAMESPACE
+	if c.Query("groupBy") != "" {
+		groupBy = c.Query("groupBy")
+	}
+	if !models.IsValidCostGroupBy(groupBy) {
+		a.Error(c, fmt.Errorf("%w: invalid groupBy: %s", models.ErrInvalidFilter, groupBy))
+		return
+	}
+
+	basis := models.COST_BASIS_REQUESTS
+	if c.Query("basis") != "" {
+		basis = c.Query("basis")
+	}
+	if !models.IsValidCostBasis(basis) {
+		a.Error(c, fmt.Errorf("%w: invalid basis: %s", models.ErrInvalidFilter, basis))
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	pods, nodes, err := a.ds.GetAllocations(c.Query("namespace"), from, to)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	// the idle capacity of the nodes is shared by all namespaces
+	idle := c.Query("namespace") == "" && !authz.GetScope(c).Restricted()
+	page, err := models.PaginateList(models.AllocateCosts(a.costs, pods, nodes, groupBy, basis, idle), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
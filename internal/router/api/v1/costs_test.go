@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+func TestCosts(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), func(ds *persistence.DataStore) {
+		nodes := models.NewCollection[string, models.Node]()
+		nodes.Set("node-1", models.Node{Name: "node-1", Cpu: 4000, Memory: 8 * 1024 * 1024 * 1024, Labels: map[string]string{}, Annotations: map[string]string{}, CreationTimestamp: test_created}, true)
+		require.NoError(t, ds.ReplaceNodes(nodes))
+
+		workloads := models.NewCollection[string, models.Workload]()
+		workloads.Set("deployment_web_default", models.DeploymentWorkload{GeneralWorkloadInfo: testWorkloadInfo("web", map[string]string{"app": "web"})}, true)
+		workloads.Set("pod_web-1_default", models.PodWorkload{
+			GeneralWorkloadInfo: testWorkloadInfo("web-1", map[string]string{"app": "web"}),
+			Status:              "Running",
+			NodeName:            "node-1",
+			PodOwnerRessources:  []models.PodOwnerRessource{{APIVersion: "apps/v1", Kind: "ReplicaSet", UID: "1", Name: "web-5d4f"}},
+		}, true)
+		require.NoError(t, ds.ReplaceWorkloads(workloads))
+
+		// the second collection allocates the minute since the first one
+		allocated := time.Now().Truncate(time.Hour).Add(time.Minute)
+		require.NoError(t, ds.RecordAllocations(nodes, workloads, allocated))
+		require.NoError(t, ds.RecordAllocations(nodes, workloads, allocated.Add(time.Minute)))
+	})
+
+	w := get("/api/v1/costs", api.GetCosts, "/api/v1/costs?groupBy=workload")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var costs struct {
+		Data []models.CostAllocation `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &costs))
+	require.Len(t, costs.Data, 2)
+	assert.Equal(t, models.COST_IDLE, costs.Data[0].Name, "the unallocated capacity of the node is the most expensive")
+	assert.InDelta(t, 4.0/60-0.1/60, costs.Data[0].CPUCoreHours, 1e-9)
+	assert.InDelta(t, 8.0/60, costs.Data[0].MemoryGiBHours, 1e-9, "the pod doesn't request memory")
+
+	web := costs.Data[1]
+	assert.Equal(t, "default/"+models.WORKLOAD_TYPE_DEPLOYMENT+"/web", web.Name, "the pod is accounted to its deployment")
+	assert.InDelta(t, 0.1/60, web.CPUCoreHours, 1e-9, "100m requested for a minute")
+	assert.InDelta(t, 0.1/60*test_costs.Default.CPUHour, web.TotalCost, 1e-9)
+	assert.Equal(t, "USD", web.Currency)
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+const (
+	DEFAULT_CALENDAR_RANGE = time.Hour * 24      // the calendar ends this long after from when no to is requested
+	MAX_CALENDAR_RANGE     = time.Hour * 24 * 31 // longer ranges are rejected
+	MAX_CALENDAR_RUNS      = 1000                // the runs of a single cronjob are cut off after this number
+)
+
+// GetCronjobCalendar lists the planned runs of the cronjobs in the requested time range, sorted by time
+func (a *API) GetCronjobCalendar(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseCalendarRange(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	selector, err := parseLabelSelector(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	collection, err := a.ka.GetCronjobs(c.Query("namespace"))
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+	collection = collection.Filter(func(item models.Workload) bool {
+		return selector.Matches(item.GetLabels())
+	})
+
+	page, err := models.PaginateList(models.CronjobCalendar(collection.ToList(), from, to, MAX_CALENDAR_RUNS), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+// parseCalendarRange returns the time range requested by the from and to query parameters.
+// from defaults to now, to defaults to DEFAULT_CALENDAR_RANGE after from.
+func parseCalendarRange(c *gin.Context) (time.Time, time.Time, error) {
+	from := time.Now()
+	if c.Query("from") != "" {
+		t, err := time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			zap.L().Error("Could not parse value for from", zap.String("query_from", c.Query("from")))
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid from: %s", models.ErrInvalidFilter, c.Query("from"))
+		}
+		from = t
+	}
+
+	to := from.Add(DEFAULT_CALENDAR_RANGE)
+	if c.Query("to") != "" {
+		t, err := time.Parse(time.RFC3339, c.Query("to"))
+		if err != nil {
+			zap.L().Error("Could not parse value for to", zap.String("query_to", c.Query("to")))
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid to: %s", models.ErrInvalidFilter, c.Query("to"))
+		}
+		to = t
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be before to", models.ErrInvalidFilter)
+	}
+	if to.Sub(from) > MAX_CALENDAR_RANGE {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: the time range must not exceed %s", models.ErrInvalidFilter, MAX_CALENDAR_RANGE)
+	}
+
+	return from, to, nil
+}
@@ -1,17 +1,64 @@
 package v1
 
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
 const (
-	SUCCESS     = 200
-	ERROR       = 500
-	BAD_REQUEST = 400
-	NOT_FOUND   = 404
+	SUCCESS      = 200
+	ERROR        = 500
+	BAD_REQUEST  = 400
+	UNAUTHORIZED = 401
+	FORBIDDEN    = 403
+	NOT_FOUND    = 404
+	GONE         = 410
+	UNAVAILABLE  = 503
 )
 
 var MsgFlags = map[int]string{
-	SUCCESS:     "ok",
-	ERROR:       "fail",
-	BAD_REQUEST: "invalid parameters provided",
-	NOT_FOUND:   "resource could not be found",
+	SUCCESS:      "ok",
+	ERROR:        "fail",
+	BAD_REQUEST:  "invalid parameters provided",
+	UNAUTHORIZED: "authentication required",
+	FORBIDDEN:    "access to the resource is forbidden",
+	NOT_FOUND:    "resource could not be found",
+	GONE:         "requested position is not available anymore",
+	UNAVAILABLE:  "upstream service is unavailable",
+}
+
+// machine readable reasons of the error codes
+var reasons = map[int]string{
+	ERROR:        "internal_error",
+	BAD_REQUEST:  "invalid_filter",
+	UNAUTHORIZED: "unauthorized",
+	FORBIDDEN:    "forbidden",
+	NOT_FOUND:    "not_found",
+	GONE:         "expired",
+	UNAVAILABLE:  "unavailable",
+}
+
+// error_mappings maps the model errors to the error codes, the error code equals the http status
+var error_mappings = []struct {
+	err  error
+	code int
+}{
+	{err: models.ErrNotFound, code: NOT_FOUND},
+	{err: models.ErrUnauthorized, code: UNAUTHORIZED},
+	{err: models.ErrForbidden, code: FORBIDDEN},
+	{err: models.ErrUnavailable, code: UNAVAILABLE},
+	{err: models.ErrInvalidFilter, code: BAD_REQUEST},
+	{err: models.ErrExpired, code: GONE},
+}
+
+// ErrorDetails - describes the error of a failed request
+type ErrorDetails struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
 }
 
 func GetErrorMsg(code int) string {
@@ -22,3 +69,49 @@ func GetErrorMsg(code int) string {
 
 	return MsgFlags[ERROR]
 }
+
+// ErrorCode returns the error code of the error, unknown errors are internal errors
+func ErrorCode(err error) int {
+	for _, mapping := range error_mappings {
+		if errors.Is(err, mapping.err) {
+			return mapping.code
+		}
+	}
+
+	return ERROR
+}
+
+// ErrorMiddleware responds to the last error added to the context if the handler did not respond yet
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		AbortWithError(c, c.Errors.Last().Err)
+	}
+}
+
+// AbortWithError responds with the error and stops the handler chain, used by middlewares outside of the api group
+func AbortWithError(c *gin.Context, err error) {
+	code := ErrorCode(err)
+	details := err.Error()
+	if code == ERROR {
+		zap.L().Error("request failed", zap.String("path", c.Request.URL.Path), zap.Error(err))
+		// internal errors are not exposed
+		details = "an internal server error occurred"
+	}
+
+	c.AbortWithStatusJSON(code, Response{
+		Code:  code,
+		Msg:   GetErrorMsg(code),
+		Error: &ErrorDetails{Reason: reasons[code], Details: details},
+	})
+}
+
+// Error adds the error to the context, the response is written by the ErrorMiddleware
+func (a *API) Error(c *gin.Context, err error) {
+	_ = c.Error(err)
+}
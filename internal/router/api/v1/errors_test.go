@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err     error
+		status  int
+		reason  string
+		details string
+	}{
+		{err: fmt.Errorf("namespace default: %w", models.ErrNotFound), status: http.StatusNotFound, reason: "not_found", details: "namespace default: not found"},
+		{err: fmt.Errorf("%w: pods is forbidden", models.ErrForbidden), status: http.StatusForbidden, reason: "forbidden", details: "forbidden: pods is forbidden"},
+		{err: fmt.Errorf("%w: connection refused", models.ErrUnavailable), status: http.StatusServiceUnavailable, reason: "unavailable", details: "upstream unavailable: connection refused"},
+		{err: fmt.Errorf("%w: unsupported filter x", models.ErrInvalidFilter), status: http.StatusBadRequest, reason: "invalid_filter", details: "invalid filter: unsupported filter x"},
+		{err: fmt.Errorf("%w: resume token", models.ErrExpired), status: http.StatusGone, reason: "expired", details: "expired: resume token"},
+		{err: fmt.Errorf("%w: invalid token", models.ErrUnauthorized), status: http.StatusUnauthorized, reason: "unauthorized", details: "unauthorized: invalid token"},
+		{err: errors.New("database is locked"), status: http.StatusInternalServerError, reason: "internal_error", details: "an internal server error occurred"},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(ErrorMiddleware())
+		r.GET("/", func(c *gin.Context) { (&API{}).Error(c, tt.err) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var response Response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, tt.status, w.Code)
+		assert.Equal(t, tt.status, response.Code)
+		if assert.NotNil(t, response.Error) {
+			assert.Equal(t, tt.reason, response.Error.Reason)
+			assert.Equal(t, tt.details, response.Error.Details)
+		}
+	}
+}
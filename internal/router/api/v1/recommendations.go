@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+const MAX_RECOMMENDATION_HEADROOM = 1000.0 // percent, higher headrooms are rejected
+
+// GetRecommendations lists the right-sizing recommendations of the containers per workload, based on the usage of the last 7 days by default
+func (a *API) GetRecommendations(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseTimeRange(c, nil)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	headroom, err := parseHeadroom(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	workloadType := ""
+	if c.Query("workload_type") != "" {
+		t, ok := workloadTypeFromParam(c.Query("workload_type"))
+		if !ok || t == models.WORKLOAD_TYPE_JOB || t == models.WORKLOAD_TYPE_CRONJOB {
+			a.Error(c, fmt.Errorf("%w: invalid workload_type: %s", models.ErrInvalidFilter, c.Query("workload_type")))
+			return
+		}
+		workloadType = t
+	}
+
+	classification := c.Query("classification")
+	if classification != "" && !models.IsValidRecommendationClassification(classification) {
+		a.Error(c, fmt.Errorf("%w: invalid classification: %s", models.ErrInvalidFilter, classification))
+		return
+	}
+
+	patch := false
+	if c.Query("patch") != "" {
+		if patch, err = strconv.ParseBool(c.Query("patch")); err != nil {
+			a.Error(c, fmt.Errorf("%w: invalid patch: %s", models.ErrInvalidFilter, c.Query("patch")))
+			return
+		}
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	recommendations, err := a.ds.GetRecommendations(c.Query("namespace"), workloadType, from, to, headroom)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	result := make([]models.WorkloadRecommendation, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		if classification != "" && recommendation.Classification != classification {
+			continue
+		}
+
+		if patch {
+			if recommendation.Patch, err = models.RecommendationPatch(recommendation); err != nil {
+				a.Error(c, err)
+				return
+			}
+		}
+		result = append(result, recommendation)
+	}
+
+	page, err := models.PaginateList(result, opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+// parseHeadroom returns the headroom in percent added to the usage, requested by the headroom query parameter
+func parseHeadroom(c *gin.Context) (float64, error) {
+	if c.Query("headroom") == "" {
+		return models.DEFAULT_RECOMMENDATION_HEADROOM, nil
+	}
+
+	headroom, err := strconv.ParseFloat(c.Query("headroom"), 64)
+	if err != nil || headroom < 0 || headroom > MAX_RECOMMENDATION_HEADROOM {
+		zap.L().Error("Could not parse value for headroom", zap.String("query_headroom", c.Query("headroom")))
+		return 0, fmt.Errorf("%w: invalid headroom: %s", models.ErrInvalidFilter, c.Query("headroom"))
+	}
+
+	return headroom, nil
+}
@@ -0,0 +1,190 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+const (
+	DEFAULT_RESTARTS_RANGE = time.Hour * 24 // the restarts are evaluated for this long before to when no from is requested
+	DEFAULT_TOP_RESTARTERS = 10             // page size of the top restarters when no limit is requested
+)
+
+// GetTopRestarters lists the workloads with the most container restarts, within the last 24 hours by default
+func (a *API) GetTopRestarters(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseRestartsRange(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+	if c.Query("limit") == "" {
+		opts.Limit = DEFAULT_TOP_RESTARTERS
+	}
+
+	restarts, err := a.ds.ListContainerRestarts(models.RestartFilter{Namespace: c.Query("namespace"), From: from, To: to})
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	page, err := models.PaginateList(models.AggregateRestarts(restarts, from, to), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+// GetCrashLoops lists the periods in which containers restarted repeatedly, within the last 24 hours by default
+func (a *API) GetCrashLoops(c *gin.Context) {
+	a = a.scoped(c)
+
+	from, to, err := parseRestartsRange(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	gap, threshold, err := parseCrashLoopOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	restarts, err := a.ds.ListContainerRestarts(models.RestartFilter{Namespace: c.Query("namespace"), From: from, To: to})
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	page, err := models.PaginateList(models.DetectCrashLoops(restarts, gap, threshold), opts)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	listResponse(a, c, page, opts)
+}
+
+func (a *API) GetWorkloadRestarts(c *gin.Context) {
+	a = a.scoped(c)
+
+	workloadType, ok := workloadTypeFromParam(c.Param("workloadType"))
+	if !ok {
+		zap.L().Error("invalid workload type passed!")
+		a.Error(c, fmt.Errorf("%w: invalid workload type %s", models.ErrInvalidFilter, c.Param("workloadType")))
+		return
+	}
+
+	a.workloadRestarts(c, workloadType)
+}
+
+func (a *API) GetPodRestarts(c *gin.Context) {
+	a = a.scoped(c)
+
+	a.workloadRestarts(c, models.WORKLOAD_TYPE_POD)
+}
+
+// workloadRestarts responds with the restart history of a workload, its rate and crash loops
+func (a *API) workloadRestarts(c *gin.Context, workloadType string) {
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+	if namespace == "" || name == "" {
+		a.Error(c, fmt.Errorf("%w: namespace and name are required", models.ErrInvalidFilter))
+		return
+	}
+
+	from, to, err := parseRestartsRange(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	gap, threshold, err := parseCrashLoopOptions(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	filter := models.RestartFilter{Namespace: namespace, From: from, To: to}
+	if workloadType == models.WORKLOAD_TYPE_POD {
+		filter.PodName = name
+	} else {
+		filter.WorkloadType = workloadType
+		filter.WorkloadName = name
+	}
+
+	restarts, err := a.ds.ListContainerRestarts(filter)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	a.Response(c, http.StatusOK, SUCCESS, models.WorkloadRestartHistory{
+		Summary:    models.SummarizeRestarts(namespace, workloadType, name, restarts, from, to),
+		Restarts:   restarts,
+		CrashLoops: models.DetectCrashLoops(restarts, gap, threshold),
+	})
+}
+
+// parseRestartsRange returns the time range requested by the from and to query parameters, from defaults to DEFAULT_RESTARTS_RANGE before to
+func parseRestartsRange(c *gin.Context) (time.Time, time.Time, error) {
+	from, to, err := parseTimeRange(c, nil)
+	if err != nil {
+		return from, to, err
+	}
+
+	if c.Query("from") == "" {
+		from = to.Add(-DEFAULT_RESTARTS_RANGE)
+	}
+
+	return from, to, nil
+}
+
+// parseCrashLoopOptions returns the maximum pause between the restarts of a crash loop and the minimum number of restarts,
+// requested by the gap and threshold query parameters
+func parseCrashLoopOptions(c *gin.Context) (time.Duration, int, error) {
+	gap := models.DEFAULT_CRASHLOOP_GAP
+	if c.Query("gap") != "" {
+		g, err := time.ParseDuration(c.Query("gap"))
+		if err != nil || g <= 0 {
+			zap.L().Error("Could not parse value for gap", zap.String("query_gap", c.Query("gap")))
+			return 0, 0, fmt.Errorf("%w: invalid gap: %s", models.ErrInvalidFilter, c.Query("gap"))
+		}
+		gap = g
+	}
+
+	threshold := models.DEFAULT_CRASHLOOP_THRESHOLD
+	if c.Query("threshold") != "" {
+		t, err := strconv.Atoi(c.Query("threshold"))
+		if err != nil || t <= 0 {
+			zap.L().Error("Could not parse value for threshold", zap.String("query_threshold", c.Query("threshold")))
+			return 0, 0, fmt.Errorf("%w: invalid threshold: %s", models.ErrInvalidFilter, c.Query("threshold"))
+		}
+		threshold = t
+	}
+
+	return gap, threshold, nil
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+// seedRestarts stores two collections, the pod of the web deployment restarted 3 times and the pod of the nightly cronjob once in between
+func seedRestarts(t *testing.T) func(ds *persistence.DataStore) {
+	return func(ds *persistence.DataStore) {
+		replicaSet := models.PodOwnerRessource{APIVersion: "apps/v1", Kind: "ReplicaSet", UID: "1", Name: "web-5d4f"}
+		job := models.PodOwnerRessource{APIVersion: "batch/v1", Kind: "Job", UID: "2", Name: "nightly-28000000",
+			Owner: &models.PodOwnerRessource{APIVersion: "batch/v1", Kind: "CronJob", UID: "3", Name: "nightly"}}
+
+		collect := func(web int, nightly int) {
+			webInfo := testWorkloadInfo("web-1", map[string]string{"app": "web"})
+			webInfo.Containers[0].Restarts = web
+			if web > 0 {
+				webInfo.Containers[0].LastTermination = &models.ContainerTermination{Reason: "OOMKilled", ExitCode: 137, FinishedAt: time.Now().Add(-time.Minute).Truncate(time.Second)}
+			}
+			nightlyInfo := testWorkloadInfo("nightly-28000000-abcde", map[string]string{"job-name": "nightly-28000000"})
+			nightlyInfo.Containers[0].Restarts = nightly
+
+			workloads := models.NewCollection[string, models.Workload]()
+			workloads.Set("deployment_web_default", models.DeploymentWorkload{GeneralWorkloadInfo: testWorkloadInfo("web", map[string]string{"app": "web"})}, true)
+			workloads.Set("pod_web-1_default", models.PodWorkload{GeneralWorkloadInfo: webInfo, Status: "Running", Restarts: web, PodOwnerRessources: []models.PodOwnerRessource{replicaSet}}, true)
+			workloads.Set("pod_nightly-28000000-abcde_default", models.PodWorkload{GeneralWorkloadInfo: nightlyInfo, Status: "Running", Restarts: nightly, PodOwnerRessources: []models.PodOwnerRessource{job}}, true)
+			require.NoError(t, ds.ReplaceWorkloads(workloads))
+		}
+		collect(0, 0)
+		collect(3, 1)
+	}
+}
+
+func TestRestarts(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), seedRestarts(t))
+
+	w := get("/api/v1/restarts/top", api.GetTopRestarters, "/api/v1/restarts/top")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var top struct {
+		Data []models.WorkloadRestarts `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &top))
+	require.Len(t, top.Data, 2, "the first collection is the baseline")
+	assert.Equal(t, models.WORKLOAD_TYPE_DEPLOYMENT, top.Data[0].WorkloadType, "the restarts of the pod are accounted to its deployment")
+	assert.Equal(t, "web", top.Data[0].WorkloadName)
+	assert.Equal(t, 3, top.Data[0].Restarts)
+	assert.Equal(t, map[string]int{"OOMKilled": 3}, top.Data[0].Reasons)
+
+	w = get("/api/v1/workloads/pods/:namespace/:name/restarts", api.GetPodRestarts, "/api/v1/workloads/pods/default/web-1/restarts?threshold=3")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var history struct {
+		Data models.WorkloadRestartHistory `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Data.Restarts, 1)
+	assert.Equal(t, int32(137), history.Data.Restarts[0].ExitCode)
+	assert.Equal(t, 3, history.Data.Summary.Restarts)
+	assert.Len(t, history.Data.CrashLoops, 1, "the increase by 3 within one collection is a crash loop")
+}
+
+func TestCronjobRestarts(t *testing.T) {
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), seedRestarts(t))
+
+	w := get("/api/v1/workloads/:workloadType/:namespace/:name/restarts", api.GetWorkloadRestarts, "/api/v1/workloads/cronjobs/default/nightly/restarts")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var history struct {
+		Data models.WorkloadRestartHistory `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Data.Restarts, 1, "the restarts of the pod are accounted to the cronjob of its job")
+	assert.Equal(t, models.WORKLOAD_TYPE_CRONJOB, history.Data.Restarts[0].WorkloadType)
+	assert.Equal(t, "nightly", history.Data.Restarts[0].WorkloadName)
+	assert.Equal(t, 1, history.Data.Summary.Restarts)
+}
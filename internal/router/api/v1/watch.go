@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+/**
+	The watch api streams the changes of the stored objects as server-sent events or over a websocket.
+	Streams outlive the write timeout of the server, the deadline is extended before every write instead.
+**/
+
+// time a single write to a watching client may take before the stream is closed
+const watch_write_timeout = time.Second * 30
+
+type connContextKey struct{}
+
+// ConnContext stores the connection in the context of its requests, used as http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// extendWriteDeadline extends the write deadline of the connection of the request
+func extendWriteDeadline(ctx context.Context) {
+	if conn, ok := ctx.Value(connContextKey{}).(net.Conn); ok {
+		if err := conn.SetWriteDeadline(time.Now().Add(watch_write_timeout)); err != nil {
+			zap.L().Debug("could not extend write deadline", zap.Error(err))
+		}
+	}
+}
+
+// parseWatchFilter returns the filter requested by the kinds and namespace query parameters
+func parseWatchFilter(c *gin.Context) (watch.Filter, error) {
+	filter := watch.Filter{Namespace: c.Query("namespace")}
+	for _, kind := range models.ParseFields(c.Query("kinds")) {
+		if !models.IsValidWatchKind(kind) {
+			zap.L().Error("Invalid value for kinds", zap.String("query_kinds", c.Query("kinds")))
+			return filter, fmt.Errorf("%w: invalid kind %s, supported kinds: %s", models.ErrInvalidFilter, kind, strings.Join(models.WATCH_KINDS, ", "))
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+
+	return filter, nil
+}
+
+// Watch streams the changes of the stored objects, a websocket is used when the client requests an upgrade.
+// The stream continues after the event of the resume query parameter or the Last-Event-ID header.
+func (a *API) Watch(c *gin.Context) {
+	filter, err := parseWatchFilter(c)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	filter.Scope = authz.GetScope(c)
+	if filter.Namespace != "" {
+		if err := filter.Scope.Check(filter.Namespace); err != nil {
+			a.Error(c, err)
+			return
+		}
+	}
+
+	resume := c.Query("resume")
+	if resume == "" {
+		resume = c.GetHeader("Last-Event-ID")
+	}
+
+	subscription, err := a.broker.Subscribe(filter, resume)
+	if err != nil {
+		a.Error(c, err)
+		return
+	}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		a.watchWebsocket(c, subscription)
+		return
+	}
+
+	a.watchEvents(c, subscription)
+}
+
+// watchEvents writes the stream as server-sent events, heartbeats are sent as comments
+func (a *API) watchEvents(c *gin.Context, subscription *watch.Subscription) {
+	ctx := c.Request.Context()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// disables the response buffering of nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	write := func(message string) error {
+		extendWriteDeadline(ctx)
+		if _, err := io.WriteString(c.Writer, message); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	if err := write(": watching\n\n"); err != nil {
+		return
+	}
+
+	a.stream(ctx, subscription, func(event models.WatchEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if event.ID != "" {
+			return write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data))
+		}
+		return write(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))
+	}, func() error {
+		return write(": heartbeat\n\n")
+	})
+}
+
+// watchWebsocket writes the stream as json messages over a websocket, heartbeats carry the current resume token
+func (a *API) watchWebsocket(c *gin.Context, subscription *watch.Subscription) {
+	server := websocket.Server{
+		Handshake: checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+
+			// the connection is hijacked, a closed websocket is only noticed by reading
+			go func() {
+				_, _ = io.Copy(io.Discard, ws)
+				cancel()
+			}()
+
+			send := func(event models.WatchEvent) error {
+				if err := ws.SetWriteDeadline(time.Now().Add(watch_write_timeout)); err != nil {
+					return err
+				}
+				return websocket.JSON.Send(ws, event)
+			}
+
+			a.stream(ctx, subscription, send, func() error {
+				return send(models.WatchEvent{ID: subscription.Token(), Type: models.WATCH_HEARTBEAT, Timestamp: time.Now()})
+			})
+		},
+	}
+
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkOrigin rejects a websocket authenticated by the session cookie unless it is opened by a page of kdd itself,
+// the browser sends the cookie along with a websocket opened by any same-site page.
+// Clients sending their token in a header are not restricted to an origin, a page can't set the headers of a websocket.
+func checkOrigin(_ *websocket.Config, r *http.Request) error {
+	if _, err := r.Cookie(auth.SESSION_COOKIE); err != nil {
+		return nil
+	}
+
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil || origin.Host != r.Host {
+		return fmt.Errorf("%w: websocket of origin %q", models.ErrForbidden, r.Header.Get("Origin"))
+	}
+
+	return nil
+}
+
+// stream sends the events of the subscription until the client disconnects, a heartbeat is sent when no event was sent within the interval
+func (a *API) stream(ctx context.Context, subscription *watch.Subscription, send func(models.WatchEvent) error, heartbeat func() error) {
+	for {
+		next, cancel := context.WithTimeout(ctx, a.broker.Heartbeat())
+		events, err := subscription.Next(next)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			err = heartbeat()
+		case errors.Is(err, models.ErrExpired):
+			// the client needs to reload the lists and watch again without a resume token
+			_ = send(models.WatchEvent{
+				Type:      models.WATCH_EXPIRED,
+				Object:    ErrorDetails{Reason: reasons[GONE], Details: err.Error()},
+				Timestamp: time.Now(),
+			})
+			return
+		case err == nil:
+			for _, event := range events {
+				if err = send(event); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			zap.L().Debug("closing watch stream", zap.Error(err))
+			return
+		}
+	}
+}
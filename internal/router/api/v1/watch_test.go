@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/openapi"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+)
+
+// readEvent reads the next server-sent event, comments are skipped
+func readEvent(t *testing.T, reader *bufio.Reader) (id string, event string, data string) {
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && data != "":
+			return id, event, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	broker := watch.NewBroker(&watch.BrokerConfig{})
+	api := newTestAPI(t, broker, nil)
+	server := httptest.NewServer(newTestRouter("/api/v1/watch", api.Watch))
+	t.Cleanup(server.Close)
+
+	spec := openapi.Spec()
+	schema := openapi.Ref("WatchEvent")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/watch?kinds=workload&namespace=default", nil)
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	broker.Publish([]models.WatchEvent{
+		{Type: models.WATCH_ADDED, Kind: models.WATCH_KIND_NODE, Key: "node-1", Object: models.Node{Name: "node-1"}, Timestamp: time.Now()},
+		{Type: models.WATCH_DELETED, Kind: models.WATCH_KIND_WORKLOAD, Key: "pods/default/web-1", Namespace: "default", Timestamp: time.Now()},
+		{Type: models.WATCH_DELETED, Kind: models.WATCH_KIND_WORKLOAD, Key: "pods/default/web-2", Namespace: "default", Timestamp: time.Now()},
+	})
+
+	reader := bufio.NewReader(response.Body)
+	id, event, data := readEvent(t, reader)
+	assert.Equal(t, models.WATCH_DELETED, event, "the node is not watched")
+	assert.NoError(t, spec.ValidateJSON(schema, []byte(data)), data)
+	assert.Contains(t, data, "pods/default/web-1")
+
+	// the websocket continues after the last received event
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/watch?resume="+id, server.URL)
+	require.NoError(t, err)
+	ws, err := websocket.DialConfig(config)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(time.Second*5)))
+
+	var message models.WatchEvent
+	require.NoError(t, websocket.JSON.Receive(ws, &message))
+	assert.Equal(t, "pods/default/web-2", message.Key)
+
+	_, _, data = readEvent(t, reader)
+	assert.Contains(t, data, "pods/default/web-2")
+}
+
+func TestWatchWebsocketOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		session bool
+		ok      bool
+	}{
+		{origin: "http://kdd.example.com", session: true, ok: true},
+		{origin: "http://evil.example.com", session: true, ok: false},
+		{origin: "", session: true, ok: false},
+		// a page can't set the token header of a websocket
+		{origin: "http://evil.example.com", session: false, ok: true},
+		{origin: "", session: false, ok: true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s session %t", test.origin, test.session), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://kdd.example.com/api/v1/watch", nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			if test.session {
+				r.AddCookie(&http.Cookie{Name: auth.SESSION_COOKIE, Value: "session"})
+			}
+
+			err := checkOrigin(nil, r)
+			if test.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, models.ErrForbidden)
+			}
+		})
+	}
+
+	// the handshake of the websocket is rejected
+	api := newTestAPI(t, watch.NewBroker(&watch.BrokerConfig{}), nil)
+	server := httptest.NewServer(newTestRouter("/api/v1/watch", api.Watch))
+	t.Cleanup(server.Close)
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/watch", "http://evil.example.com")
+	require.NoError(t, err)
+	config.Header.Set("Cookie", auth.SESSION_COOKIE+"=session")
+	_, err = websocket.DialConfig(config)
+	assert.Error(t, err)
+}
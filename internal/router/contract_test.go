@@ -0,0 +1,482 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/config"
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/openapi"
+	"gitlab.com/patrick.erber/kdd/internal/persistence"
+	v1 "gitlab.com/patrick.erber/kdd/internal/router/api/v1"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
+	"gitlab.com/patrick.erber/kdd/pkg/client"
+)
+
+var contract_created = time.Date(2023, 1, 22, 10, 0, 0, 0, time.UTC)
+
+var contract_costs = models.CostModel{Currency: "USD", Default: models.CostPrice{CPUHour: 0.03, MemoryGBHour: 0.004}}
+
+// newContractRouter returns the api routes backed by a seeded data store and a stubbed kubernetes api, the middlewares run before the api
+func newContractRouter(t *testing.T, broker *watch.Broker, middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	ds, err := persistence.NewSQLiteDataStore(filepath.Join(t.TempDir(), "contract.sqlite"), config.MetricsRetentionConfig{
+		Raw:         time.Hour * 24,
+		OneMinute:   time.Hour * 24 * 7,
+		FiveMinutes: time.Hour * 24 * 30,
+		OneHour:     time.Hour * 24 * 365,
+	})
+	require.NoError(t, err)
+	t.Cleanup(ds.CloseConnections)
+
+	seedDataStore(t, ds)
+
+	kube := httptest.NewServer(http.HandlerFunc(stubKubeAPI))
+	t.Cleanup(kube.Close)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: kube.URL})
+	require.NoError(t, err)
+
+	checker := health.NewChecker(&health.CheckerConfig{Kinds: []string{"node"}, Ping: ds.Ping})
+	checker.Collected("node", nil)
+	checker.SyncFinished(nil)
+
+	r := gin.New()
+	r.Use(middlewares...)
+	RegisterAPIv1(r, ds, adapters.NewKubeAPIAdapter(&adapters.KubeAPIAdapterConfig{ClientSet: clientSet}), broker, checker, contract_costs)
+
+	return r
+}
+
+func seedDataStore(t *testing.T, ds *persistence.DataStore) {
+	nodes := models.NewCollection[string, models.Node]()
+	nodes.Set("node-1", models.Node{
+		Name:              "node-1",
+		Status:            "Ready",
+		Cpu:               4000,
+		Memory:            8 * 1024 * 1024 * 1024,
+		OsImage:           "Ubuntu 22.04",
+		KubeletVersion:    "v1.26.0",
+		Roles:             "control-plane",
+		Labels:            map[string]string{"kubernetes.io/hostname": "node-1"},
+		Annotations:       map[string]string{},
+		CreationTimestamp: contract_created,
+	}, true)
+	require.NoError(t, ds.ReplaceNodes(nodes))
+
+	namespaces := models.NewCollection[string, models.Namespace]()
+	namespaces.Set("default", models.Namespace{
+		Name:              "default",
+		Status:            "Active",
+		Labels:            map[string]string{"team": "core"},
+		Annotations:       map[string]string{},
+		CreationTimestamp: contract_created,
+		ResourceQuotas: []models.ResourceQuota{{Name: "compute", Resources: []models.QuotaResource{
+			{Resource: "pods", Hard: "10", Used: "1", Utilization: 10},
+			{Resource: "requests.cpu", Hard: "400m", Used: "380m", Utilization: 95},
+		}}},
+		LimitRanges: []models.LimitRange{{Name: "defaults", Limits: []models.LimitRangeItem{
+			{Type: models.LIMIT_RANGE_TYPE_CONTAINER, Max: models.ResourceValues{CPU: 200}},
+		}}},
+	}, true)
+	require.NoError(t, ds.ReplaceNamespaces(namespaces))
+
+	info := func(name string, labels map[string]string) models.GeneralWorkloadInfo {
+		return models.GeneralWorkloadInfo{
+			WorkloadName:      name,
+			Namespace:         "default",
+			Labels:            labels,
+			Annotations:       map[string]string{},
+			Selector:          map[string]string{"app": "web"},
+			Containers:        []models.Container{{ContainerName: "web", Image: "nginx", ImageVersion: "1.23", RequestCPU: 100}},
+			CreationTimestamp: contract_created,
+		}
+	}
+
+	workloads := models.NewCollection[string, models.Workload]()
+	workloads.Set("deployment_web_default", models.DeploymentWorkload{
+		GeneralWorkloadInfo: info("web", map[string]string{"app": "web"}),
+		Status:              models.DeploymentStatus{Desired: 1, Ready: 1, Available: 1, Up2date: 1},
+	}, true)
+	workloads.Set("statefulset_db_default", models.StatefulSetWorkload{
+		GeneralWorkloadInfo: info("db", map[string]string{"app": "db"}),
+		Status:              models.StatefulSetStatus{Current: 1, Ready: 1, Up2date: 1, Available: 1, Replicas: 1},
+	}, true)
+	workloads.Set("daemonset_agent_default", models.DaemonSetWorkload{
+		GeneralWorkloadInfo: info("agent", map[string]string{"app": "agent"}),
+		Status:              models.DaemonSetStatus{Desired: 1, Current: 1, Ready: 1, Up2date: 1, Available: 1},
+	}, true)
+	workloads.Set("pod_web-1_default", models.PodWorkload{
+		GeneralWorkloadInfo: info("web-1", map[string]string{"app": "web"}),
+		Status:              "Running",
+		NodeName:            "node-1",
+		Restarts:            2,
+		PodOwnerRessources:  []models.PodOwnerRessource{{APIVersion: "apps/v1", Kind: "ReplicaSet", UID: "1", Name: "web-5d4f"}},
+	}, true)
+	require.NoError(t, ds.ReplaceWorkloads(workloads))
+
+	// the second collection records the restarts of the pod
+	restarted := info("web-1", map[string]string{"app": "web"})
+	restarted.Containers[0].Restarts = 3
+	restarted.Containers[0].LastTermination = &models.ContainerTermination{Reason: "OOMKilled", ExitCode: 137, FinishedAt: time.Now().Add(-time.Minute).Truncate(time.Second)}
+	workloads.Set("pod_web-1_default", models.PodWorkload{
+		GeneralWorkloadInfo: restarted,
+		Status:              "Running",
+		NodeName:            "node-1",
+		Restarts:            3,
+		PodOwnerRessources:  []models.PodOwnerRessource{{APIVersion: "apps/v1", Kind: "ReplicaSet", UID: "1", Name: "web-5d4f"}},
+	}, true)
+	require.NoError(t, ds.ReplaceWorkloads(workloads))
+
+	metrics := models.NewCollection[string, models.PodContainerMetric]()
+	metrics.Set("default_web-1_web", models.PodContainerMetric{
+		PodName:           "web-1",
+		Namespace:         "default",
+		ContainerName:     "web",
+		CPUUsage:          50,
+		MemoryUsage:       1024,
+		CreationTimestamp: time.Now().Add(-time.Minute).Truncate(time.Second),
+	}, true)
+	require.NoError(t, ds.UpdateMetrics(metrics))
+
+	// the second collection allocates the minute since the first one
+	allocated := time.Now().Truncate(time.Hour).Add(time.Minute)
+	require.NoError(t, ds.RecordAllocations(nodes, workloads, allocated))
+	require.NoError(t, ds.RecordAllocations(nodes, workloads, allocated.Add(time.Minute)))
+
+	require.NoError(t, ds.CreateSnapshot())
+}
+
+// stubKubeAPI responds to the requests of the kubernetes api adapter
+func stubKubeAPI(w http.ResponseWriter, r *http.Request) {
+	created := meta_v1.NewTime(contract_created)
+	job := batch_v1.Job{
+		TypeMeta: meta_v1.TypeMeta{Kind: "Job", APIVersion: "batch/v1"},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: "backup", Namespace: "default", Labels: map[string]string{"app": "backup"}, CreationTimestamp: created,
+			OwnerReferences: []meta_v1.OwnerReference{{APIVersion: "batch/v1", Kind: "CronJob", Name: "nightly"}},
+		},
+		// the selector of jobs is defaulted by the kubernetes api
+		Spec:   batch_v1.JobSpec{Selector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"job-name": "backup"}}},
+		Status: batch_v1.JobStatus{Succeeded: 1, StartTime: &created, CompletionTime: &created},
+	}
+	cronjob := batch_v1.CronJob{
+		TypeMeta:   meta_v1.TypeMeta{Kind: "CronJob", APIVersion: "batch/v1"},
+		ObjectMeta: meta_v1.ObjectMeta{Name: "nightly", Namespace: "default", Labels: map[string]string{"app": "nightly"}, CreationTimestamp: created},
+		Spec:       batch_v1.CronJobSpec{Schedule: "0 2 * * *", ConcurrencyPolicy: batch_v1.ForbidConcurrent},
+		Status:     batch_v1.CronJobStatus{LastScheduleTime: &created},
+	}
+
+	var response any
+	switch strings.TrimPrefix(r.URL.Path, "/apis/batch/v1") {
+	case "/jobs", "/namespaces/default/jobs":
+		response = batch_v1.JobList{TypeMeta: meta_v1.TypeMeta{Kind: "JobList", APIVersion: "batch/v1"}, Items: []batch_v1.Job{job}}
+	case "/namespaces/default/jobs/backup":
+		response = job
+	case "/cronjobs", "/namespaces/default/cronjobs":
+		response = batch_v1.CronJobList{TypeMeta: meta_v1.TypeMeta{Kind: "CronJobList", APIVersion: "batch/v1"}, Items: []batch_v1.CronJob{cronjob}}
+	case "/namespaces/default/cronjobs/nightly":
+		response = cronjob
+	case "/api/v1/namespaces/default/events":
+		response = core_v1.EventList{
+			TypeMeta: meta_v1.TypeMeta{Kind: "EventList", APIVersion: "v1"},
+			Items: []core_v1.Event{{
+				ObjectMeta:     meta_v1.ObjectMeta{Name: "web-1.1", Namespace: "default"},
+				InvolvedObject: core_v1.ObjectReference{Kind: "Pod", Name: "web-1"},
+				Reason:         "Pulled",
+				Type:           "Normal",
+				Count:          1,
+				FirstTimestamp: created,
+				LastTimestamp:  created,
+			}},
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(meta_v1.Status{
+			TypeMeta: meta_v1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   meta_v1.StatusFailure,
+			Reason:   meta_v1.StatusReasonNotFound,
+			Code:     http.StatusNotFound,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// operationFor returns the documented path matching the request path, literal segments take precedence over parameters
+func operationFor(spec *openapi.Document, requestPath string) string {
+	segments := strings.Split(strings.TrimPrefix(requestPath, openapi.BASE_PATH), "/")
+
+	best, bestLiterals := "", -1
+	for path := range spec.Paths {
+		pathSegments := strings.Split(path, "/")
+		if len(pathSegments) != len(segments) {
+			continue
+		}
+
+		literals := 0
+		for i, segment := range pathSegments {
+			if strings.HasPrefix(segment, "{") {
+				continue
+			}
+			if segment != segments[i] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+
+		if literals > bestLiterals {
+			best, bestLiterals = path, literals
+		}
+	}
+
+	return best
+}
+
+func TestOpenAPIDescribesAllRoutes(t *testing.T) {
+	spec := openapi.Spec()
+	r := newContractRouter(t, watch.NewBroker(&watch.BrokerConfig{}))
+
+	documented := make(map[string]bool)
+	for _, route := range r.Routes() {
+		path := strings.TrimPrefix(route.Path, openapi.BASE_PATH)
+		parts := strings.Split(path, "/")
+		for i, part := range parts {
+			if strings.HasPrefix(part, ":") {
+				parts[i] = fmt.Sprintf("{%s}", strings.TrimPrefix(part, ":"))
+			}
+		}
+		path = strings.Join(parts, "/")
+
+		require.NotNil(t, spec.Operation(path), "route %s %s is not documented", route.Method, route.Path)
+		assert.Equal(t, http.MethodGet, route.Method)
+		documented[path] = true
+	}
+
+	for path := range spec.Paths {
+		assert.True(t, documented[path], "documented path %s is not registered", path)
+	}
+}
+
+func TestContract(t *testing.T) {
+	spec := openapi.Spec()
+	r := newContractRouter(t, watch.NewBroker(&watch.BrokerConfig{}))
+
+	at := url.QueryEscape(time.Now().Add(time.Minute).Format(time.RFC3339))
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{url: "/api/v1/nodes", status: 200},
+		{url: "/api/v1/nodes?at=" + at, status: 200},
+		{url: "/api/v1/nodes?limit=1&sort=-name&labelSelector=kubernetes.io/hostname", status: 200},
+		{url: "/api/v1/namespaces", status: 200},
+		{url: "/api/v1/namespaces/default", status: 200},
+		{url: "/api/v1/namespaces/default?at=" + at, status: 200},
+		{url: "/api/v1/namespaces/missing", status: 404},
+		{url: "/api/v1/workloads", status: 200},
+		{url: "/api/v1/workloads?at=" + at + "&limit=2", status: 200},
+		{url: "/api/v1/workloads/deployments?namespace=default", status: 200},
+		{url: "/api/v1/workloads/statefulsets", status: 200},
+		{url: "/api/v1/workloads/daemonsets", status: 200},
+		{url: "/api/v1/workloads/pods?labelSelector=app%3Dweb", status: 200},
+		{url: "/api/v1/workloads/jobs", status: 200},
+		{url: "/api/v1/workloads/cronjobs?namespace=default", status: 200},
+		{url: "/api/v1/workloads/pods/default/web-1", status: 200},
+		{url: "/api/v1/workloads/pods/default/web-1/changes", status: 200},
+		{url: "/api/v1/workloads/deployments/default/web", status: 200},
+		{url: "/api/v1/workloads/statefulsets/default/db?at=" + at, status: 200},
+		{url: "/api/v1/workloads/jobs/default/backup", status: 200},
+		{url: "/api/v1/workloads/cronjobs/default/nightly", status: 200},
+		{url: "/api/v1/workloads/cronjobs/default/missing", status: 404},
+		{url: "/api/v1/cronjobs/calendar", status: 200},
+		{url: "/api/v1/restarts/top", status: 200},
+		{url: "/api/v1/restarts/crashloops?namespace=default&gap=1h&threshold=1", status: 200},
+		{url: "/api/v1/restarts/crashloops?threshold=0", status: 400},
+		{url: "/api/v1/workloads/deployments/default/web/restarts", status: 200},
+		{url: "/api/v1/workloads/pods/default/web-1/restarts?from=2023-01-01T00:00:00Z&gap=5m", status: 200},
+		{url: "/api/v1/workloads/cronjobs/default/nightly/restarts", status: 200},
+		{url: "/api/v1/recommendations", status: 200},
+		{url: "/api/v1/recommendations?namespace=default&workload_type=deployments&classification=insufficient_data&headroom=30&patch=true", status: 200},
+		{url: "/api/v1/recommendations?headroom=-1", status: 400},
+		{url: "/api/v1/recommendations?classification=oversized", status: 400},
+		{url: "/api/v1/recommendations?workload_type=jobs", status: 400},
+		{url: "/api/v1/costs", status: 200},
+		{url: "/api/v1/costs?namespace=default&groupBy=label:team&basis=max", status: 200},
+		{url: "/api/v1/costs?groupBy=workload&basis=usage", status: 200},
+		{url: "/api/v1/costs?groupBy=team", status: 400},
+		{url: "/api/v1/costs?basis=limits", status: 400},
+		{url: "/api/v1/cronjobs/calendar?namespace=default&from=2023-01-22T00:00:00Z&to=2023-01-25T00:00:00Z", status: 200},
+		{url: "/api/v1/cronjobs/calendar?from=2023-01-01T00:00:00Z&to=2023-03-01T00:00:00Z", status: 400},
+		{url: "/api/v1/workloads/deployments/default/web/changes", status: 200},
+		{url: "/api/v1/workloads/unknown/default/web", status: 400},
+		{url: "/api/v1/container-metrics", status: 200},
+		{url: "/api/v1/container-metrics?rate=bad", status: 400},
+		{url: "/api/v1/metrics/query?namespace=default&workload=web&workload_type=deployments", status: 200},
+		{url: "/api/v1/snapshots", status: 200},
+		{url: "/api/v1/snapshots?format=pdf", status: 400},
+		{url: "/api/v1/search?q=web", status: 200},
+		{url: "/api/v1/search", status: 400},
+		{url: "/api/v1/openapi.json", status: 200},
+		{url: "/api/v1/status", status: 200},
+		{url: "/api/v1/audit?denied=true&from=" + at, status: 200},
+		{url: "/api/v1/audit?denied=maybe", status: 400},
+		{url: "/api/v1/watch?kinds=pods", status: 400},
+		{url: "/api/v1/watch?resume=invalid", status: 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+			require.Equal(t, test.status, w.Code, w.Body.String())
+
+			path := operationFor(spec, strings.Split(test.url, "?")[0])
+			require.NotEmpty(t, path)
+
+			schema := spec.Operation(path).ResponseSchema(w.Code)
+			require.NotNil(t, schema)
+			assert.NoError(t, spec.ValidateJSON(schema, w.Body.Bytes()), w.Body.String())
+		})
+	}
+}
+
+func TestNamespaceScope(t *testing.T) {
+	authn, err := auth.NewAuth(context.Background(), &auth.AuthConfig{Anonymous: true})
+	require.NoError(t, err)
+	mappings := authz.NewGroupMappings(&authz.GroupMappingsConfig{Mappings: []authz.Mapping{{User: auth.METHOD_ANONYMOUS, Namespaces: []string{"team-a"}}}})
+	r := newContractRouter(t, watch.NewBroker(&watch.BrokerConfig{}), authn.Middleware(v1.AbortWithError), authz.Middleware(mappings, v1.AbortWithError))
+
+	at := url.QueryEscape(time.Now().Add(time.Minute).Format(time.RFC3339))
+	tests := []struct {
+		url    string
+		status int
+		items  int // number of items of list responses, -1 for other responses
+	}{
+		{url: "/api/v1/nodes", status: 200, items: 1},
+		{url: "/api/v1/namespaces", status: 200, items: 0},
+		{url: "/api/v1/namespaces?at=" + at, status: 200, items: 0},
+		{url: "/api/v1/namespaces/default", status: 403, items: -1},
+		{url: "/api/v1/namespaces/default?at=" + at, status: 403, items: -1},
+		{url: "/api/v1/workloads", status: 200, items: 0},
+		{url: "/api/v1/workloads?at=" + at, status: 200, items: 0},
+		{url: "/api/v1/workloads/pods?labelSelector=app%3Dweb", status: 200, items: 0},
+		{url: "/api/v1/workloads/deployments?namespace=default", status: 403, items: -1},
+		{url: "/api/v1/workloads/deployments/default/web", status: 403, items: -1},
+		{url: "/api/v1/workloads/deployments/default/web/changes", status: 403, items: -1},
+		{url: "/api/v1/workloads/jobs", status: 200, items: 0},
+		{url: "/api/v1/workloads/cronjobs/default/nightly", status: 403, items: -1},
+		{url: "/api/v1/cronjobs/calendar", status: 200, items: 0},
+		{url: "/api/v1/restarts/top", status: 200, items: 0},
+		{url: "/api/v1/workloads/deployments/default/web/restarts", status: 403, items: -1},
+		{url: "/api/v1/recommendations", status: 200, items: 0},
+		{url: "/api/v1/recommendations?namespace=default", status: 403, items: -1},
+		{url: "/api/v1/costs", status: 200, items: 0},
+		{url: "/api/v1/costs?namespace=default", status: 403, items: -1},
+		{url: "/api/v1/container-metrics", status: 200, items: 0},
+		{url: "/api/v1/metrics/query?namespace=default&workload=web&workload_type=deployments", status: 403, items: -1},
+		{url: "/api/v1/watch?namespace=default", status: 403, items: -1},
+		{url: "/api/v1/search?q=default", status: 200, items: -1},
+		{url: "/api/v1/audit", status: 403, items: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+			require.Equal(t, test.status, w.Code, w.Body.String())
+			if test.items < 0 {
+				return
+			}
+
+			var response struct {
+				Data []json.RawMessage `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+			assert.Len(t, response.Data, test.items)
+		})
+	}
+}
+
+func TestMetricsScope(t *testing.T) {
+	authn, err := auth.NewAuth(context.Background(), &auth.AuthConfig{Anonymous: true})
+	require.NoError(t, err)
+
+	tests := []struct {
+		namespaces []string
+		status     int
+	}{
+		{namespaces: []string{"team-a"}, status: 403},
+		{namespaces: []string{authz.ALL_NAMESPACES}, status: 200},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.namespaces, ","), func(t *testing.T) {
+			mappings := authz.NewGroupMappings(&authz.GroupMappingsConfig{Mappings: []authz.Mapping{{User: auth.METHOD_ANONYMOUS, Namespaces: test.namespaces}}})
+			r := gin.New()
+			r.Use(authn.Middleware(v1.AbortWithError), authz.Middleware(mappings, v1.AbortWithError))
+			r.GET("/metrics", metrics(exporter.NewRegistry().Handler()))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Equal(t, test.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(newContractRouter(t, watch.NewBroker(&watch.BrokerConfig{})))
+	t.Cleanup(server.Close)
+
+	c := client.NewClient(&client.ClientConfig{BaseURL: server.URL})
+
+	workloads, err := c.GetWorkloads(context.Background(), client.GetWorkloadsParams{Limit: 2, Sort: "workload_info.workload_name"})
+	require.NoError(t, err)
+	require.Len(t, workloads.Data, 2)
+	require.NotNil(t, workloads.Data[0].DaemonSetWorkload)
+	assert.Equal(t, "agent", workloads.Data[0].DaemonSetWorkload.WorkloadInfo.WorkloadName)
+	assert.Equal(t, int64(4), workloads.Metadata.Total)
+	assert.NotEmpty(t, workloads.Metadata.Continue)
+
+	details, err := c.GetWorkload(context.Background(), client.GetWorkloadParams{WorkloadType: "cronjobs", Namespace: "default", Name: "nightly"})
+	require.NoError(t, err)
+	require.NotNil(t, details.Data.Workload.CronjobWorkload)
+	assert.Equal(t, "0 2 * * *", details.Data.Workload.CronjobWorkload.Schedule)
+
+	_, err = c.GetNamespace(context.Background(), client.GetNamespaceParams{Name: "missing"})
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "not_found", apiErr.Response.Error.Reason)
+
+	spec, err := c.GetOpenAPI(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, openapi.OPENAPI_VERSION, (*spec)["openapi"])
+}
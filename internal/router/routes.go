@@ -6,12 +6,32 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gitlab.com/patrick.erber/kdd/internal/adapters"
+	"gitlab.com/patrick.erber/kdd/internal/audit"
+	"gitlab.com/patrick.erber/kdd/internal/auth"
+	"gitlab.com/patrick.erber/kdd/internal/authz"
+	"gitlab.com/patrick.erber/kdd/internal/exporter"
+	"gitlab.com/patrick.erber/kdd/internal/health"
+	"gitlab.com/patrick.erber/kdd/internal/models"
+	"gitlab.com/patrick.erber/kdd/internal/openapi"
 	"gitlab.com/patrick.erber/kdd/internal/persistence"
 	v1 "gitlab.com/patrick.erber/kdd/internal/router/api/v1"
+	"gitlab.com/patrick.erber/kdd/internal/watch"
 )
 
-func InitRouter(ds *persistence.DataStore, ka *adapters.KubeAPIAdapter) *gin.Engine {
+// ConnContext needs to be set as http.Server.ConnContext, the watch api extends the write deadline of the connection
+var ConnContext = v1.ConnContext
+
+// InitRouter returns the routes of kdd, the requests are not audited when auditLog is nil
+func InitRouter(ds *persistence.DataStore, ka *adapters.KubeAPIAdapter, broker *watch.Broker, checker *health.Checker, costs models.CostModel, authn *auth.Auth, authorizer authz.Authorizer, auditLog *audit.Logger) *gin.Engine {
 	r := gin.New()
+	r.Use(exporter.Middleware())
+	if auditLog != nil {
+		// before the authentication to record the denied requests
+		r.Use(audit.Middleware(auditLog))
+	}
+	r.Use(authn.Middleware(v1.AbortWithError))
+	r.Use(authz.Middleware(authorizer, v1.AbortWithError))
+	authn.RegisterRoutes(r)
 
 	r.StaticFS("/static", http.Dir("../_ui/build/static"))
 	r.LoadHTMLFiles("../_ui/build/index.html")
@@ -24,23 +44,68 @@ func InitRouter(ds *persistence.DataStore, ka *adapters.KubeAPIAdapter) *gin.Eng
 		c.HTML(200, "index.html", gin.H{})
 	})
 
-	apiv1 := r.Group("/api/v1")
+	RegisterAPIv1(r, ds, ka, broker, checker, costs)
+
+	// probes of kubernetes
+	r.GET("/healthz", checker.Healthz)
+	r.GET("/readyz", checker.Readyz)
+
+	// prometheus metrics of the cluster state and of kdd itself
+	r.GET("/metrics", metrics(exporter.DefaultRegistry.Handler()))
+
+	return r
+}
+
+// metrics serves the prometheus metrics, the cluster state covers every namespace and is not served to restricted principals
+func metrics(handler http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authz.GetScope(c).Restricted() {
+			v1.AbortWithError(c, fmt.Errorf("metrics: %w", models.ErrForbidden))
+			return
+		}
+
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// RegisterAPIv1 registers the routes of the api below /api/v1, every route needs to be described in the openapi package
+func RegisterAPIv1(r gin.IRouter, ds *persistence.DataStore, ka *adapters.KubeAPIAdapter, broker *watch.Broker, checker *health.Checker, costs models.CostModel) {
+	spec := openapi.Spec()
+
+	apiv1 := r.Group(openapi.BASE_PATH)
+	apiv1.Use(v1.ErrorMiddleware())
 	{
-		api := v1.NewAPI(ds, ka)
+		api := v1.NewAPI(ds, ka, broker, checker, costs)
 		apiv1.GET("/nodes", api.GetNodes)
 		apiv1.GET("/namespaces", api.GetNamespaces)
 		apiv1.GET("/namespaces/:name", api.GetNamespace)
 		apiv1.GET("/workloads", api.GetWorkloads)
 		apiv1.GET("/workloads/deployments", api.GetDeployments)
 		apiv1.GET("/workloads/pods/:namespace/:name", api.GetPod)
+		apiv1.GET("/workloads/pods/:namespace/:name/changes", api.GetPodChanges)
+		apiv1.GET("/workloads/pods/:namespace/:name/restarts", api.GetPodRestarts)
 		apiv1.GET("/workloads/:workloadType/:namespace/:name", api.GetWorkload)
+		apiv1.GET("/workloads/:workloadType/:namespace/:name/changes", api.GetWorkloadChanges)
+		apiv1.GET("/workloads/:workloadType/:namespace/:name/restarts", api.GetWorkloadRestarts)
 		apiv1.GET("/workloads/statefulsets", api.GetStatefulSets)
 		apiv1.GET("/workloads/jobs", api.GetJobs)
 		apiv1.GET("/workloads/cronjobs", api.GetCronjobs)
+		apiv1.GET("/cronjobs/calendar", api.GetCronjobCalendar)
+		apiv1.GET("/restarts/top", api.GetTopRestarters)
+		apiv1.GET("/restarts/crashloops", api.GetCrashLoops)
+		apiv1.GET("/recommendations", api.GetRecommendations)
+		apiv1.GET("/costs", api.GetCosts)
 		apiv1.GET("/workloads/pods", api.GetPods)
 		apiv1.GET("/workloads/daemonsets", api.GetDaemonSet)
 		apiv1.GET("/container-metrics", api.GetContainerMetrics)
+		apiv1.GET("/metrics/query", api.QueryMetrics)
+		apiv1.GET("/snapshots", api.GetSnapshots)
+		apiv1.GET("/search", api.Search)
+		apiv1.GET("/watch", api.Watch)
+		apiv1.GET("/audit", api.GetAuditLog)
+		apiv1.GET("/status", api.GetStatus)
+		apiv1.GET("/openapi.json", func(c *gin.Context) {
+			c.JSON(http.StatusOK, spec)
+		})
 	}
-
-	return r
 }
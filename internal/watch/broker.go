@@ -0,0 +1,230 @@
+package watch
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+/**
+	The watch package distributes the changes of the stored objects to the clients of the watch api.
+	The broker keeps the latest events in a ring buffer, every subscription reads the buffer at its own position.
+	A slow client therefore never blocks the controller or other clients, it expires when it falls behind the kept history.
+	Events are identified by resume tokens, a client can continue after the last received event as long as it is kept.
+**/
+
+const (
+	DEFAULT_HISTORY_SIZE = 4096
+	DEFAULT_BATCH_SIZE   = 256
+	DEFAULT_HEARTBEAT    = time.Second * 15
+)
+
+type BrokerConfig struct {
+	HistorySize int           // number of events kept for slow and resuming clients, grown to the largest published batch
+	BatchSize   int           // maximum number of events returned by Next
+	Heartbeat   time.Duration // interval of the heartbeats sent on idle streams
+}
+
+// Broker - distributes the published events to the subscriptions
+type Broker struct {
+	cfg   *BrokerConfig
+	lock  sync.RWMutex
+	epoch int64 // tokens of a former process are expired
+	ring  []models.WatchEvent
+	next  uint64 // sequence of the next published event, sequences start at 1
+	// closed and replaced on every publish to wake up the waiting subscriptions
+	published chan struct{}
+}
+
+// Filter - selects the events of a subscription
+type Filter struct {
+	Kinds     []string               // all kinds when empty
+	Namespace string                 // all namespaces when empty, cluster scoped objects are excluded otherwise
+	Scope     *models.NamespaceScope // namespaces the client is allowed to see, cluster scoped objects are not restricted
+}
+
+// Subscription - the position of a client in the stream
+type Subscription struct {
+	broker *Broker
+	filter Filter
+	cursor uint64 // sequence of the next event to read
+}
+
+// NewBroker creates a broker, the zero values of the config are replaced by the defaults
+func NewBroker(cfg *BrokerConfig) *Broker {
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = DEFAULT_HISTORY_SIZE
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DEFAULT_BATCH_SIZE
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = DEFAULT_HEARTBEAT
+	}
+
+	return &Broker{
+		cfg:       cfg,
+		epoch:     time.Now().UnixNano(),
+		ring:      make([]models.WatchEvent, cfg.HistorySize),
+		next:      1,
+		published: make(chan struct{}),
+	}
+}
+
+// Heartbeat returns the interval of the heartbeats sent on idle streams
+func (b *Broker) Heartbeat() time.Duration {
+	return b.cfg.Heartbeat
+}
+
+// Publish assigns the resume tokens to the events, stores them and wakes up the subscriptions
+func (b *Broker) Publish(events []models.WatchEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(events) > len(b.ring) {
+		// a sync larger than the history would expire every subscription, the batch overwrites the whole ring anyway
+		b.ring = make([]models.WatchEvent, len(events))
+	}
+
+	for _, event := range events {
+		event.ID = b.token(b.next)
+		b.ring[b.next%uint64(len(b.ring))] = event
+		b.next++
+	}
+
+	close(b.published)
+	b.published = make(chan struct{})
+}
+
+// Subscribe returns a subscription starting after the event of the resume token or with the next published event
+func (b *Broker) Subscribe(filter Filter, resume string) (*Subscription, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	s := &Subscription{broker: b, filter: filter, cursor: b.next}
+	if resume == "" {
+		return s, nil
+	}
+
+	epoch, sequence, err := parseToken(resume)
+	if err != nil {
+		return nil, err
+	}
+	if epoch != b.epoch || sequence+1 < b.first() {
+		return nil, fmt.Errorf("%w: resume token %s is not available anymore", models.ErrExpired, resume)
+	}
+	if sequence >= b.next {
+		return nil, fmt.Errorf("%w: unknown resume token %s", models.ErrInvalidFilter, resume)
+	}
+
+	s.cursor = sequence + 1
+	return s, nil
+}
+
+// Next waits until events matching the filter are available and returns them.
+// ErrExpired is returned when the subscription fell behind the kept history.
+func (s *Subscription) Next(ctx context.Context) ([]models.WatchEvent, error) {
+	for {
+		s.broker.lock.RLock()
+		if s.cursor < s.broker.first() {
+			s.broker.lock.RUnlock()
+			return nil, fmt.Errorf("%w: the client fell behind the kept history", models.ErrExpired)
+		}
+
+		events := make([]models.WatchEvent, 0)
+		for ; s.cursor < s.broker.next && len(events) < s.broker.cfg.BatchSize; s.cursor++ {
+			event := s.broker.ring[s.cursor%uint64(len(s.broker.ring))]
+			if s.filter.Matches(event) {
+				events = append(events, event)
+			}
+		}
+		published := s.broker.published
+		s.broker.lock.RUnlock()
+
+		if len(events) > 0 {
+			return events, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-published:
+		}
+	}
+}
+
+// Token returns the resume token of the last event read by the subscription
+func (s *Subscription) Token() string {
+	return s.broker.token(s.cursor - 1)
+}
+
+// Matches checks if the event is selected by the filter
+func (f Filter) Matches(event models.WatchEvent) bool {
+	if f.Namespace != "" && event.Namespace != f.Namespace {
+		return false
+	}
+
+	if event.Namespace != "" && !f.Scope.Allows(event.Namespace) {
+		return false
+	}
+
+	if len(f.Kinds) == 0 {
+		return true
+	}
+
+	for _, kind := range f.Kinds {
+		if kind == event.Kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// first returns the sequence of the oldest kept event
+func (b *Broker) first() uint64 {
+	if b.next <= uint64(len(b.ring)) {
+		return 1
+	}
+
+	return b.next - uint64(len(b.ring))
+}
+
+// token returns the opaque resume token of the sequence
+func (b *Broker) token(sequence uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", b.epoch, sequence)))
+}
+
+func parseToken(token string) (int64, uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid resume token", models.ErrInvalidFilter)
+	}
+
+	epochValue, sequenceValue, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: invalid resume token", models.ErrInvalidFilter)
+	}
+
+	epoch, err := strconv.ParseInt(epochValue, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid resume token", models.ErrInvalidFilter)
+	}
+
+	sequence, err := strconv.ParseUint(sequenceValue, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid resume token", models.ErrInvalidFilter)
+	}
+
+	return epoch, sequence, nil
+}
@@ -0,0 +1,175 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+func workloadEvents(namespace string, names ...string) []models.WatchEvent {
+	events := make([]models.WatchEvent, len(names))
+	for i, name := range names {
+		events[i] = models.WatchEvent{Type: models.WATCH_ADDED, Kind: models.WATCH_KIND_WORKLOAD, Key: name, Namespace: namespace}
+	}
+
+	return events
+}
+
+func keys(events []models.WatchEvent) []string {
+	result := make([]string, len(events))
+	for i, event := range events {
+		result[i] = event.Key
+	}
+
+	return result
+}
+
+func TestBrokerResume(t *testing.T) {
+	broker := NewBroker(&BrokerConfig{HistorySize: 4})
+	ctx := context.Background()
+
+	subscription, err := broker.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+
+	broker.Publish(workloadEvents("default", "a", "b"))
+	events, err := subscription.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys(events))
+
+	broker.Publish(workloadEvents("default", "c"))
+
+	// resuming after a continues with b
+	resumed, err := broker.Subscribe(Filter{}, events[0].ID)
+	require.NoError(t, err)
+	events, err = resumed.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, keys(events))
+
+	// b is not kept anymore, resuming after a expires
+	broker.Publish(workloadEvents("default", "d", "e", "f"))
+	_, err = broker.Subscribe(Filter{}, events[0].ID)
+	assert.NoError(t, err)
+	_, err = broker.Subscribe(Filter{}, broker.token(1))
+	assert.ErrorIs(t, err, models.ErrExpired)
+
+	// tokens of another process are expired
+	_, err = broker.Subscribe(Filter{}, NewBroker(&BrokerConfig{}).token(1))
+	assert.ErrorIs(t, err, models.ErrExpired)
+
+	_, err = broker.Subscribe(Filter{}, "invalid")
+	assert.ErrorIs(t, err, models.ErrInvalidFilter)
+}
+
+func TestBrokerSlowSubscriptionExpires(t *testing.T) {
+	broker := NewBroker(&BrokerConfig{HistorySize: 4})
+
+	slow, err := broker.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		broker.Publish(workloadEvents("default", fmt.Sprintf("w-%d", i)))
+	}
+
+	_, err = slow.Next(context.Background())
+	assert.ErrorIs(t, err, models.ErrExpired)
+}
+
+func TestBrokerBatchLargerThanHistory(t *testing.T) {
+	broker := NewBroker(&BrokerConfig{HistorySize: 4})
+	ctx := context.Background()
+
+	lagging, err := broker.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+	broker.Publish(workloadEvents("default", "a"))
+
+	current, err := broker.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+
+	names := make([]string, 10)
+	for i := range names {
+		names[i] = fmt.Sprintf("w-%d", i)
+	}
+	broker.Publish(workloadEvents("default", names...))
+
+	// the subscription which read everything before the batch receives the whole batch
+	events, err := current.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, names, keys(events))
+
+	resumed, err := broker.Subscribe(Filter{}, events[4].ID)
+	require.NoError(t, err)
+	events, err = resumed.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, names[5:], keys(events))
+
+	// the event published before the batch is not kept
+	_, err = lagging.Next(ctx)
+	assert.ErrorIs(t, err, models.ErrExpired)
+}
+
+func TestBrokerFilter(t *testing.T) {
+	broker := NewBroker(&BrokerConfig{BatchSize: 2})
+
+	subscription, err := broker.Subscribe(Filter{Kinds: []string{models.WATCH_KIND_WORKLOAD}, Namespace: "team-a"}, "")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		broker.Publish([]models.WatchEvent{{Kind: models.WATCH_KIND_NODE, Key: "node-1"}})
+		broker.Publish(workloadEvents("team-b", "x"))
+		broker.Publish(workloadEvents("team-a", "a", "b", "c"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := subscription.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys(events))
+
+	events, err = subscription.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, keys(events))
+
+	// waits until the context is done without new events
+	short, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = subscription.Next(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFilterScope(t *testing.T) {
+	filter := Filter{Scope: models.NewNamespaceScope("team-a")}
+
+	assert.True(t, filter.Matches(models.WatchEvent{Kind: models.WATCH_KIND_NODE, Key: "node-1"}))
+	assert.True(t, filter.Matches(models.WatchEvent{Kind: models.WATCH_KIND_WORKLOAD, Key: "a", Namespace: "team-a"}))
+	assert.False(t, filter.Matches(models.WatchEvent{Kind: models.WATCH_KIND_WORKLOAD, Key: "x", Namespace: "team-b"}))
+	assert.False(t, filter.Matches(models.WatchEvent{Kind: models.WATCH_KIND_NAMESPACE, Key: "team-b", Namespace: "team-b"}))
+}
+
+func TestCollectionEvents(t *testing.T) {
+	now := time.Now()
+	previous := models.NewCollection[string, models.Namespace]()
+	previous.Set("a", models.Namespace{Name: "a", Status: "Active"}, true)
+	previous.Set("b", models.Namespace{Name: "b", Status: "Active"}, true)
+	previous.Set("c", models.Namespace{Name: "c", Status: "Active"}, true)
+
+	current := models.NewCollection[string, models.Namespace]()
+	current.Set("a", models.Namespace{Name: "a", Status: "Active"}, true)
+	current.Set("b", models.Namespace{Name: "b", Status: "Terminating"}, true)
+	current.Set("d", models.Namespace{Name: "d", Status: "Active"}, true)
+
+	events := CollectionEvents(models.WATCH_KIND_NAMESPACE, previous, current, func(n models.Namespace) string { return n.Name }, now)
+	require.Len(t, events, 3)
+	assert.Equal(t, models.WatchEvent{Type: models.WATCH_ADDED, Kind: models.WATCH_KIND_NAMESPACE, Key: "d", Namespace: "d", Object: models.Namespace{Name: "d", Status: "Active"}, Timestamp: now}, events[0])
+	assert.Equal(t, models.WATCH_MODIFIED, events[1].Type)
+	assert.Equal(t, "Terminating", events[1].Object.(models.Namespace).Status)
+	assert.Equal(t, models.WATCH_DELETED, events[2].Type)
+	assert.Equal(t, "c", events[2].Key)
+}
@@ -0,0 +1,63 @@
+package watch
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"gitlab.com/patrick.erber/kdd/internal/models"
+)
+
+// NamespaceFunc returns the namespace of an object, empty for cluster scoped objects
+type NamespaceFunc[V any] func(V) string
+
+// CollectionEvents returns the ADDED, MODIFIED and DELETED events of the objects which changed between the collections
+func CollectionEvents[V any](kind string, previous *models.Collection[string, V], current *models.Collection[string, V], namespace NamespaceFunc[V], now time.Time) []models.WatchEvent {
+	diff := models.DiffCollections(previous, current, equal[V])
+
+	events := make([]models.WatchEvent, 0, len(diff.Added)+len(diff.Modified)+len(diff.Deleted))
+	events = appendEvents(events, models.WATCH_ADDED, kind, diff.Added, current, namespace, now)
+	events = appendEvents(events, models.WATCH_MODIFIED, kind, diff.Modified, current, namespace, now)
+	// deleted objects are sent with their last known state
+	events = appendEvents(events, models.WATCH_DELETED, kind, diff.Deleted, previous, namespace, now)
+
+	return events
+}
+
+// MetricEvents returns an ADDED event for every new sample, the metrics of removed containers are not reported
+func MetricEvents(previous *models.MetricCollection, current *models.MetricCollection, now time.Time) []models.WatchEvent {
+	diff := models.DiffCollections(previous, current, equal[models.PodContainerMetric])
+
+	namespace := func(m models.PodContainerMetric) string { return m.Namespace }
+	events := make([]models.WatchEvent, 0, len(diff.Added)+len(diff.Modified))
+	events = appendEvents(events, models.WATCH_ADDED, models.WATCH_KIND_METRIC, append(diff.Added, diff.Modified...), current, namespace, now)
+
+	return events
+}
+
+func appendEvents[V any](events []models.WatchEvent, eventType string, kind string, keys []string, collection *models.Collection[string, V], namespace NamespaceFunc[V], now time.Time) []models.WatchEvent {
+	// sorted to publish the events of a sync in a stable order
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		object, ok := collection.Get(key)
+		if !ok {
+			continue
+		}
+
+		events = append(events, models.WatchEvent{
+			Type:      eventType,
+			Kind:      kind,
+			Key:       key,
+			Namespace: namespace(object),
+			Object:    object,
+			Timestamp: now,
+		})
+	}
+
+	return events
+}
+
+func equal[V any](a V, b V) bool {
+	return reflect.DeepEqual(a, b)
+}
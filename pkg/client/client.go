@@ -0,0 +1,86 @@
+// Package client is the go client of the kdd api.
+// The types and methods of the operations are generated out of the OpenAPI document of the api, see client_gen.go.
+package client
+
+//go:generate go run ../../internal/openapi/clientgen -o client_gen.go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type ClientConfig struct {
+	BaseURL    string       // scheme and host of the api, e.g. http://localhost:8080
+	HTTPClient *http.Client // defaults to http.DefaultClient
+	Token      string       // bearer token sent with every request, a static api token or an OIDC id token
+}
+
+type Client struct {
+	cfg *ClientConfig
+}
+
+func NewClient(cfg *ClientConfig) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &Client{cfg: cfg}
+}
+
+// Response - the envelope of every successful response, the metadata is only set for lists
+type Response[T any] struct {
+	Code     int           `json:"code"`
+	Msg      string        `json:"msg"`
+	Data     T             `json:"data"`
+	Metadata *ListMetadata `json:"metadata,omitempty"`
+}
+
+// APIError - returned for failed requests
+type APIError struct {
+	StatusCode int
+	Response   ErrorResponse
+}
+
+func (e *APIError) Error() string {
+	if e.Response.Error.Reason == "" {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("request failed with status %d: %s: %s", e.StatusCode, e.Response.Error.Reason, e.Response.Error.Details)
+}
+
+// get requests the path and decodes the json body into result, failed requests return an *APIError
+func (c *Client) get(ctx context.Context, path string, query url.Values, result any) error {
+	u := strings.TrimSuffix(c.cfg.BaseURL, "/") + BASE_PATH + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	res, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: res.StatusCode}
+		// the body is only informative, the status is reported either way
+		_ = json.NewDecoder(res.Body).Decode(&apiErr.Response)
+		return apiErr
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
@@ -0,0 +1,1834 @@
+// Code generated by internal/openapi/clientgen. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// BASE_PATH - the path the api is served at
+const BASE_PATH = "/api/v1"
+
+var (
+	_ = json.Marshal
+	_ = fmt.Errorf
+	_ = strconv.Itoa
+	_ = time.RFC3339
+)
+
+type ActiveCronjobInfo struct {
+	APIVersion string `json:"api_version"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+}
+
+type AuditEntry struct {
+	AuthMethod string            `json:"auth_method,omitempty"`
+	ClientIp   string            `json:"client_ip"`
+	Denied     bool              `json:"denied"`
+	ID         int64             `json:"id,omitempty"`
+	LatencyMs  float64           `json:"latency_ms"`
+	Method     string            `json:"method"`
+	Params     map[string]string `json:"params"`
+	Path       string            `json:"path"`
+	Route      string            `json:"route"`
+	Status     int64             `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	User       string            `json:"user"`
+}
+
+type CollectionStatus struct {
+	Error       string     `json:"error,omitempty"`
+	Kind        string     `json:"kind"`
+	LastAttempt *time.Time `json:"last_attempt"`
+	LastSuccess *time.Time `json:"last_success"`
+	Stale       bool       `json:"stale"`
+}
+
+type Container struct {
+	ContainerName   string                `json:"container_name"`
+	Image           string                `json:"image"`
+	ImageVersion    string                `json:"image_version"`
+	InitContainer   bool                  `json:"init_container"`
+	LastTermination *ContainerTermination `json:"last_termination,omitempty"`
+	LimitCPU        int64                 `json:"limit_cpu"`
+	LimitMemory     int64                 `json:"limit_memory"`
+	RequestCPU      int64                 `json:"request_cpu"`
+	RequestMemory   int64                 `json:"request_memory"`
+	Restarts        int64                 `json:"restarts"`
+	WaitingReason   string                `json:"waiting_reason,omitempty"`
+}
+
+type ContainerRecommendation struct {
+	Classification string               `json:"classification"`
+	ContainerName  string               `json:"container_name"`
+	CPUUsage       ResourceUsage        `json:"cpu_usage"`
+	Current        ResourceRequirements `json:"current"`
+	MemoryUsage    ResourceUsage        `json:"memory_usage"`
+	Reasons        []string             `json:"reasons"`
+	Recommended    ResourceRequirements `json:"recommended"`
+	Samples        int64                `json:"samples"`
+}
+
+type ContainerRestart struct {
+	ContainerName string    `json:"container_name"`
+	ExitCode      int32     `json:"exit_code"`
+	Increase      int64     `json:"increase"`
+	Namespace     string    `json:"namespace"`
+	PodName       string    `json:"pod_name"`
+	Reason        string    `json:"reason"`
+	Restarts      int64     `json:"restarts"`
+	Timestamp     time.Time `json:"timestamp"`
+	WorkloadName  string    `json:"workload_name"`
+	WorkloadType  string    `json:"workload_type"`
+}
+
+type ContainerTermination struct {
+	ExitCode   int32     `json:"exit_code"`
+	FinishedAt time.Time `json:"finished_at"`
+	Reason     string    `json:"reason"`
+}
+
+type CostAllocation struct {
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	CPUCost        float64 `json:"cpu_cost"`
+	Currency       string  `json:"currency"`
+	MemoryCost     float64 `json:"memory_cost"`
+	MemoryGibHours float64 `json:"memory_gib_hours"`
+	Name           string  `json:"name"`
+	Namespace      string  `json:"namespace,omitempty"`
+	TotalCost      float64 `json:"total_cost"`
+	WorkloadName   string  `json:"workload_name,omitempty"`
+	WorkloadType   string  `json:"workload_type,omitempty"`
+}
+
+type CrashLoop struct {
+	ContainerName string           `json:"container_name"`
+	End           time.Time        `json:"end"`
+	Namespace     string           `json:"namespace"`
+	PodName       string           `json:"pod_name"`
+	Reasons       map[string]int64 `json:"reasons"`
+	Restarts      int64            `json:"restarts"`
+	Start         time.Time        `json:"start"`
+	WorkloadName  string           `json:"workload_name"`
+	WorkloadType  string           `json:"workload_type"`
+}
+
+type CronjobAnalysis struct {
+	ConcurrencyRisk        bool        `json:"concurrency_risk"`
+	Error                  string      `json:"error,omitempty"`
+	ExpectedLastRun        *time.Time  `json:"expected_last_run"`
+	IntervalSeconds        int64       `json:"interval_seconds"`
+	Missed                 bool        `json:"missed"`
+	NextRuns               []time.Time `json:"next_runs"`
+	TypicalDurationSeconds *int64      `json:"typical_duration_seconds"`
+}
+
+type CronjobRun struct {
+	ConcurrencyPolicy string    `json:"concurrency_policy"`
+	Cronjob           string    `json:"cronjob"`
+	Namespace         string    `json:"namespace"`
+	Schedule          string    `json:"schedule"`
+	Time              time.Time `json:"time"`
+}
+
+type CronjobStatus struct {
+	ActiveJobs         []ActiveCronjobInfo `json:"active_jobs"`
+	LastScheduledTime  *time.Time          `json:"last_scheduled_time"`
+	LastSuccessfulTime *time.Time          `json:"last_successful_time"`
+}
+
+type CronjobWorkload struct {
+	Analysis              *CronjobAnalysis    `json:"analysis,omitempty"`
+	BackoffLimit          *int32              `json:"backoff_limit"`
+	ConcurrencyPolicy     string              `json:"concurrency_policy"`
+	FailedJobsHistory     *int32              `json:"failed_jobs_history"`
+	Schedule              string              `json:"schedule"`
+	Status                CronjobStatus       `json:"status"`
+	SuccessfulJobsHistory *int32              `json:"successful_jobs_history"`
+	Suspend               *bool               `json:"suspend"`
+	TimeZone              *string             `json:"time_zone"`
+	Type                  string              `json:"type"`
+	WorkloadInfo          GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type DaemonSetStatus struct {
+	Available int64 `json:"available"`
+	Current   int64 `json:"current"`
+	Desired   int64 `json:"desired"`
+	Ready     int64 `json:"ready"`
+	Up2date   int64 `json:"up2date"`
+}
+
+type DaemonSetWorkload struct {
+	Status       DaemonSetStatus     `json:"status"`
+	Type         string              `json:"type"`
+	WorkloadInfo GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type DatabaseStatus struct {
+	Error     string `json:"error,omitempty"`
+	Reachable bool   `json:"reachable"`
+}
+
+type DeploymentStatus struct {
+	Available int64 `json:"available"`
+	Desired   int64 `json:"desired"`
+	Ready     int64 `json:"ready"`
+	Up2date   int64 `json:"up2date"`
+}
+
+type DeploymentWorkload struct {
+	Status       DeploymentStatus    `json:"status"`
+	Type         string              `json:"type"`
+	WorkloadInfo GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type ErrorDetails struct {
+	Details string `json:"details"`
+	Reason  string `json:"reason"`
+}
+
+type ErrorResponse struct {
+	Code  int64        `json:"code"`
+	Data  any          `json:"data,omitempty"`
+	Error ErrorDetails `json:"error"`
+	Msg   string       `json:"msg"`
+}
+
+type Event struct {
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Message   string    `json:"message"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Object    string    `json:"object"`
+	Reason    string    `json:"reason"`
+	Source    string    `json:"source"`
+	Type      string    `json:"type"`
+}
+
+type FieldChange struct {
+	Field    string `json:"field"`
+	NewValue any    `json:"new_value"`
+	OldValue any    `json:"old_value"`
+}
+
+type GeneralWorkloadInfo struct {
+	Annotations         map[string]string          `json:"annotations"`
+	Containers          []Container                `json:"containers"`
+	CreationDate        time.Time                  `json:"creation_date"`
+	Labels              map[string]string          `json:"labels"`
+	Namespace           string                     `json:"namespace"`
+	Selector            map[string]string          `json:"selector"`
+	SelectorExpressions []LabelSelectorRequirement `json:"selector_expressions,omitempty"`
+	WorkloadName        string                     `json:"workload_name"`
+}
+
+type JobStatus struct {
+	Active         int32      `json:"active"`
+	CompletionTime *time.Time `json:"completion_time"`
+	Failed         int32      `json:"failed"`
+	Ready          *int32     `json:"ready"`
+	StartTime      *time.Time `json:"start_time"`
+	Succeeded      int32      `json:"succeeded"`
+}
+
+type JobWorkload struct {
+	CronjobName  string              `json:"cronjob_name,omitempty"`
+	Status       JobStatus           `json:"status"`
+	Type         string              `json:"type"`
+	WorkloadInfo GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type LimitRange struct {
+	Limits []LimitRangeItem `json:"limits"`
+	Name   string           `json:"name"`
+}
+
+type LimitRangeItem struct {
+	Default        ResourceValues `json:"default"`
+	DefaultRequest ResourceValues `json:"default_request"`
+	Max            ResourceValues `json:"max"`
+	Min            ResourceValues `json:"min"`
+	Type           string         `json:"type"`
+}
+
+type ListMetadata struct {
+	Continue string `json:"continue,omitempty"`
+	Total    int64  `json:"total"`
+}
+
+type MetricSeries struct {
+	Name   string   `json:"name"`
+	Values []*int64 `json:"values"`
+}
+
+type MetricsQueryResult struct {
+	Aggregation string         `json:"aggregation"`
+	From        time.Time      `json:"from"`
+	Series      []MetricSeries `json:"series"`
+	Step        string         `json:"step"`
+	Timestamps  []time.Time    `json:"timestamps"`
+	To          time.Time      `json:"to"`
+}
+
+type Namespace struct {
+	Annotations    map[string]string `json:"annotations"`
+	CreationDate   time.Time         `json:"creation_date"`
+	Labels         map[string]string `json:"labels"`
+	LimitRanges    []LimitRange      `json:"limit_ranges"`
+	Name           string            `json:"name"`
+	ResourceQuotas []ResourceQuota   `json:"resource_quotas"`
+	Status         string            `json:"status"`
+}
+
+type NamespaceDetails struct {
+	Events    []Event            `json:"events"`
+	Namespace Namespace          `json:"namespace"`
+	Warnings  []NamespaceWarning `json:"warnings"`
+	Workloads []Workload         `json:"workloads"`
+}
+
+type NamespaceWarning struct {
+	Container    string `json:"container,omitempty"`
+	Kind         string `json:"kind"`
+	Message      string `json:"message"`
+	Name         string `json:"name"`
+	Resource     string `json:"resource"`
+	WorkloadName string `json:"workload_name,omitempty"`
+	WorkloadType string `json:"workload_type,omitempty"`
+}
+
+type Node struct {
+	Annotations    map[string]string `json:"annotations"`
+	CPU            int64             `json:"cpu"`
+	CreationDate   time.Time         `json:"creation_date"`
+	KubeletVersion string            `json:"kubelet_version"`
+	Labels         map[string]string `json:"labels"`
+	Memory         int64             `json:"memory"`
+	Name           string            `json:"name"`
+	OsImage        string            `json:"os_image"`
+	Roles          string            `json:"roles"`
+	Status         string            `json:"status"`
+}
+
+type PodContainerMetric struct {
+	ContainerName string    `json:"container_name"`
+	CPUUsage      int64     `json:"cpu_usage"`
+	CreationDate  time.Time `json:"creation_date"`
+	MemoryUsage   int64     `json:"memory_usage"`
+	Namespace     string    `json:"namespace"`
+	Podname       string    `json:"podname"`
+}
+
+type PodDetails struct {
+	Metrics  []PodContainerMetric `json:"metrics"`
+	Workload Workload             `json:"workload"`
+}
+
+type PodOwnerRessource struct {
+	APIVersion string             `json:"api_version"`
+	Kind       string             `json:"kind"`
+	Name       string             `json:"name"`
+	Owner      *PodOwnerRessource `json:"owner,omitempty"`
+	UID        string             `json:"uid"`
+}
+
+type PodWorkload struct {
+	NodeName           string              `json:"node_name"`
+	PodOwnerRessources []PodOwnerRessource `json:"pod_owner_ressources"`
+	Restarts           int64               `json:"restarts"`
+	Status             string              `json:"status"`
+	Type               string              `json:"type"`
+	WorkloadInfo       GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type QuotaResource struct {
+	Hard        string  `json:"hard"`
+	Resource    string  `json:"resource"`
+	Used        string  `json:"used"`
+	Utilization float64 `json:"utilization"`
+}
+
+type ResourceQuota struct {
+	Name      string          `json:"name"`
+	Resources []QuotaResource `json:"resources"`
+}
+
+type ResourceRequirements struct {
+	Limits   ResourceValues `json:"limits"`
+	Requests ResourceValues `json:"requests"`
+}
+
+type ResourceUsage struct {
+	Max int64 `json:"max"`
+	P95 int64 `json:"p95"`
+}
+
+type ResourceValues struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+type SearchResult struct {
+	Highlights   map[string]string `json:"highlights"`
+	Key          string            `json:"key"`
+	Kind         string            `json:"kind"`
+	Name         string            `json:"name"`
+	Namespace    string            `json:"namespace,omitempty"`
+	WorkloadType string            `json:"workload_type,omitempty"`
+}
+
+type SearchResults struct {
+	Namespaces []SearchResult `json:"namespaces"`
+	Nodes      []SearchResult `json:"nodes"`
+	Workloads  []SearchResult `json:"workloads"`
+}
+
+type Snapshot struct {
+	CreationDate time.Time `json:"creation_date"`
+	ID           int64     `json:"id"`
+	Namespaces   int64     `json:"namespaces"`
+	Nodes        int64     `json:"nodes"`
+	Workloads    int64     `json:"workloads"`
+}
+
+type StatefulSetStatus struct {
+	Available int64 `json:"available"`
+	Current   int64 `json:"current"`
+	Ready     int64 `json:"ready"`
+	Replicas  int64 `json:"replicas"`
+	Up2date   int64 `json:"up2date"`
+}
+
+type StatefulSetWorkload struct {
+	Status       StatefulSetStatus   `json:"status"`
+	Type         string              `json:"type"`
+	WorkloadInfo GeneralWorkloadInfo `json:"workload_info"`
+}
+
+type SyncStatus struct {
+	CheckedAt   time.Time          `json:"checked_at"`
+	Collections []CollectionStatus `json:"collections"`
+	Database    DatabaseStatus     `json:"database"`
+	Error       string             `json:"error,omitempty"`
+	LastSync    *time.Time         `json:"last_sync"`
+	Ready       bool               `json:"ready"`
+	Synced      bool               `json:"synced"`
+}
+
+type WatchEvent struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Object    any       `json:"object"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+}
+
+// Workload - one of the schemas selected by type, exactly one field is set
+type Workload struct {
+	CronjobWorkload     *CronjobWorkload
+	DaemonSetWorkload   *DaemonSetWorkload
+	DeploymentWorkload  *DeploymentWorkload
+	JobWorkload         *JobWorkload
+	PodWorkload         *PodWorkload
+	StatefulSetWorkload *StatefulSetWorkload
+}
+
+func (u *Workload) UnmarshalJSON(data []byte) error {
+	var discriminator struct {
+		Value string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &discriminator); err != nil {
+		return err
+	}
+
+	*u = Workload{}
+	switch discriminator.Value {
+	case "Cronjob":
+		u.CronjobWorkload = &CronjobWorkload{}
+		return json.Unmarshal(data, u.CronjobWorkload)
+	case "Daemonset":
+		u.DaemonSetWorkload = &DaemonSetWorkload{}
+		return json.Unmarshal(data, u.DaemonSetWorkload)
+	case "Deployment":
+		u.DeploymentWorkload = &DeploymentWorkload{}
+		return json.Unmarshal(data, u.DeploymentWorkload)
+	case "Job":
+		u.JobWorkload = &JobWorkload{}
+		return json.Unmarshal(data, u.JobWorkload)
+	case "Pod":
+		u.PodWorkload = &PodWorkload{}
+		return json.Unmarshal(data, u.PodWorkload)
+	case "Statefulset":
+		u.StatefulSetWorkload = &StatefulSetWorkload{}
+		return json.Unmarshal(data, u.StatefulSetWorkload)
+	}
+
+	return fmt.Errorf("unknown type %q", discriminator.Value)
+}
+
+func (u Workload) MarshalJSON() ([]byte, error) {
+	switch {
+	case u.CronjobWorkload != nil:
+		return json.Marshal(u.CronjobWorkload)
+	case u.DaemonSetWorkload != nil:
+		return json.Marshal(u.DaemonSetWorkload)
+	case u.DeploymentWorkload != nil:
+		return json.Marshal(u.DeploymentWorkload)
+	case u.JobWorkload != nil:
+		return json.Marshal(u.JobWorkload)
+	case u.PodWorkload != nil:
+		return json.Marshal(u.PodWorkload)
+	case u.StatefulSetWorkload != nil:
+		return json.Marshal(u.StatefulSetWorkload)
+	}
+
+	return []byte("null"), nil
+}
+
+type WorkloadChange struct {
+	Changes      []FieldChange `json:"changes"`
+	CreationDate time.Time     `json:"creation_date"`
+	Revision     int64         `json:"revision"`
+	Type         string        `json:"type"`
+}
+
+type WorkloadDetails struct {
+	Metrics  []PodContainerMetric `json:"metrics"`
+	Pods     []Workload           `json:"pods"`
+	Workload Workload             `json:"workload"`
+}
+
+type WorkloadRecommendation struct {
+	Classification string                    `json:"classification"`
+	Containers     []ContainerRecommendation `json:"containers"`
+	Namespace      string                    `json:"namespace"`
+	Patch          any                       `json:"patch,omitempty"`
+	WorkloadName   string                    `json:"workload_name"`
+	WorkloadType   string                    `json:"workload_type"`
+}
+
+type WorkloadRestartHistory struct {
+	CrashLoops []CrashLoop        `json:"crash_loops"`
+	Restarts   []ContainerRestart `json:"restarts"`
+	Summary    WorkloadRestarts   `json:"summary"`
+}
+
+type WorkloadRestarts struct {
+	LastRestart  time.Time        `json:"last_restart"`
+	Namespace    string           `json:"namespace"`
+	Pods         int64            `json:"pods"`
+	RatePerHour  float64          `json:"rate_per_hour"`
+	Reasons      map[string]int64 `json:"reasons"`
+	Restarts     int64            `json:"restarts"`
+	WorkloadName string           `json:"workload_name"`
+	WorkloadType string           `json:"workload_type"`
+}
+
+// GetAuditLogParams - the parameters of GetAuditLog
+type GetAuditLogParams struct {
+	// name of the principal to filter by
+	User string
+	// route pattern to filter by, e.g. /api/v1/namespaces/:name
+	Route string
+	// prefix of the request path to filter by
+	Path string
+	// http status to filter by
+	Status int
+	// only the requests denied by the authentication or authorization when true
+	Denied *bool
+	// start of the time range (RFC3339)
+	From time.Time
+	// end of the time range (RFC3339)
+	To time.Time
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetAuditLog - List the recorded api requests, newest first and 100 per page by default
+func (c *Client) GetAuditLog(ctx context.Context, params GetAuditLogParams) (*Response[[]AuditEntry], error) {
+	query := url.Values{}
+	if params.User != "" {
+		query.Set("user", params.User)
+	}
+	if params.Route != "" {
+		query.Set("route", params.Route)
+	}
+	if params.Path != "" {
+		query.Set("path", params.Path)
+	}
+	if params.Status != 0 {
+		query.Set("status", strconv.Itoa(params.Status))
+	}
+	if params.Denied != nil {
+		query.Set("denied", strconv.FormatBool(*params.Denied))
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]AuditEntry]
+	if err := c.get(ctx, "/audit", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetContainerMetricsParams - the parameters of GetContainerMetrics
+type GetContainerMetricsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// start of the time range (RFC3339), defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to at or now
+	To time.Time
+	// window the metrics are reduced to, e.g. 5m
+	Rate string
+	// aggregation used to reduce the metrics: max, min, avg, last or a percentile like p95
+	Agg string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetContainerMetrics - List the reduced container metrics
+func (c *Client) GetContainerMetrics(ctx context.Context, params GetContainerMetricsParams) (*Response[[]PodContainerMetric], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Rate != "" {
+		query.Set("rate", params.Rate)
+	}
+	if params.Agg != "" {
+		query.Set("agg", params.Agg)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]PodContainerMetric]
+	if err := c.get(ctx, "/container-metrics", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCostsParams - the parameters of GetCosts
+type GetCostsParams struct {
+	// start of the time range (RFC3339), defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// namespace to filter by
+	Namespace string
+	// namespace, workload or label:<key> to group by the value of a label of the pods, defaults to namespace
+	GroupBy string
+	// resources the costs are calculated from, max is the max of requests and usage, defaults to requests
+	Basis string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetCosts - List the costs of the pods per group sorted by the total cost, including the idle cost of the cluster unless restricted to namespaces
+func (c *Client) GetCosts(ctx context.Context, params GetCostsParams) (*Response[[]CostAllocation], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.GroupBy != "" {
+		query.Set("groupBy", params.GroupBy)
+	}
+	if params.Basis != "" {
+		query.Set("basis", params.Basis)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]CostAllocation]
+	if err := c.get(ctx, "/costs", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCronjobCalendarParams - the parameters of GetCronjobCalendar
+type GetCronjobCalendarParams struct {
+	// start of the time range (RFC3339), defaults to now
+	From time.Time
+	// end of the time range (RFC3339), defaults to 24 hours after from, at most 31 days after from
+	To time.Time
+	// namespace to filter by
+	Namespace string
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetCronjobCalendar - List the planned runs of the cronjobs sorted by time, suspended cronjobs are skipped
+func (c *Client) GetCronjobCalendar(ctx context.Context, params GetCronjobCalendarParams) (*Response[[]CronjobRun], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]CronjobRun]
+	if err := c.get(ctx, "/cronjobs/calendar", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// QueryMetricsParams - the parameters of QueryMetrics
+type QueryMetricsParams struct {
+	// start of the time range (RFC3339), defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to at or now
+	To time.Time
+	// distance of the points, e.g. 1m, at least 10s
+	Step string
+	// aggregation across the selected pods
+	Aggregation string
+	// namespace to filter by
+	Namespace string
+	// container to filter by
+	Container string
+	// pod to filter by
+	Pod string
+	// workload whose pods are selected, requires namespace and workload_type
+	Workload string
+	// type of the workload
+	WorkloadType string
+}
+
+// QueryMetrics - Query the usage as aligned series
+func (c *Client) QueryMetrics(ctx context.Context, params QueryMetricsParams) (*Response[MetricsQueryResult], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Step != "" {
+		query.Set("step", params.Step)
+	}
+	if params.Aggregation != "" {
+		query.Set("aggregation", params.Aggregation)
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.Container != "" {
+		query.Set("container", params.Container)
+	}
+	if params.Pod != "" {
+		query.Set("pod", params.Pod)
+	}
+	if params.Workload != "" {
+		query.Set("workload", params.Workload)
+	}
+	if params.WorkloadType != "" {
+		query.Set("workload_type", params.WorkloadType)
+	}
+
+	var result Response[MetricsQueryResult]
+	if err := c.get(ctx, "/metrics/query", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetNamespacesParams - the parameters of GetNamespaces
+type GetNamespacesParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetNamespaces - List the namespaces
+func (c *Client) GetNamespaces(ctx context.Context, params GetNamespacesParams) (*Response[[]Namespace], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Namespace]
+	if err := c.get(ctx, "/namespaces", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetNamespaceParams - the parameters of GetNamespace
+type GetNamespaceParams struct {
+	// name of the resource
+	Name string
+	// point in time (RFC3339) to load the state of
+	At time.Time
+}
+
+// GetNamespace - Get a namespace with its workloads and events
+func (c *Client) GetNamespace(ctx context.Context, params GetNamespaceParams) (*Response[NamespaceDetails], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+
+	var result Response[NamespaceDetails]
+	if err := c.get(ctx, "/namespaces/"+url.PathEscape(params.Name), query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetNodesParams - the parameters of GetNodes
+type GetNodesParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetNodes - List the nodes
+func (c *Client) GetNodes(ctx context.Context, params GetNodesParams) (*Response[[]Node], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Node]
+	if err := c.get(ctx, "/nodes", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetOpenAPI - Get this OpenAPI document
+func (c *Client) GetOpenAPI(ctx context.Context) (*map[string]any, error) {
+	query := url.Values{}
+
+	var result map[string]any
+	if err := c.get(ctx, "/openapi.json", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetRecommendationsParams - the parameters of GetRecommendations
+type GetRecommendationsParams struct {
+	// start of the time range (RFC3339) the usage is evaluated for, defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// namespace to filter by
+	Namespace string
+	// type of the workload
+	WorkloadType string
+	// classification of the workload to filter by
+	Classification string
+	// percent added to the usage for the recommended requests and limits, defaults to 20
+	Headroom string
+	// adds the strategic merge patch applying the recommended resources to every workload
+	Patch *bool
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetRecommendations - List the right-sizing recommendations of the containers of the deployments, statefulsets, daemonsets and pods without owner
+func (c *Client) GetRecommendations(ctx context.Context, params GetRecommendationsParams) (*Response[[]WorkloadRecommendation], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.WorkloadType != "" {
+		query.Set("workload_type", params.WorkloadType)
+	}
+	if params.Classification != "" {
+		query.Set("classification", params.Classification)
+	}
+	if params.Headroom != "" {
+		query.Set("headroom", params.Headroom)
+	}
+	if params.Patch != nil {
+		query.Set("patch", strconv.FormatBool(*params.Patch))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]WorkloadRecommendation]
+	if err := c.get(ctx, "/recommendations", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCrashLoopsParams - the parameters of GetCrashLoops
+type GetCrashLoopsParams struct {
+	// namespace to filter by
+	Namespace string
+	// start of the time range (RFC3339), defaults to 24 hours before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// longest pause between the restarts of a crash loop, defaults to 10m
+	Gap string
+	// minimum number of restarts of a crash loop, defaults to 3
+	Threshold int
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetCrashLoops - List the periods in which containers restarted repeatedly
+func (c *Client) GetCrashLoops(ctx context.Context, params GetCrashLoopsParams) (*Response[[]CrashLoop], error) {
+	query := url.Values{}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Gap != "" {
+		query.Set("gap", params.Gap)
+	}
+	if params.Threshold != 0 {
+		query.Set("threshold", strconv.Itoa(params.Threshold))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]CrashLoop]
+	if err := c.get(ctx, "/restarts/crashloops", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetTopRestartersParams - the parameters of GetTopRestarters
+type GetTopRestartersParams struct {
+	// namespace to filter by
+	Namespace string
+	// start of the time range (RFC3339), defaults to 24 hours before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetTopRestarters - List the workloads with the most container restarts, 10 per page by default
+func (c *Client) GetTopRestarters(ctx context.Context, params GetTopRestartersParams) (*Response[[]WorkloadRestarts], error) {
+	query := url.Values{}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]WorkloadRestarts]
+	if err := c.get(ctx, "/restarts/top", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SearchParams - the parameters of Search
+type SearchParams struct {
+	// terms which all need to match
+	Q string
+	// maximum number of results per kind
+	Limit int
+}
+
+// Search - Search nodes, namespaces and workloads
+func (c *Client) Search(ctx context.Context, params SearchParams) (*Response[SearchResults], error) {
+	query := url.Values{}
+	if params.Q != "" {
+		query.Set("q", params.Q)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+
+	var result Response[SearchResults]
+	if err := c.get(ctx, "/search", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetSnapshotsParams - the parameters of GetSnapshots
+type GetSnapshotsParams struct {
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetSnapshots - List the snapshots of the history
+func (c *Client) GetSnapshots(ctx context.Context, params GetSnapshotsParams) (*Response[[]Snapshot], error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Snapshot]
+	if err := c.get(ctx, "/snapshots", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetStatus - Get the state of the synchronization with kubernetes and of the database
+func (c *Client) GetStatus(ctx context.Context) (*Response[SyncStatus], error) {
+	query := url.Values{}
+
+	var result Response[SyncStatus]
+	if err := c.get(ctx, "/status", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetWorkloadsParams - the parameters of GetWorkloads
+type GetWorkloadsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetWorkloads - List the workloads
+func (c *Client) GetWorkloads(ctx context.Context, params GetWorkloadsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetCronjobsParams - the parameters of GetCronjobs
+type GetCronjobsParams struct {
+	// namespace to filter by
+	Namespace string
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetCronjobs - List the cronjobs
+func (c *Client) GetCronjobs(ctx context.Context, params GetCronjobsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/cronjobs", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetDaemonSetsParams - the parameters of GetDaemonSets
+type GetDaemonSetsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetDaemonSets - List the daemonsets
+func (c *Client) GetDaemonSets(ctx context.Context, params GetDaemonSetsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/daemonsets", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetDeploymentsParams - the parameters of GetDeployments
+type GetDeploymentsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// namespace to filter by
+	Namespace string
+	// name to filter by
+	Name string
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetDeployments - List the deployments
+func (c *Client) GetDeployments(ctx context.Context, params GetDeploymentsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.Name != "" {
+		query.Set("name", params.Name)
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/deployments", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetJobsParams - the parameters of GetJobs
+type GetJobsParams struct {
+	// namespace to filter by
+	Namespace string
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetJobs - List the jobs
+func (c *Client) GetJobs(ctx context.Context, params GetJobsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/jobs", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPodsParams - the parameters of GetPods
+type GetPodsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// namespace to filter by
+	Namespace string
+	// name to filter by
+	Name string
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetPods - List the pods
+func (c *Client) GetPods(ctx context.Context, params GetPodsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.Namespace != "" {
+		query.Set("namespace", params.Namespace)
+	}
+	if params.Name != "" {
+		query.Set("name", params.Name)
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/pods", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPodParams - the parameters of GetPod
+type GetPodParams struct {
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// start of the time range (RFC3339), defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to at or now
+	To time.Time
+	// window the metrics are reduced to, e.g. 5m
+	Rate string
+	// aggregation used to reduce the metrics: max, min, avg, last or a percentile like p95
+	Agg string
+}
+
+// GetPod - Get a pod with the metrics of its containers
+func (c *Client) GetPod(ctx context.Context, params GetPodParams) (*Response[PodDetails], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Rate != "" {
+		query.Set("rate", params.Rate)
+	}
+	if params.Agg != "" {
+		query.Set("agg", params.Agg)
+	}
+
+	var result Response[PodDetails]
+	if err := c.get(ctx, "/workloads/pods/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name), query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPodChangesParams - the parameters of GetPodChanges
+type GetPodChangesParams struct {
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetPodChanges - List the changes of a pod
+func (c *Client) GetPodChanges(ctx context.Context, params GetPodChangesParams) (*Response[[]WorkloadChange], error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]WorkloadChange]
+	if err := c.get(ctx, "/workloads/pods/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name)+"/changes", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPodRestartsParams - the parameters of GetPodRestarts
+type GetPodRestartsParams struct {
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// start of the time range (RFC3339), defaults to 24 hours before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// longest pause between the restarts of a crash loop, defaults to 10m
+	Gap string
+	// minimum number of restarts of a crash loop, defaults to 3
+	Threshold int
+}
+
+// GetPodRestarts - Get the restart history of the containers of a pod with its crash loops
+func (c *Client) GetPodRestarts(ctx context.Context, params GetPodRestartsParams) (*Response[WorkloadRestartHistory], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Gap != "" {
+		query.Set("gap", params.Gap)
+	}
+	if params.Threshold != 0 {
+		query.Set("threshold", strconv.Itoa(params.Threshold))
+	}
+
+	var result Response[WorkloadRestartHistory]
+	if err := c.get(ctx, "/workloads/pods/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name)+"/restarts", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetStatefulSetsParams - the parameters of GetStatefulSets
+type GetStatefulSetsParams struct {
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// kubernetes label selector, e.g. app in (a,b),tier!=db
+	LabelSelector string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetStatefulSets - List the statefulsets
+func (c *Client) GetStatefulSets(ctx context.Context, params GetStatefulSetsParams) (*Response[[]Workload], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if params.LabelSelector != "" {
+		query.Set("labelSelector", params.LabelSelector)
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]Workload]
+	if err := c.get(ctx, "/workloads/statefulsets", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetWorkloadParams - the parameters of GetWorkload
+type GetWorkloadParams struct {
+	// type of the workload
+	WorkloadType string
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// point in time (RFC3339) to load the state of
+	At time.Time
+	// start of the time range (RFC3339), defaults to 7 days before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to at or now
+	To time.Time
+	// window the metrics are reduced to, e.g. 5m
+	Rate string
+	// aggregation used to reduce the metrics: max, min, avg, last or a percentile like p95
+	Agg string
+}
+
+// GetWorkload - Get a workload with its pods and their container metrics
+func (c *Client) GetWorkload(ctx context.Context, params GetWorkloadParams) (*Response[WorkloadDetails], error) {
+	query := url.Values{}
+	if !params.At.IsZero() {
+		query.Set("at", params.At.Format(time.RFC3339))
+	}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Rate != "" {
+		query.Set("rate", params.Rate)
+	}
+	if params.Agg != "" {
+		query.Set("agg", params.Agg)
+	}
+
+	var result Response[WorkloadDetails]
+	if err := c.get(ctx, "/workloads/"+url.PathEscape(params.WorkloadType)+"/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name), query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetWorkloadChangesParams - the parameters of GetWorkloadChanges
+type GetWorkloadChangesParams struct {
+	// type of the workload
+	WorkloadType string
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// maximum number of items of the page, 0 returns all items
+	Limit int
+	// token of the next page returned in the metadata
+	Continue string
+	// comma separated json paths to sort by, a leading - sorts descending
+	Sort string
+	// comma separated json paths the items are reduced to, the reduced items only contain these fields
+	Fields string
+}
+
+// GetWorkloadChanges - List the changes of a workload
+func (c *Client) GetWorkloadChanges(ctx context.Context, params GetWorkloadChangesParams) (*Response[[]WorkloadChange], error) {
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Continue != "" {
+		query.Set("continue", params.Continue)
+	}
+	if params.Sort != "" {
+		query.Set("sort", params.Sort)
+	}
+	if params.Fields != "" {
+		query.Set("fields", params.Fields)
+	}
+
+	var result Response[[]WorkloadChange]
+	if err := c.get(ctx, "/workloads/"+url.PathEscape(params.WorkloadType)+"/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name)+"/changes", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetWorkloadRestartsParams - the parameters of GetWorkloadRestarts
+type GetWorkloadRestartsParams struct {
+	// type of the workload
+	WorkloadType string
+	// namespace of the resource
+	Namespace string
+	// name of the resource
+	Name string
+	// start of the time range (RFC3339), defaults to 24 hours before to
+	From time.Time
+	// end of the time range (RFC3339), defaults to now
+	To time.Time
+	// longest pause between the restarts of a crash loop, defaults to 10m
+	Gap string
+	// minimum number of restarts of a crash loop, defaults to 3
+	Threshold int
+}
+
+// GetWorkloadRestarts - Get the restart history of the containers of a workload with its rate and crash loops
+func (c *Client) GetWorkloadRestarts(ctx context.Context, params GetWorkloadRestartsParams) (*Response[WorkloadRestartHistory], error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("from", params.From.Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("to", params.To.Format(time.RFC3339))
+	}
+	if params.Gap != "" {
+		query.Set("gap", params.Gap)
+	}
+	if params.Threshold != 0 {
+		query.Set("threshold", strconv.Itoa(params.Threshold))
+	}
+
+	var result Response[WorkloadRestartHistory]
+	if err := c.get(ctx, "/workloads/"+url.PathEscape(params.WorkloadType)+"/"+url.PathEscape(params.Namespace)+"/"+url.PathEscape(params.Name)+"/restarts", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}